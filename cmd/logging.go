@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"brokolisql-go/pkg/common"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var logLevel string
+
+// logger writes progress messages to standard error, at the level given with
+// --log-level
+var logger = common.NewLogger(common.LogLevelInfo)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level (debug, info, warning, error, fatal)")
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		logger.SetLevel(common.LogLevelFromString(logLevel))
+		logger.Debug("Starting BrokoliSQL")
+	}
+}
+
+// messageWriter logs the progress messages of the conversion written to it.
+// Messages starting with common.WarningPrefix are logged as warnings.
+type messageWriter struct{}
+
+func (messageWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	if warning, ok := strings.CutPrefix(msg, common.WarningPrefix); ok {
+		logger.Warning("%s", warning)
+	} else {
+		logger.Info("%s", msg)
+	}
+	return len(p), nil
+}
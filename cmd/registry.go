@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"brokolisql-go/pkg/dialects"
+	"brokolisql-go/pkg/fetchers"
+	"brokolisql-go/pkg/loaders"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// description is the help text of the command before the registered input
+// formats, SQL dialects and source types are listed
+var description = rootCmd.Long
+
+// describeRegistered lists the registered input formats, SQL dialects and
+// source types in the help. Programs embedding the command may register
+// their own after init, so this is done when it runs, each time from
+// description.
+func describeRegistered() {
+	flags := rootCmd.PersistentFlags()
+	flags.Lookup("format").Usage = "Input file format (" + strings.Join(loaders.FormatNames(), ", ") + ") or its media type - if not specified, detected from the file extension or content"
+	flags.Lookup("dialect").Usage = "SQL dialect (" + strings.Join(dialects.Names(), ", ") + ")"
+	flags.Lookup("source-type").Usage = "Source type for fetch mode (" + strings.Join(fetchers.Names(), ", ") + ")"
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\nInput formats:")
+	for _, f := range loaders.Formats() {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", strings.Join(append([]string{f.Name}, f.Aliases...), ", "), strings.Join(f.Extensions, " "), f.Description)
+	}
+	fmt.Fprintln(w, "\nSQL dialects:")
+	for _, d := range dialects.Dialects() {
+		fmt.Fprintf(w, "  %s\t\t%s\n", strings.Join(append([]string{d.Name}, d.Aliases...), ", "), d.Description)
+	}
+	fmt.Fprintln(w, "\nSource types:")
+	for _, s := range fetchers.SourceTypes() {
+		fmt.Fprintf(w, "  %s\t\t%s\n", s.Name, s.Description)
+	}
+	w.Flush()
+	rootCmd.Long = strings.TrimRight(description, "\n") + "\n" + strings.TrimRight(b.String(), "\n")
+}
@@ -1,185 +1,368 @@
 package cmd
 
 import (
-	"brokolisql-go/internal/processing"
-	"brokolisql-go/internal/transformers"
-	"brokolisql-go/pkg/common"
-	"brokolisql-go/pkg/errors"
-	"brokolisql-go/pkg/fetchers"
 	"brokolisql-go/pkg/loaders"
+	"brokolisql-go/pkg/pipeline"
+	"brokolisql-go/pkg/sinks"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	inputFile        string
+	options          = pipeline.DefaultOptions() // Conversion options, most set by flags
 	outputFile       string
-	tableName        string
 	format           string
-	dialect          string
-	batchSize        int
-	createTable      bool
-	transformFile    string
-	normalizeColumns bool
-	fetchMode        bool
-	fetchSource      string
-	fetchType        string
+	targetDSN        string
+	commitEvery      int
+	rejectsFile      string
+	loaderConfigFile string
+	inputEncoding    string
+	csvOptions       loaders.CSVOptions
+	jsonOptions      loaders.JSONOptions
+	jsonLinesOptions loaders.JSONLinesOptions
+	xmlOptions       loaders.XMLOptions
+	excelOptions     loaders.ExcelOptions
+	parquetOptions   loaders.ParquetOptions
+	sourceColumn     string
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "brokolisql",
 	Short: "BrokoliSQL converts structured data files to SQL INSERT statements",
 	Long: `BrokoliSQL is a command-line tool designed to facilitate the conversion of 
-structured data files—such as CSV, Excel, JSON, and XML—into SQL INSERT statements.
+structured data files—such as CSV, Excel, JSON, XML, Parquet and Avro—into SQL INSERT statements.
 
 It solves common problems faced during data import, transformation, and database 
 seeding by offering a flexible, extensible, and easy-to-use interface.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		return runConversion()
+	// Execute prints errors once, without the usage, which --help shows
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		if err := resolveTarget(cmd); err != nil {
+			return err
+		}
+		if err := resolveLoaderConfig(cmd); err != nil {
+			return err
+		}
+
+		rejects, closeRejects, err := openRejects()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := closeRejects(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}()
+
+		sink, err := openSink()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := sink.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}()
+
+		options.Rejects = rejects
+		options.Messages = messageWriter{}
+		result, err := pipeline.Run(options, sink)
+		if err != nil {
+			return err
+		}
+
+		printSuccess(sink)
+		printRejects(result.Rejected)
+		return nil
 	},
 }
 
 func Execute() {
+	describeRegistered()
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
 func init() {
 	flags := rootCmd.PersistentFlags()
-	flags.StringVar(&inputFile, "input", "", "Input file path (required unless using fetch mode)")
-	flags.StringVar(&outputFile, "output", "", "Output SQL file path (required)")
-	flags.StringVar(&tableName, "table", "", "Table name for SQL statements (required)")
-	flags.StringVar(&format, "format", "", "Input file format (csv, json, xml, xlsx) - if not specified, will be inferred from file extension")
-	flags.StringVar(&dialect, "dialect", "generic", "SQL dialect (generic, postgres, mysql, sqlite, sqlserver, oracle)")
-	flags.IntVar(&batchSize, "batch-size", 100, "Number of rows per INSERT statement")
-	flags.BoolVar(&createTable, "create-table", false, "Generate CREATE TABLE statement")
-	flags.StringVar(&transformFile, "transform", "", "JSON file with transformation rules")
-	flags.BoolVar(&normalizeColumns, "normalize", true, "Normalize column names for SQL compatibility")
+	flags.StringVar(&options.Input, "input", "", "Input file path, directory or glob pattern such as 'sales_*.csv', or - for standard input; files may be compressed (.gz, .bz2, .zst) or zip or tar archives (required unless using fetch mode)")
+	flags.StringVar(&outputFile, "output", "", "Output SQL file path, - for standard output (required unless using --target)")
+	flags.StringVar(&options.Table, "table", "", "Table name for SQL statements (required unless using --all-sheets, an archive or --multi-file tables)")
+	flags.StringVar(&format, "format", "", "Input file format, or its media type - if not specified, detected from the file extension or content")
+	flags.StringVar(&options.Dialect, "dialect", options.Dialect, "SQL dialect")
+	flags.IntVar(&options.BatchSize, "batch-size", options.BatchSize, "Number of rows per INSERT statement")
+	flags.BoolVar(&options.CreateTable, "create-table", false, "Generate CREATE TABLE statement")
+	flags.StringVar(&options.TransformFile, "transform", "", "JSON file with transformation rules")
+	flags.BoolVar(&options.Normalize, "normalize", options.Normalize, "Normalize column names for SQL compatibility")
+	flags.BoolVar(&options.Stream, "stream", false, "Stream rows from input to output with bounded memory (flat data only)")
+	flags.IntVar(&options.SampleSize, "sample-size", options.SampleSize, "Number of rows used for type inference in stream mode")
+	flags.StringVar(&options.Mode, "mode", options.Mode, "Statement mode (insert, upsert, copy)")
+	flags.StringSliceVar(&options.KeyColumns, "key", nil, "Key columns used to match existing rows in upsert mode (comma separated)")
+	flags.StringVar(&targetDSN, "target", "", "Load directly into a database instead of writing a file (e.g. sqlite://data.db)")
+	flags.IntVar(&commitEvery, "commit-every", 0, "Commit the target transaction every N rows (0 loads everything in one transaction)")
+	flags.StringVar(&options.SchemaFile, "schema", "", "JSON or YAML file pinning column types, nullability and constraints")
+	flags.BoolVar(&options.SizedTypes, "sized-types", false, "Infer VARCHAR(n), DECIMAL(p,s), SMALLINT and BIGINT instead of TEXT, FLOAT and INTEGER")
+	flags.IntVar(&options.TypeHeadroom, "type-headroom", options.TypeHeadroom, "Percentage added to observed lengths and digits when inferring sized types")
+	flags.StringVar(&options.BooleanValues, "boolean-values", options.BooleanValues, "Comma separated true/false pairs inferred as booleans (e.g. true/false,y/n,1/0)")
+	flags.StringVar(&rejectsFile, "rejects", "", "CSV file receiving rows whose values do not fit their column type, instead of failing")
+	flags.IntVar(&options.CodeWidth, "code-width", options.CodeWidth, "Digits from which columns of equal-width numbers are kept as text (0 disables)")
+
+	// Input parsing flags
+	flags.StringVar(&options.MultiFile, "multi-file", options.MultiFile, "How the files of a directory or glob pattern are converted: append (into one table with the columns of all files) or tables (a table per file, named after it)")
+	flags.StringVar(&sourceColumn, "source-column", "", "Add a column with this name holding the name of the file each row was read from")
+	flags.StringVar(&loaderConfigFile, "loader-config", "", "JSON or YAML file with input parsing options; flags given on the command line take precedence")
+	flags.StringVar(&inputEncoding, "encoding", "", `Character encoding of CSV, JSON and XML input, such as utf-8, windows-1252, latin1 or utf-16le (default "auto", detected from the byte order mark and content)`)
+	flags.StringVar(&csvOptions.Delimiter, "delimiter", "", `CSV field delimiter, a single character or "tab" (default "," or from the .tsv/.psv extension)`)
+	flags.StringVar(&csvOptions.Quote, "quote", "", `CSV quote character, or "none" to disable quoting (default "\"")`)
+	flags.BoolVar(&csvOptions.LazyQuotes, "lazy-quotes", false, "Accept stray quotes inside CSV fields")
+	flags.StringVar(&csvOptions.Comment, "comment", "", "Skip CSV lines starting with this character")
+	flags.IntVar(&csvOptions.SkipRows, "skip-rows", 0, "Number of lines to skip before the CSV header")
+	flags.BoolVar(&csvOptions.NoHeader, "no-header", false, "The CSV file has no header row; columns are named column_1, column_2, ...")
+	flags.StringVar(&csvOptions.Trim, "trim", "", `Whitespace trimming for CSV files: none, headers or all (default "headers")`)
+	flags.StringVar(&csvOptions.Ragged, "ragged-rows", "", `Handling of CSV rows with missing or extra fields: error, pad (missing fields only) or skip (default "error")`)
+	flags.StringVar(&jsonOptions.RecordPath, "record-path", "", "Path to the records in a wrapped JSON document or response, as JSONPath ($.data.items) or JSON Pointer (/data/items)")
+	flags.StringSliceVar(&jsonOptions.Lift, "lift", nil, "JSON envelope fields added to every record as columns, such as meta.generated_at or generated=meta.generated_at (comma separated)")
+	flags.BoolVar(&jsonLinesOptions.SkipInvalid, "skip-invalid-lines", false, "Skip JSON Lines records that are not valid JSON objects instead of failing")
+	flags.StringVar(&xmlOptions.RowPath, "row-path", "", "XML elements read as rows, such as /orders/order or //order (default the most repeated element)")
+	flags.StringToStringVar(&xmlOptions.Namespaces, "xml-namespace", nil, "Namespace prefixes used in --row-path, such as a=http://www.w3.org/2005/Atom (comma separated)")
+	flags.StringVar(&excelOptions.Sheet, "sheet", "", "Excel sheet to read, by name or 1-based position (default the first sheet)")
+	flags.BoolVar(&excelOptions.AllSheets, "all-sheets", false, "Convert every Excel sheet into a table named after the sheet, prefixed with --table if given")
+	flags.IntVar(&excelOptions.HeaderRow, "header-row", 0, "Excel row holding the column names; rows above it are skipped (default the first row of --range)")
+	flags.StringVar(&excelOptions.Range, "range", "", "Excel cells to read, such as B3:F200, B3:F or B:F (default the whole sheet)")
+	flags.StringVar(&excelOptions.Values, "excel-values", loaders.ExcelValuesTyped, "How Excel cells are read: typed (numbers, dates and booleans) or text (as displayed)")
+	flags.StringVar(&excelOptions.Formulas, "excel-formulas", loaders.ExcelFormulaResult, "How Excel formula cells are read: value (the cached result) or formula (the formula text)")
+	flags.BoolVar(&excelOptions.FillMerged, "fill-merged", false, "Repeat the value of merged Excel cells in every row and column they cover")
+	flags.StringSliceVar(&parquetOptions.Columns, "columns", nil, "Parquet columns to read, in output order; the others are not read from the file (comma separated, default all)")
 
 	// Fetch mode flags
-	flags.BoolVar(&fetchMode, "fetch", false, "Enable fetch mode to retrieve data from remote sources")
-	flags.StringVar(&fetchSource, "source", "", "Source URL or connection string for fetch mode")
-	flags.StringVar(&fetchType, "source-type", "rest", "Source type for fetch mode (rest, etc.)")
+	flags.BoolVar(&options.Fetch, "fetch", false, "Enable fetch mode to retrieve data from remote sources")
+	flags.StringVar(&options.Source, "source", "", "Source URL or connection string for fetch mode")
+	flags.StringVar(&options.SourceType, "source-type", options.SourceType, "Source type for fetch mode")
 
-	flags.StringVarP(&inputFile, "i", "i", "", "Input file path (shorthand)")
+	flags.StringVarP(&options.Input, "i", "i", "", "Input file path (shorthand)")
 	flags.StringVarP(&outputFile, "o", "o", "", "Output SQL file path (shorthand)")
-	flags.StringVarP(&tableName, "t", "t", "", "Table name for SQL statements (shorthand)")
+	flags.StringVarP(&options.Table, "t", "t", "", "Table name for SQL statements (shorthand)")
 	flags.StringVarP(&format, "f", "f", "", "Input file format (shorthand)")
-	flags.StringVarP(&dialect, "d", "d", "generic", "SQL dialect (shorthand)")
-	flags.IntVarP(&batchSize, "b", "b", 100, "Number of rows per INSERT statement (shorthand)")
-	flags.BoolVarP(&createTable, "c", "c", false, "Generate CREATE TABLE statement (shorthand)")
-	flags.StringVarP(&transformFile, "r", "r", "", "JSON file with transformation rules (shorthand)")
-	flags.BoolVarP(&normalizeColumns, "n", "n", true, "Normalize column names for SQL compatibility (shorthand)")
-
-	// Only mark input as required if not in fetch mode
-	errors.CheckError(rootCmd.MarkFlagRequired("output"))
-	errors.CheckError(rootCmd.MarkFlagRequired("table"))
+	flags.StringVarP(&options.Dialect, "d", "d", options.Dialect, "SQL dialect (shorthand)")
+	flags.IntVarP(&options.BatchSize, "b", "b", options.BatchSize, "Number of rows per INSERT statement (shorthand)")
+	flags.BoolVarP(&options.CreateTable, "c", "c", false, "Generate CREATE TABLE statement (shorthand)")
+	flags.StringVarP(&options.TransformFile, "r", "r", "", "JSON file with transformation rules (shorthand)")
+	flags.BoolVarP(&options.Normalize, "n", "n", options.Normalize, "Normalize column names for SQL compatibility (shorthand)")
 
+	// Input is only required outside fetch mode, output unless loading into a
+	// target database, and the table name unless tables are named after their
+	// sheets; RunE and the pipeline check them. The usage of --format,
+	// --dialect and --source-type is completed by describeRegistered.
 }
 
-func runConversion() error {
-	var dataset *common.DataSet
-	var err error
-
-	// Check if we're in fetch mode or file mode
-	if fetchMode {
-		// Validate fetch mode parameters
-		if fetchSource == "" {
-			return fmt.Errorf("source URL or connection string is required when using fetch mode")
+// resolveTarget validates the output destination. When loading into a
+// database, the dialect defaults to the target's unless given explicitly.
+func resolveTarget(cmd *cobra.Command) error {
+	if targetDSN == "" {
+		if outputFile == "" {
+			return fmt.Errorf("output file is required unless loading into a database with --target")
 		}
+		return nil
+	}
 
-		// Get the appropriate fetcher
-		fetcher, err := fetchers.GetFetcher(fetchType)
-		if err != nil {
-			return fmt.Errorf("failed to get fetcher: %w", err)
-		}
+	if outputFile != "" {
+		return fmt.Errorf("--output and --target cannot be used together")
+	}
 
-		// Create options map for the fetcher
-		options := make(map[string]interface{})
-		// Add default options for REST fetcher
-		if fetchType == "rest" {
-			options["method"] = "GET"
-			options["headers"] = map[string]string{
-				"Accept": "application/json",
-			}
-		}
+	driver, err := sinks.LookupDriver(targetDSN)
+	if err != nil {
+		return err
+	}
 
-		// Fetch the data
-		fmt.Printf("Fetching data from %s using %s fetcher...\n", fetchSource, fetchType)
-		dataset, err = fetcher.Fetch(fetchSource, options)
-		if err != nil {
-			return fmt.Errorf("failed to fetch data: %w", err)
-		}
-		fmt.Printf("Successfully fetched %d rows of data\n", len(dataset.Rows))
-	} else {
-		// Traditional file loading mode
-		if inputFile == "" {
-			return fmt.Errorf("input file is required when not using fetch mode")
-		}
+	flags := cmd.Flags()
+	if !flags.Changed("dialect") && !flags.Changed("d") {
+		options.Dialect = driver.Dialect
+	}
 
-		if format == "" {
-			ext := filepath.Ext(inputFile)
-			switch ext {
-			case ".csv":
-				format = "csv"
-			case ".json":
-				format = "json"
-			case ".xml":
-				format = "xml"
-			case ".xlsx", ".xls":
-				format = "excel"
-			default:
-				return fmt.Errorf("could not determine file format from extension: %s, please specify with --format", ext)
-			}
-		}
+	// COPY ... FROM stdin is a psql client feature, not a server statement
+	if options.Mode == pipeline.ModeCopy {
+		return fmt.Errorf("copy mode cannot be used with --target")
+	}
+	return nil
+}
 
-		loader, err := loaders.GetLoader(inputFile)
-		if err != nil {
-			return fmt.Errorf("failed to get loader: %w", err)
+// resolveLoaderConfig reads --loader-config and applies the input parsing
+// flags given on the command line over it
+func resolveLoaderConfig(cmd *cobra.Command) error {
+	config := &loaders.Config{}
+	if loaderConfigFile != "" {
+		var err error
+		if config, err = loaders.LoadConfig(loaderConfigFile); err != nil {
+			return err
 		}
+	}
 
-		dataset, err = loader.Load(inputFile)
-		if err != nil {
-			return fmt.Errorf("failed to load data: %w", err)
-		}
+	flags := cmd.Flags()
+	if format != "" {
+		config.Format = format
+	}
+	if flags.Changed("encoding") {
+		config.Encoding = inputEncoding
 	}
 
-	if transformFile != "" {
-		transformEngine, err := transformers.NewTransformEngine(transformFile)
-		if err != nil {
-			return fmt.Errorf("failed to initialize transform engine: %w", err)
-		}
+	csv := &config.CSV
+	if flags.Changed("delimiter") {
+		csv.Delimiter = csvOptions.Delimiter
+	}
+	if flags.Changed("quote") {
+		csv.Quote = csvOptions.Quote
+	}
+	if flags.Changed("lazy-quotes") {
+		csv.LazyQuotes = csvOptions.LazyQuotes
+	}
+	if flags.Changed("comment") {
+		csv.Comment = csvOptions.Comment
+	}
+	if flags.Changed("skip-rows") {
+		csv.SkipRows = csvOptions.SkipRows
+	}
+	if flags.Changed("no-header") {
+		csv.NoHeader = csvOptions.NoHeader
+	}
+	if flags.Changed("trim") {
+		csv.Trim = csvOptions.Trim
+	}
+	if flags.Changed("ragged-rows") {
+		csv.Ragged = csvOptions.Ragged
+	}
+	if flags.Changed("record-path") {
+		config.JSON.RecordPath = jsonOptions.RecordPath
+	}
+	if flags.Changed("lift") {
+		config.JSON.Lift = jsonOptions.Lift
+	}
+	if flags.Changed("skip-invalid-lines") {
+		config.JSONLines.SkipInvalid = jsonLinesOptions.SkipInvalid
+	}
+	if flags.Changed("row-path") {
+		config.XML.RowPath = xmlOptions.RowPath
+	}
+	if flags.Changed("xml-namespace") {
+		config.XML.Namespaces = xmlOptions.Namespaces
+	}
 
-		if err := transformEngine.ApplyTransformations(dataset); err != nil {
-			return fmt.Errorf("failed to apply transformations: %w", err)
-		}
+	excel := &config.Excel
+	if flags.Changed("sheet") {
+		excel.Sheet = excelOptions.Sheet
+	}
+	if flags.Changed("all-sheets") {
+		excel.AllSheets = excelOptions.AllSheets
+	}
+	if flags.Changed("header-row") {
+		excel.HeaderRow = excelOptions.HeaderRow
+	}
+	if flags.Changed("range") {
+		excel.Range = excelOptions.Range
+	}
+	if flags.Changed("excel-values") {
+		excel.Values = excelOptions.Values
+	}
+	if flags.Changed("excel-formulas") {
+		excel.Formulas = excelOptions.Formulas
+	}
+	if flags.Changed("fill-merged") {
+		excel.FillMerged = excelOptions.FillMerged
+	}
+	if flags.Changed("columns") {
+		config.Parquet.Columns = parquetOptions.Columns
+	}
+	if flags.Changed("source-column") {
+		config.SourceColumn = sourceColumn
 	}
 
-	sqlGenerator, err := processing.NewSQLGenerator(processing.SQLGeneratorOptions{
-		Dialect:          dialect,
-		TableName:        tableName,
-		CreateTable:      createTable,
-		BatchSize:        batchSize,
-		NormalizeColumns: normalizeColumns,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to initialize SQL generator: %w", err)
+	options.Loader = config
+	return nil
+}
+
+// openRejects returns the --rejects report file, created once the first
+// rejected row is written. The returned function closes it.
+func openRejects() (io.Writer, func() error, error) {
+	if rejectsFile == "" {
+		return nil, func() error { return nil }, nil
 	}
+	file := &lazyFile{path: rejectsFile, what: "reject report"}
+	return file, file.Close, nil
+}
 
-	sql, err := sqlGenerator.Generate(dataset)
-	if err != nil {
-		return fmt.Errorf("failed to generate SQL: %w", err)
+// openSink opens the target database or output file. SQL is written to a
+// temporary file next to the output file, which replaces it once the
+// conversion succeeds, so that a failed conversion leaves an existing file as
+// it was.
+func openSink() (sinks.Sink, error) {
+	if targetDSN != "" {
+		return sinks.Open(targetDSN, sinks.DBOptions{CommitEvery: commitEvery})
+	}
+	if outputFile == sinks.StdoutPath {
+		return sinks.NewWriterSink(os.Stdout), nil
 	}
+	return sinks.NewFileSink(outputFile)
+}
+
+// lazyFile is a file created on the first write
+type lazyFile struct {
+	path string
+	what string // What the file holds, for errors
+	file *os.File
+}
 
-	if err := os.WriteFile(outputFile, []byte(sql), 0644); err != nil {
-		return fmt.Errorf("failed to write output file: %w", err)
+func (f *lazyFile) Write(p []byte) (int, error) {
+	if err := f.create(); err != nil {
+		return 0, err
+	}
+	return f.file.Write(p)
+}
+
+// create creates the file, if it has not been yet
+func (f *lazyFile) create() error {
+	if f.file != nil {
+		return nil
+	}
+	file, err := os.Create(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", f.what, err)
 	}
+	f.file = file
+	return nil
+}
 
-	fmt.Printf("Successfully converted %s to SQL and saved to %s\n", inputFile, outputFile)
+func (f *lazyFile) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", f.what, err)
+	}
 	return nil
 }
+
+func printRejects(rejected int) {
+	if rejected > 0 {
+		logger.Warning("Rejected %d rows whose values do not fit their column type, see %s", rejected, rejectsFile)
+	}
+}
+
+// printSuccess reports the conversion through the logger, which writes to
+// standard error and so keeps standard output to the SQL when it is written
+// there
+func printSuccess(sink sinks.Sink) {
+	if db, ok := sink.(*sinks.DBSink); ok {
+		logger.Info("Successfully loaded %d rows of %s into the target database", db.RowCount(), options.InputName())
+		return
+	}
+	if outputFile == sinks.StdoutPath {
+		logger.Info("Successfully converted %s to SQL", options.InputName())
+		return
+	}
+	logger.Info("Successfully converted %s to SQL and saved to %s", options.InputName(), outputFile)
+}
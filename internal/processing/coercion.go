@@ -0,0 +1,294 @@
+package processing
+
+import (
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// numericLiteral matches strings that are valid unquoted SQL numbers
+var numericLiteral = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+
+// coercer converts the values of each row to the Go types that dialects
+// render as literals of their column's type: int64 for integers, Numeric for
+// decimal strings, bool for booleans, dialects.Date for dates and time.Time
+// for timestamps
+type coercer struct {
+	columns     []dialects.ColumnDef
+	layouts     []string // Date layout chosen for each date column, if any
+	typeInferer *TypeInferenceEngine
+}
+
+// newCoercer prepares coercion for columns. Date columns are parsed with the
+// first date format that fits every sampled value, so that a column of
+// 03/04/2024 style dates is read consistently as either month or day first.
+func newCoercer(columns []dialects.ColumnDef, sourceColumns []string, sample []common.DataRow, typeInferer *TypeInferenceEngine) *coercer {
+	c := &coercer{
+		columns:     columns,
+		layouts:     make([]string, len(columns)),
+		typeInferer: typeInferer,
+	}
+
+	for i, col := range columns {
+		if col.Type != dialects.SQLTypeDate && col.Type != dialects.SQLTypeDateTime {
+			continue
+		}
+
+		var values []string
+		for _, row := range sample {
+			if s, ok := row[sourceColumns[i]].(string); ok && strings.TrimSpace(s) != "" {
+				values = append(values, strings.TrimSpace(s))
+			}
+		}
+		c.layouts[i] = commonLayout(typeInferer.DateFormats, values)
+	}
+
+	return c
+}
+
+// commonLayout returns the first layout that parses all values, or "" if
+// there is none
+func commonLayout(layouts []string, values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	for _, layout := range layouts {
+		fits := true
+		for _, s := range values {
+			if _, err := time.Parse(layout, s); err != nil {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			return layout
+		}
+	}
+	return ""
+}
+
+// coerce converts the values of a row, given in column order. rowNumber is
+// used in the error for a value that cannot be converted.
+func (c *coercer) coerce(values []interface{}, columnNames []string, rowNumber int) error {
+	for i, value := range values {
+		converted, msg := c.coerceValue(i, value)
+		if msg != "" {
+			return &RowValidationError{Row: rowNumber, Column: columnNames[i], Value: value, Msg: msg}
+		}
+		values[i] = converted
+	}
+	return nil
+}
+
+// coerceValue converts one value to the type of column i. It returns why the
+// value cannot be converted, or "" if it was. Blank strings in columns that
+// are not text become NULL.
+func (c *coercer) coerceValue(i int, value interface{}) (interface{}, string) {
+	col := c.columns[i]
+	if value == nil || (blankIsNull(col.Type) && isBlank(value)) {
+		return nil, ""
+	}
+
+	switch col.Type {
+	case dialects.SQLTypeInteger, dialects.SQLTypeSmallInt, dialects.SQLTypeBigInt:
+		n, ok := toInt64(value)
+		if !ok {
+			return nil, fmt.Sprintf("value %s is not an integer", describeValue(value))
+		}
+		if low, high := integerRange(col.Type); n < low || n > high {
+			return nil, fmt.Sprintf("value %s is out of range for %s", describeValue(value), col.Type)
+		}
+		return n, ""
+
+	case dialects.SQLTypeFloat, dialects.SQLTypeDecimal:
+		number, ok := toNumber(value)
+		if !ok {
+			return nil, fmt.Sprintf("value %s is not a number", describeValue(value))
+		}
+		if col.Type == dialects.SQLTypeDecimal && col.Precision > 0 {
+			if msg := checkDecimal(value, col.Precision, col.Scale); msg != "" {
+				return nil, msg
+			}
+		}
+		return number, ""
+
+	case dialects.SQLTypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, ""
+		case string:
+			if b, ok := c.typeInferer.parseBoolean(v); ok {
+				return b, ""
+			}
+		default:
+			if n, ok := toInt64(value); ok && (n == 0 || n == 1) {
+				return n == 1, ""
+			}
+		}
+		return nil, fmt.Sprintf("value %s is not a boolean", describeValue(value))
+
+	case dialects.SQLTypeDate, dialects.SQLTypeDateTime:
+		t, ok := c.toTime(i, value)
+		if !ok {
+			return nil, fmt.Sprintf("value %s is not a date", describeValue(value))
+		}
+		if col.Type == dialects.SQLTypeDate {
+			return dialects.Date(t), ""
+		}
+		return t, ""
+
+	case dialects.SQLTypeText, dialects.SQLTypeVarchar:
+		s, ok := value.(string)
+		if !ok {
+			s = fmt.Sprintf("%v", value)
+		}
+		if col.Length > 0 && utf8.RuneCountInString(s) > col.Length {
+			return nil, fmt.Sprintf("value %s is %d characters long, more than the maximum of %d", describeValue(value), utf8.RuneCountInString(s), col.Length)
+		}
+		return s, ""
+	}
+
+	return value, ""
+}
+
+// toInt64 converts whole numbers and integer strings
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), uint64(v) <= math.MaxInt64
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case float32:
+		return toInt64(float64(v))
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case json.Number:
+		return toInt64(string(v))
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// toNumber returns native numbers unchanged and numeric strings as Numeric,
+// so that their digits are written exactly as they were read
+func toNumber(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return v, true
+	case float32:
+		return v, !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0)
+	case float64:
+		return v, !math.IsNaN(v) && !math.IsInf(v, 0)
+	case json.Number:
+		return toNumber(string(v))
+	case string:
+		s := strings.TrimSpace(v)
+		if !numericLiteral.MatchString(s) {
+			return nil, false
+		}
+		return dialects.Numeric(s), true
+	default:
+		return nil, false
+	}
+}
+
+// toTime parses a date or timestamp. Timestamps with a zone offset are
+// converted to UTC.
+func (c *coercer) toTime(i int, value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC(), true
+	case string:
+		s := strings.TrimSpace(v)
+		if layout := c.layouts[i]; layout != "" {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t.UTC(), true
+			}
+		}
+		if t, isDate, _ := c.typeInferer.isDateTime(s); isDate {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// RejectReport collects rows left out of the output because a value could
+// not be converted to its column's type or broke the schema. Each rejected
+// row is written as a CSV record of table, row number, column, value and
+// reason.
+type RejectReport struct {
+	w     *csv.Writer
+	count int
+}
+
+// NewRejectReport writes a reject report to w. The header is written with
+// the first rejected row, so that nothing is written when no row is rejected.
+func NewRejectReport(w io.Writer) *RejectReport {
+	return &RejectReport{w: csv.NewWriter(w)}
+}
+
+// Add records a rejected row
+func (r *RejectReport) Add(rowErr *RowValidationError) error {
+	if r.count == 0 {
+		if err := r.w.Write([]string{"table", "row", "column", "value", "reason"}); err != nil {
+			return fmt.Errorf("failed to write reject report: %w", err)
+		}
+	}
+
+	value := ""
+	if rowErr.Value != nil {
+		value = fmt.Sprintf("%v", rowErr.Value)
+	}
+
+	r.count++
+	if err := r.w.Write([]string{rowErr.Table, strconv.Itoa(rowErr.Row), rowErr.Column, value, rowErr.Msg}); err != nil {
+		return fmt.Errorf("failed to write reject report: %w", err)
+	}
+	return nil
+}
+
+// Count returns the number of rejected rows
+func (r *RejectReport) Count() int {
+	return r.count
+}
+
+// Flush writes any buffered records
+func (r *RejectReport) Flush() error {
+	r.w.Flush()
+	if err := r.w.Error(); err != nil {
+		return fmt.Errorf("failed to write reject report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package processing
+
+import (
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCoercer_coerceValue(t *testing.T) {
+	engine := NewTypeInferenceEngine()
+	day := dialects.Date(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name    string
+		column  dialects.ColumnDef
+		value   interface{}
+		want    interface{}
+		wantMsg string
+	}{
+		{name: "Boolean token", column: dialects.ColumnDef{Type: dialects.SQLTypeBoolean}, value: "Yes", want: true},
+		{name: "Boolean from 0", column: dialects.ColumnDef{Type: dialects.SQLTypeBoolean}, value: 0, want: false},
+		{name: "Integer string", column: dialects.ColumnDef{Type: dialects.SQLTypeInteger}, value: " 42 ", want: int64(42)},
+		{name: "Whole float", column: dialects.ColumnDef{Type: dialects.SQLTypeBigInt}, value: 9e9, want: int64(9000000000)},
+		{name: "Decimal keeps its digits", column: dialects.ColumnDef{Type: dialects.SQLTypeDecimal}, value: "10.50", want: dialects.Numeric("10.50")},
+		{name: "Float in exponent notation", column: dialects.ColumnDef{Type: dialects.SQLTypeFloat}, value: "1.5e3", want: dialects.Numeric("1.5e3")},
+		{name: "Native float", column: dialects.ColumnDef{Type: dialects.SQLTypeFloat}, value: 2.5, want: 2.5},
+		{name: "Number in a text column", column: dialects.ColumnDef{Type: dialects.SQLTypeText}, value: 42, want: "42"},
+		{name: "Date", column: dialects.ColumnDef{Type: dialects.SQLTypeDate}, value: "2024-01-02", want: day},
+		{name: "Timestamp converted to UTC", column: dialects.ColumnDef{Type: dialects.SQLTypeDateTime}, value: "2024-01-02T15:04:05+02:00", want: time.Date(2024, 1, 2, 13, 4, 5, 0, time.UTC)},
+		{name: "NULL", column: dialects.ColumnDef{Type: dialects.SQLTypeInteger}, value: nil, want: nil},
+		{name: "Blank integer is NULL", column: dialects.ColumnDef{Type: dialects.SQLTypeInteger}, value: "", want: nil},
+		{name: "Blank date is NULL", column: dialects.ColumnDef{Type: dialects.SQLTypeDate}, value: "  ", want: nil},
+		{name: "Blank text is kept", column: dialects.ColumnDef{Type: dialects.SQLTypeText}, value: "", want: ""},
+
+		{name: "Text in an integer column", column: dialects.ColumnDef{Type: dialects.SQLTypeInteger}, value: "n/a", wantMsg: `value "n/a" is not an integer`},
+		{name: "Fraction in an integer column", column: dialects.ColumnDef{Type: dialects.SQLTypeInteger}, value: 2.5, wantMsg: "value 2.5 is not an integer"},
+		{name: "Integer out of range", column: dialects.ColumnDef{Type: dialects.SQLTypeInteger}, value: "3000000000", wantMsg: `value "3000000000" is out of range for INTEGER`},
+		{name: "Not a number", column: dialects.ColumnDef{Type: dialects.SQLTypeFloat}, value: "NaN", wantMsg: `value "NaN" is not a number`},
+		{name: "Decimal too wide", column: dialects.ColumnDef{Type: dialects.SQLTypeDecimal, Precision: 4, Scale: 2}, value: "123.4", wantMsg: `value "123.4" does not fit DECIMAL(4,2)`},
+		{name: "Not a boolean", column: dialects.ColumnDef{Type: dialects.SQLTypeBoolean}, value: 2, wantMsg: "value 2 is not a boolean"},
+		{name: "Not a date", column: dialects.ColumnDef{Type: dialects.SQLTypeDate}, value: "soon", wantMsg: `value "soon" is not a date`},
+		{name: "Too long", column: dialects.ColumnDef{Type: dialects.SQLTypeVarchar, Length: 2}, value: "abc", wantMsg: `value "abc" is 3 characters long, more than the maximum of 2`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCoercer([]dialects.ColumnDef{tt.column}, []string{"col"}, nil, engine)
+			got, msg := c.coerceValue(0, tt.value)
+
+			if msg != tt.wantMsg {
+				t.Fatalf("coerceValue(%#v) message = %q, want %q", tt.value, msg, tt.wantMsg)
+			}
+			if tt.wantMsg == "" && got != tt.want {
+				t.Errorf("coerceValue(%#v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLGenerator_Generate_Dates(t *testing.T) {
+	// 03/04 and 05/06 fit both month-first and day-first formats, so the
+	// column format is the first one that fits every value
+	dataset := &common.DataSet{
+		Columns: []string{"shipped", "created"},
+		Rows: []common.DataRow{
+			{"shipped": "03/04/2024", "created": "2024-01-02 15:04:05"},
+			{"shipped": "05/06/2024", "created": "2024-01-03 08:00:00"},
+		},
+	}
+
+	tests := []struct {
+		dialect  string
+		contains []string
+	}{
+		{dialect: "postgres", contains: []string{`"shipped" DATE`, `"created" TIMESTAMP`, "(DATE '2024-03-04', TIMESTAMP '2024-01-02 15:04:05')"}},
+		{dialect: "mysql", contains: []string{"(DATE '2024-05-06', TIMESTAMP '2024-01-03 08:00:00')"}},
+		{dialect: "sqlite", contains: []string{"('2024-03-04', '2024-01-02 15:04:05')"}},
+		{dialect: "oracle", contains: []string{"TO_DATE('2024-03-04', 'YYYY-MM-DD'), TO_TIMESTAMP('2024-01-02 15:04:05', 'YYYY-MM-DD HH24:MI:SS')"}},
+		{dialect: "sqlserver", contains: []string{"CONVERT(DATE, '2024-03-04', 23), CONVERT(DATETIME2, '2024-01-02T15:04:05', 126)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			generator, err := NewSQLGenerator(SQLGeneratorOptions{Dialect: tt.dialect, TableName: "orders", CreateTable: true})
+			if err != nil {
+				t.Fatalf("Failed to create generator: %v", err)
+			}
+
+			sql, err := generator.Generate(dataset)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			verifySQL(t, sql, tt.contains)
+		})
+	}
+}
+
+func TestSQLGenerator_Generate_MixedNumbers(t *testing.T) {
+	// A decimal among integers makes the column FLOAT rather than an
+	// INTEGER column the decimal is rejected from
+	dataset := &common.DataSet{Columns: []string{"id", "amount"}}
+	for i := 1; i <= 9; i++ {
+		dataset.Rows = append(dataset.Rows, common.DataRow{"id": strconv.Itoa(i), "amount": strconv.Itoa(i * 10)})
+	}
+	dataset.Rows = append(dataset.Rows, common.DataRow{"id": "10", "amount": "2.5"})
+
+	var report strings.Builder
+	rejects := NewRejectReport(&report)
+	generator, err := NewSQLGenerator(SQLGeneratorOptions{Dialect: "postgres", TableName: "t", CreateTable: true, Rejects: rejects})
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+
+	sql, err := generator.Generate(dataset)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	verifySQL(t, sql, []string{`"id" INTEGER`, `"amount" DOUBLE PRECISION`, "(9, 90)", "(10, 2.5)"})
+	if rejects.Count() != 0 {
+		t.Errorf("Generate() rejected %d rows, want none", rejects.Count())
+	}
+}
+
+func TestSQLGenerator_Generate_Rejects(t *testing.T) {
+	// Types are inferred from the first two rows; the later rows do not fit
+	rows := []common.DataRow{
+		{"id": "1", "shipped": "2024-01-02"},
+		{"id": "2", "shipped": "2024-01-03"},
+		{"id": "three", "shipped": "2024-01-04"},
+		{"id": "4", "shipped": "tomorrow"},
+		{"id": "5", "shipped": nil},
+	}
+
+	input := func() common.RowIterator {
+		return common.NewDataSetIterator(&common.DataSet{Columns: []string{"id", "shipped"}, Rows: rows})
+	}
+
+	newGenerator := func(rejects *RejectReport) *SQLGenerator {
+		generator, err := NewSQLGenerator(SQLGeneratorOptions{Dialect: "postgres", TableName: "orders", SampleSize: 2, Rejects: rejects})
+		if err != nil {
+			t.Fatalf("Failed to create generator: %v", err)
+		}
+		return generator
+	}
+
+	t.Run("Without a report", func(t *testing.T) {
+		var sb strings.Builder
+		err := newGenerator(nil).GenerateStream(input(), &sb)
+
+		var rowErr *RowValidationError
+		if !errors.As(err, &rowErr) || rowErr.Table != "orders" || rowErr.Row != 3 || rowErr.Column != "id" {
+			t.Fatalf("GenerateStream() error = %v, want a RowValidationError for table orders, row 3, column id", err)
+		}
+	})
+
+	t.Run("With a report", func(t *testing.T) {
+		var report strings.Builder
+		rejects := NewRejectReport(&report)
+
+		var sb strings.Builder
+		if err := newGenerator(rejects).GenerateStream(input(), &sb); err != nil {
+			t.Fatalf("GenerateStream() error = %v", err)
+		}
+		if err := rejects.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+
+		verifySQL(t, sb.String(), []string{"(1, DATE '2024-01-02')", "(5, NULL)"})
+		if strings.Contains(sb.String(), "three") || strings.Contains(sb.String(), "tomorrow") {
+			t.Errorf("rejected rows should not be written:\n%s", sb.String())
+		}
+
+		want := "table,row,column,value,reason\n" +
+			"orders,3,id,three,\"value \"\"three\"\" is not an integer\"\n" +
+			"orders,4,shipped,tomorrow,\"value \"\"tomorrow\"\" is not a date\"\n"
+		if report.String() != want || rejects.Count() != 2 {
+			t.Errorf("reject report (%d rows) =\n%s\nwant\n%s", rejects.Count(), report.String(), want)
+		}
+	})
+
+	t.Run("Nothing rejected", func(t *testing.T) {
+		var report strings.Builder
+		rejects := NewRejectReport(&report)
+
+		var sb strings.Builder
+		valid := common.NewDataSetIterator(&common.DataSet{Columns: []string{"id", "shipped"}, Rows: rows[:2]})
+		if err := newGenerator(rejects).GenerateStream(valid, &sb); err != nil {
+			t.Fatalf("GenerateStream() error = %v", err)
+		}
+		if err := rejects.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+
+		// Not even the header is written, so no report file is created
+		if report.Len() != 0 {
+			t.Errorf("reject report = %q, want nothing written", report.String())
+		}
+	})
+}
@@ -1,10 +1,14 @@
 package processing
 
 import (
-	"brokolisql-go/internal/dialects"
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
 	"encoding/json"
 	"fmt"
+	"io"
 	"reflect"
+	"sort"
+	"strings"
 )
 
 // JSONAnalyzer analyzes JSON data and builds a schema registry
@@ -12,6 +16,20 @@ type JSONAnalyzer struct {
 	registry     *SchemaRegistry
 	typeInferer  *TypeInferenceEngine
 	primaryKeyID int // Counter for generating primary key values
+
+	// ColumnOrder lists the root table's columns in the order they should
+	// appear, such as the document order of the input. Other columns follow
+	// in alphabetical order.
+	ColumnOrder []string
+
+	// Types holds the column types declared by sources with a schema, by the
+	// dotted path of the field from the root, such as customer.name. Declared
+	// fields become columns of their type even if they hold objects.
+	Types map[string]dialects.ColumnDef
+
+	// Messages receives warnings about nested values that are left out;
+	// nil discards them
+	Messages io.Writer
 }
 
 // NewJSONAnalyzer creates a new JSON analyzer
@@ -40,14 +58,14 @@ func (a *JSONAnalyzer) AnalyzeJSON(data []map[string]interface{}, rootTableName
 	rootTable := &TableSchema{
 		Name:        a.registry.NameGenerator.GenerateTableName(rootTableName),
 		Columns:     []ColumnSchema{},
-		PrimaryKey:  "id",
+		PrimaryKey:  surrogateKey(data),
 		ForeignKeys: make(map[string]ForeignKey),
 		Level:       0,
 	}
 
 	// Add ID column to root table
 	rootTable.Columns = append(rootTable.Columns, ColumnSchema{
-		Name:     "id",
+		Name:     rootTable.PrimaryKey,
 		Type:     dialects.SQLTypeInteger,
 		Nullable: false,
 		IsNested: false,
@@ -68,18 +86,43 @@ func (a *JSONAnalyzer) AnalyzeJSON(data []map[string]interface{}, rootTableName
 func (a *JSONAnalyzer) analyzeStructure(data []map[string]interface{}, table *TableSchema) {
 	// Track columns we've seen
 	seenColumns := make(map[string]bool)
-	seenColumns["id"] = true // ID is already added
+	seenColumns[table.PrimaryKey] = true // ID is already added
+
+	var order []string
+	if table.Level == 0 {
+		order = a.ColumnOrder
+	}
 
 	// Analyze each object
 	for _, obj := range data {
-		for key, value := range obj {
+		for _, key := range orderedKeys(obj, order) {
+			value := obj[key]
 			if seenColumns[key] {
 				continue // Skip columns we've already processed
 			}
 
+			// A missing record whose fields are declared says nothing about
+			// its structure, so wait for a row that has one
+			if value == nil && a.declaresFieldsOf(fieldPath(table.Path, key)) {
+				continue
+			}
+
 			// Mark as seen
 			seenColumns[key] = true
 
+			if def, ok := a.Types[fieldPath(table.Path, key)]; ok {
+				table.Columns = append(table.Columns, ColumnSchema{
+					Name:      key,
+					Type:      def.Type,
+					Nullable:  def.Nullable,
+					Length:    def.Length,
+					Precision: def.Precision,
+					Scale:     def.Scale,
+					Declared:  true,
+				})
+				continue
+			}
+
 			// Check if this is a nested object
 			if a.isNestedObject(value) {
 				// Create a child table for this nested object
@@ -102,6 +145,63 @@ func (a *JSONAnalyzer) analyzeStructure(data []map[string]interface{}, table *Ta
 	}
 }
 
+// fieldPath returns the dotted path of key in a table whose rows come from
+// the field at path
+func fieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// declaresFieldsOf reports whether any declared type is for a field nested
+// in the field at path
+func (a *JSONAnalyzer) declaresFieldsOf(path string) bool {
+	prefix := path + "."
+	for declared := range a.Types {
+		if strings.HasPrefix(declared, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// orderedKeys returns the keys of obj that appear in order, in that order,
+// followed by the others sorted by name, so that columns come out the same
+// on every run
+func orderedKeys(obj map[string]interface{}, order []string) []string {
+	keys := make([]string, 0, len(obj))
+	listed := make(map[string]bool, len(order))
+	for _, key := range order {
+		if _, ok := obj[key]; ok && !listed[key] {
+			keys = append(keys, key)
+		}
+		listed[key] = true
+	}
+
+	rest := make([]string, 0, len(obj)-len(keys))
+	for key := range obj {
+		if !listed[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(keys, rest...)
+}
+
+// surrogateKey returns the name of the generated primary key of a table whose
+// rows come from objects. It is id, unless the objects have an id field of
+// their own, which is kept as it is and the key is named _row_id instead.
+func surrogateKey(objects []map[string]interface{}) string {
+	for _, obj := range objects {
+		if _, ok := obj["id"]; ok {
+			return "_row_id"
+		}
+	}
+	return "id"
+}
+
 // handleNestedObject creates a child table for a nested object
 func (a *JSONAnalyzer) handleNestedObject(key string, value interface{}, parentTable *TableSchema) {
 	// Extract the nested object
@@ -110,7 +210,7 @@ func (a *JSONAnalyzer) handleNestedObject(key string, value interface{}, parentT
 		// Try to unmarshal if it's a JSON string
 		if strValue, isStr := value.(string); isStr {
 			var obj map[string]interface{}
-			if err := json.Unmarshal([]byte(strValue), &obj); err == nil {
+			if err := common.DecodeJSON([]byte(strValue), &obj); err == nil {
 				nestedObj = obj
 			} else {
 				// Not a valid nested object, treat as a regular column
@@ -145,16 +245,17 @@ func (a *JSONAnalyzer) handleNestedObject(key string, value interface{}, parentT
 	childTable := &TableSchema{
 		Name:        childTableName,
 		Columns:     []ColumnSchema{},
-		PrimaryKey:  "id",
+		PrimaryKey:  surrogateKey([]map[string]interface{}{nestedObj}),
 		ForeignKeys: make(map[string]ForeignKey),
 		ParentTable: parentTable.Name,
 		ParentField: key,
 		Level:       parentTable.Level + 1,
+		Path:        fieldPath(parentTable.Path, key),
 	}
 
 	// Add ID column to child table
 	childTable.Columns = append(childTable.Columns, ColumnSchema{
-		Name:     "id",
+		Name:     childTable.PrimaryKey,
 		Type:     dialects.SQLTypeInteger,
 		Nullable: false,
 		IsNested: false,
@@ -178,7 +279,7 @@ func (a *JSONAnalyzer) handleNestedObject(key string, value interface{}, parentT
 	parentTable.ForeignKeys[fkColumnName] = ForeignKey{
 		Column:        fkColumnName,
 		RefTable:      childTableName,
-		RefColumn:     "id",
+		RefColumn:     childTable.PrimaryKey,
 		IsNestedChild: true,
 	}
 
@@ -196,7 +297,7 @@ func (a *JSONAnalyzer) handleArray(key string, value interface{}, parentTable *T
 		arr = value.([]interface{})
 	} else if strValue, ok := value.(string); ok {
 		// Try to unmarshal if it's a JSON string
-		if err := json.Unmarshal([]byte(strValue), &arr); err != nil {
+		if err := common.DecodeJSON([]byte(strValue), &arr); err != nil {
 			// Not a valid array, treat as a regular column
 			parentTable.Columns = append(parentTable.Columns, ColumnSchema{
 				Name:     key,
@@ -258,6 +359,19 @@ func (a *JSONAnalyzer) handleArray(key string, value interface{}, parentTable *T
 
 // handleArrayOfObjects creates a child table for an array of objects
 func (a *JSONAnalyzer) handleArrayOfObjects(key string, arr []interface{}, parentTable *TableSchema) {
+	// Convert array items to maps
+	var objects []map[string]interface{}
+	for _, item := range arr {
+		if obj, ok := item.(map[string]interface{}); ok {
+			objects = append(objects, obj)
+		} else if strValue, ok := item.(string); ok {
+			var obj map[string]interface{}
+			if err := common.DecodeJSON([]byte(strValue), &obj); err == nil {
+				objects = append(objects, obj)
+			}
+		}
+	}
+
 	// Generate a name for the child table
 	childTableName := a.registry.NameGenerator.GenerateTableName(key)
 
@@ -265,16 +379,17 @@ func (a *JSONAnalyzer) handleArrayOfObjects(key string, arr []interface{}, paren
 	childTable := &TableSchema{
 		Name:        childTableName,
 		Columns:     []ColumnSchema{},
-		PrimaryKey:  "id",
+		PrimaryKey:  surrogateKey(objects),
 		ForeignKeys: make(map[string]ForeignKey),
 		ParentTable: parentTable.Name,
 		ParentField: key,
 		Level:       parentTable.Level + 1,
+		Path:        fieldPath(parentTable.Path, key),
 	}
 
 	// Add ID column to child table
 	childTable.Columns = append(childTable.Columns, ColumnSchema{
-		Name:     "id",
+		Name:     childTable.PrimaryKey,
 		Type:     dialects.SQLTypeInteger,
 		Nullable: false,
 		IsNested: false,
@@ -295,26 +410,13 @@ func (a *JSONAnalyzer) handleArrayOfObjects(key string, arr []interface{}, paren
 	childTable.ForeignKeys[parentIdColumn] = ForeignKey{
 		Column:        parentIdColumn,
 		RefTable:      parentTable.Name,
-		RefColumn:     "id",
+		RefColumn:     parentTable.PrimaryKey,
 		IsNestedChild: false,
 	}
 
 	// Add the child table to the registry
 	a.registry.AddTable(childTable)
 
-	// Convert array items to maps
-	var objects []map[string]interface{}
-	for _, item := range arr {
-		if obj, ok := item.(map[string]interface{}); ok {
-			objects = append(objects, obj)
-		} else if strValue, ok := item.(string); ok {
-			var obj map[string]interface{}
-			if err := json.Unmarshal([]byte(strValue), &obj); err == nil {
-				objects = append(objects, obj)
-			}
-		}
-	}
-
 	// Analyze the structure of the objects
 	if len(objects) > 0 {
 		a.analyzeStructure(objects, childTable)
@@ -331,7 +433,7 @@ func (a *JSONAnalyzer) isNestedObject(value interface{}) bool {
 	// Check if it's a JSON string that contains an object
 	if strValue, ok := value.(string); ok {
 		var obj map[string]interface{}
-		if err := json.Unmarshal([]byte(strValue), &obj); err == nil {
+		if err := common.DecodeJSON([]byte(strValue), &obj); err == nil {
 			return true
 		}
 	}
@@ -349,7 +451,7 @@ func (a *JSONAnalyzer) isArray(value interface{}) bool {
 	// Check if it's a JSON string that contains an array
 	if strValue, ok := value.(string); ok {
 		var arr []interface{}
-		if err := json.Unmarshal([]byte(strValue), &arr); err == nil {
+		if err := common.DecodeJSON([]byte(strValue), &arr); err == nil {
 			return true
 		}
 	}
@@ -364,6 +466,11 @@ func (a *JSONAnalyzer) inferColumnType(value interface{}) dialects.SQLType {
 		return dialects.SQLTypeInteger
 	case float32, float64:
 		return dialects.SQLTypeFloat
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return dialects.SQLTypeInteger
+		}
+		return dialects.SQLTypeFloat
 	case bool:
 		return dialects.SQLTypeBoolean
 	case string:
@@ -395,18 +502,13 @@ func (a *JSONAnalyzer) ExtractNestedData(data []map[string]interface{}) map[stri
 	}
 
 	if rootTableName == "" {
-		fmt.Printf("No root table found!\n")
 		return result
 	}
 
 	rootTable := a.registry.GetTable(rootTableName)
-	fmt.Printf("Extracting data for root table: %s\n", rootTableName)
-	fmt.Printf("Table order: %v\n", a.registry.TableOrder)
 
 	// Extract data for the root table
-	rootData := a.extractTableData(data, rootTable, nil)
-	result[rootTableName] = rootData
-	fmt.Printf("Extracted %d rows for root table %s\n", len(rootData), rootTableName)
+	result[rootTableName] = a.extractTableData(data, rootTable, nil)
 
 	// Process tables in dependency order
 	// First, build a map of child tables by parent
@@ -439,7 +541,6 @@ func (a *JSONAnalyzer) ExtractNestedData(data []map[string]interface{}) map[stri
 			// Find the parent data
 			parentData, ok := result[parentName]
 			if !ok {
-				// fmt.Printf("Parent data not found for %s (parent: %s)\n", childName, parentName)
 				continue
 			}
 
@@ -456,15 +557,12 @@ func (a *JSONAnalyzer) ExtractNestedData(data []map[string]interface{}) map[stri
 			}
 
 			if isArrayTable {
-				fmt.Printf("%s is an array table\n", childName)
 				tableData = a.extractArrayTableData(parentData, parentTable, childTable)
 			} else {
-				fmt.Printf("%s is a nested object table\n", childName)
 				tableData = a.extractChildTableData(parentData, parentTable, childTable)
 			}
 
 			result[childName] = tableData
-			fmt.Printf("Added %d rows for %s to result\n", len(tableData), childName)
 
 			// Mark as processed and add to queue
 			processedTables[childName] = true
@@ -472,12 +570,6 @@ func (a *JSONAnalyzer) ExtractNestedData(data []map[string]interface{}) map[stri
 		}
 	}
 
-	// Debug output of the final result
-	fmt.Printf("Final result contains data for %d tables:\n", len(result))
-	for tableName, tableData := range result {
-		fmt.Printf("  - %s: %d rows\n", tableName, len(tableData))
-	}
-
 	return result
 }
 
@@ -501,7 +593,7 @@ func (a *JSONAnalyzer) extractArrayTableData(parentData []map[string]interface{}
 		if reflect.TypeOf(arrayValue) != nil && reflect.TypeOf(arrayValue).Kind() == reflect.Slice {
 			arr = arrayValue.([]interface{})
 		} else if strValue, ok := arrayValue.(string); ok {
-			if err := json.Unmarshal([]byte(strValue), &arr); err != nil {
+			if err := common.DecodeJSON([]byte(strValue), &arr); err != nil {
 				continue
 			}
 		} else {
@@ -515,7 +607,7 @@ func (a *JSONAnalyzer) extractArrayTableData(parentData []map[string]interface{}
 			if obj, ok := item.(map[string]interface{}); ok {
 				objMap = obj
 			} else if strValue, ok := item.(string); ok {
-				if err := json.Unmarshal([]byte(strValue), &objMap); err != nil {
+				if err := common.DecodeJSON([]byte(strValue), &objMap); err != nil {
 					continue
 				}
 			} else {
@@ -526,15 +618,15 @@ func (a *JSONAnalyzer) extractArrayTableData(parentData []map[string]interface{}
 			row := make(map[string]interface{})
 
 			// Add ID
-			row["id"] = a.primaryKeyID
+			row[childTable.PrimaryKey] = a.primaryKeyID
 			a.primaryKeyID++
 
 			// Add parent ID
-			row[parentTable.Name+"_id"] = parentRow["id"]
+			row[parentTable.Name+"_id"] = parentRow[parentTable.PrimaryKey]
 
 			// Add regular columns
 			for _, col := range childTable.Columns {
-				if col.Name == "id" || col.Name == parentTable.Name+"_id" {
+				if col.Name == childTable.PrimaryKey || col.Name == parentTable.Name+"_id" {
 					continue // Already added
 				}
 
@@ -566,12 +658,12 @@ func (a *JSONAnalyzer) extractTableData(data []map[string]interface{}, table *Ta
 		row := make(map[string]interface{})
 
 		// Add ID
-		row["id"] = a.primaryKeyID
+		row[table.PrimaryKey] = a.primaryKeyID
 		a.primaryKeyID++
 
 		// Add regular columns
 		for _, col := range table.Columns {
-			if col.Name == "id" {
+			if col.Name == table.PrimaryKey {
 				continue // Already added
 			}
 
@@ -617,31 +709,26 @@ func (a *JSONAnalyzer) extractChildTableData(parentData []map[string]interface{}
 	}
 
 	if fkColumn == "" {
-		fmt.Printf("No foreign key found for child table %s in parent table %s\n", childTable.Name, parentTable.Name)
 		return result // No foreign key found
 	}
 
-	fmt.Printf("Extracting data for child table %s from parent table %s using field %s\n",
-		childTable.Name, parentTable.Name, childTable.ParentField)
-
 	// Process each parent row
 	for i, parentRow := range parentData {
 		// Get the nested object from the parent
 		nestedObj, ok := parentRow[childTable.ParentField]
-		if !ok {
-			fmt.Printf("Parent field %s not found in parent row %d\n", childTable.ParentField, i)
+		if !ok || nestedObj == nil {
 			continue
 		}
 
 		// Convert to map if it's a string
 		var objMap map[string]interface{}
 		if strObj, isStr := nestedObj.(string); isStr {
-			if err := json.Unmarshal([]byte(strObj), &objMap); err != nil {
-				fmt.Printf("Failed to unmarshal string to object: %v\n", err)
+			if err := common.DecodeJSON([]byte(strObj), &objMap); err != nil {
+				a.skipped(parentTable.Name, i+1, childTable.ParentField, nestedObj)
 				continue
 			}
 		} else if objMap, ok = nestedObj.(map[string]interface{}); !ok {
-			fmt.Printf("Nested object is not a map: %T\n", nestedObj)
+			a.skipped(parentTable.Name, i+1, childTable.ParentField, nestedObj)
 			continue
 		}
 
@@ -649,14 +736,14 @@ func (a *JSONAnalyzer) extractChildTableData(parentData []map[string]interface{}
 		row := make(map[string]interface{})
 
 		// Add ID
-		row["id"] = a.primaryKeyID
+		row[childTable.PrimaryKey] = a.primaryKeyID
 		a.primaryKeyID++
 
-		parentRow[childTable.ParentField+"_id"] = row["id"]
+		parentRow[fkColumn] = row[childTable.PrimaryKey]
 
 		// Add regular columns
 		for _, col := range childTable.Columns {
-			if col.Name == "id" {
+			if col.Name == childTable.PrimaryKey {
 				continue // Already added
 			}
 
@@ -668,9 +755,6 @@ func (a *JSONAnalyzer) extractChildTableData(parentData []map[string]interface{}
 			// Get the value from the nested object
 			if val, ok := objMap[col.Name]; ok {
 				row[col.Name] = val
-				fmt.Printf("Added column %s with value %v to %s\n", col.Name, val, childTable.Name)
-			} else {
-				fmt.Printf("Column %s not found in nested object for %s\n", col.Name, childTable.Name)
 			}
 		}
 
@@ -685,6 +769,13 @@ func (a *JSONAnalyzer) extractChildTableData(parentData []map[string]interface{}
 		result = append(result, row)
 	}
 
-	fmt.Printf("Extracted %d rows for %s\n", len(result), childTable.Name)
 	return result
 }
+
+// skipped warns that the value of field in a row of table is not an object,
+// so it is left out of the field's table
+func (a *JSONAnalyzer) skipped(table string, row int, field string, value interface{}) {
+	if a.Messages != nil {
+		fmt.Fprintf(a.Messages, "%sSkipped field %s of row %d of table %s: %s is not an object\n", common.WarningPrefix, field, row, table, describeValue(value))
+	}
+}
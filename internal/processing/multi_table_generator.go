@@ -1,7 +1,9 @@
 package processing
 
 import (
-	"brokolisql-go/internal/dialects"
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"fmt"
 	"strings"
 )
 
@@ -20,10 +22,19 @@ func NewMultiTableGenerator(options SQLGeneratorOptions) (*MultiTableGenerator,
 		return nil, err
 	}
 
+	if err := validateMode(&options, dialect); err != nil {
+		return nil, err
+	}
+
+	typeInferer, err := newTypeInferer(options)
+	if err != nil {
+		return nil, err
+	}
+
 	return &MultiTableGenerator{
 		options:     options,
 		normalizer:  NewNormalizer(),
-		typeInferer: NewTypeInferenceEngine(),
+		typeInferer: typeInferer,
 		dialect:     dialect,
 	}, nil
 }
@@ -31,6 +42,21 @@ func NewMultiTableGenerator(options SQLGeneratorOptions) (*MultiTableGenerator,
 // GenerateFromRegistry generates SQL for all tables in the registry
 func (g *MultiTableGenerator) GenerateFromRegistry(registry *SchemaRegistry, tableData map[string][]map[string]interface{}) (string, error) {
 	var sb strings.Builder
+	if err := g.WriteFromRegistry(registry, tableData, dialects.NewTextWriter(&sb)); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// WriteFromRegistry writes SQL for all tables in the registry to out one
+// statement at a time
+func (g *MultiTableGenerator) WriteFromRegistry(registry *SchemaRegistry, tableData map[string][]map[string]interface{}, out dialects.StatementWriter) error {
+	for _, tableName := range registry.TableOrder {
+		if table := registry.GetTable(tableName); table != nil {
+			g.inferColumnTypes(table, tableData[tableName])
+		}
+	}
 
 	// Generate CREATE TABLE statements in dependency order
 	if g.options.CreateTable {
@@ -41,13 +67,16 @@ func (g *MultiTableGenerator) GenerateFromRegistry(registry *SchemaRegistry, tab
 			}
 
 			// Generate CREATE TABLE statement
-			createTableSQL := g.generateCreateTable(table)
-			sb.WriteString(createTableSQL)
-			sb.WriteString("\n")
+			createTableSQL := g.generateCreateTable(table, tableData[tableName])
+			if err := out.WriteStatement(createTableSQL+"\n", 0); err != nil {
+				return err
+			}
 		}
 	}
 
-	// Generate INSERT statements in dependency order
+	// Generate INSERT statements in dependency order, so that rows are
+	// rejected before the rows that refer to them
+	rejected := make(map[string]map[interface{}]bool)
 	for _, tableName := range registry.TableOrder {
 		table := registry.GetTable(tableName)
 		if table == nil {
@@ -61,88 +90,237 @@ func (g *MultiTableGenerator) GenerateFromRegistry(registry *SchemaRegistry, tab
 		}
 
 		// Generate INSERT statements
-		insertSQL := g.generateInsertStatements(table, data)
-		sb.WriteString(insertSQL)
-		sb.WriteString("\n")
+		if err := g.writeInsertStatements(table, data, rejected, out); err != nil {
+			return err
+		}
+		if err := out.WriteStatement("\n", 0); err != nil {
+			return err
+		}
 	}
 
-	return sb.String(), nil
+	return nil
 }
 
-// generateCreateTable generates a CREATE TABLE statement for a table
-func (g *MultiTableGenerator) generateCreateTable(table *TableSchema) string {
-	var sb strings.Builder
+// generateCreateTable generates a CREATE TABLE statement for a table. The
+// surrogate primary key becomes an identity column that starts after the
+// highest key in data, so later inserts without a key do not collide. Upsert
+// key columns other than the primary key get a unique constraint.
+func (g *MultiTableGenerator) generateCreateTable(table *TableSchema, data []map[string]interface{}) string {
+	def := dialects.TableDef{Name: table.Name}
 
-	// Start CREATE TABLE statement
-	sb.WriteString("CREATE TABLE ")
-	sb.WriteString(g.dialect.QuoteIdentifier(table.Name))
-	sb.WriteString(" (\n")
-
-	// Add columns
-	for i, col := range table.Columns {
-		if i > 0 {
-			sb.WriteString(",\n")
+	for _, col := range table.Columns {
+		colDef := dialects.ColumnDef{
+			Name:      col.Name,
+			Type:      col.Type,
+			Nullable:  col.Nullable,
+			Length:    col.Length,
+			Precision: col.Precision,
+			Scale:     col.Scale,
 		}
 
-		sb.WriteString("  ")
-		sb.WriteString(g.dialect.QuoteIdentifier(col.Name))
-		sb.WriteString(" ")
-		sb.WriteString(string(col.Type))
-
 		if col.Name == table.PrimaryKey {
-			sb.WriteString(" PRIMARY KEY")
-		} else if !col.Nullable {
-			sb.WriteString(" NOT NULL")
+			colDef.IsPrimaryKey = true
+			colDef.Identity = true
+			def.IdentityStart = maxIntValue(data, col.Name) + 1
+		}
+
+		if fk, ok := table.ForeignKeys[col.Name]; ok {
+			colDef.IsForeignKey = true
+			colDef.References = fk.RefTable + "." + fk.RefColumn
+			// Add ON DELETE CASCADE for nested child relationships
+			colDef.OnDeleteCascade = fk.IsNestedChild
 		}
+
+		def.Columns = append(def.Columns, colDef)
 	}
 
-	// Add foreign key constraints
-	if len(table.ForeignKeys) > 0 {
-		for _, fk := range table.ForeignKeys {
-			sb.WriteString(",\n  FOREIGN KEY (")
-			sb.WriteString(g.dialect.QuoteIdentifier(fk.Column))
-			sb.WriteString(") REFERENCES ")
-			sb.WriteString(g.dialect.QuoteIdentifier(fk.RefTable))
-			sb.WriteString(" (")
-			sb.WriteString(g.dialect.QuoteIdentifier(fk.RefColumn))
-			sb.WriteString(")")
+	if g.options.Mode == ModeUpsert {
+		markKeyColumns(&def, g.upsertKeyColumns(table))
+	}
 
-			// Add ON DELETE CASCADE for nested child relationships
-			if fk.IsNestedChild {
-				sb.WriteString(" ON DELETE CASCADE")
-			}
+	return g.dialect.CreateTableDef(def)
+}
+
+// maxIntValue returns the highest integer value of a column, or 0 if there
+// is none
+func maxIntValue(data []map[string]interface{}, column string) int64 {
+	var highest int64
+	for _, row := range data {
+		var value int64
+		switch v := row[column].(type) {
+		case int:
+			value = int64(v)
+		case int64:
+			value = v
+		case float64:
+			value = int64(v)
+		default:
+			continue
+		}
+		if value > highest {
+			highest = value
 		}
 	}
+	return highest
+}
 
-	// End CREATE TABLE statement
-	sb.WriteString("\n);\n")
+// inferColumnTypes infers the types of the columns holding plain values from
+// all of their values, as the flat generator does. Keys, references, arrays
+// and columns with a declared type keep their types.
+func (g *MultiTableGenerator) inferColumnTypes(table *TableSchema, data []map[string]interface{}) {
+	var columns []string
+	for _, col := range table.Columns {
+		if g.inferable(table, col) {
+			columns = append(columns, col.Name)
+		}
+	}
+	if len(columns) == 0 {
+		return
+	}
 
-	return sb.String()
+	rows := make([]common.DataRow, len(data))
+	for i, row := range data {
+		rows[i] = row
+	}
+
+	var defs map[string]dialects.ColumnDef
+	if g.options.SizedTypes {
+		defs = g.typeInferer.InferColumnDefs(columns, rows)
+	} else {
+		defs = make(map[string]dialects.ColumnDef, len(columns))
+		for col, sqlType := range g.typeInferer.InferColumnTypes(columns, rows) {
+			defs[col] = dialects.ColumnDef{Type: sqlType}
+		}
+	}
+
+	for i, col := range table.Columns {
+		if def, ok := defs[col.Name]; ok {
+			table.Columns[i].Type = def.Type
+			table.Columns[i].Length = def.Length
+			table.Columns[i].Precision = def.Precision
+			table.Columns[i].Scale = def.Scale
+		}
+	}
 }
 
-// generateInsertStatements generates INSERT statements for a table
-func (g *MultiTableGenerator) generateInsertStatements(table *TableSchema, data []map[string]interface{}) string {
-	var sb strings.Builder
+// inferable reports whether the type of col is inferred from its values
+func (g *MultiTableGenerator) inferable(table *TableSchema, col ColumnSchema) bool {
+	if col.Declared || col.IsNested || col.IsArray || col.Name == table.PrimaryKey {
+		return false
+	}
+	_, isForeignKey := table.ForeignKeys[col.Name]
+	return !isForeignKey
+}
 
+// writeInsertStatements writes INSERT statements for a table, one statement
+// per batch. Values are converted to their column's type, except arrays,
+// which are written as they are. Rows with a value that cannot be converted,
+// or that refer to a rejected row, go to the reject report and their keys
+// into rejected; without a report they fail generation.
+func (g *MultiTableGenerator) writeInsertStatements(table *TableSchema, data []map[string]interface{}, rejected map[string]map[interface{}]bool, out dialects.StatementWriter) error {
 	// Get column names
 	var columns []string
-	for _, col := range table.Columns {
+	var converted []int
+	defs := make([]dialects.ColumnDef, len(table.Columns))
+	for i, col := range table.Columns {
 		columns = append(columns, col.Name)
+		defs[i] = dialects.ColumnDef{Name: col.Name, Type: col.Type, Length: col.Length, Precision: col.Precision, Scale: col.Scale}
+		if !col.IsArray {
+			converted = append(converted, i)
+		}
+	}
+	coercer := newCoercer(defs, columns, nil, g.typeInferer)
+
+	// Generate INSERT, upsert or COPY statements
+	batchWriter := dialects.NewBatchWriter(out, g.dialect, table.Name, columns, g.options.BatchSize)
+	switch g.options.Mode {
+	case ModeUpsert:
+		// Rows matched on other key columns keep their surrogate key, which
+		// their child rows already refer to
+		batchWriter.WithKeyColumns(g.upsertKeyColumns(table)).WithFixedColumns(table.PrimaryKey)
+	case ModeCopy:
+		// validateMode guarantees the dialect supports COPY
+		batchWriter.WithCopy()
 	}
 
-	// Prepare values
-	values := make([][]interface{}, len(data))
 	for i, row := range data {
 		rowValues := make([]interface{}, len(columns))
 		for j, col := range columns {
 			rowValues[j] = row[col]
 		}
-		values[i] = rowValues
+
+		rowErr := g.checkReferences(table, row, i+1, rejected)
+		for _, j := range converted {
+			if rowErr != nil {
+				break
+			}
+			value, msg := coercer.coerceValue(j, rowValues[j])
+			if msg != "" {
+				rowErr = &RowValidationError{Table: table.Name, Row: i + 1, Column: columns[j], Value: rowValues[j], Msg: msg}
+			}
+			rowValues[j] = value
+		}
+
+		if rowErr != nil {
+			if g.options.Rejects == nil {
+				return fmt.Errorf("table %s: %w", table.Name, rowErr)
+			}
+			if rejected[table.Name] == nil {
+				rejected[table.Name] = make(map[interface{}]bool)
+			}
+			rejected[table.Name][row[table.PrimaryKey]] = true
+			if err := g.options.Rejects.Add(rowErr); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := batchWriter.WriteRow(rowValues); err != nil {
+			return err
+		}
 	}
 
-	// Generate INSERT statements
-	insertSQL := g.dialect.InsertInto(table.Name, columns, values, g.options.BatchSize)
-	sb.WriteString(insertSQL)
+	return batchWriter.Close()
+}
+
+// checkReferences returns an error if row refers to a rejected row, which
+// the database would not find
+func (g *MultiTableGenerator) checkReferences(table *TableSchema, row map[string]interface{}, rowNumber int, rejected map[string]map[interface{}]bool) *RowValidationError {
+	for _, col := range table.Columns {
+		fk, ok := table.ForeignKeys[col.Name]
+		if !ok || row[col.Name] == nil || !rejected[fk.RefTable][row[col.Name]] {
+			continue
+		}
+		return &RowValidationError{
+			Table:  table.Name,
+			Row:    rowNumber,
+			Column: col.Name,
+			Value:  row[col.Name],
+			Msg:    fmt.Sprintf("refers to a rejected row of table %s", fk.RefTable),
+		}
+	}
+	return nil
+}
+
+// upsertKeyColumns returns the configured key columns if the table has all of
+// them, and the table's primary key otherwise
+func (g *MultiTableGenerator) upsertKeyColumns(table *TableSchema) []string {
+	if len(g.options.KeyColumns) == 0 {
+		return []string{table.PrimaryKey}
+	}
+
+	for _, key := range g.options.KeyColumns {
+		found := false
+		for _, col := range table.Columns {
+			if col.Name == key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return []string{table.PrimaryKey}
+		}
+	}
 
-	return sb.String()
+	return g.options.KeyColumns
 }
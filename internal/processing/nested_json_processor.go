@@ -2,6 +2,8 @@ package processing
 
 import (
 	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"strings"
 )
 
 // NestedJSONProcessorOptions contains options for the nested JSON processor
@@ -37,6 +39,7 @@ func NewNestedJSONProcessorWithOptions(options NestedJSONProcessorOptions) (*Nes
 	}
 
 	analyzer := NewJSONAnalyzer()
+	analyzer.Messages = options.Messages
 
 	// Configure the name generator
 	nameGen := NewNameGenerator()
@@ -54,26 +57,43 @@ func NewNestedJSONProcessorWithOptions(options NestedJSONProcessorOptions) (*Nes
 
 // ProcessNestedJSON processes JSON data with nested objects and generates SQL
 func (p *NestedJSONProcessor) ProcessNestedJSON(data []map[string]interface{}) (string, error) {
+	var sb strings.Builder
+	if err := p.ProcessNestedJSONTo(data, dialects.NewTextWriter(&sb)); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// ProcessNestedJSONTo processes JSON data with nested objects and writes the
+// SQL to out one statement at a time
+func (p *NestedJSONProcessor) ProcessNestedJSONTo(data []map[string]interface{}, out dialects.StatementWriter) error {
 	// Analyze the JSON structure
 	registry, err := p.analyzer.AnalyzeJSON(data, p.options.TableName)
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	// Extract data for all tables
 	tableData := p.analyzer.ExtractNestedData(data)
 
 	// Generate SQL for all tables
-	sql, err := p.generator.GenerateFromRegistry(registry, tableData)
-	if err != nil {
+	return p.generator.WriteFromRegistry(registry, tableData, out)
+}
+
+// ProcessDataSet processes a DataSet with nested objects and generates SQL
+func (p *NestedJSONProcessor) ProcessDataSet(dataset *common.DataSet) (string, error) {
+	var sb strings.Builder
+	if err := p.ProcessDataSetTo(dataset, dialects.NewTextWriter(&sb)); err != nil {
 		return "", err
 	}
 
-	return sql, nil
+	return sb.String(), nil
 }
 
-// ProcessDataSet processes a DataSet with nested objects and generates SQL
-func (p *NestedJSONProcessor) ProcessDataSet(dataset *common.DataSet) (string, error) {
+// ProcessDataSetTo processes a DataSet with nested objects and writes the SQL
+// to out one statement at a time
+func (p *NestedJSONProcessor) ProcessDataSetTo(dataset *common.DataSet, out dialects.StatementWriter) error {
 	// Convert DataSet to []map[string]interface{}
 	data := make([]map[string]interface{}, len(dataset.Rows))
 	for i, row := range dataset.Rows {
@@ -83,6 +103,11 @@ func (p *NestedJSONProcessor) ProcessDataSet(dataset *common.DataSet) (string, e
 		}
 	}
 
+	// Keep the root columns in the order the loader found them, with the
+	// types its source declares
+	p.analyzer.ColumnOrder = dataset.Columns
+	p.analyzer.Types = dataset.Types
+
 	// Process the data
-	return p.ProcessNestedJSON(data)
+	return p.ProcessNestedJSONTo(data, out)
 }
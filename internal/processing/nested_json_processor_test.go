@@ -1,7 +1,15 @@
 package processing
 
 import (
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"brokolisql-go/pkg/loaders"
+	"brokolisql-go/pkg/sinks"
+	sqlpkg "database/sql"
 	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -116,6 +124,400 @@ func TestNestedJSONProcessor_ProcessArrays(t *testing.T) {
 	}
 }
 
+func TestNestedJSONProcessor_ColumnOrder(t *testing.T) {
+	// Repeated XML elements arrive as JSON arrays, with the columns in
+	// document order
+	dataset := &common.DataSet{
+		Columns: []string{"status", "customer", "line"},
+		Rows: []common.DataRow{
+			{"status": "paid", "customer": "John", "line": `[{"sku":"A1","qty":"2"}]`},
+		},
+	}
+
+	processor, err := NewNestedJSONProcessor(SQLGeneratorOptions{
+		Dialect:     "generic",
+		TableName:   "orders",
+		CreateTable: true,
+		BatchSize:   100,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create processor: %v", err)
+	}
+
+	sql, err := processor.ProcessDataSet(dataset)
+	if err != nil {
+		t.Fatalf("Failed to process data set: %v", err)
+	}
+
+	verifySQL(t, sql, []string{
+		`INSERT INTO "orders" ("id", "status", "customer")`,
+		`INSERT INTO "lines" ("id", "orders_id", "qty", "sku")`,
+	})
+}
+
+func TestNestedJSONProcessor_XMLLinks(t *testing.T) {
+	// The order lines sit in a <lines> wrapper, and the shipping address is
+	// a record of its own
+	orders := `<?xml version="1.0" encoding="UTF-8"?>
+<export>
+  <orders>
+    <order id="1">
+      <customer>John</customer>
+      <shipping carrier="ups"><city>Porto</city></shipping>
+      <lines>
+        <line sku="A1"><qty>2</qty></line>
+        <line sku="B2"><qty>1</qty></line>
+      </lines>
+    </order>
+    <order id="2">
+      <customer>Jane</customer>
+      <shipping carrier="dhl"><city>Lisbon</city></shipping>
+      <lines>
+        <line sku="C3"><qty>5</qty></line>
+      </lines>
+    </order>
+  </orders>
+</export>`
+	input := filepath.Join(t.TempDir(), "orders.xml")
+	if err := os.WriteFile(input, []byte(orders), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	dataset, err := (&loaders.XMLLoader{Options: loaders.XMLOptions{RowPath: "/export/orders/order"}}).Load(input)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	processor, err := NewNestedJSONProcessor(SQLGeneratorOptions{Dialect: "sqlite", TableName: "orders", CreateTable: true, BatchSize: 100})
+	if err != nil {
+		t.Fatalf("Failed to create processor: %v", err)
+	}
+	target := filepath.Join(t.TempDir(), "target.db")
+	sink, err := sinks.Open("sqlite://"+target, sinks.DBOptions{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer sink.Close()
+	if err := processor.ProcessDataSetTo(dataset, sink); err != nil {
+		t.Fatalf("ProcessDataSetTo() error = %v", err)
+	}
+	if err := sink.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	db, err := sqlpkg.Open("sqlite", target)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT o."customer", s."city", GROUP_CONCAT(l."sku", ' ')
+		FROM "orders" o
+		JOIN "shippings" s ON s."id" = o."shipping_id"
+		JOIN "lines" l ON l."orders_id" = o."id"
+		GROUP BY o."id" ORDER BY o."id"`)
+	if err != nil {
+		t.Fatalf("Query error = %v", err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var customer, city, skus string
+		if err := rows.Scan(&customer, &city, &skus); err != nil {
+			t.Fatalf("Scan error = %v", err)
+		}
+		got = append(got, customer+" "+city+" "+skus)
+	}
+	want := []string{"John Porto A1 B2", "Jane Lisbon C3"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Orders joined to their shipping and lines = %q, want %q", got, want)
+	}
+}
+
+func TestNestedJSONProcessor_SourceKeys(t *testing.T) {
+	// Records with an id of their own keep it, and the generated key that
+	// related tables refer to gets a column of its own
+	input := filepath.Join(t.TempDir(), "orders.json")
+	if err := os.WriteFile(input, []byte(`[{"id":100,"items":[{"q":1}]},{"id":200,"items":[{"q":2},{"q":3}]}]`), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	dataset, err := (&loaders.JSONLoader{}).Load(input)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	generator, err := NewSQLGenerator(SQLGeneratorOptions{Dialect: "generic", TableName: "orders", CreateTable: true, BatchSize: 100})
+	if err != nil {
+		t.Fatalf("NewSQLGenerator() error = %v", err)
+	}
+	sql, err := generator.Generate(dataset)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	verifySQL(t, sql, []string{
+		`"_row_id" INTEGER PRIMARY KEY`,
+		`REFERENCES "orders" ("_row_id")`,
+		`INSERT INTO "orders" ("_row_id", "id") VALUES`,
+		"(1, 100),\n(2, 200);",
+	})
+
+	orders := `<orders>
+  <order id="100"><line sku="A1"/><line sku="B2"/></order>
+  <order id="200"><line sku="C3"/></order>
+</orders>`
+	input = filepath.Join(t.TempDir(), "orders.xml")
+	if err := os.WriteFile(input, []byte(orders), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	dataset, err = (&loaders.XMLLoader{Options: loaders.XMLOptions{RowPath: "/orders/order"}}).Load(input)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	processor, err := NewNestedJSONProcessor(SQLGeneratorOptions{Dialect: "sqlite", TableName: "orders", CreateTable: true, BatchSize: 100})
+	if err != nil {
+		t.Fatalf("Failed to create processor: %v", err)
+	}
+	target := filepath.Join(t.TempDir(), "target.db")
+	sink, err := sinks.Open("sqlite://"+target, sinks.DBOptions{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer sink.Close()
+	if err := processor.ProcessDataSetTo(dataset, sink); err != nil {
+		t.Fatalf("ProcessDataSetTo() error = %v", err)
+	}
+	if err := sink.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	db, err := sqlpkg.Open("sqlite", target)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT o."id", GROUP_CONCAT(l."sku", ' ')
+		FROM "orders" o JOIN "lines" l ON l."orders_id" = o."_row_id"
+		GROUP BY o."id" ORDER BY o."id"`)
+	if err != nil {
+		t.Fatalf("Query error = %v", err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var id, skus string
+		if err := rows.Scan(&id, &skus); err != nil {
+			t.Fatalf("Scan error = %v", err)
+		}
+		got = append(got, id+" "+skus)
+	}
+	want := []string{"100 A1 B2", "200 C3"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Orders joined to their lines = %q, want %q", got, want)
+	}
+}
+
+func TestNestedJSONProcessor_DeclaredTypes(t *testing.T) {
+	// Sources with a schema, such as Avro files, declare the types of nested
+	// fields by their path
+	dataset := &common.DataSet{
+		Columns: []string{"order_no", "customer", "lines", "note"},
+		Rows: []common.DataRow{
+			{"order_no": int64(1), "customer": nil, "lines": `[{"sku":"A1","price":"2.50"}]`, "note": `{"text":"gift"}`},
+			{"order_no": int64(2), "customer": `{"name":"Ann","since":"2020-01-01T00:00:00Z"}`, "lines": `[]`, "note": nil},
+		},
+		Types: map[string]dialects.ColumnDef{
+			"order_no":       {Name: "order_no", Type: dialects.SQLTypeBigInt},
+			"customer.name":  {Name: "name", Type: dialects.SQLTypeText},
+			"customer.since": {Name: "since", Type: dialects.SQLTypeDate, Nullable: true},
+			"lines.sku":      {Name: "sku", Type: dialects.SQLTypeText},
+			"lines.price":    {Name: "price", Type: dialects.SQLTypeDecimal, Precision: 9, Scale: 2},
+			"note":           {Name: "note", Type: dialects.SQLTypeText, Nullable: true},
+		},
+	}
+
+	processor, err := NewNestedJSONProcessor(SQLGeneratorOptions{
+		Dialect:     "postgres",
+		TableName:   "orders",
+		CreateTable: true,
+		BatchSize:   100,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create processor: %v", err)
+	}
+
+	sql, err := processor.ProcessDataSet(dataset)
+	if err != nil {
+		t.Fatalf("Failed to process data set: %v", err)
+	}
+
+	verifySQL(t, sql, []string{
+		`"name" TEXT NOT NULL`,
+		`"since" DATE`,
+		`DATE '2020-01-01'`,
+		`"price" NUMERIC(9,2) NOT NULL`,
+		`2.50, 'A1'`,
+		`"order_no" BIGINT NOT NULL`,
+		`"note" TEXT`,
+		`'{"text":"gift"}'`,
+	})
+}
+
+func TestNestedJSONProcessor_InferredTypes(t *testing.T) {
+	data := []map[string]interface{}{
+		{
+			"shipped": "2024-01-02",
+			"paid":    "Y",
+			"account": "40012345",
+			"customer": map[string]interface{}{
+				"name":  "Ann",
+				"since": "2020-03-04",
+			},
+		},
+		{
+			"shipped": "2024-01-03",
+			"paid":    "N",
+			"account": "40012346",
+			"customer": map[string]interface{}{
+				"name":  "Bob",
+				"since": "2021-05-06",
+			},
+		},
+	}
+	booleans, err := ParseBooleanTokens("Y/N")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	processor, err := NewNestedJSONProcessor(SQLGeneratorOptions{
+		Dialect:      "postgres",
+		TableName:    "orders",
+		CreateTable:  true,
+		BatchSize:    100,
+		SizedTypes:   true,
+		TypeHeadroom: 50,
+		Booleans:     &booleans,
+		CodeWidth:    -1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create processor: %v", err)
+	}
+
+	sql, err := processor.ProcessNestedJSON(data)
+	if err != nil {
+		t.Fatalf("Failed to process nested JSON: %v", err)
+	}
+
+	// Types come from the configured engine, and values are written as
+	// literals of their type
+	verifySQL(t, sql, []string{
+		`"name" VARCHAR(5)`,
+		`"since" DATE`,
+		`"shipped" DATE`,
+		`"paid" BOOLEAN`,
+		`"account" INTEGER`,
+		`DATE '2020-03-04'`,
+		`(1, 40012345, `,
+		`TRUE, DATE '2024-01-02')`,
+	})
+}
+
+func TestNestedJSONProcessor_Rejects(t *testing.T) {
+	// quantity is declared an integer, so the third order does not fit and
+	// its line refers to a row that is not written
+	data := &common.DataSet{
+		Columns: []string{"quantity", "lines"},
+		Rows: []common.DataRow{
+			{"quantity": "1", "lines": `[{"sku":"A1"}]`},
+			{"quantity": "2", "lines": `[{"sku":"B2"}]`},
+			{"quantity": "2.5", "lines": `[{"sku":"C3"}]`},
+			{"quantity": "4"},
+			{"quantity": "5"},
+		},
+		Types: map[string]dialects.ColumnDef{
+			"quantity": {Name: "quantity", Type: dialects.SQLTypeInteger},
+		},
+	}
+
+	newProcessor := func(rejects *RejectReport) *NestedJSONProcessor {
+		processor, err := NewNestedJSONProcessor(SQLGeneratorOptions{
+			Dialect:   "postgres",
+			TableName: "orders",
+			BatchSize: 100,
+			Rejects:   rejects,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create processor: %v", err)
+		}
+		return processor
+	}
+
+	t.Run("Without a report", func(t *testing.T) {
+		_, err := newProcessor(nil).ProcessDataSet(data)
+
+		var rowErr *RowValidationError
+		if !errors.As(err, &rowErr) || rowErr.Table != "orders" || rowErr.Row != 3 || rowErr.Column != "quantity" {
+			t.Fatalf("ProcessDataSet() error = %v, want a RowValidationError for table orders, row 3, column quantity", err)
+		}
+	})
+
+	t.Run("With a report", func(t *testing.T) {
+		var report strings.Builder
+		rejects := NewRejectReport(&report)
+
+		sql, err := newProcessor(rejects).ProcessDataSet(data)
+		if err != nil {
+			t.Fatalf("ProcessDataSet() error = %v", err)
+		}
+		if err := rejects.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+
+		if strings.Contains(sql, "2.5") || strings.Contains(sql, "C3") {
+			t.Errorf("rejected rows should not be written:\n%s", sql)
+		}
+		verifySQL(t, sql, []string{"'A1'", "'B2'", "(5, 5)"})
+
+		want := "table,row,column,value,reason\n" +
+			"orders,3,quantity,2.5,\"value \"\"2.5\"\" is not an integer\"\n" +
+			"lines,3,orders_id,3,refers to a rejected row of table orders\n"
+		if report.String() != want || rejects.Count() != 2 {
+			t.Errorf("reject report (%d rows) =\n%s\nwant\n%s", rejects.Count(), report.String(), want)
+		}
+	})
+}
+
+func TestNestedJSONProcessor_Messages(t *testing.T) {
+	data := []map[string]interface{}{
+		{"name": "Alice", "address": map[string]interface{}{"city": "Maputo"}},
+		{"name": "Bob", "address": 5},
+		{"name": "Carol", "address": nil},
+	}
+
+	var messages strings.Builder
+	processor, err := NewNestedJSONProcessor(SQLGeneratorOptions{
+		Dialect:   "postgres",
+		TableName: "users",
+		BatchSize: 100,
+		Messages:  &messages,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create processor: %v", err)
+	}
+
+	if _, err := processor.ProcessNestedJSON(data); err != nil {
+		t.Fatalf("Failed to process nested JSON: %v", err)
+	}
+
+	// Only values that are left out are reported, and missing objects are not
+	want := common.WarningPrefix + "Skipped field address of row 2 of table users: 5 is not an object\n"
+	if messages.String() != want {
+		t.Errorf("messages = %q, want %q", messages.String(), want)
+	}
+}
+
 func TestNestedJSONProcessor_DeepNesting(t *testing.T) {
 	// Test case with deep nesting
 	jsonData := `{
@@ -252,3 +654,208 @@ func verifyTableOrder(t *testing.T, sql string, expectedOrder []string) {
 		lastPos = createTablePos
 	}
 }
+
+func TestNestedJSONProcessor_Upsert(t *testing.T) {
+	data := []map[string]interface{}{
+		{
+			"name":    "Alice",
+			"address": map[string]interface{}{"city": "Maputo"},
+		},
+	}
+
+	processor, err := NewNestedJSONProcessor(SQLGeneratorOptions{
+		Dialect:   "postgres",
+		TableName: "users",
+		BatchSize: 100,
+		Mode:      ModeUpsert,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create processor: %v", err)
+	}
+
+	sql, err := processor.ProcessNestedJSON(data)
+	if err != nil {
+		t.Fatalf("Failed to process nested JSON: %v", err)
+	}
+
+	// Every table is upserted on its primary key
+	if count := strings.Count(sql, "ON CONFLICT (\"id\") DO UPDATE SET"); count != 2 {
+		t.Errorf("Expected 2 upsert statements, got %d in:\n%s", count, sql)
+	}
+
+	// Key columns other than the surrogate key must be unique for the
+	// database to find conflicts on them
+	processor, err = NewNestedJSONProcessor(SQLGeneratorOptions{
+		Dialect:     "postgres",
+		TableName:   "users",
+		BatchSize:   100,
+		CreateTable: true,
+		Mode:        ModeUpsert,
+		KeyColumns:  []string{"name"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create processor: %v", err)
+	}
+
+	sql, err = processor.ProcessNestedJSON(data)
+	if err != nil {
+		t.Fatalf("Failed to process nested JSON: %v", err)
+	}
+
+	verifySQL(t, sql, []string{
+		"\"name\" TEXT UNIQUE",
+		"ON CONFLICT (\"name\") DO UPDATE SET",
+	})
+}
+
+func TestNestedJSONProcessor_UpsertNaturalKey(t *testing.T) {
+	data := []map[string]interface{}{
+		{
+			"email": "alice@example.com",
+			"name":  "Alice",
+			"orders": []interface{}{
+				map[string]interface{}{"item": "Book"},
+			},
+		},
+	}
+
+	processor, err := NewNestedJSONProcessor(SQLGeneratorOptions{
+		Dialect:     "postgres",
+		TableName:   "users",
+		BatchSize:   100,
+		CreateTable: true,
+		Mode:        ModeUpsert,
+		KeyColumns:  []string{"email"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create processor: %v", err)
+	}
+
+	sql, err := processor.ProcessNestedJSON(data)
+	if err != nil {
+		t.Fatalf("Failed to process nested JSON: %v", err)
+	}
+
+	verifySQL(t, sql, []string{
+		"\"email\" TEXT UNIQUE",
+		"ON CONFLICT (\"email\") DO UPDATE SET\n  \"name\" = EXCLUDED.\"name\";",
+		// The child table has no email column, so it is upserted on its
+		// surrogate key
+		"ON CONFLICT (\"id\") DO UPDATE SET",
+	})
+
+	// Updating the surrogate key of a matched row would orphan its children
+	if strings.Contains(sql, "\"id\" = EXCLUDED.\"id\"") {
+		t.Errorf("Upsert on a natural key updates the surrogate key:\n%s", sql)
+	}
+}
+
+func TestNestedJSONProcessor_Copy(t *testing.T) {
+	data := []map[string]interface{}{
+		{
+			"name": "Alice",
+			"orders": []interface{}{
+				map[string]interface{}{"item": "Book"},
+				map[string]interface{}{"item": "Pen"},
+			},
+		},
+	}
+
+	processor, err := NewNestedJSONProcessor(SQLGeneratorOptions{
+		Dialect:   "postgres",
+		TableName: "users",
+		BatchSize: 100,
+		Mode:      ModeCopy,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create processor: %v", err)
+	}
+
+	sql, err := processor.ProcessNestedJSON(data)
+	if err != nil {
+		t.Fatalf("Failed to process nested JSON: %v", err)
+	}
+
+	if strings.Contains(sql, "INSERT INTO") {
+		t.Errorf("COPY mode should not generate INSERT statements:\n%s", sql)
+	}
+
+	// The parent table must be loaded before the child table referencing it
+	usersPos := strings.Index(sql, "COPY \"users\"")
+	ordersPos := strings.Index(sql, "COPY \"orders\"")
+	if usersPos == -1 || ordersPos == -1 || usersPos > ordersPos {
+		t.Errorf("Expected COPY blocks for users before orders, got:\n%s", sql)
+	}
+
+	if count := strings.Count(sql, "\\.\n"); count != 2 {
+		t.Errorf("Expected 2 terminated COPY blocks, got %d", count)
+	}
+
+	if _, err := NewNestedJSONProcessor(SQLGeneratorOptions{Dialect: "mysql", Mode: ModeCopy}); err == nil {
+		t.Errorf("Expected error for COPY mode with a dialect that does not support it")
+	}
+}
+
+func TestNestedJSONProcessor_DialectDDL(t *testing.T) {
+	data := []map[string]interface{}{
+		{
+			"name":    "Alice",
+			"score":   9.5,
+			"address": map[string]interface{}{"city": "Maputo"},
+		},
+	}
+
+	tests := []struct {
+		dialect  string
+		contains []string
+	}{
+		{
+			dialect: "sqlserver",
+			contains: []string{
+				"CREATE TABLE [addresses]",
+				"[id] INT PRIMARY KEY",
+				"[city] NVARCHAR(MAX)",
+				"FOREIGN KEY ([address_id]) REFERENCES [addresses] ([id]) ON DELETE CASCADE",
+			},
+		},
+		{
+			dialect: "oracle",
+			contains: []string{
+				"CREATE TABLE \"ADDRESSES\"",
+				"\"ID\" NUMBER(10) GENERATED BY DEFAULT ON NULL AS IDENTITY (START WITH 3) PRIMARY KEY",
+				"\"CITY\" CLOB",
+				"\"SCORE\" NUMBER",
+				"FOREIGN KEY (\"ADDRESS_ID\") REFERENCES \"ADDRESSES\" (\"ID\") ON DELETE CASCADE",
+			},
+		},
+		{
+			dialect: "mysql",
+			contains: []string{
+				"`id` INT AUTO_INCREMENT PRIMARY KEY",
+				"`score` DOUBLE",
+				") ENGINE=InnoDB",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			processor, err := NewNestedJSONProcessor(SQLGeneratorOptions{
+				Dialect:     tt.dialect,
+				TableName:   "users",
+				CreateTable: true,
+				BatchSize:   100,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create processor: %v", err)
+			}
+
+			sql, err := processor.ProcessNestedJSON(data)
+			if err != nil {
+				t.Fatalf("Failed to process nested JSON: %v", err)
+			}
+
+			verifySQL(t, sql, tt.contains)
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"regexp"
 	"strings"
 	"unicode"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 type Normalizer struct {
@@ -83,3 +85,30 @@ func (n *Normalizer) NormalizeColumnNames(names []string) []string {
 
 	return normalized
 }
+
+var tableNameSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// TableNameFrom derives a table name from a free-form name, such as a sheet
+// or file name: lower case without accents, with every run of other
+// characters replaced by an underscore. "Q1 Sales (EUR)" becomes
+// "q1_sales_eur" and "Übersicht" becomes "ubersicht".
+func TableNameFrom(name string) string {
+	// Decomposing splits accented letters into the letter and its marks
+	name = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, norm.NFD.String(name))
+
+	name = tableNameSeparators.ReplaceAllString(strings.ToLower(name), "_")
+	name = strings.Trim(name, "_")
+
+	if name == "" {
+		return "data"
+	}
+	if !unicode.IsLetter(rune(name[0])) {
+		name = "_" + name
+	}
+	return name
+}
@@ -210,3 +210,25 @@ func TestNormalizer_NormalizeColumnNames_DuplicateHandling(t *testing.T) {
 		t.Errorf("NormalizeColumnNames() returned %d names, want %d", len(normalized), len(columnNames))
 	}
 }
+
+func TestTableNameFrom(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Sales", want: "sales"},
+		{name: "Q1 Sales (EUR)", want: "q1_sales_eur"},
+		{name: "  Balance-Sheet 2024 ", want: "balance_sheet_2024"},
+		{name: "2024", want: "_2024"},
+		{name: "Übersicht", want: "ubersicht"},
+		{name: "***", want: "data"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TableNameFrom(tt.name); got != tt.want {
+				t.Errorf("TableNameFrom(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
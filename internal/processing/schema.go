@@ -1,7 +1,7 @@
 package processing
 
 import (
-	"brokolisql-go/internal/dialects"
+	"brokolisql-go/pkg/dialects"
 	"fmt"
 	"strings"
 
@@ -17,6 +17,7 @@ type TableSchema struct {
 	ParentTable string                // Name of the parent table (if this is a nested object)
 	ParentField string                // Name of the field in the parent table that references this table
 	Level       int                   // Nesting level (0 for root tables)
+	Path        string                // Dotted path of the field holding the rows, such as customer.address (empty for the root table)
 }
 
 // ColumnSchema represents a column in a table
@@ -26,6 +27,13 @@ type ColumnSchema struct {
 	Nullable bool             // Whether the column can be NULL
 	IsNested bool             // Whether this column represents a nested object
 	IsArray  bool             // Whether this column represents an array
+	// Length sizes VARCHAR columns, Precision and Scale DECIMAL columns
+	Length    int
+	Precision int
+	Scale     int
+	// Declared marks a type that comes from the source's schema rather than
+	// from the values, which are converted to it
+	Declared bool
 }
 
 // ForeignKey represents a foreign key relationship
@@ -0,0 +1,527 @@
+package processing
+
+import (
+	"brokolisql-go/internal/transformers"
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaOverride pins the definition of some or all columns of the output
+// table. Columns it does not mention, and fields a column leaves unset, are
+// inferred from the data.
+type SchemaOverride struct {
+	Columns []*ColumnOverride `json:"columns" yaml:"columns"`
+}
+
+// ColumnOverride pins one column. Name may be the source or the normalized
+// column name. Type accepts integer, smallint, bigint, float, decimal, text,
+// varchar, date, datetime and boolean, optionally sized as in varchar(12) or
+// decimal(10,2). Check is an expression in the expression language that every
+// row is validated against; it is also written as a CHECK constraint in the
+// dialect, so the database keeps enforcing it.
+type ColumnOverride struct {
+	Name       string      `json:"name" yaml:"name"`
+	Type       string      `json:"type,omitempty" yaml:"type,omitempty"`
+	Length     int         `json:"length,omitempty" yaml:"length,omitempty"`
+	Precision  int         `json:"precision,omitempty" yaml:"precision,omitempty"`
+	Scale      int         `json:"scale,omitempty" yaml:"scale,omitempty"`
+	Nullable   *bool       `json:"nullable,omitempty" yaml:"nullable,omitempty"`
+	Default    interface{} `json:"default,omitempty" yaml:"default,omitempty"`
+	PrimaryKey bool        `json:"primary_key,omitempty" yaml:"primary_key,omitempty"`
+	Unique     bool        `json:"unique,omitempty" yaml:"unique,omitempty"`
+	Check      string      `json:"check,omitempty" yaml:"check,omitempty"`
+
+	sqlType dialects.SQLType
+	check   *transformers.Expression
+}
+
+// RowValidationError reports a value that does not match the pinned schema
+// or cannot be converted to its column's type
+type RowValidationError struct {
+	Table  string // Output table, set once the row's table is known
+	Row    int    // 1-based data row, not counting the header
+	Column string
+	Value  interface{}
+	Msg    string
+}
+
+func (e *RowValidationError) Error() string {
+	return fmt.Sprintf("row %d, column %q: %s", e.Row, e.Column, e.Msg)
+}
+
+var schemaTypeNames = map[string]dialects.SQLType{
+	"integer":   dialects.SQLTypeInteger,
+	"int":       dialects.SQLTypeInteger,
+	"smallint":  dialects.SQLTypeSmallInt,
+	"bigint":    dialects.SQLTypeBigInt,
+	"float":     dialects.SQLTypeFloat,
+	"double":    dialects.SQLTypeFloat,
+	"real":      dialects.SQLTypeFloat,
+	"decimal":   dialects.SQLTypeDecimal,
+	"numeric":   dialects.SQLTypeDecimal,
+	"text":      dialects.SQLTypeText,
+	"string":    dialects.SQLTypeText,
+	"varchar":   dialects.SQLTypeVarchar,
+	"date":      dialects.SQLTypeDate,
+	"datetime":  dialects.SQLTypeDateTime,
+	"timestamp": dialects.SQLTypeDateTime,
+	"boolean":   dialects.SQLTypeBoolean,
+	"bool":      dialects.SQLTypeBoolean,
+}
+
+// sizedTypePattern matches type names with a size, such as varchar(12) or
+// decimal(10, 2)
+var sizedTypePattern = regexp.MustCompile(`^\s*([A-Za-z]+)\s*\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\)\s*$`)
+
+// LoadSchemaOverride reads a schema file. Files ending in .yaml or .yml are
+// read as YAML, anything else as JSON. Unknown fields are rejected so that
+// typos do not silently fall back to inference.
+func LoadSchemaOverride(path string) (*SchemaOverride, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+
+	var schema SchemaOverride
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&schema)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&schema)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema file: %w", err)
+	}
+
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// Validate checks the column definitions and resolves their types and check
+// expressions
+func (s *SchemaOverride) Validate() error {
+	seen := make(map[string]bool, len(s.Columns))
+	for i, col := range s.Columns {
+		if col == nil || col.Name == "" {
+			return fmt.Errorf("schema column %d has no name", i+1)
+		}
+		if seen[col.Name] {
+			return fmt.Errorf("schema column %q is defined more than once", col.Name)
+		}
+		seen[col.Name] = true
+
+		if err := col.validate(); err != nil {
+			return fmt.Errorf("schema column %q: %w", col.Name, err)
+		}
+	}
+	return nil
+}
+
+func (c *ColumnOverride) validate() error {
+	typeName := strings.ToLower(strings.TrimSpace(c.Type))
+
+	// Sizes may be written as part of the type name
+	if match := sizedTypePattern.FindStringSubmatch(typeName); match != nil {
+		typeName = match[1]
+		size, _ := strconv.Atoi(match[2])
+		switch schemaTypeNames[typeName] {
+		case dialects.SQLTypeVarchar, dialects.SQLTypeText:
+			c.Length = size
+		case dialects.SQLTypeDecimal:
+			c.Precision = size
+			if match[3] != "" {
+				c.Scale, _ = strconv.Atoi(match[3])
+			}
+		default:
+			return fmt.Errorf("type %s does not take a size", match[1])
+		}
+	}
+
+	switch {
+	case typeName != "":
+		sqlType, ok := schemaTypeNames[typeName]
+		if !ok {
+			return fmt.Errorf("unknown type %q", c.Type)
+		}
+		c.sqlType = sqlType
+	case c.Length > 0:
+		c.sqlType = dialects.SQLTypeVarchar
+	case c.Precision > 0:
+		c.sqlType = dialects.SQLTypeDecimal
+	}
+
+	// A length turns text into a bounded VARCHAR
+	if c.sqlType == dialects.SQLTypeText && c.Length > 0 {
+		c.sqlType = dialects.SQLTypeVarchar
+	}
+
+	if c.Length < 0 {
+		return fmt.Errorf("length must be positive, got %d", c.Length)
+	}
+	if c.Length > 0 && c.sqlType != dialects.SQLTypeVarchar {
+		return fmt.Errorf("length only applies to varchar columns")
+	}
+	if c.sqlType == dialects.SQLTypeVarchar && c.Length == 0 {
+		return fmt.Errorf("varchar requires a length")
+	}
+
+	if (c.Precision != 0 || c.Scale != 0) && c.sqlType != dialects.SQLTypeDecimal {
+		return fmt.Errorf("precision and scale only apply to decimal columns")
+	}
+	if c.sqlType == dialects.SQLTypeDecimal {
+		if c.Precision <= 0 || c.Precision > maxDecimalPrecision {
+			return fmt.Errorf("decimal precision must be between 1 and %d, got %d", maxDecimalPrecision, c.Precision)
+		}
+		if c.Scale < 0 || c.Scale > c.Precision {
+			return fmt.Errorf("decimal scale must be between 0 and the precision %d, got %d", c.Precision, c.Scale)
+		}
+	}
+
+	if c.PrimaryKey && c.Nullable != nil && *c.Nullable {
+		return fmt.Errorf("a primary key column cannot be nullable")
+	}
+
+	if c.Check != "" {
+		check, err := transformers.ParseExpression(c.Check)
+		if err != nil {
+			return fmt.Errorf("invalid check: %w", err)
+		}
+		c.check = check
+	}
+
+	return nil
+}
+
+// required reports whether the column rejects NULL values
+func (c *ColumnOverride) required() bool {
+	return c.PrimaryKey || (c.Nullable != nil && !*c.Nullable)
+}
+
+// apply overlays the pinned fields on an inferred column definition
+func (c *ColumnOverride) apply(def *dialects.ColumnDef) {
+	if c.sqlType != "" {
+		def.Type = c.sqlType
+		def.Length = c.Length
+		def.Precision = c.Precision
+		def.Scale = c.Scale
+	}
+	if c.Nullable != nil {
+		def.Nullable = *c.Nullable
+	}
+	def.Default = c.Default
+	def.IsPrimaryKey = c.PrimaryKey
+	def.Unique = c.Unique
+}
+
+// pinnedColumn is a schema column matched to a column of the data
+type pinnedColumn struct {
+	index    int // Position in the data columns
+	override *ColumnOverride
+}
+
+// resolve matches the schema columns to the data columns by source or
+// normalized name. A nil schema pins nothing.
+func (s *SchemaOverride) resolve(sourceColumns, columns []string) ([]pinnedColumn, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	pinned := make([]pinnedColumn, 0, len(s.Columns))
+	for _, col := range s.Columns {
+		index := -1
+		for i := range columns {
+			if sourceColumns[i] == col.Name || columns[i] == col.Name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("schema column %q not found in data", col.Name)
+		}
+		pinned = append(pinned, pinnedColumn{index: index, override: col})
+	}
+	return pinned, nil
+}
+
+// checkSQL writes the column's check as SQL for dialect. own reports whether
+// the check refers to no other column, so that it can be written as a column
+// constraint; some databases only accept other columns in table constraints.
+func (p pinnedColumn) checkSQL(dialect dialects.Dialect, sourceColumns, columns []string) (sql string, own bool, err error) {
+	own = true
+	sql, err = p.override.check.SQL(dialect, func(name string) (string, error) {
+		for i := range columns {
+			if sourceColumns[i] == name || columns[i] == name {
+				own = own && i == p.index
+				return columns[i], nil
+			}
+		}
+		return "", fmt.Errorf("column %q not found in data", name)
+	})
+	return sql, own, err
+}
+
+// primaryKey returns the output names of the pinned primary key columns
+func primaryKey(pinned []pinnedColumn, columns []string) []string {
+	var keys []string
+	for _, p := range pinned {
+		if p.override.PrimaryKey {
+			keys = append(keys, columns[p.index])
+		}
+	}
+	return keys
+}
+
+// schemaValidator checks rows against the pinned columns
+type schemaValidator struct {
+	pinned        []pinnedColumn
+	columnDefs    []dialects.ColumnDef
+	sourceColumns []string
+	columns       []string
+	typeInferer   *TypeInferenceEngine
+	hasChecks     bool
+}
+
+func newSchemaValidator(pinned []pinnedColumn, columnDefs []dialects.ColumnDef, sourceColumns, columns []string, typeInferer *TypeInferenceEngine) *schemaValidator {
+	v := &schemaValidator{
+		pinned:        pinned,
+		columnDefs:    columnDefs,
+		sourceColumns: sourceColumns,
+		columns:       columns,
+		typeInferer:   typeInferer,
+	}
+	for _, p := range pinned {
+		if p.override.check != nil {
+			v.hasChecks = true
+		}
+	}
+	return v
+}
+
+// validate checks one row. rowNumber is used in the error message.
+func (v *schemaValidator) validate(row common.DataRow, rowNumber int) error {
+	var checkRow common.DataRow
+	if v.hasChecks {
+		// Checks are written against the table, so make the output names
+		// available alongside the source names. Blank cells of columns that
+		// are not text are inserted as NULL, so they are checked as NULL.
+		checkRow = make(common.DataRow, len(row)+len(v.columns))
+		for key, value := range row {
+			checkRow[key] = value
+		}
+		for i, col := range v.columns {
+			value := row[v.sourceColumns[i]]
+			if blankIsNull(v.columnDefs[i].Type) && isBlank(value) {
+				value = nil
+				checkRow[v.sourceColumns[i]] = nil
+			}
+			checkRow[col] = value
+		}
+	}
+
+	for _, p := range v.pinned {
+		column := v.columns[p.index]
+		value := row[v.sourceColumns[p.index]]
+
+		if msg := v.checkValue(p.override, value); msg != "" {
+			return &RowValidationError{Row: rowNumber, Column: column, Value: value, Msg: msg}
+		}
+
+		if p.override.check != nil {
+			result, err := p.override.check.Eval(checkRow)
+			if err != nil {
+				return &RowValidationError{Row: rowNumber, Column: column, Value: value, Msg: fmt.Sprintf("check (%s) failed: %v", p.override.Check, err)}
+			}
+			// As in SQL, a check that evaluates to NULL passes
+			if passed, ok := result.(bool); ok && !passed {
+				return &RowValidationError{Row: rowNumber, Column: column, Value: value, Msg: fmt.Sprintf("value %s violates check (%s)", describeValue(value), p.override.Check)}
+			}
+		}
+	}
+	return nil
+}
+
+// checkValue returns why value does not fit the column, or "" if it does
+func (v *schemaValidator) checkValue(c *ColumnOverride, value interface{}) string {
+	if value == nil || (blankIsNull(c.sqlType) && isBlank(value)) {
+		if c.required() {
+			return "value is required"
+		}
+		return ""
+	}
+
+	switch c.sqlType {
+	case dialects.SQLTypeInteger, dialects.SQLTypeSmallInt, dialects.SQLTypeBigInt:
+		if !isIntegerValue(value) {
+			return fmt.Sprintf("value %s is not an integer", describeValue(value))
+		}
+		if low, high := integerRange(c.sqlType); !inRange(value, low, high) {
+			return fmt.Sprintf("value %s is out of range for %s", describeValue(value), c.sqlType)
+		}
+	case dialects.SQLTypeFloat:
+		if _, ok := numericString(value); !ok {
+			return fmt.Sprintf("value %s is not a number", describeValue(value))
+		}
+	case dialects.SQLTypeDecimal:
+		return checkDecimal(value, c.Precision, c.Scale)
+	case dialects.SQLTypeVarchar:
+		if length := utf8.RuneCountInString(fmt.Sprintf("%v", value)); length > c.Length {
+			return fmt.Sprintf("value %s is %d characters long, more than the maximum of %d", describeValue(value), length, c.Length)
+		}
+	case dialects.SQLTypeBoolean:
+		switch val := value.(type) {
+		case bool:
+		case string:
+			if !v.typeInferer.isBoolean(val) {
+				return fmt.Sprintf("value %s is not a boolean", describeValue(value))
+			}
+		default:
+			if !isIntegerValue(value) {
+				return fmt.Sprintf("value %s is not a boolean", describeValue(value))
+			}
+		}
+	case dialects.SQLTypeDate, dialects.SQLTypeDateTime:
+		switch val := value.(type) {
+		case time.Time:
+		case string:
+			if _, isDate, _ := v.typeInferer.isDateTime(val); !isDate {
+				return fmt.Sprintf("value %s is not a date", describeValue(value))
+			}
+		default:
+			return fmt.Sprintf("value %s is not a date", describeValue(value))
+		}
+	}
+	return ""
+}
+
+// blankIsNull reports whether a blank string in a column of the given type
+// stands for NULL, as it does for every type but text. Columns whose type is
+// not known keep their blank strings.
+func blankIsNull(sqlType dialects.SQLType) bool {
+	switch sqlType {
+	case "", dialects.SQLTypeText, dialects.SQLTypeVarchar:
+		return false
+	default:
+		return true
+	}
+}
+
+// isBlank reports whether value is an empty or whitespace-only string
+func isBlank(value interface{}) bool {
+	s, ok := value.(string)
+	return ok && strings.TrimSpace(s) == ""
+}
+
+// isIntegerValue reports whether value is a whole number
+func isIntegerValue(value interface{}) bool {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return true
+	case float32:
+		return float64(v) == float64(int64(v))
+	case float64:
+		return v == float64(int64(v))
+	case json.Number:
+		return isIntegerValue(string(v))
+	case string:
+		_, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return err == nil
+	default:
+		return false
+	}
+}
+
+// integerRange returns the values an integer type can hold
+func integerRange(sqlType dialects.SQLType) (int64, int64) {
+	switch sqlType {
+	case dialects.SQLTypeSmallInt:
+		return math.MinInt16, math.MaxInt16
+	case dialects.SQLTypeInteger:
+		return math.MinInt32, math.MaxInt32
+	default:
+		return math.MinInt64, math.MaxInt64
+	}
+}
+
+// inRange reports whether an integer value lies between low and high
+func inRange(value interface{}, low, high int64) bool {
+	s, ok := numericString(value)
+	if !ok {
+		return false
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return err == nil && n >= low && n <= high
+}
+
+// numericString returns value as a plain decimal string if it is a number
+func numericString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case json.Number:
+		return numericString(string(v))
+	case string:
+		s := strings.TrimSpace(v)
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return "", false
+		}
+		if strings.ContainsAny(s, "eE") {
+			s = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		return s, true
+	default:
+		return "", false
+	}
+}
+
+// checkDecimal checks that value fits DECIMAL(precision, scale)
+func checkDecimal(value interface{}, precision, scale int) string {
+	s, ok := numericString(value)
+	if !ok {
+		return fmt.Sprintf("value %s is not a number", describeValue(value))
+	}
+
+	s = strings.TrimLeft(s, "+-")
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	intDigits := len(strings.TrimLeft(intPart, "0"))
+	fracDigits := len(strings.TrimRight(fracPart, "0"))
+
+	if fracDigits > scale {
+		return fmt.Sprintf("value %s has more than %d digits after the decimal point", describeValue(value), scale)
+	}
+	if intDigits > precision-scale {
+		return fmt.Sprintf("value %s does not fit DECIMAL(%d,%d)", describeValue(value), precision, scale)
+	}
+	return ""
+}
+
+// describeValue formats a value for error messages
+func describeValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprintf("%v", value)
+}
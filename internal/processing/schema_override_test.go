@@ -0,0 +1,435 @@
+package processing
+
+import (
+	"brokolisql-go/pkg/common"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSchemaFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write schema file: %v", err)
+	}
+	return path
+}
+
+func TestLoadSchemaOverride(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		wantErr  string
+		validate func(t *testing.T, schema *SchemaOverride)
+	}{
+		{
+			name: "YAML with sized types",
+			file: "schema.yaml",
+			content: `columns:
+  - name: code
+    type: VARCHAR(12)
+    nullable: false
+    primary_key: true
+  - name: price
+    type: decimal(10, 2)
+    check: price > 0
+`,
+			validate: func(t *testing.T, schema *SchemaOverride) {
+				code, price := schema.Columns[0], schema.Columns[1]
+				if code.sqlType != "VARCHAR" || code.Length != 12 {
+					t.Errorf("code = %s(%d), want VARCHAR(12)", code.sqlType, code.Length)
+				}
+				if price.sqlType != "DECIMAL" || price.Precision != 10 || price.Scale != 2 {
+					t.Errorf("price = %s(%d,%d), want DECIMAL(10,2)", price.sqlType, price.Precision, price.Scale)
+				}
+				if price.check == nil {
+					t.Errorf("price check was not parsed")
+				}
+			},
+		},
+		{
+			name:    "JSON with length implying varchar",
+			file:    "schema.json",
+			content: `{"columns": [{"name": "code", "length": 5, "unique": true, "default": "n/a"}]}`,
+			validate: func(t *testing.T, schema *SchemaOverride) {
+				if col := schema.Columns[0]; col.sqlType != "VARCHAR" || col.Length != 5 || col.Default != "n/a" {
+					t.Errorf("code = %+v, want VARCHAR(5) defaulting to n/a", col)
+				}
+			},
+		},
+		{
+			name:    "Unknown field",
+			file:    "schema.json",
+			content: `{"columns": [{"name": "code", "nulable": false}]}`,
+			wantErr: `unknown field "nulable"`,
+		},
+		{
+			name:    "Unknown YAML field",
+			file:    "schema.yml",
+			content: "columns:\n  - name: code\n    primary: true\n",
+			wantErr: "field primary not found",
+		},
+		{
+			name:    "Unknown type",
+			file:    "schema.json",
+			content: `{"columns": [{"name": "code", "type": "uuid"}]}`,
+			wantErr: `schema column "code": unknown type "uuid"`,
+		},
+		{
+			name:    "Missing name",
+			file:    "schema.json",
+			content: `{"columns": [{"type": "integer"}]}`,
+			wantErr: "schema column 1 has no name",
+		},
+		{
+			name:    "Duplicate column",
+			file:    "schema.json",
+			content: `{"columns": [{"name": "id"}, {"name": "id"}]}`,
+			wantErr: `schema column "id" is defined more than once`,
+		},
+		{
+			name:    "Length on an integer",
+			file:    "schema.json",
+			content: `{"columns": [{"name": "id", "type": "integer", "length": 4}]}`,
+			wantErr: "length only applies to varchar columns",
+		},
+		{
+			name:    "Decimal without precision",
+			file:    "schema.json",
+			content: `{"columns": [{"name": "price", "type": "decimal"}]}`,
+			wantErr: "decimal precision must be between 1 and 38",
+		},
+		{
+			name:    "Scale larger than precision",
+			file:    "schema.json",
+			content: `{"columns": [{"name": "price", "type": "decimal(4,6)"}]}`,
+			wantErr: "decimal scale must be between 0 and the precision 4",
+		},
+		{
+			name:    "Nullable primary key",
+			file:    "schema.json",
+			content: `{"columns": [{"name": "id", "primary_key": true, "nullable": true}]}`,
+			wantErr: "a primary key column cannot be nullable",
+		},
+		{
+			name:    "Invalid check",
+			file:    "schema.json",
+			content: `{"columns": [{"name": "price", "check": "price >"}]}`,
+			wantErr: "invalid check",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := LoadSchemaOverride(writeSchemaFile(t, tt.file, tt.content))
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadSchemaOverride() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadSchemaOverride() error = %v", err)
+			}
+			tt.validate(t, schema)
+		})
+	}
+}
+
+func TestSQLGenerator_Generate_Schema(t *testing.T) {
+	schema := &SchemaOverride{Columns: []*ColumnOverride{
+		{Name: "code", Type: "varchar(4)", PrimaryKey: true},
+		{Name: "AMOUNT", Type: "decimal(6,2)", Default: 0, Check: "amount >= 0"},
+		{Name: "name", Unique: true, Nullable: boolPtr(false)},
+		{Name: "count", Check: "count <= 10 OR amount > 0"},
+	}}
+
+	dataset := &common.DataSet{
+		Columns: []string{"code", "name", "amount", "count"},
+		Rows: []common.DataRow{
+			{"code": "A1", "name": "Alice", "amount": "10.50", "count": "3"},
+			{"code": "B2", "name": "Bob", "amount": 7, "count": "4"},
+		},
+	}
+
+	generator, err := NewSQLGenerator(SQLGeneratorOptions{
+		Dialect:          "postgres",
+		TableName:        "orders",
+		CreateTable:      true,
+		NormalizeColumns: true,
+		Schema:           schema,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+
+	sql, err := generator.Generate(dataset)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	verifySQL(t, sql, []string{
+		`"CODE" VARCHAR(4) PRIMARY KEY`,
+		`"NAME" TEXT NOT NULL UNIQUE`,
+		`"AMOUNT" NUMERIC(6,2) DEFAULT 0 CHECK ("AMOUNT" >= 0),`,
+		// Types missing from the schema are still inferred, and a check on
+		// several columns becomes a table constraint
+		"\"COUNT\" INTEGER,\n  CHECK ((\"COUNT\" <= 10) OR (\"AMOUNT\" > 0))\n);",
+	})
+}
+
+func TestSQLGenerator_Generate_SchemaCheckErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		check   string
+		wantErr string
+	}{
+		{name: "Unknown column", dialect: "postgres", check: "amount > total", wantErr: `failed to write the check of column "amount" as SQL: column "total" not found in data`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := NewSQLGenerator(SQLGeneratorOptions{
+				Dialect:     tt.dialect,
+				TableName:   "orders",
+				CreateTable: true,
+				Schema:      &SchemaOverride{Columns: []*ColumnOverride{{Name: "amount", Check: tt.check}}},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create generator: %v", err)
+			}
+
+			_, err = generator.Generate(&common.DataSet{
+				Columns: []string{"amount"},
+				Rows:    []common.DataRow{{"amount": "1"}},
+			})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Generate() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSQLGenerator_Generate_SchemaCheckWithoutSQL(t *testing.T) {
+	// SQLite has no regular expressions, so the check is left out of the
+	// table with a warning and only validated while converting
+	var messages strings.Builder
+	generator, err := NewSQLGenerator(SQLGeneratorOptions{
+		Dialect:     "sqlite",
+		TableName:   "orders",
+		CreateTable: true,
+		Schema: &SchemaOverride{Columns: []*ColumnOverride{
+			{Name: "code", Check: "code ~ '^[A-Z][0-9]+$'"},
+			{Name: "amount", Check: "amount >= 0"},
+		}},
+		Messages: &messages,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+
+	sql, err := generator.Generate(&common.DataSet{
+		Columns: []string{"code", "amount"},
+		Rows:    []common.DataRow{{"code": "A12", "amount": "1"}},
+	})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	verifySQL(t, sql, []string{`"code" TEXT,`, `"amount" INTEGER CHECK ("amount" >= 0)`})
+	if strings.Contains(sql, "code\" ~") || strings.Contains(sql, "REGEXP") {
+		t.Errorf("Generate() SQL = %v, should leave out the regular expression check", sql)
+	}
+
+	want := common.WarningPrefix + `Left the check (code ~ '^[A-Z][0-9]+$') of column "code" out of CREATE TABLE: regular expressions cannot be written as SQL for sqlite` + "\n"
+	if messages.String() != want {
+		t.Errorf("Messages = %q, want %q", messages.String(), want)
+	}
+
+	_, err = generator.Generate(&common.DataSet{
+		Columns: []string{"code", "amount"},
+		Rows:    []common.DataRow{{"code": "12A", "amount": "1"}},
+	})
+	var rowErr *RowValidationError
+	if !errors.As(err, &rowErr) || rowErr.Column != "code" {
+		t.Errorf("Generate() error = %v, want the check of column code to fail", err)
+	}
+}
+
+func TestSQLGenerator_Generate_SchemaViolations(t *testing.T) {
+	schema := &SchemaOverride{Columns: []*ColumnOverride{
+		{Name: "code", Type: "varchar(4)", PrimaryKey: true},
+		{Name: "amount", Type: "decimal(5,2)", Check: "amount >= 0"},
+		{Name: "quantity", Type: "smallint"},
+		{Name: "shipped", Type: "date"},
+		{Name: "active", Type: "boolean"},
+	}}
+
+	valid := common.DataRow{"code": "A1", "amount": "1.5", "quantity": "2", "shipped": "2024-01-02", "active": "yes"}
+
+	tests := []struct {
+		name    string
+		row     common.DataRow
+		wantCol string
+		wantMsg string
+	}{
+		{name: "Too long", row: common.DataRow{"code": "ABCDE"}, wantCol: "CODE", wantMsg: `value "ABCDE" is 5 characters long, more than the maximum of 4`},
+		{name: "Missing primary key", row: common.DataRow{"code": nil}, wantCol: "CODE", wantMsg: "value is required"},
+		{name: "Too many decimals", row: common.DataRow{"amount": 1.255}, wantCol: "AMOUNT", wantMsg: "value 1.255 has more than 2 digits after the decimal point"},
+		{name: "Too many digits", row: common.DataRow{"amount": "1234.5"}, wantCol: "AMOUNT", wantMsg: `value "1234.5" does not fit DECIMAL(5,2)`},
+		{name: "Check violated", row: common.DataRow{"amount": "-2"}, wantCol: "AMOUNT", wantMsg: `value "-2" violates check (amount >= 0)`},
+		{name: "Not an integer", row: common.DataRow{"quantity": "2.5"}, wantCol: "QUANTITY", wantMsg: `value "2.5" is not an integer`},
+		{name: "Out of range", row: common.DataRow{"quantity": 40000}, wantCol: "QUANTITY", wantMsg: "value 40000 is out of range for SMALLINT"},
+		{name: "Not a date", row: common.DataRow{"shipped": "yesterday"}, wantCol: "SHIPPED", wantMsg: `value "yesterday" is not a date`},
+		{name: "Not a boolean", row: common.DataRow{"active": "maybe"}, wantCol: "ACTIVE", wantMsg: `value "maybe" is not a boolean`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bad := common.DataRow{}
+			for key, value := range valid {
+				bad[key] = value
+			}
+			for key, value := range tt.row {
+				bad[key] = value
+			}
+
+			generator, err := NewSQLGenerator(SQLGeneratorOptions{
+				Dialect:          "generic",
+				TableName:        "orders",
+				NormalizeColumns: true,
+				Schema:           schema,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create generator: %v", err)
+			}
+
+			_, err = generator.Generate(&common.DataSet{
+				Columns: []string{"code", "amount", "quantity", "shipped", "active"},
+				Rows:    []common.DataRow{valid, bad},
+			})
+
+			var rowErr *RowValidationError
+			if !errors.As(err, &rowErr) {
+				t.Fatalf("Generate() error = %v, want a RowValidationError", err)
+			}
+			if rowErr.Row != 2 || rowErr.Column != tt.wantCol || rowErr.Msg != tt.wantMsg {
+				t.Errorf("Generate() error = %v, want row 2, column %q: %s", err, tt.wantCol, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestSQLGenerator_Generate_SchemaBlankCells(t *testing.T) {
+	schema := &SchemaOverride{Columns: []*ColumnOverride{
+		{Name: "id", Type: "integer", PrimaryKey: true},
+		{Name: "amount", Type: "decimal(5,2)", Check: "amount >= 0"},
+		{Name: "shipped", Type: "date", Nullable: boolPtr(false)},
+		{Name: "note", Type: "varchar(5)", Nullable: boolPtr(false)},
+	}}
+	columns := []string{"id", "amount", "shipped", "note"}
+
+	generate := func(row common.DataRow) (string, error) {
+		generator, err := NewSQLGenerator(SQLGeneratorOptions{Dialect: "generic", TableName: "orders", Schema: schema})
+		if err != nil {
+			t.Fatalf("Failed to create generator: %v", err)
+		}
+		return generator.Generate(&common.DataSet{Columns: columns, Rows: []common.DataRow{row}})
+	}
+
+	t.Run("Blank cells of nullable columns are NULL", func(t *testing.T) {
+		// Blank text is a value, so it satisfies NOT NULL. The blank amount
+		// is checked as NULL, which passes as it does in SQL.
+		sql, err := generate(common.DataRow{"id": "1", "amount": " ", "shipped": "2024-01-02", "note": ""})
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		verifySQL(t, sql, []string{"(1, NULL, DATE '2024-01-02', '')"})
+	})
+
+	tests := []struct {
+		name    string
+		row     common.DataRow
+		wantCol string
+	}{
+		{name: "Blank primary key", row: common.DataRow{"id": "", "amount": "1", "shipped": "2024-01-02", "note": "a"}, wantCol: "id"},
+		{name: "Blank required date", row: common.DataRow{"id": "1", "amount": "1", "shipped": " ", "note": "a"}, wantCol: "shipped"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generate(tt.row)
+
+			var rowErr *RowValidationError
+			if !errors.As(err, &rowErr) {
+				t.Fatalf("Generate() error = %v, want a RowValidationError", err)
+			}
+			if rowErr.Column != tt.wantCol || rowErr.Msg != "value is required" {
+				t.Errorf("Generate() error = %v, want column %q: value is required", err, tt.wantCol)
+			}
+		})
+	}
+}
+
+func TestSQLGenerator_Generate_SchemaErrors(t *testing.T) {
+	t.Run("Unknown column", func(t *testing.T) {
+		generator, err := NewSQLGenerator(SQLGeneratorOptions{
+			Dialect: "generic",
+			Schema:  &SchemaOverride{Columns: []*ColumnOverride{{Name: "missing", Type: "integer"}}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create generator: %v", err)
+		}
+
+		_, err = generator.Generate(&common.DataSet{Columns: []string{"id"}, Rows: []common.DataRow{{"id": 1}}})
+		if err == nil || !strings.Contains(err.Error(), `schema column "missing" not found in data`) {
+			t.Errorf("Generate() error = %v, want schema column not found", err)
+		}
+	})
+
+	t.Run("Nested data", func(t *testing.T) {
+		generator, err := NewSQLGenerator(SQLGeneratorOptions{
+			Dialect: "generic",
+			Schema:  &SchemaOverride{Columns: []*ColumnOverride{{Name: "id", Type: "integer"}}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create generator: %v", err)
+		}
+
+		_, err = generator.Generate(&common.DataSet{
+			Columns: []string{"id", "address"},
+			Rows:    []common.DataRow{{"id": 1, "address": map[string]interface{}{"city": "Maputo"}}},
+		})
+		if !errors.Is(err, ErrSchemaWithNestedData) {
+			t.Errorf("Generate() error = %v, want %v", err, ErrSchemaWithNestedData)
+		}
+	})
+
+	t.Run("Upsert defaults to the primary key", func(t *testing.T) {
+		generator, err := NewSQLGenerator(SQLGeneratorOptions{
+			Dialect:          "postgres",
+			TableName:        "users",
+			NormalizeColumns: true,
+			Mode:             ModeUpsert,
+			Schema:           &SchemaOverride{Columns: []*ColumnOverride{{Name: "id", Type: "integer", PrimaryKey: true}}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create generator: %v", err)
+		}
+
+		sql, err := generator.Generate(&common.DataSet{Columns: []string{"id", "name"}, Rows: []common.DataRow{{"id": 1, "name": "Alice"}}})
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		verifySQL(t, sql, []string{`ON CONFLICT ("ID") DO UPDATE SET`})
+	})
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
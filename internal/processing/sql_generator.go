@@ -1,18 +1,49 @@
 package processing
 
 import (
-	"brokolisql-go/internal/dialects"
+	"brokolisql-go/internal/transformers"
 	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
 )
 
+// ErrSchemaWithNestedData is returned when a schema file is given for nested
+// objects, whose tables are derived from the structure of the data
+var ErrSchemaWithNestedData = errors.New("a schema file can only be used with flat data")
+
+// ErrNestedDataInStream is returned when streaming input contains nested
+// objects, which can only be converted with the whole dataset in memory
+var ErrNestedDataInStream = errors.New("nested objects are not supported in streaming mode")
+
 type SQLGeneratorOptions struct {
 	Dialect          string
 	TableName        string
 	CreateTable      bool
 	BatchSize        int
 	NormalizeColumns bool
+	SampleSize       int             // Rows buffered for type inference when streaming
+	Mode             string          // Statement mode: insert (default), upsert or copy
+	KeyColumns       []string        // Columns identifying existing rows in upsert mode
+	Schema           *SchemaOverride // Pinned column definitions, inferred if nil
+	SizedTypes       bool            // Infer VARCHAR(n), DECIMAL(p,s), SMALLINT and BIGINT
+	TypeHeadroom     int             // Percentage added to observed sizes when SizedTypes is set
+	Booleans         *BooleanTokens  // Strings inferred as booleans, DefaultBooleanTokens if nil
+	CodeWidth        int             // Width from which equal-width numbers stay text, 8 if zero, negative to disable
+	Rejects          *RejectReport   // Receives rows with unconvertible values; without it they fail generation
+	Messages         io.Writer       // Receives warnings about nested values and checks left out; nil discards them
 }
 
+// Statement modes supported by the SQL generators
+const (
+	ModeInsert = "insert"
+	ModeUpsert = "upsert"
+	ModeCopy   = "copy"
+)
+
 type SQLGenerator struct {
 	options     SQLGeneratorOptions
 	normalizer  *Normalizer
@@ -30,88 +61,371 @@ func NewSQLGenerator(options SQLGeneratorOptions) (*SQLGenerator, error) {
 		options.BatchSize = 100
 	}
 
+	if options.SampleSize <= 0 {
+		options.SampleSize = 1000
+	}
+
 	dialect, err := dialects.GetDialect(options.Dialect)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := validateMode(&options, dialect); err != nil {
+		return nil, err
+	}
+
+	if options.Schema != nil {
+		if err := options.Schema.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	typeInferer, err := newTypeInferer(options)
+	if err != nil {
+		return nil, err
+	}
+
 	return &SQLGenerator{
 		options:     options,
 		normalizer:  NewNormalizer(),
-		typeInferer: NewTypeInferenceEngine(),
+		typeInferer: typeInferer,
 		dialect:     dialect,
 	}, nil
 }
 
 func (g *SQLGenerator) Generate(dataset *common.DataSet) (string, error) {
+	var sb strings.Builder
+	if err := g.GenerateTo(dataset, dialects.NewTextWriter(&sb)); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// GenerateTo writes the SQL for dataset to out one statement at a time
+func (g *SQLGenerator) GenerateTo(dataset *common.DataSet, out dialects.StatementWriter) error {
 	// Check if we need to handle nested objects
 	hasNestedObjects := g.hasNestedObjects(dataset)
 
 	if hasNestedObjects {
+		if g.options.Schema != nil {
+			return ErrSchemaWithNestedData
+		}
+
 		// Use the nested JSON processor for nested objects
 		processor, err := NewNestedJSONProcessor(g.options)
 		if err != nil {
-			return "", err
+			return err
 		}
-		return processor.ProcessDataSet(dataset)
+		return processor.ProcessDataSetTo(dataset, out)
 	}
 
-	// Original implementation for flat data
-	columns := dataset.Columns
-	if g.options.NormalizeColumns {
-		columns = g.normalizer.NormalizeColumnNames(columns)
+	return g.writeFlat(out, dataset.Columns, dataset.Types, dataset.Rows, nil)
+}
+
+// GenerateStream reads rows from it and writes SQL directly to w. Only the
+// first SampleSize rows, which are used for type inference, and the current
+// INSERT batch are held in memory. Nested objects require the whole dataset to
+// build related tables, so they are rejected in streaming mode.
+func (g *SQLGenerator) GenerateStream(it common.RowIterator, w io.Writer) error {
+	return g.GenerateStreamTo(it, dialects.NewTextWriter(w))
+}
+
+// GenerateStreamTo is GenerateStream for a StatementWriter destination
+func (g *SQLGenerator) GenerateStreamTo(it common.RowIterator, out dialects.StatementWriter) error {
+	sample := make([]common.DataRow, 0, g.options.SampleSize)
+	for len(sample) < g.options.SampleSize {
+		row, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		sample = append(sample, row)
+	}
+
+	declared := common.ColumnTypesOf(it)
+	if g.hasNestedObjects(&common.DataSet{Rows: sample, Types: declared}) {
+		return ErrNestedDataInStream
 	}
 
+	return g.writeFlat(out, it.Columns(), declared, sample, it)
+}
+
+// writeFlat writes CREATE TABLE and INSERT statements for flat data. The
+// buffered rows are used for type inference and written first, followed by any
+// remaining rows from rest. Columns with a declared type keep it.
+func (g *SQLGenerator) writeFlat(out dialects.StatementWriter, sourceColumns []string, declared map[string]dialects.ColumnDef, buffered []common.DataRow, rest common.RowIterator) error {
+	columns := sourceColumns
 	if g.options.NormalizeColumns {
-		normalizedRows := make([]common.DataRow, len(dataset.Rows))
-		for i, row := range dataset.Rows {
-			normalizedRow := make(common.DataRow)
-			for j, col := range dataset.Columns {
-				normalizedCol := columns[j]
-				normalizedRow[normalizedCol] = row[col]
-			}
-			normalizedRows[i] = normalizedRow
-		}
-		dataset.Rows = normalizedRows
+		columns = g.normalizer.NormalizeColumnNames(sourceColumns)
 	}
 
-	var sql string
+	pinned, err := g.options.Schema.resolve(sourceColumns, columns)
+	if err != nil {
+		return err
+	}
 
-	if g.options.CreateTable {
-		columnTypes := g.typeInferer.InferColumnTypes(columns, dataset.Rows)
+	batchWriter := dialects.NewBatchWriter(out, g.dialect, g.options.TableName, columns, g.options.BatchSize)
 
-		columnDefs := make([]dialects.ColumnDef, len(columns))
+	var keyColumns []string
+	if g.options.Mode == ModeUpsert {
+		keyColumns, err = g.resolveKeyColumns(sourceColumns, columns, pinned)
+		if err != nil {
+			return err
+		}
+		batchWriter.WithKeyColumns(keyColumns)
+	}
+
+	if g.options.Mode == ModeCopy {
+		batchWriter.WithCopy()
+	}
+
+	columnDefs := make([]dialects.ColumnDef, len(columns))
+	if g.options.SizedTypes {
+		inferred := g.typeInferer.InferColumnDefs(sourceColumns, buffered)
+		for i, col := range columns {
+			columnDefs[i] = inferred[sourceColumns[i]]
+			columnDefs[i].Name = col
+		}
+	} else {
+		columnTypes := g.typeInferer.InferColumnTypes(sourceColumns, buffered)
 		for i, col := range columns {
 			columnDefs[i] = dialects.ColumnDef{
 				Name:     col,
-				Type:     columnTypes[col],
+				Type:     columnTypes[sourceColumns[i]],
 				Nullable: true, // Default to nullable
 			}
 		}
+	}
+	for i, col := range sourceColumns {
+		if def, ok := declared[col]; ok {
+			def.Name = columns[i]
+			columnDefs[i] = def
+		}
+	}
+	for _, p := range pinned {
+		p.override.apply(&columnDefs[p.index])
+	}
 
-		sql += g.dialect.CreateTable(g.options.TableName, columnDefs)
-		sql += "\n"
+	if g.options.CreateTable {
+		table := dialects.TableDef{Name: g.options.TableName, Columns: columnDefs}
+		for _, p := range pinned {
+			if p.override.check == nil {
+				continue
+			}
+			check, own, err := p.checkSQL(g.dialect, sourceColumns, columns)
+			if errors.Is(err, transformers.ErrNoSQLEquivalent) {
+				// Rows are still validated against the check, so only the
+				// database's own enforcement is lost
+				g.warnf("Left the check (%s) of column %q out of CREATE TABLE: %v", p.override.Check, columns[p.index], err)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write the check of column %q as SQL: %w", columns[p.index], err)
+			}
+			if own {
+				table.Columns[p.index].Check = check
+			} else {
+				table.Checks = append(table.Checks, check)
+			}
+		}
+		markKeyColumns(&table, keyColumns)
+		createTableSQL := g.dialect.CreateTableDef(table)
+		if err := out.WriteStatement(createTableSQL+"\n", 0); err != nil {
+			return err
+		}
 	}
 
-	values := make([][]interface{}, len(dataset.Rows))
-	for i, row := range dataset.Rows {
-		rowValues := make([]interface{}, len(columns))
-		for j, col := range columns {
+	validator := newSchemaValidator(pinned, columnDefs, sourceColumns, columns, g.typeInferer)
+	coercer := newCoercer(columnDefs, sourceColumns, buffered, g.typeInferer)
+	rowNumber := 0
+
+	writeRow := func(row common.DataRow) error {
+		rowNumber++
+
+		// Values are written as literals of their column's type
+		rowValues := make([]interface{}, len(sourceColumns))
+		for j, col := range sourceColumns {
 			rowValues[j] = row[col]
 		}
-		values[i] = rowValues
+
+		err := validator.validate(row, rowNumber)
+		if err == nil {
+			err = coercer.coerce(rowValues, columns, rowNumber)
+		}
+		if err != nil {
+			var rowErr *RowValidationError
+			if errors.As(err, &rowErr) {
+				rowErr.Table = g.options.TableName
+				if g.options.Rejects != nil {
+					return g.options.Rejects.Add(rowErr)
+				}
+			}
+			return err
+		}
+
+		return batchWriter.WriteRow(rowValues)
+	}
+
+	for _, row := range buffered {
+		if err := writeRow(row); err != nil {
+			return err
+		}
 	}
 
-	sql += g.dialect.InsertInto(g.options.TableName, columns, values, g.options.BatchSize)
+	if rest != nil {
+		for {
+			row, err := rest.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if err := writeRow(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	return batchWriter.Close()
+}
 
-	return sql, nil
+// warnf writes a warning to the generator's messages, if any
+func (g *SQLGenerator) warnf(format string, args ...interface{}) {
+	if g.options.Messages != nil {
+		fmt.Fprintf(g.options.Messages, common.WarningPrefix+format+"\n", args...)
+	}
 }
 
-// hasNestedObjects checks if the dataset contains nested objects
+// resolveKeyColumns maps the configured key columns to output column names.
+// Keys may be given either as source names or as normalized names. Without
+// configured keys, the primary key pinned by the schema is used.
+func (g *SQLGenerator) resolveKeyColumns(sourceColumns, columns []string, pinned []pinnedColumn) ([]string, error) {
+	if len(g.options.KeyColumns) == 0 {
+		if keys := primaryKey(pinned, columns); len(keys) > 0 {
+			return keys, nil
+		}
+		return nil, fmt.Errorf("upsert mode requires at least one key column")
+	}
+
+	keyColumns := make([]string, 0, len(g.options.KeyColumns))
+	for _, key := range g.options.KeyColumns {
+		found := false
+		for i := range columns {
+			if sourceColumns[i] == key || columns[i] == key {
+				keyColumns = append(keyColumns, columns[i])
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("key column %q not found in data", key)
+		}
+	}
+
+	return keyColumns, nil
+}
+
+// markKeyColumns constrains the upsert key columns of a table, so that the
+// database detects rows whose keys conflict. They become the primary key, or a
+// unique key if the table already has a different primary key.
+func markKeyColumns(table *dialects.TableDef, keyColumns []string) {
+	if len(keyColumns) == 0 {
+		return
+	}
+
+	var primaryKeys []string
+	for _, col := range table.Columns {
+		if col.IsPrimaryKey {
+			primaryKeys = append(primaryKeys, col.Name)
+		}
+	}
+	if sameColumns(primaryKeys, keyColumns) {
+		return
+	}
+
+	if len(primaryKeys) > 0 && len(keyColumns) > 1 {
+		table.UniqueKey = keyColumns
+		return
+	}
+
+	for i := range table.Columns {
+		if !slices.Contains(keyColumns, table.Columns[i].Name) {
+			continue
+		}
+		if len(primaryKeys) == 0 {
+			table.Columns[i].IsPrimaryKey = true
+		} else {
+			table.Columns[i].Unique = true
+		}
+	}
+}
+
+// sameColumns reports whether a and b hold the same columns in any order
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, col := range a {
+		if !slices.Contains(b, col) {
+			return false
+		}
+	}
+	return true
+}
+
+// newTypeInferer returns a type inference engine configured by options
+func newTypeInferer(options SQLGeneratorOptions) (*TypeInferenceEngine, error) {
+	if options.TypeHeadroom < 0 {
+		return nil, fmt.Errorf("type headroom must not be negative, got %d", options.TypeHeadroom)
+	}
+
+	typeInferer := NewTypeInferenceEngine()
+	typeInferer.Headroom = float64(options.TypeHeadroom) / 100
+	if options.Booleans != nil {
+		typeInferer.Booleans = *options.Booleans
+	}
+	if options.CodeWidth != 0 {
+		typeInferer.CodeWidth = max(options.CodeWidth, 0)
+	}
+	return typeInferer, nil
+}
+
+// validateMode defaults the statement mode and rejects unknown modes, modes
+// the dialect cannot render and key columns outside upsert mode
+func validateMode(options *SQLGeneratorOptions, dialect dialects.Dialect) error {
+	switch options.Mode {
+	case "":
+		options.Mode = ModeInsert
+	case ModeInsert, ModeUpsert:
+	case ModeCopy:
+		if _, ok := dialect.(dialects.CopyDialect); !ok {
+			return fmt.Errorf("copy mode is not supported by the %s dialect", dialect.Name())
+		}
+	default:
+		return fmt.Errorf("unsupported output mode: %s", options.Mode)
+	}
+
+	// Plain INSERTs would fail on the rows the keys are meant to match
+	if len(options.KeyColumns) > 0 && options.Mode != ModeUpsert {
+		return fmt.Errorf("key columns %s are only used in upsert mode, not %s mode", strings.Join(options.KeyColumns, ", "), options.Mode)
+	}
+	return nil
+}
+
+// hasNestedObjects checks if the dataset contains nested objects. Columns
+// with a declared type are stored as they are.
 func (g *SQLGenerator) hasNestedObjects(dataset *common.DataSet) bool {
 	// Check each row for nested objects
 	for _, row := range dataset.Rows {
-		for _, value := range row {
+		for col, value := range row {
+			if _, ok := dataset.Types[col]; ok {
+				continue
+			}
+
 			// Check if it's a map
 			if _, ok := value.(map[string]interface{}); ok {
 				return true
@@ -123,6 +437,13 @@ func (g *SQLGenerator) hasNestedObjects(dataset *common.DataSet) bool {
 				if len(strValue) > 1 && strValue[0] == '{' && strValue[len(strValue)-1] == '}' {
 					return true
 				}
+
+				// Arrays of objects, such as repeated XML child elements,
+				// become related tables too
+				if len(strValue) > 1 && strValue[0] == '[' && strValue[len(strValue)-1] == ']' &&
+					strings.HasPrefix(strings.TrimSpace(strValue[1:]), "{") {
+					return true
+				}
 			}
 		}
 	}
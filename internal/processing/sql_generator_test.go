@@ -2,6 +2,11 @@ package processing
 
 import (
 	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"brokolisql-go/pkg/sinks"
+	sqlpkg "database/sql"
+	"encoding/json"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -333,3 +338,518 @@ func TestSQLGenerator_Generate_DifferentDialects(t *testing.T) {
 		})
 	}
 }
+
+func TestSQLGenerator_Generate_SizedTypes(t *testing.T) {
+	dataset := &common.DataSet{
+		Columns: []string{"id", "code", "amount", "views"},
+		Rows: []common.DataRow{
+			{"id": 1, "code": "AB-1", "amount": "19.99", "views": "5000000000"},
+			{"id": 2, "code": "AB-22", "amount": "120.5", "views": "12"},
+		},
+	}
+
+	tests := []struct {
+		dialect  string
+		contains []string
+	}{
+		{
+			dialect:  "postgres",
+			contains: []string{`"ID" SMALLINT`, `"CODE" VARCHAR(5)`, `"AMOUNT" NUMERIC(5,2)`, `"VIEWS" BIGINT`},
+		},
+		{
+			dialect:  "oracle",
+			contains: []string{`"ID" NUMBER(5)`, `"CODE" VARCHAR2(5 CHAR)`, `"AMOUNT" NUMBER(5,2)`, `"VIEWS" NUMBER(19)`},
+		},
+		{
+			dialect:  "sqlserver",
+			contains: []string{"[ID] SMALLINT", "[CODE] NVARCHAR(5)", "[AMOUNT] DECIMAL(5,2)", "[VIEWS] BIGINT"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			generator, err := NewSQLGenerator(SQLGeneratorOptions{
+				Dialect:          tt.dialect,
+				TableName:        "stats",
+				CreateTable:      true,
+				NormalizeColumns: true,
+				SizedTypes:       true,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create generator: %v", err)
+			}
+
+			sql, err := generator.Generate(dataset)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			verifySQL(t, sql, tt.contains)
+		})
+	}
+
+	if _, err := NewSQLGenerator(SQLGeneratorOptions{Dialect: "generic", TypeHeadroom: -1}); err == nil {
+		t.Errorf("NewSQLGenerator() with negative headroom should fail")
+	}
+}
+
+func TestSQLGenerator_Generate_JSONNumbers(t *testing.T) {
+	var records []map[string]interface{}
+	input := `[{"id": 1, "score": 12, "price": 1.5, "views": 5000000000}, {"id": 2, "score": 3, "price": 2, "views": 7}]`
+	if err := json.Unmarshal([]byte(input), &records); err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+	dataset := &common.DataSet{Columns: []string{"id", "score", "price", "views"}}
+	for _, record := range records {
+		dataset.Rows = append(dataset.Rows, record)
+	}
+
+	tests := []struct {
+		name       string
+		sizedTypes bool
+		contains   []string
+	}{
+		{
+			name:       "Sized types",
+			sizedTypes: true,
+			contains:   []string{`"score" SMALLINT`, `"price" NUMERIC(2,1)`, `"views" BIGINT`, "(1, 12, 1.5, 5000000000)"},
+		},
+		{
+			name:     "Plain types",
+			contains: []string{`"score" INTEGER`, `"price" DOUBLE PRECISION`, `"views" BIGINT`, "(1, 12, 1.5, 5000000000)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := NewSQLGenerator(SQLGeneratorOptions{
+				Dialect:     "postgres",
+				TableName:   "stats",
+				CreateTable: true,
+				BatchSize:   100,
+				SizedTypes:  tt.sizedTypes,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create generator: %v", err)
+			}
+
+			sql, err := generator.Generate(dataset)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			verifySQL(t, sql, tt.contains)
+		})
+	}
+}
+
+func TestSQLGenerator_Generate_TypedValues(t *testing.T) {
+	dataset := &common.DataSet{
+		Columns: []string{"zip", "account", "active", "flag", "price", "score"},
+		Rows: []common.DataRow{
+			{"zip": "01234", "account": "40012345", "active": "yes", "flag": "1", "price": "10.50", "score": 7},
+			{"zip": "90210", "account": "40098765", "active": "No", "flag": "0", "price": "3", "score": "n/a"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		options  SQLGeneratorOptions
+		contains []string
+	}{
+		{
+			name:    "Default policy",
+			options: SQLGeneratorOptions{Dialect: "postgres"},
+			contains: []string{
+				`"zip" TEXT`, `"account" TEXT`, `"active" BOOLEAN`, `"flag" INTEGER`, `"price" DOUBLE PRECISION`, `"score" TEXT`,
+				"('01234', '40012345', TRUE, 1, 10.50, '7')",
+				"('90210', '40098765', FALSE, 0, 3, 'n/a')",
+			},
+		},
+		{
+			name:    "Digits as booleans",
+			options: SQLGeneratorOptions{Dialect: "postgres", Booleans: &BooleanTokens{True: []string{"yes", "1"}, False: []string{"no", "0"}}},
+			contains: []string{
+				`"flag" BOOLEAN`,
+				"('01234', '40012345', TRUE, TRUE, 10.50, '7')",
+			},
+		},
+		{
+			name:     "Code width disabled",
+			options:  SQLGeneratorOptions{Dialect: "postgres", CodeWidth: -1},
+			contains: []string{`"account" INTEGER`, "('01234', 40012345, TRUE"},
+		},
+		{
+			name:     "Dialect without boolean literals",
+			options:  SQLGeneratorOptions{Dialect: "sqlserver"},
+			contains: []string{"[active] BIT", "SELECT '01234', '40012345', 1, 1, 10.50, '7' UNION ALL"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.TableName = "accounts"
+			tt.options.CreateTable = true
+
+			generator, err := NewSQLGenerator(tt.options)
+			if err != nil {
+				t.Fatalf("Failed to create generator: %v", err)
+			}
+
+			sql, err := generator.Generate(dataset)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			verifySQL(t, sql, tt.contains)
+		})
+	}
+}
+
+func TestSQLGenerator_Generate_DeclaredTypes(t *testing.T) {
+	// Declared types are used as they are, even where inference would pick
+	// another type, and JSON text in a declared column is not split into
+	// related tables
+	dataset := &common.DataSet{
+		Columns: []string{"id", "code", "amount", "tags"},
+		Rows: []common.DataRow{
+			{"id": int64(1), "code": "0042", "amount": "12.50", "tags": `{"a":1}`},
+			{"id": int64(2), "code": "0043", "amount": "-3.00"},
+		},
+		Types: map[string]dialects.ColumnDef{
+			"id":     {Type: dialects.SQLTypeBigInt},
+			"amount": {Type: dialects.SQLTypeDecimal, Precision: 9, Scale: 2, Nullable: true},
+			"tags":   {Type: dialects.SQLTypeText, Nullable: true},
+		},
+	}
+
+	generator, err := NewSQLGenerator(SQLGeneratorOptions{Dialect: "postgres", TableName: "items", CreateTable: true})
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+
+	sql, err := generator.Generate(dataset)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	verifySQL(t, sql, []string{
+		`"id" BIGINT NOT NULL`, `"code" TEXT`, `"amount" NUMERIC(9,2)`, `"tags" TEXT`,
+		`(1, '0042', 12.50, '{"a":1}')`,
+		`(2, '0043', -3.00, NULL)`,
+	})
+
+	var sb strings.Builder
+	if err := generator.GenerateStream(common.NewDataSetIterator(dataset), &sb); err != nil {
+		t.Fatalf("GenerateStream() error = %v", err)
+	}
+	if sb.String() != sql {
+		t.Errorf("GenerateStream() = %q, want %q", sb.String(), sql)
+	}
+}
+
+func TestSQLGenerator_GenerateStream(t *testing.T) {
+	dataset := &common.DataSet{
+		Columns: []string{"id", "name"},
+		Rows: []common.DataRow{
+			{"id": 1, "name": "John"},
+			{"id": 2, "name": "Jane"},
+			{"id": 3, "name": "Bob"},
+		},
+	}
+
+	options := SQLGeneratorOptions{
+		Dialect:          "generic",
+		TableName:        "users",
+		CreateTable:      true,
+		BatchSize:        2,
+		NormalizeColumns: true,
+		SampleSize:       1,
+	}
+
+	generator, err := NewSQLGenerator(options)
+	if err != nil {
+		t.Fatalf("Failed to create SQL generator: %v", err)
+	}
+
+	var sb strings.Builder
+	if err := generator.GenerateStream(common.NewDataSetIterator(dataset), &sb); err != nil {
+		t.Fatalf("GenerateStream() error = %v", err)
+	}
+
+	// Streaming output must match the in-memory generator
+	want, err := generator.Generate(dataset)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if sb.String() != want {
+		t.Errorf("GenerateStream() = %q, want %q", sb.String(), want)
+	}
+
+	if count := strings.Count(sb.String(), "INSERT INTO"); count != 2 {
+		t.Errorf("GenerateStream() wrote %d INSERT statements, want 2", count)
+	}
+}
+
+func TestSQLGenerator_GenerateStream_NestedData(t *testing.T) {
+	dataset := &common.DataSet{
+		Columns: []string{"id", "address"},
+		Rows: []common.DataRow{
+			{"id": 1, "address": map[string]interface{}{"city": "London"}},
+		},
+	}
+
+	generator, err := NewSQLGenerator(SQLGeneratorOptions{Dialect: "generic", TableName: "users"})
+	if err != nil {
+		t.Fatalf("Failed to create SQL generator: %v", err)
+	}
+
+	var sb strings.Builder
+	err = generator.GenerateStream(common.NewDataSetIterator(dataset), &sb)
+	if err != ErrNestedDataInStream {
+		t.Errorf("GenerateStream() error = %v, want %v", err, ErrNestedDataInStream)
+	}
+}
+
+func TestSQLGenerator_Generate_Upsert(t *testing.T) {
+	dataset := &common.DataSet{
+		Columns: []string{"id", "name"},
+		Rows: []common.DataRow{
+			{"id": 1, "name": "John"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		keyColumns []string
+		wantErr    bool
+		contains   string
+	}{
+		{
+			name:       "Key given as source column",
+			keyColumns: []string{"id"},
+			contains:   "ON CONFLICT (\"ID\") DO UPDATE SET",
+		},
+		{
+			name:       "Key given as normalized column",
+			keyColumns: []string{"ID"},
+			contains:   "ON CONFLICT (\"ID\") DO UPDATE SET",
+		},
+		{
+			name:       "Missing key columns",
+			keyColumns: nil,
+			wantErr:    true,
+		},
+		{
+			name:       "Unknown key column",
+			keyColumns: []string{"email"},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := NewSQLGenerator(SQLGeneratorOptions{
+				Dialect:          "postgres",
+				TableName:        "users",
+				NormalizeColumns: true,
+				Mode:             ModeUpsert,
+				KeyColumns:       tt.keyColumns,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create SQL generator: %v", err)
+			}
+
+			sql, err := generator.Generate(dataset)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Generate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !strings.Contains(sql, tt.contains) {
+				t.Errorf("Generate() SQL = %v, should contain %v", sql, tt.contains)
+			}
+		})
+	}
+
+	if _, err := NewSQLGenerator(SQLGeneratorOptions{Dialect: "generic", Mode: "replace"}); err == nil {
+		t.Errorf("NewSQLGenerator() expected error for unsupported mode")
+	}
+
+	// Key columns without upsert mode would silently write plain INSERTs
+	for _, mode := range []string{"", ModeInsert, ModeCopy} {
+		options := SQLGeneratorOptions{Dialect: "postgres", Mode: mode, KeyColumns: []string{"id"}}
+		if _, err := NewSQLGenerator(options); err == nil || !strings.Contains(err.Error(), "only used in upsert mode") {
+			t.Errorf("NewSQLGenerator() in mode %q with key columns error = %v, want upsert mode required", mode, err)
+		}
+		if _, err := NewMultiTableGenerator(options); err == nil || !strings.Contains(err.Error(), "only used in upsert mode") {
+			t.Errorf("NewMultiTableGenerator() in mode %q with key columns error = %v, want upsert mode required", mode, err)
+		}
+	}
+}
+
+func TestSQLGenerator_Upsert_SQLite(t *testing.T) {
+	// Rows 1 and 3 share the keys, so the last one must win
+	dataset := &common.DataSet{
+		Columns: []string{"id", "region", "code", "name"},
+		Rows: []common.DataRow{
+			{"id": 1, "region": "eu", "code": "A", "name": "John"},
+			{"id": 2, "region": "us", "code": "B", "name": "Jane"},
+			{"id": 1, "region": "eu", "code": "A", "name": "Johnny"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		keyColumns []string
+		schema     *SchemaOverride
+		wantDDL    string
+	}{
+		{
+			name:       "Key becomes the primary key",
+			keyColumns: []string{"id"},
+			wantDDL:    `"id" INTEGER PRIMARY KEY`,
+		},
+		{
+			name:       "Composite key becomes the primary key",
+			keyColumns: []string{"region", "code"},
+			wantDDL:    `PRIMARY KEY ("region", "code")`,
+		},
+		{
+			name:       "Key is unique beside a pinned primary key",
+			keyColumns: []string{"code"},
+			schema:     &SchemaOverride{Columns: []*ColumnOverride{{Name: "id", PrimaryKey: true}}},
+			wantDDL:    `"code" TEXT UNIQUE`,
+		},
+		{
+			name:       "Composite key is unique beside a pinned primary key",
+			keyColumns: []string{"region", "code"},
+			schema:     &SchemaOverride{Columns: []*ColumnOverride{{Name: "id", PrimaryKey: true}}},
+			wantDDL:    `UNIQUE ("region", "code")`,
+		},
+		{
+			name:    "Pinned primary key is the key",
+			schema:  &SchemaOverride{Columns: []*ColumnOverride{{Name: "id", PrimaryKey: true}}},
+			wantDDL: `"id" INTEGER PRIMARY KEY`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := NewSQLGenerator(SQLGeneratorOptions{
+				Dialect:     "sqlite",
+				TableName:   "users",
+				BatchSize:   1,
+				CreateTable: true,
+				Mode:        ModeUpsert,
+				KeyColumns:  tt.keyColumns,
+				Schema:      tt.schema,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create SQL generator: %v", err)
+			}
+
+			sql, err := generator.Generate(dataset)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if !strings.Contains(sql, tt.wantDDL) {
+				t.Errorf("Generate() SQL = %v, should contain %v", sql, tt.wantDDL)
+			}
+
+			dsn := "sqlite://" + filepath.Join(t.TempDir(), "target.db")
+			sink, err := sinks.Open(dsn, sinks.DBOptions{})
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer sink.Close()
+
+			if err := generator.GenerateTo(dataset, sink); err != nil {
+				t.Fatalf("GenerateTo() error = %v", err)
+			}
+			if err := sink.Commit(); err != nil {
+				t.Fatalf("Commit() error = %v", err)
+			}
+
+			db, err := sqlpkg.Open("sqlite", strings.TrimPrefix(dsn, "sqlite://"))
+			if err != nil {
+				t.Fatalf("sql.Open() error = %v", err)
+			}
+			defer db.Close()
+
+			var count int
+			var name string
+			if err := db.QueryRow(`SELECT COUNT(*), MAX(CASE WHEN "id" = 1 THEN "name" END) FROM "users"`).Scan(&count, &name); err != nil {
+				t.Fatalf("Query error = %v", err)
+			}
+			if count != 2 || name != "Johnny" {
+				t.Errorf("Table holds %d rows with name %q, want 2 rows with %q", count, name, "Johnny")
+			}
+		})
+	}
+}
+
+// statementCounter records the rows carried by each data statement
+type statementCounter struct {
+	rows []int
+}
+
+func (c *statementCounter) WriteStatement(sql string, rows int) error {
+	if rows > 0 {
+		c.rows = append(c.rows, rows)
+	}
+	return nil
+}
+
+func TestSQLGenerator_GenerateTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		dataset  *common.DataSet
+		wantRows []int
+	}{
+		{
+			name: "Flat data",
+			dataset: &common.DataSet{
+				Columns: []string{"id", "name"},
+				Rows: []common.DataRow{
+					{"id": 1, "name": "John"},
+					{"id": 2, "name": "Jane"},
+					{"id": 3, "name": "Bob"},
+				},
+			},
+			wantRows: []int{2, 1},
+		},
+		{
+			name: "Nested data",
+			dataset: &common.DataSet{
+				Columns: []string{"id", "address"},
+				Rows: []common.DataRow{
+					{"id": 1, "address": map[string]interface{}{"city": "London"}},
+					{"id": 2, "address": map[string]interface{}{"city": "Paris"}},
+					{"id": 3, "address": map[string]interface{}{"city": "Rome"}},
+				},
+			},
+			// Parent rows first, then the address table, each in batches of 2
+			wantRows: []int{2, 1, 2, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := NewSQLGenerator(SQLGeneratorOptions{Dialect: "sqlite", TableName: "users", CreateTable: true, BatchSize: 2})
+			if err != nil {
+				t.Fatalf("Failed to create SQL generator: %v", err)
+			}
+
+			counter := &statementCounter{}
+			if err := generator.GenerateTo(tt.dataset, counter); err != nil {
+				t.Fatalf("GenerateTo() error = %v", err)
+			}
+
+			if len(counter.rows) != len(tt.wantRows) {
+				t.Fatalf("GenerateTo() wrote data statements with rows %v, want %v", counter.rows, tt.wantRows)
+			}
+			for i := range tt.wantRows {
+				if counter.rows[i] != tt.wantRows[i] {
+					t.Errorf("GenerateTo() wrote data statements with rows %v, want %v", counter.rows, tt.wantRows)
+					break
+				}
+			}
+		})
+	}
+}
@@ -1,19 +1,87 @@
 package processing
 
 import (
-	"brokolisql-go/internal/dialects"
+	"brokolisql-go/pkg/dialects"
+	"encoding/json"
+	"fmt"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"brokolisql-go/pkg/common"
 )
 
+// BooleanTokens lists the strings that count as true and as false. They are
+// compared case-insensitively after trimming.
+type BooleanTokens struct {
+	True  []string
+	False []string
+}
+
+// DefaultBooleanTokens accepts words only. Digits and single letters such as
+// 1/0 and y/n are as often codes or flags with more values to come, so they
+// have to be opted into.
+var DefaultBooleanTokens = BooleanTokens{
+	True:  []string{"true", "yes"},
+	False: []string{"false", "no"},
+}
+
+// ParseBooleanTokens parses comma separated true/false pairs, such as
+// "true/false,yes/no,1/0". An empty spec accepts no strings as booleans.
+func ParseBooleanTokens(spec string) (BooleanTokens, error) {
+	var tokens BooleanTokens
+	seen := make(map[string]bool)
+
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		trueToken, falseToken, ok := strings.Cut(pair, "/")
+		trueToken = strings.ToLower(strings.TrimSpace(trueToken))
+		falseToken = strings.ToLower(strings.TrimSpace(falseToken))
+		if !ok || trueToken == "" || falseToken == "" || strings.Contains(falseToken, "/") {
+			return BooleanTokens{}, fmt.Errorf("invalid boolean token pair %q, want true/false", pair)
+		}
+
+		for _, token := range []string{trueToken, falseToken} {
+			if seen[token] {
+				return BooleanTokens{}, fmt.Errorf("boolean token %q is given more than once", token)
+			}
+			seen[token] = true
+		}
+
+		tokens.True = append(tokens.True, trueToken)
+		tokens.False = append(tokens.False, falseToken)
+	}
+
+	return tokens, nil
+}
+
 type TypeInferenceEngine struct {
 	DateFormats []string
 
+	// TypeThreshold is the share of values a type must match. Values are
+	// converted to the inferred type, so apart from columns holding text it
+	// is only met by types every value matches.
 	TypeThreshold float64
+
+	// Booleans are the strings inferred as booleans
+	Booleans BooleanTokens
+
+	// CodeWidth is the width from which a column of unsigned whole numbers
+	// that all have the same number of digits, such as account numbers, is
+	// kept as text. Zero disables the rule.
+	CodeWidth int
+
+	// Headroom is the fraction added to observed lengths, digits and
+	// magnitudes when InferColumnDefs sizes a column, so that values longer
+	// than those in the sample still fit
+	Headroom float64
 }
 
 func NewTypeInferenceEngine() *TypeInferenceEngine {
@@ -25,9 +93,14 @@ func NewTypeInferenceEngine() *TypeInferenceEngine {
 			"01-02-2006",
 			"02/01/2006",
 			"02-01-2006",
+			"2006-01-02 15:04:05",
+			"2006-01-02T15:04:05",
 			time.RFC3339,
 		},
 		TypeThreshold: 0.8, // 80% of values must match to infer a type
+		Booleans:      DefaultBooleanTokens,
+		CodeWidth:     8,
+		Headroom:      0.25,
 	}
 }
 
@@ -35,18 +108,155 @@ func (e *TypeInferenceEngine) InferColumnTypes(columns []string, rows []common.D
 	columnTypes := make(map[string]dialects.SQLType)
 
 	for _, col := range columns {
+		values := columnValues(rows, col)
+		columnType := e.inferType(values)
+		if columnType == dialects.SQLTypeInteger && largestMagnitude(values) > math.MaxInt32 {
+			columnType = dialects.SQLTypeBigInt
+		}
+		columnTypes[col] = columnType
+	}
+
+	return columnTypes
+}
+
+// InferColumnDefs infers sized column definitions: text becomes VARCHAR(n)
+// sized from the longest value, plain decimals become DECIMAL(p,s) sized from
+// the observed digits, and integers become SMALLINT, INTEGER or BIGINT by
+// magnitude. Every size includes Headroom.
+func (e *TypeInferenceEngine) InferColumnDefs(columns []string, rows []common.DataRow) map[string]dialects.ColumnDef {
+	columnDefs := make(map[string]dialects.ColumnDef, len(columns))
+
+	for _, col := range columns {
+		values := columnValues(rows, col)
+		def := dialects.ColumnDef{Name: col, Type: e.inferType(values), Nullable: true}
+		switch def.Type {
+		case dialects.SQLTypeText:
+			e.sizeText(&def, values)
+		case dialects.SQLTypeInteger:
+			e.sizeInteger(&def, values)
+		case dialects.SQLTypeFloat:
+			e.sizeDecimal(&def, values)
+		}
+		columnDefs[col] = def
+	}
+
+	return columnDefs
+}
+
+// columnValues returns the values of col that say something about its type.
+// Missing values and blank strings are left out, as a blank cell in a number
+// or date column is a missing value rather than text.
+func columnValues(rows []common.DataRow, col string) []interface{} {
+	var values []interface{}
+	for _, row := range rows {
+		if val, ok := row[col]; ok && val != nil && !isBlank(val) {
+			values = append(values, val)
+		}
+	}
+	return values
+}
+
+// sizeText turns a text column into VARCHAR(n). Columns without any
+// non-empty value stay TEXT.
+func (e *TypeInferenceEngine) sizeText(def *dialects.ColumnDef, values []interface{}) {
+	longest := 0
+	for _, val := range values {
+		if length := utf8.RuneCountInString(fmt.Sprintf("%v", val)); length > longest {
+			longest = length
+		}
+	}
+
+	if longest > 0 {
+		def.Type = dialects.SQLTypeVarchar
+		def.Length = e.withHeadroom(longest)
+	}
+}
 
-		var values []interface{}
-		for _, row := range rows {
-			if val, ok := row[col]; ok && val != nil {
-				values = append(values, val)
+// sizeInteger picks SMALLINT, INTEGER or BIGINT from the largest magnitude
+func (e *TypeInferenceEngine) sizeInteger(def *dialects.ColumnDef, values []interface{}) {
+	largest := largestMagnitude(values) * (1 + e.Headroom)
+	switch {
+	case largest > math.MaxInt32:
+		def.Type = dialects.SQLTypeBigInt
+	case largest <= math.MaxInt16:
+		def.Type = dialects.SQLTypeSmallInt
+	}
+}
+
+// largestMagnitude returns the largest absolute value of the numbers among
+// values, whether numbers or numeric strings
+func largestMagnitude(values []interface{}) float64 {
+	var largest float64
+	for _, val := range values {
+		s, ok := numericString(val)
+		if !ok {
+			continue
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil && math.Abs(f) > largest {
+			largest = math.Abs(f)
+		}
+	}
+	return largest
+}
+
+// maxDecimalPrecision is the largest precision every dialect supports
+const maxDecimalPrecision = 38
+
+// sizeDecimal turns a float column into DECIMAL(p,s) when every value is
+// written as a plain decimal number. Values in exponent notation, and digits
+// beyond what a DECIMAL can hold, keep the column floating point.
+func (e *TypeInferenceEngine) sizeDecimal(def *dialects.ColumnDef, values []interface{}) {
+	intDigits, scale := 0, 0
+	for _, val := range values {
+		switch v := val.(type) {
+		case string, json.Number:
+			if strings.ContainsAny(fmt.Sprint(v), "eE") {
+				return
+			}
+		case float32, float64:
+			// Binary floats that print with more significant digits than a
+			// float64 holds, such as 0.30000000000000004, are not amounts
+			str, _ := numericString(v)
+			if len(strings.Trim(strings.ReplaceAll(str, ".", ""), "-0")) > 15 {
+				return
 			}
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		default:
+			continue
+		}
+
+		s, ok := numericString(val)
+		if !ok {
+			continue
+		}
+
+		s = strings.TrimLeft(s, "+-")
+		intPart, fracPart, _ := strings.Cut(s, ".")
+		if digits := len(strings.TrimLeft(intPart, "0")); digits > intDigits {
+			intDigits = digits
 		}
+		if digits := len(fracPart); digits > scale {
+			scale = digits
+		}
+	}
 
-		columnTypes[col] = e.inferType(values)
+	if scale == 0 {
+		return
 	}
 
-	return columnTypes
+	precision := e.withHeadroom(max(intDigits, 1)) + scale
+	if precision > maxDecimalPrecision {
+		return
+	}
+
+	def.Type = dialects.SQLTypeDecimal
+	def.Precision = precision
+	def.Scale = scale
+}
+
+// withHeadroom adds Headroom to an observed size, rounding up
+func (e *TypeInferenceEngine) withHeadroom(size int) int {
+	return int(math.Ceil(float64(size) * (1 + e.Headroom)))
 }
 
 func (e *TypeInferenceEngine) inferType(values []interface{}) dialects.SQLType {
@@ -65,10 +275,21 @@ func (e *TypeInferenceEngine) inferType(values []interface{}) dialects.SQLType {
 		switch v := val.(type) {
 		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
 			intCount++
-		case float32, float64:
-			floatCount++
+		case float32, float64, json.Number:
+			// JSON numbers are floats or json.Number, whole ones included
+			if isIntegerValue(v) {
+				intCount++
+			} else {
+				floatCount++
+			}
 		case bool:
 			boolCount++
+		case time.Time:
+			if v.Equal(v.Truncate(24 * time.Hour)) {
+				dateCount++
+			} else {
+				dateTimeCount++
+			}
 		case string:
 			if e.isInteger(v) {
 				intCount++
@@ -115,6 +336,10 @@ func (e *TypeInferenceEngine) inferType(values []interface{}) dialects.SQLType {
 		return dialects.SQLTypeBoolean
 	}
 
+	if e.isFixedWidthCode(values) {
+		return dialects.SQLTypeText
+	}
+
 	if textCount > 0 {
 		if len(values) == 5 && values[0] == 1 && values[1] == 2 && values[2] == 3 && values[3] == 4 && values[4] == "abc" {
 			return dialects.SQLTypeText
@@ -127,17 +352,21 @@ func (e *TypeInferenceEngine) inferType(values []interface{}) dialects.SQLType {
 		return dialects.SQLTypeText
 	}
 
+	// Values are converted to the inferred type, so a type is only inferred
+	// if every value converts to it: a column of integers with a single
+	// decimal is FLOAT, and one of numbers and booleans is TEXT
 	switch {
-	case boolPercent >= e.TypeThreshold:
+	case boolPercent == 1:
 		return dialects.SQLTypeBoolean
-	case intPercent >= e.TypeThreshold:
+	case intPercent == 1:
 		return dialects.SQLTypeInteger
-	case (intPercent + floatPercent) >= e.TypeThreshold:
+	case intPercent+floatPercent == 1:
 		return dialects.SQLTypeFloat
-	case dateTimePercent >= e.TypeThreshold:
-		return dialects.SQLTypeDateTime
-	case datePercent >= e.TypeThreshold:
+	case datePercent == 1:
 		return dialects.SQLTypeDate
+	case dateTimePercent+datePercent == 1:
+		// Timestamps that fall on midnight read as plain dates
+		return dialects.SQLTypeDateTime
 	default:
 		return dialects.SQLTypeText
 	}
@@ -145,7 +374,7 @@ func (e *TypeInferenceEngine) inferType(values []interface{}) dialects.SQLType {
 
 func (e *TypeInferenceEngine) isInteger(s string) bool {
 	s = strings.TrimSpace(s)
-	if s == "" {
+	if s == "" || isIdentifierLike(s) {
 		return false
 	}
 
@@ -155,17 +384,87 @@ func (e *TypeInferenceEngine) isInteger(s string) bool {
 
 func (e *TypeInferenceEngine) isFloat(s string) bool {
 	s = strings.TrimSpace(s)
-	if s == "" {
+	if s == "" || isIdentifierLike(s) {
 		return false
 	}
 
+	// Whole numbers beyond int64 are identifiers rather than quantities, and
+	// would lose digits as a float
+	if isDigits(strings.TrimPrefix(s, "-")) {
+		_, err := strconv.ParseInt(s, 10, 64)
+		return err == nil
+	}
+
 	_, err := strconv.ParseFloat(s, 64)
 	return err == nil
 }
 
 func (e *TypeInferenceEngine) isBoolean(s string) bool {
+	_, ok := e.parseBoolean(s)
+	return ok
+}
+
+// parseBoolean returns the value of s under the Booleans policy, and whether
+// s is a boolean token at all
+func (e *TypeInferenceEngine) parseBoolean(s string) (bool, bool) {
 	s = strings.ToLower(strings.TrimSpace(s))
-	return s == "true" || s == "false" || s == "yes" || s == "no" || s == "1" || s == "0" || s == "t" || s == "f" || s == "y" || s == "n"
+	for _, token := range e.Booleans.True {
+		if s == token {
+			return true, true
+		}
+	}
+	for _, token := range e.Booleans.False {
+		if s == token {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// isIdentifierLike reports whether a numeric-looking string carries
+// formatting a number would lose: a leading zero, as in ZIP codes, or an
+// explicit plus sign, as in phone numbers
+func isIdentifierLike(s string) bool {
+	if strings.HasPrefix(s, "+") {
+		return true
+	}
+	digits := strings.TrimPrefix(s, "-")
+	return len(digits) > 1 && digits[0] == '0' && digits[1] != '.'
+}
+
+// isFixedWidthCode reports whether every value is a string of unsigned digits
+// of one width of at least CodeWidth
+func (e *TypeInferenceEngine) isFixedWidthCode(values []interface{}) bool {
+	if e.CodeWidth <= 0 || len(values) == 0 {
+		return false
+	}
+
+	width := 0
+	for _, val := range values {
+		s, ok := val.(string)
+		if !ok {
+			return false
+		}
+		s = strings.TrimSpace(s)
+		if !isDigits(s) || len(s) < e.CodeWidth || (width > 0 && len(s) != width) {
+			return false
+		}
+		width = len(s)
+	}
+
+	return true
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
 }
 
 func (e *TypeInferenceEngine) isDateTime(s string) (time.Time, bool, bool) {
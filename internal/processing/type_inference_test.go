@@ -1,10 +1,11 @@
 package processing
 
 import (
-	"brokolisql-go/internal/dialects"
 	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestNewTypeInferenceEngine(t *testing.T) {
@@ -34,6 +35,8 @@ func TestTypeInferenceEngine_isInteger(t *testing.T) {
 		{"Float", "123.45", false},
 		{"String", "abc", false},
 		{"Mixed", "123abc", false},
+		{"Leading zero", "007", false},
+		{"Plus sign", "+123", false},
 		{"Empty string", "", false},
 		{"Whitespace", "  ", false},
 	}
@@ -62,6 +65,8 @@ func TestTypeInferenceEngine_isFloat(t *testing.T) {
 		{"Zero", "0", true},
 		{"String", "abc", false},
 		{"Mixed", "123.45abc", false},
+		{"Beyond int64", "12345678901234567890", false},
+		{"Leading zero", "00.5", false},
 		{"Empty string", "", false},
 		{"Whitespace", "  ", false},
 	}
@@ -87,12 +92,13 @@ func TestTypeInferenceEngine_isBoolean(t *testing.T) {
 		{"False", "false", true},
 		{"Yes", "yes", true},
 		{"No", "no", true},
-		{"T", "t", true},
-		{"F", "f", true},
-		{"Y", "y", true},
-		{"N", "n", true},
-		{"1", "1", true},
-		{"0", "0", true},
+		// Letters and digits are opt-in
+		{"T", "t", false},
+		{"F", "f", false},
+		{"Y", "y", false},
+		{"N", "n", false},
+		{"1", "1", false},
+		{"0", "0", false},
 		{"Uppercase", "TRUE", true},
 		{"Mixed case", "True", true},
 		{"With whitespace", "  true  ", true},
@@ -110,6 +116,48 @@ func TestTypeInferenceEngine_isBoolean(t *testing.T) {
 	}
 }
 
+func TestTypeInferenceEngine_BooleanTokens(t *testing.T) {
+	tokens, err := ParseBooleanTokens("true/false, Y/N,1/0")
+	if err != nil {
+		t.Fatalf("ParseBooleanTokens() error = %v", err)
+	}
+
+	engine := NewTypeInferenceEngine()
+	engine.Booleans = tokens
+
+	tests := []struct {
+		input     string
+		wantValue bool
+		wantOK    bool
+	}{
+		{"true", true, true},
+		{"y", true, true},
+		{"1", true, true},
+		{"N", false, true},
+		{" 0 ", false, true},
+		{"yes", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			value, ok := engine.parseBoolean(tt.input)
+			if value != tt.wantValue || ok != tt.wantOK {
+				t.Errorf("parseBoolean(%q) = %v, %v, want %v, %v", tt.input, value, ok, tt.wantValue, tt.wantOK)
+			}
+		})
+	}
+
+	if got := engine.inferType([]interface{}{"1", "0", "1"}); got != dialects.SQLTypeBoolean {
+		t.Errorf("inferType() with 1/0 tokens = %v, want BOOLEAN", got)
+	}
+
+	for _, spec := range []string{"true", "yes/", "a/b/c", "1/0,true/1"} {
+		if _, err := ParseBooleanTokens(spec); err == nil {
+			t.Errorf("ParseBooleanTokens(%q) expected an error", spec)
+		}
+	}
+}
+
 func TestTypeInferenceEngine_isDateTime(t *testing.T) {
 	engine := NewTypeInferenceEngine()
 
@@ -176,11 +224,31 @@ func TestTypeInferenceEngine_inferType(t *testing.T) {
 			values: []interface{}{1.1, 2.2, 3.3, 4.4, 5.5},
 			want:   dialects.SQLTypeFloat,
 		},
+		{
+			name:   "Whole floats, as JSON integers are decoded",
+			values: []interface{}{1.0, 2.0, 3.0, float32(4), 5.0},
+			want:   dialects.SQLTypeInteger,
+		},
 		{
 			name:   "Mixed integers and floats",
 			values: []interface{}{1, 2, 3.3, 4.4, 5},
 			want:   dialects.SQLTypeFloat,
 		},
+		{
+			name:   "Integer strings with one decimal",
+			values: []interface{}{"1", "2", "3", "4", "5", "6", "7", "8", "9", "2.5"},
+			want:   dialects.SQLTypeFloat,
+		},
+		{
+			name:   "Mostly integers with a boolean",
+			values: []interface{}{"10", "20", "30", "40", "yes"},
+			want:   dialects.SQLTypeText,
+		},
+		{
+			name:   "Mostly dates with a number",
+			values: []interface{}{"2023-01-15", "2023-02-20", "2023-03-25", "2023-04-30", "7"},
+			want:   dialects.SQLTypeText,
+		},
 		{
 			name:   "Float strings",
 			values: []interface{}{"1.1", "2.2", "3.3", "4.4", "5.5"},
@@ -193,9 +261,49 @@ func TestTypeInferenceEngine_inferType(t *testing.T) {
 		},
 		{
 			name:   "Boolean strings",
-			values: []interface{}{"true", "false", "yes", "no", "1", "0"},
+			values: []interface{}{"true", "false", "yes", "no", "TRUE", "No"},
 			want:   dialects.SQLTypeBoolean,
 		},
+		{
+			name:   "Zeros and ones",
+			values: []interface{}{"1", "0", "1", "0"},
+			want:   dialects.SQLTypeInteger,
+		},
+		{
+			name:   "Leading zeros",
+			values: []interface{}{"01234", "90210", "10001"},
+			want:   dialects.SQLTypeText,
+		},
+		{
+			name:   "Phone numbers with plus sign",
+			values: []interface{}{"+258841234567", "+14155550100"},
+			want:   dialects.SQLTypeText,
+		},
+		{
+			name:   "Beyond int64",
+			values: []interface{}{"12345678901234567890", "98765432109876543210"},
+			want:   dialects.SQLTypeText,
+		},
+		{
+			name:   "Fixed-width codes",
+			values: []interface{}{"40012345", "40098765", "51000001"},
+			want:   dialects.SQLTypeText,
+		},
+		{
+			name:   "Varying widths",
+			values: []interface{}{"40012345", "400987651", "51000001"},
+			want:   dialects.SQLTypeInteger,
+		},
+		{
+			name:   "Short fixed width",
+			values: []interface{}{"2021", "2022", "2023"},
+			want:   dialects.SQLTypeInteger,
+		},
+		{
+			name:   "Zero and decimals below one",
+			values: []interface{}{"0", "0.5", "-0.25"},
+			want:   dialects.SQLTypeFloat,
+		},
 		{
 			name:   "All dates",
 			values: []interface{}{"2023-01-15", "2023-02-20", "2023-03-25"},
@@ -206,6 +314,16 @@ func TestTypeInferenceEngine_inferType(t *testing.T) {
 			values: []interface{}{"2023-01-15T14:30:00Z", "2023-02-20T15:45:00Z"},
 			want:   dialects.SQLTypeDateTime,
 		},
+		{
+			name:   "Time values at midnight",
+			values: []interface{}{time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2023, 2, 20, 0, 0, 0, 0, time.UTC)},
+			want:   dialects.SQLTypeDate,
+		},
+		{
+			name:   "Time values",
+			values: []interface{}{time.Date(2023, 1, 15, 14, 30, 0, 0, time.UTC), time.Date(2023, 2, 20, 0, 0, 0, 0, time.UTC)},
+			want:   dialects.SQLTypeDateTime,
+		},
 		{
 			name:   "Mixed types",
 			values: []interface{}{"abc", 123, 45.6, true, "2023-01-15"},
@@ -283,6 +401,14 @@ func TestTypeInferenceEngine_InferColumnTypes(t *testing.T) {
 	if !reflect.DeepEqual(got, want) {
 		t.Errorf("TypeInferenceEngine.InferColumnTypes() = %v, want %v", got, want)
 	}
+
+	// A blank cell is a missing value, not text
+	rows[1]["price"] = ""
+	rows[2]["created_at"] = " "
+	got = engine.InferColumnTypes(columns, rows)
+	if got["price"] != dialects.SQLTypeFloat || got["created_at"] != dialects.SQLTypeDate {
+		t.Errorf("InferColumnTypes() with blank cells = %v, want price %v and created_at %v", got, dialects.SQLTypeFloat, dialects.SQLTypeDate)
+	}
 }
 
 func TestTypeInferenceEngine_CustomThreshold(t *testing.T) {
@@ -308,3 +434,107 @@ func TestTypeInferenceEngine_CustomThreshold(t *testing.T) {
 		t.Errorf("TypeInferenceEngine.inferType() with 70%% threshold = %v, want %v", got, dialects.SQLTypeText)
 	}
 }
+
+func TestTypeInferenceEngine_InferColumnDefs(t *testing.T) {
+	tests := []struct {
+		name     string
+		headroom float64
+		values   []interface{}
+		want     dialects.ColumnDef
+	}{
+		{
+			name:   "Text sized from the longest value",
+			values: []interface{}{"abc", "abcdefgh", "añb"},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeVarchar, Length: 8},
+		},
+		{
+			name:     "Text with headroom",
+			headroom: 0.5,
+			values:   []interface{}{"abc", "abcde"},
+			want:     dialects.ColumnDef{Type: dialects.SQLTypeVarchar, Length: 8},
+		},
+		{
+			name:   "Empty text stays TEXT",
+			values: []interface{}{"", ""},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeText},
+		},
+		{
+			name:   "Small integers",
+			values: []interface{}{"1", "250", -3},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeSmallInt},
+		},
+		{
+			name:   "32-bit integers",
+			values: []interface{}{"1", "70000"},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeInteger},
+		},
+		{
+			name:   "Integers beyond 32 bits",
+			values: []interface{}{"1", "3000000000"},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeBigInt},
+		},
+		{
+			name:   "Whole floats beyond 32 bits",
+			values: []interface{}{1.0, 3000000000.0},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeBigInt},
+		},
+		{
+			name:     "Headroom pushes a magnitude into the next type",
+			headroom: 0.25,
+			values:   []interface{}{"30000"},
+			want:     dialects.ColumnDef{Type: dialects.SQLTypeInteger},
+		},
+		{
+			name:   "Decimals sized from digits",
+			values: []interface{}{"10.5", "1234.25", "7", 0.125},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeDecimal, Precision: 7, Scale: 3},
+		},
+		{
+			name:     "Decimals with headroom on the integer digits",
+			headroom: 0.5,
+			values:   []interface{}{"19.99", "5.00"},
+			want:     dialects.ColumnDef{Type: dialects.SQLTypeDecimal, Precision: 5, Scale: 2},
+		},
+		{
+			name:   "Exponent notation stays floating point",
+			values: []interface{}{"1.5", "2e10"},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeFloat},
+		},
+		{
+			name:   "Binary float noise stays floating point",
+			values: []interface{}{0.30000000000000004, 1.5},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeFloat},
+		},
+		{
+			name:   "Other types are unchanged",
+			values: []interface{}{"2023-01-15", "2023-02-20"},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeDate},
+		},
+		{
+			name:   "Blank cells are left out",
+			values: []interface{}{"10.50", "", "  ", "7.25"},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeDecimal, Precision: 4, Scale: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewTypeInferenceEngine()
+			engine.Headroom = tt.headroom
+
+			rows := make([]common.DataRow, len(tt.values))
+			for i, value := range tt.values {
+				rows[i] = common.DataRow{"col": value}
+			}
+
+			want := tt.want
+			want.Name = "col"
+			want.Nullable = true
+
+			got := engine.InferColumnDefs([]string{"col"}, rows)["col"]
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("InferColumnDefs() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,767 @@
+package transformers
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"brokolisql-go/pkg/common"
+)
+
+// Expression is a parsed row expression shared by add_column, filter_rows and
+// update_rows. The grammar is a small SQL-like language:
+//
+//	expr     := or
+//	or       := and { OR and }
+//	and      := not { AND not }
+//	not      := NOT not | compare
+//	compare  := sum [ cmp-op sum | IS [NOT] NULL | [NOT] LIKE sum | [NOT] ILIKE sum
+//	            | [NOT] IN list | [NOT] BETWEEN sum AND sum | ~ sum | !~ sum ]
+//	sum      := product { (+ | - | ||) product }
+//	product  := unary { (* | / | %) unary }
+//	unary    := (- | +) unary | primary
+//	primary  := number | 'string' | TRUE | FALSE | NULL | column | "column"
+//	            | function(args) | CASE ... END | ( expr )
+//
+// Column names that are not plain identifiers can be quoted with double
+// quotes or backticks. Columns missing from a row evaluate to NULL; the
+// transform engine rejects names that are not columns of its input.
+type Expression struct {
+	source  string
+	root    exprNode
+	columns []*columnNode // Column references in source order
+}
+
+// ExpressionError reports a syntax error in an expression. Pos is the 1-based
+// character position of the offending token.
+type ExpressionError struct {
+	Expression string
+	Pos        int
+	Msg        string
+}
+
+func (e *ExpressionError) Error() string {
+	return fmt.Sprintf("%s at position %d in %q", e.Msg, e.Pos, e.Expression)
+}
+
+// ParseExpression parses source into an Expression. Unknown functions, wrong
+// argument counts and invalid LIKE or regular expression patterns are
+// reported here rather than when the expression is evaluated.
+func ParseExpression(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{source: source, tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok.describe())
+	}
+
+	return &Expression{source: source, root: root, columns: p.columns}, nil
+}
+
+// String returns the source text of the expression
+func (e *Expression) String() string {
+	return e.source
+}
+
+// checkColumns returns an error for the first column reference that is not
+// one of columns, since it would evaluate to NULL in every row
+func (e *Expression) checkColumns(columns []string) error {
+	for _, ref := range e.columns {
+		if !slices.Contains(columns, ref.name) {
+			return &ExpressionError{
+				Expression: e.source,
+				Pos:        ref.pos,
+				Msg:        fmt.Sprintf("unknown column %q", ref.name),
+			}
+		}
+	}
+	return nil
+}
+
+// Eval evaluates the expression against a row
+func (e *Expression) Eval(row common.DataRow) (interface{}, error) {
+	return e.root.eval(row)
+}
+
+// Test evaluates the expression as a condition. NULL and false are both
+// treated as not matching.
+func (e *Expression) Test(row common.DataRow) (bool, error) {
+	value, err := e.root.eval(row)
+	if err != nil {
+		return false, err
+	}
+	return isTrue(value), nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenQuotedIdent
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // byte offset in the source
+}
+
+// is reports whether the token is the given operator or (case-insensitive)
+// keyword
+func (t token) is(text string) bool {
+	switch t.kind {
+	case tokenOperator:
+		return t.text == text
+	case tokenIdent:
+		return strings.EqualFold(t.text, text)
+	}
+	return false
+}
+
+func (t token) describe() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return fmt.Sprintf("string '%s'", t.text)
+	case tokenNumber:
+		return fmt.Sprintf("number %s", t.text)
+	default:
+		return fmt.Sprintf("'%s'", t.text)
+	}
+}
+
+// keywords cannot be used as unquoted column names
+var keywords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IS": true, "NULL": true,
+	"LIKE": true, "ILIKE": true, "IN": true, "BETWEEN": true,
+	"CASE": true, "WHEN": true, "THEN": true, "ELSE": true, "END": true,
+	"TRUE": true, "FALSE": true,
+}
+
+func isKeyword(t token) bool {
+	return t.kind == tokenIdent && keywords[strings.ToUpper(t.text)]
+}
+
+// operators lists the punctuation tokens, longest first so that "<=" is not
+// read as "<" followed by "="
+var operators = []string{
+	"||", "==", "!=", "<>", "<=", ">=", "!~",
+	"(", ")", "[", "]", ",", "+", "-", "*", "/", "%", "=", "<", ">", "~",
+}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	syntaxError := func(pos int, format string, args ...interface{}) error {
+		return &ExpressionError{Expression: source, Pos: charPos(source, pos), Msg: fmt.Sprintf(format, args...)}
+	}
+
+	i := 0
+	for i < len(source) {
+		c := rune(source[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(source) && isDigit(source[i+1]):
+			start := i
+			for i < len(source) && isDigit(source[i]) {
+				i++
+			}
+			if i < len(source) && source[i] == '.' {
+				i++
+				for i < len(source) && isDigit(source[i]) {
+					i++
+				}
+			}
+			if i < len(source) && (source[i] == 'e' || source[i] == 'E') {
+				j := i + 1
+				if j < len(source) && (source[j] == '+' || source[j] == '-') {
+					j++
+				}
+				if j < len(source) && isDigit(source[j]) {
+					i = j
+					for i < len(source) && isDigit(source[i]) {
+						i++
+					}
+				}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: source[start:i], pos: start})
+
+		case c == '\'':
+			// SQL string literal, with '' as an escaped quote
+			start := i
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(source) {
+					return nil, syntaxError(start, "unterminated string")
+				}
+				if source[i] == '\'' {
+					if i+1 < len(source) && source[i+1] == '\'' {
+						sb.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				sb.WriteByte(source[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenString, text: sb.String(), pos: start})
+
+		case c == '"' || c == '`':
+			start := i
+			end := strings.IndexByte(source[i+1:], byte(c))
+			if end < 0 {
+				return nil, syntaxError(start, "unterminated quoted column name")
+			}
+			tokens = append(tokens, token{kind: tokenQuotedIdent, text: source[i+1 : i+1+end], pos: start})
+			i += end + 2
+
+		case isIdentStart(source, i):
+			start := i
+			for i < len(source) && isIdentPart(source, i) {
+				_, size := decodeRune(source, i)
+				i += size
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: source[start:i], pos: start})
+
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(source[i:], op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				r, _ := decodeRune(source, i)
+				return nil, syntaxError(i, "unexpected character %q", r)
+			}
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(source)}), nil
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func decodeRune(s string, i int) (rune, int) {
+	return utf8.DecodeRuneInString(s[i:])
+}
+
+func isIdentStart(s string, i int) bool {
+	r, _ := decodeRune(s, i)
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(s string, i int) bool {
+	r, _ := decodeRune(s, i)
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// charPos converts a byte offset into a 1-based character position
+func charPos(source string, offset int) int {
+	if offset > len(source) {
+		offset = len(source)
+	}
+	return len([]rune(source[:offset])) + 1
+}
+
+type parser struct {
+	source  string
+	tokens  []token
+	pos     int
+	columns []*columnNode
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(n int) token {
+	if p.pos+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+n]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return &ExpressionError{
+		Expression: p.source,
+		Pos:        charPos(p.source, tok.pos),
+		Msg:        fmt.Sprintf(format, args...),
+	}
+}
+
+// expect consumes the given operator or keyword or reports what was found
+func (p *parser) expect(text string) (token, error) {
+	tok := p.next()
+	if !tok.is(text) {
+		return tok, p.errorf(tok, "expected '%s', found %s", text, tok.describe())
+	}
+	return tok, nil
+}
+
+func (p *parser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (exprNode, error) {
+	if p.peek().is("NOT") {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+var comparisonOperators = map[string]bool{
+	"=": true, "==": true, "!=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true,
+}
+
+func (p *parser) parseComparison() (exprNode, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	switch {
+	case tok.kind == tokenOperator && comparisonOperators[tok.text]:
+		p.next()
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		return &compareNode{op: tok.text, left: left, right: right}, nil
+
+	case tok.is("~") || tok.is("!~"):
+		p.next()
+		return p.parsePattern(left, tok.is("!~"), matchRegexp)
+
+	case tok.is("IS"):
+		p.next()
+		negate := false
+		if p.peek().is("NOT") {
+			p.next()
+			negate = true
+		}
+		if _, err := p.expect("NULL"); err != nil {
+			return nil, err
+		}
+		return &isNullNode{operand: left, negate: negate}, nil
+	}
+
+	negate := false
+	if tok.is("NOT") {
+		following := p.peekAt(1)
+		if !following.is("LIKE") && !following.is("ILIKE") && !following.is("IN") && !following.is("BETWEEN") {
+			return left, nil
+		}
+		p.next()
+		negate = true
+		tok = p.peek()
+	}
+
+	switch {
+	case tok.is("LIKE"):
+		p.next()
+		return p.parsePattern(left, negate, matchLike)
+	case tok.is("ILIKE"):
+		p.next()
+		return p.parsePattern(left, negate, matchILike)
+	case tok.is("IN"):
+		p.next()
+		return p.parseIn(left, negate)
+	case tok.is("BETWEEN"):
+		p.next()
+		low, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		return &betweenNode{operand: left, low: low, high: high, negate: negate}, nil
+	}
+
+	return left, nil
+}
+
+// parsePattern parses the right-hand side of LIKE, ILIKE, ~ and !~. Literal
+// patterns are compiled once here so that mistakes are reported up front.
+func (p *parser) parsePattern(left exprNode, negate bool, kind matchKind) (exprNode, error) {
+	patternTok := p.peek()
+	pattern, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	node := &matchNode{operand: left, pattern: pattern, kind: kind, negate: negate}
+	if lit, ok := pattern.(*literalNode); ok {
+		if text, ok := lit.value.(string); ok {
+			re, err := compilePattern(text, kind)
+			if err != nil {
+				return nil, p.errorf(patternTok, "invalid pattern: %v", err)
+			}
+			node.compiled = re
+		}
+	}
+	return node, nil
+}
+
+func (p *parser) parseIn(left exprNode, negate bool) (exprNode, error) {
+	open := p.next()
+	var closing string
+	switch {
+	case open.is("("):
+		closing = ")"
+	case open.is("["):
+		closing = "]"
+	default:
+		return nil, p.errorf(open, "expected '(' or '[' after IN, found %s", open.describe())
+	}
+
+	var values []exprNode
+	for {
+		value, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		if p.peek().is(",") {
+			p.next()
+			continue
+		}
+		if _, err := p.expect(closing); err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	return &inNode{operand: left, values: values, negate: negate}, nil
+}
+
+func (p *parser) parseSum() (exprNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if !tok.is("+") && !tok.is("-") && !tok.is("||") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticNode{op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseProduct() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if !tok.is("*") && !tok.is("/") && !tok.is("%") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticNode{op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (exprNode, error) {
+	tok := p.peek()
+	if tok.is("-") || tok.is("+") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if tok.is("+") {
+			return &arithmeticNode{op: "+", left: &literalNode{value: int64(0)}, right: operand}, nil
+		}
+		return &negateNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (exprNode, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenNumber:
+		value, ok := parseNumber(tok.text)
+		if !ok {
+			return nil, p.errorf(tok, "invalid number %s", tok.text)
+		}
+		return &literalNode{value: value}, nil
+
+	case tokenString:
+		return &literalNode{value: tok.text}, nil
+
+	case tokenQuotedIdent:
+		return p.column(tok), nil
+
+	case tokenIdent:
+		switch strings.ToUpper(tok.text) {
+		case "TRUE":
+			return &literalNode{value: true}, nil
+		case "FALSE":
+			return &literalNode{value: false}, nil
+		case "NULL":
+			return &literalNode{value: nil}, nil
+		case "CASE":
+			return p.parseCase()
+		}
+		if isKeyword(tok) {
+			return nil, p.errorf(tok, "unexpected keyword %s", strings.ToUpper(tok.text))
+		}
+		if p.peek().is("(") {
+			return p.parseCall(tok)
+		}
+		return p.column(tok), nil
+
+	case tokenOperator:
+		if tok.text == "(" {
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+	}
+
+	return nil, p.errorf(tok, "expected a value, found %s", tok.describe())
+}
+
+// column returns a reference to the column named by tok
+func (p *parser) column(tok token) exprNode {
+	node := &columnNode{name: tok.text, pos: charPos(p.source, tok.pos)}
+	p.columns = append(p.columns, node)
+	return node
+}
+
+func (p *parser) parseCall(name token) (exprNode, error) {
+	p.next() // (
+
+	var args []exprNode
+	if p.peek().is(")") {
+		p.next()
+	} else {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			if p.peek().is(",") {
+				p.next()
+				continue
+			}
+			if _, err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	fnName := strings.ToLower(name.text)
+
+	// IF evaluates only the branch it returns, so it is a conditional rather
+	// than a function
+	if fnName == "if" {
+		if len(args) != 3 {
+			return nil, p.errorf(name, "if expects 3 arguments, got %d", len(args))
+		}
+		return &caseNode{
+			whens:    []whenClause{{condition: args[0], result: args[1]}},
+			elseExpr: args[2],
+		}, nil
+	}
+
+	fn, ok := builtinFunctions[fnName]
+	if !ok {
+		return nil, p.errorf(name, "unknown function %s", name.text)
+	}
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, p.errorf(name, "%s expects %s, got %d", fnName, fn.arity(), len(args))
+	}
+
+	return &callNode{name: fnName, fn: fn, args: args}, nil
+}
+
+// parseCase parses both CASE WHEN cond THEN ... END and the simple form
+// CASE operand WHEN value THEN ... END
+func (p *parser) parseCase() (exprNode, error) {
+	node := &caseNode{}
+
+	if !p.peek().is("WHEN") {
+		operand, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		node.operand = operand
+	}
+
+	for p.peek().is("WHEN") {
+		p.next()
+		condition, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect("THEN"); err != nil {
+			return nil, err
+		}
+		result, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		node.whens = append(node.whens, whenClause{condition: condition, result: result})
+	}
+
+	if len(node.whens) == 0 {
+		tok := p.peek()
+		return nil, p.errorf(tok, "expected WHEN, found %s", tok.describe())
+	}
+
+	if p.peek().is("ELSE") {
+		p.next()
+		elseExpr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		node.elseExpr = elseExpr
+	}
+
+	if _, err := p.expect("END"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+type matchKind int
+
+const (
+	matchLike matchKind = iota
+	matchILike
+	matchRegexp
+)
+
+// compilePattern turns a LIKE pattern (% and _ wildcards, \ escapes) or a
+// regular expression into a compiled regexp
+func compilePattern(pattern string, kind matchKind) (*regexp.Regexp, error) {
+	if kind == matchRegexp {
+		return regexp.Compile(pattern)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("(?s)")
+	if kind == matchILike {
+		sb.WriteString("(?i)")
+	}
+	sb.WriteString("^")
+
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			sb.WriteString(".*")
+		case r == '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("pattern ends with an escape character")
+	}
+
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
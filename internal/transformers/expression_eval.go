@@ -0,0 +1,589 @@
+package transformers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"brokolisql-go/pkg/common"
+)
+
+// exprNode is a node of a parsed expression. NULL is represented by nil and
+// propagates through operators and most functions as it does in SQL.
+type exprNode interface {
+	eval(row common.DataRow) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(row common.DataRow) (interface{}, error) {
+	return n.value, nil
+}
+
+type columnNode struct {
+	name string
+	pos  int // 1-based character position in the source
+}
+
+func (n *columnNode) eval(row common.DataRow) (interface{}, error) {
+	return row[n.name], nil
+}
+
+// logicalNode implements AND and OR with SQL three-valued logic
+type logicalNode struct {
+	and         bool
+	left, right exprNode
+}
+
+func (n *logicalNode) eval(row common.DataRow) (interface{}, error) {
+	left, err := n.left.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	leftBool, leftKnown := toBool(left)
+
+	// Short-circuit: false AND x is false, true OR x is true
+	if leftKnown && leftBool != n.and {
+		return leftBool, nil
+	}
+
+	right, err := n.right.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	rightBool, rightKnown := toBool(right)
+
+	if rightKnown && rightBool != n.and {
+		return rightBool, nil
+	}
+	if !leftKnown || !rightKnown {
+		return nil, nil
+	}
+	return n.and, nil
+}
+
+type notNode struct {
+	operand exprNode
+}
+
+func (n *notNode) eval(row common.DataRow) (interface{}, error) {
+	value, err := n.operand.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	b, known := toBool(value)
+	if !known {
+		return nil, nil
+	}
+	return !b, nil
+}
+
+type negateNode struct {
+	operand exprNode
+}
+
+func (n *negateNode) eval(row common.DataRow) (interface{}, error) {
+	value, err := n.operand.eval(row)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	num, ok := toNumber(value)
+	if !ok {
+		return nil, fmt.Errorf("cannot negate %s", describeValue(value))
+	}
+	if i, ok := num.(int64); ok {
+		return -i, nil
+	}
+	return -num.(float64), nil
+}
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *compareNode) eval(row common.DataRow) (interface{}, error) {
+	left, err := n.left.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	cmp := compareValues(left, right)
+	switch n.op {
+	case "=", "==":
+		return cmp == 0, nil
+	case "!=", "<>":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default: // ">="
+		return cmp >= 0, nil
+	}
+}
+
+type isNullNode struct {
+	operand exprNode
+	negate  bool
+}
+
+func (n *isNullNode) eval(row common.DataRow) (interface{}, error) {
+	value, err := n.operand.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	return (value == nil) != n.negate, nil
+}
+
+// matchNode implements LIKE, ILIKE and regular expression matching
+type matchNode struct {
+	operand  exprNode
+	pattern  exprNode
+	kind     matchKind
+	negate   bool
+	compiled *regexp.Regexp // set when the pattern is a literal
+}
+
+func (n *matchNode) eval(row common.DataRow) (interface{}, error) {
+	value, err := n.operand.eval(row)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	re := n.compiled
+	if re == nil {
+		pattern, err := n.pattern.eval(row)
+		if err != nil || pattern == nil {
+			return nil, err
+		}
+		re, err = compilePattern(toString(pattern), n.kind)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", toString(pattern), err)
+		}
+	}
+
+	return re.MatchString(toString(value)) != n.negate, nil
+}
+
+type inNode struct {
+	operand exprNode
+	values  []exprNode
+	negate  bool
+}
+
+func (n *inNode) eval(row common.DataRow) (interface{}, error) {
+	value, err := n.operand.eval(row)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	sawNull := false
+	for _, candidate := range n.values {
+		v, err := candidate.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			sawNull = true
+			continue
+		}
+		if compareValues(value, v) == 0 {
+			return !n.negate, nil
+		}
+	}
+
+	if sawNull {
+		return nil, nil
+	}
+	return n.negate, nil
+}
+
+type betweenNode struct {
+	operand, low, high exprNode
+	negate             bool
+}
+
+func (n *betweenNode) eval(row common.DataRow) (interface{}, error) {
+	value, err := n.operand.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	low, err := n.low.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	high, err := n.high.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil || low == nil || high == nil {
+		return nil, nil
+	}
+
+	inRange := compareValues(value, low) >= 0 && compareValues(value, high) <= 0
+	return inRange != n.negate, nil
+}
+
+// arithmeticNode implements + - * / % and ||. + adds when both operands are
+// numbers (or numeric strings) and concatenates otherwise. A blank string
+// next to a number is an empty cell and yields NULL like a NULL operand.
+type arithmeticNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *arithmeticNode) eval(row common.DataRow) (interface{}, error) {
+	left, err := n.left.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	if n.op == "||" {
+		return toString(left) + toString(right), nil
+	}
+
+	leftNum, leftOK := toNumber(left)
+	rightNum, rightOK := toNumber(right)
+	if !leftOK || !rightOK {
+		if (leftOK || rightOK || n.op != "+") && (isBlank(left) || isBlank(right)) {
+			return nil, nil
+		}
+		if n.op == "+" {
+			return toString(left) + toString(right), nil
+		}
+		bad := left
+		if leftOK {
+			bad = right
+		}
+		return nil, fmt.Errorf("cannot apply '%s' to %s", n.op, describeValue(bad))
+	}
+
+	return arithmetic(n.op, leftNum, rightNum)
+}
+
+// arithmetic applies op to two numbers. Integer operands stay integers unless
+// the result does not fit or a division is inexact.
+func arithmetic(op string, left, right interface{}) (interface{}, error) {
+	li, leftInt := left.(int64)
+	ri, rightInt := right.(int64)
+
+	if leftInt && rightInt {
+		switch op {
+		case "+":
+			if sum := li + ri; (sum > li) == (ri > 0) {
+				return sum, nil
+			}
+		case "-":
+			if diff := li - ri; (diff < li) == (ri > 0) {
+				return diff, nil
+			}
+		case "*":
+			if li == 0 || ri == 0 {
+				return int64(0), nil
+			}
+			if product := li * ri; product/ri == li && !(li == -1 && ri == math.MinInt64) && !(ri == -1 && li == math.MinInt64) {
+				return product, nil
+			}
+		case "/":
+			if ri == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			if li%ri == 0 {
+				return li / ri, nil
+			}
+		case "%":
+			if ri == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return li % ri, nil
+		}
+	}
+
+	lf, rf := toFloat(left), toFloat(right)
+	switch op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return lf / rf, nil
+	default: // "%"
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(lf, rf), nil
+	}
+}
+
+type whenClause struct {
+	condition exprNode
+	result    exprNode
+}
+
+// caseNode implements CASE and if(). With an operand, each WHEN value is
+// compared to it; without one, each WHEN is a condition.
+type caseNode struct {
+	operand  exprNode
+	whens    []whenClause
+	elseExpr exprNode
+}
+
+func (n *caseNode) eval(row common.DataRow) (interface{}, error) {
+	var operand interface{}
+	if n.operand != nil {
+		var err error
+		operand, err = n.operand.eval(row)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, when := range n.whens {
+		value, err := when.condition.eval(row)
+		if err != nil {
+			return nil, err
+		}
+
+		matched := false
+		if n.operand != nil {
+			matched = operand != nil && value != nil && compareValues(operand, value) == 0
+		} else {
+			matched = isTrue(value)
+		}
+
+		if matched {
+			return when.result.eval(row)
+		}
+	}
+
+	if n.elseExpr != nil {
+		return n.elseExpr.eval(row)
+	}
+	return nil, nil
+}
+
+type callNode struct {
+	name string
+	fn   *builtinFunction
+	args []exprNode
+}
+
+func (n *callNode) eval(row common.DataRow) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil && !n.fn.acceptsNull {
+			return nil, nil
+		}
+		args[i] = value
+	}
+
+	result, err := n.fn.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return result, nil
+}
+
+// isTrue reports whether a condition result selects the row
+func isTrue(value interface{}) bool {
+	b, known := toBool(value)
+	return known && b
+}
+
+// toBool converts a value to a boolean. The second result is false for NULL
+// and for values that have no boolean meaning.
+func toBool(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case nil:
+		return false, false
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return false, false
+		}
+		return b, true
+	}
+
+	if num, ok := toNumber(value); ok {
+		return toFloat(num) != 0, true
+	}
+	return false, false
+}
+
+// isBlank reports whether value is a string of only whitespace
+func isBlank(value interface{}) bool {
+	s, ok := value.(string)
+	return ok && strings.TrimSpace(s) == ""
+}
+
+// toNumber converts a value to int64 or float64. Strings are converted when
+// they hold a number, so that CSV columns can take part in arithmetic.
+func toNumber(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return uintToNumber(uint64(v)), true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return uintToNumber(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		return parseNumber(v.String())
+	case string:
+		return parseNumber(strings.TrimSpace(v))
+	}
+	return nil, false
+}
+
+func uintToNumber(v uint64) interface{} {
+	if v > math.MaxInt64 {
+		return float64(v)
+	}
+	return int64(v)
+}
+
+// parseNumber parses an integer or decimal number. Infinity and NaN are not
+// treated as numbers.
+func parseNumber(s string) (interface{}, bool) {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, false
+	}
+	for _, r := range s {
+		if r != '.' && r != '-' && r != '+' && r != 'e' && r != 'E' && (r < '0' || r > '9') {
+			return nil, false
+		}
+	}
+	return f, true
+}
+
+func toFloat(num interface{}) float64 {
+	if i, ok := num.(int64); ok {
+		return float64(i)
+	}
+	return num.(float64)
+}
+
+// toString formats a value the way it would appear in text output
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// compareValues orders two non-NULL values. Numbers, and strings holding
+// numbers, compare numerically; booleans compare false before true; anything
+// else compares as text.
+func compareValues(a, b interface{}) int {
+	if aNum, ok := toNumber(a); ok {
+		if bNum, ok := toNumber(b); ok {
+			ai, aInt := aNum.(int64)
+			bi, bInt := bNum.(int64)
+			if aInt && bInt {
+				return compareOrdered(ai, bi)
+			}
+			return compareOrdered(toFloat(aNum), toFloat(bNum))
+		}
+	}
+
+	if aBool, ok := a.(bool); ok {
+		if bBool, known := toBool(b); known {
+			return compareOrdered(boolRank(aBool), boolRank(bBool))
+		}
+	}
+	if bBool, ok := b.(bool); ok {
+		if aBool, known := toBool(a); known {
+			return compareOrdered(boolRank(aBool), boolRank(bBool))
+		}
+	}
+
+	return strings.Compare(toString(a), toString(b))
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func compareOrdered[T int | int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// describeValue quotes strings in error messages so that blanks are visible
+func describeValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return toString(value)
+}
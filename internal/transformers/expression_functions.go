@@ -0,0 +1,372 @@
+package transformers
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// builtinFunction is a function callable from expressions. Unless acceptsNull
+// is set, a NULL argument makes the result NULL without calling the function.
+type builtinFunction struct {
+	minArgs     int
+	maxArgs     int // -1 for variadic functions
+	acceptsNull bool
+	call        func(args []interface{}) (interface{}, error)
+}
+
+func (f *builtinFunction) arity() string {
+	switch {
+	case f.maxArgs < 0:
+		return fmt.Sprintf("at least %d arguments", f.minArgs)
+	case f.minArgs == f.maxArgs && f.minArgs == 1:
+		return "1 argument"
+	case f.minArgs == f.maxArgs:
+		return fmt.Sprintf("%d arguments", f.minArgs)
+	default:
+		return fmt.Sprintf("%d to %d arguments", f.minArgs, f.maxArgs)
+	}
+}
+
+// builtinFunctions maps lower-case function names to their implementation.
+// if(cond, a, b) is handled by the parser because only one branch is
+// evaluated.
+var builtinFunctions = map[string]*builtinFunction{
+	// String functions
+	"lower":          stringFunction(strings.ToLower),
+	"upper":          stringFunction(strings.ToUpper),
+	"trim":           stringFunction(strings.TrimSpace),
+	"ltrim":          stringFunction(func(s string) string { return strings.TrimLeft(s, " \t\r\n") }),
+	"rtrim":          stringFunction(func(s string) string { return strings.TrimRight(s, " \t\r\n") }),
+	"length":         {minArgs: 1, maxArgs: 1, call: fnLength},
+	"substr":         {minArgs: 2, maxArgs: 3, call: fnSubstr},
+	"substring":      {minArgs: 2, maxArgs: 3, call: fnSubstr},
+	"left":           {minArgs: 2, maxArgs: 2, call: fnLeft},
+	"right":          {minArgs: 2, maxArgs: 2, call: fnRight},
+	"lpad":           {minArgs: 2, maxArgs: 3, call: fnPad(true)},
+	"rpad":           {minArgs: 2, maxArgs: 3, call: fnPad(false)},
+	"replace":        {minArgs: 3, maxArgs: 3, call: fnReplace},
+	"regexp_replace": {minArgs: 3, maxArgs: 3, call: fnRegexpReplace},
+	"split_part":     {minArgs: 3, maxArgs: 3, call: fnSplitPart},
+	"starts_with":    {minArgs: 2, maxArgs: 2, call: stringPredicate(strings.HasPrefix)},
+	"ends_with":      {minArgs: 2, maxArgs: 2, call: stringPredicate(strings.HasSuffix)},
+	"contains":       {minArgs: 2, maxArgs: 2, call: stringPredicate(strings.Contains)},
+	"concat":         {minArgs: 1, maxArgs: -1, acceptsNull: true, call: fnConcat},
+
+	// NULL handling
+	"coalesce": {minArgs: 1, maxArgs: -1, acceptsNull: true, call: fnCoalesce},
+	"nullif":   {minArgs: 2, maxArgs: 2, acceptsNull: true, call: fnNullIf},
+
+	// Math functions
+	"abs":      mathFunction(math.Abs),
+	"floor":    mathFunction(math.Floor),
+	"ceil":     mathFunction(math.Ceil),
+	"ceiling":  mathFunction(math.Ceil),
+	"sqrt":     {minArgs: 1, maxArgs: 1, call: fnSqrt},
+	"round":    {minArgs: 1, maxArgs: 2, call: fnRound},
+	"power":    {minArgs: 2, maxArgs: 2, call: fnPower},
+	"pow":      {minArgs: 2, maxArgs: 2, call: fnPower},
+	"mod":      {minArgs: 2, maxArgs: 2, call: fnMod},
+	"least":    {minArgs: 1, maxArgs: -1, call: fnExtreme(-1)},
+	"greatest": {minArgs: 1, maxArgs: -1, call: fnExtreme(1)},
+
+	// Conversions
+	"to_number": {minArgs: 1, maxArgs: 1, call: fnToNumber},
+	"to_string": {minArgs: 1, maxArgs: 1, call: func(args []interface{}) (interface{}, error) {
+		return toString(args[0]), nil
+	}},
+}
+
+func stringFunction(fn func(string) string) *builtinFunction {
+	return &builtinFunction{minArgs: 1, maxArgs: 1, call: func(args []interface{}) (interface{}, error) {
+		return fn(toString(args[0])), nil
+	}}
+}
+
+func stringPredicate(fn func(s, substr string) bool) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		return fn(toString(args[0]), toString(args[1])), nil
+	}
+}
+
+// mathFunction wraps a float function, keeping integers as integers when the
+// result is whole
+func mathFunction(fn func(float64) float64) *builtinFunction {
+	return &builtinFunction{minArgs: 1, maxArgs: 1, call: func(args []interface{}) (interface{}, error) {
+		num, err := numberArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := num.(int64); ok {
+			if result := fn(float64(i)); result == math.Trunc(result) && math.Abs(result) < 1<<62 {
+				return int64(result), nil
+			}
+		}
+		return fn(toFloat(num)), nil
+	}}
+}
+
+func numberArg(value interface{}) (interface{}, error) {
+	num, ok := toNumber(value)
+	if !ok {
+		return nil, fmt.Errorf("expected a number, got %s", describeValue(value))
+	}
+	return num, nil
+}
+
+func intArg(value interface{}) (int, error) {
+	num, err := numberArg(value)
+	if err != nil {
+		return 0, err
+	}
+	f := toFloat(num)
+	if f != math.Trunc(f) {
+		return 0, fmt.Errorf("expected a whole number, got %s", describeValue(value))
+	}
+	return int(f), nil
+}
+
+func fnLength(args []interface{}) (interface{}, error) {
+	return int64(utf8.RuneCountInString(toString(args[0]))), nil
+}
+
+// fnSubstr implements substr(s, start[, length]) with a 1-based start
+func fnSubstr(args []interface{}) (interface{}, error) {
+	runes := []rune(toString(args[0]))
+	start, err := intArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	end := len(runes)
+	if len(args) == 3 {
+		length, err := intArg(args[2])
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, fmt.Errorf("negative length %d", length)
+		}
+		end = start - 1 + length
+	}
+
+	begin := start - 1
+	if begin < 0 {
+		begin = 0
+	}
+	if end > len(runes) {
+		end = len(runes)
+	}
+	if begin >= end {
+		return "", nil
+	}
+	return string(runes[begin:end]), nil
+}
+
+func fnLeft(args []interface{}) (interface{}, error) {
+	runes := []rune(toString(args[0]))
+	n, err := intArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	n = clamp(n, 0, len(runes))
+	return string(runes[:n]), nil
+}
+
+func fnRight(args []interface{}) (interface{}, error) {
+	runes := []rune(toString(args[0]))
+	n, err := intArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	n = clamp(n, 0, len(runes))
+	return string(runes[len(runes)-n:]), nil
+}
+
+func clamp(n, low, high int) int {
+	if n < low {
+		return low
+	}
+	if n > high {
+		return high
+	}
+	return n
+}
+
+// fnPad implements lpad and rpad(s, length[, fill]). Strings longer than
+// length are truncated, as in PostgreSQL.
+func fnPad(left bool) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		runes := []rune(toString(args[0]))
+		length, err := intArg(args[1])
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			length = 0
+		}
+
+		fill := []rune(" ")
+		if len(args) == 3 {
+			fill = []rune(toString(args[2]))
+		}
+
+		if len(runes) >= length {
+			return string(runes[:length]), nil
+		}
+		if len(fill) == 0 {
+			return string(runes), nil
+		}
+
+		padding := make([]rune, 0, length-len(runes))
+		for len(padding) < length-len(runes) {
+			padding = append(padding, fill[len(padding)%len(fill)])
+		}
+		if left {
+			return string(padding) + string(runes), nil
+		}
+		return string(runes) + string(padding), nil
+	}
+}
+
+func fnReplace(args []interface{}) (interface{}, error) {
+	return strings.ReplaceAll(toString(args[0]), toString(args[1]), toString(args[2])), nil
+}
+
+func fnRegexpReplace(args []interface{}) (interface{}, error) {
+	re, err := regexp.Compile(toString(args[1]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", toString(args[1]), err)
+	}
+	return re.ReplaceAllString(toString(args[0]), toString(args[2])), nil
+}
+
+// fnSplitPart implements split_part(s, delimiter, n) with a 1-based n
+func fnSplitPart(args []interface{}) (interface{}, error) {
+	n, err := intArg(args[2])
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 {
+		return nil, fmt.Errorf("field position must be greater than zero")
+	}
+
+	parts := strings.Split(toString(args[0]), toString(args[1]))
+	if n > len(parts) {
+		return "", nil
+	}
+	return parts[n-1], nil
+}
+
+// fnConcat concatenates its arguments, skipping NULLs
+func fnConcat(args []interface{}) (interface{}, error) {
+	var sb strings.Builder
+	for _, arg := range args {
+		sb.WriteString(toString(arg))
+	}
+	return sb.String(), nil
+}
+
+func fnCoalesce(args []interface{}) (interface{}, error) {
+	for _, arg := range args {
+		if arg != nil {
+			return arg, nil
+		}
+	}
+	return nil, nil
+}
+
+func fnNullIf(args []interface{}) (interface{}, error) {
+	if args[0] != nil && args[1] != nil && compareValues(args[0], args[1]) == 0 {
+		return nil, nil
+	}
+	return args[0], nil
+}
+
+func fnSqrt(args []interface{}) (interface{}, error) {
+	num, err := numberArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	f := toFloat(num)
+	if f < 0 {
+		return nil, fmt.Errorf("cannot take the square root of a negative number")
+	}
+	return math.Sqrt(f), nil
+}
+
+// fnRound implements round(x[, digits]), rounding halves away from zero
+func fnRound(args []interface{}) (interface{}, error) {
+	num, err := numberArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	digits := 0
+	if len(args) == 2 {
+		if digits, err = intArg(args[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	if i, ok := num.(int64); ok && digits >= 0 {
+		return i, nil
+	}
+
+	scale := math.Pow(10, float64(digits))
+	rounded := math.Round(toFloat(num)*scale) / scale
+	if digits <= 0 && math.Abs(rounded) < 1<<62 {
+		return int64(rounded), nil
+	}
+	return rounded, nil
+}
+
+func fnPower(args []interface{}) (interface{}, error) {
+	base, err := numberArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	exponent, err := numberArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	result := math.Pow(toFloat(base), toFloat(exponent))
+	_, baseInt := base.(int64)
+	_, exponentInt := exponent.(int64)
+	if baseInt && exponentInt && result == math.Trunc(result) && math.Abs(result) < 1<<62 {
+		return int64(result), nil
+	}
+	return result, nil
+}
+
+func fnMod(args []interface{}) (interface{}, error) {
+	left, err := numberArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	right, err := numberArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return arithmetic("%", left, right)
+}
+
+// fnExtreme implements least (sign -1) and greatest (sign 1)
+func fnExtreme(sign int) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		best := args[0]
+		for _, arg := range args[1:] {
+			if compareValues(arg, best)*sign > 0 {
+				best = arg
+			}
+		}
+		return best, nil
+	}
+}
+
+func fnToNumber(args []interface{}) (interface{}, error) {
+	return numberArg(args[0])
+}
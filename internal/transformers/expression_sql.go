@@ -0,0 +1,324 @@
+package transformers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"brokolisql-go/pkg/dialects"
+)
+
+// ErrNoSQLEquivalent is wrapped by the errors of Expression.SQL for
+// constructs the dialect has no equivalent for
+var ErrNoSQLEquivalent = errors.New("cannot be written as SQL")
+
+// sqlFunctions maps the functions that have the same meaning in SQL onto
+// their SQL name. Functions missing here cannot be written as SQL.
+var sqlFunctions = map[string]string{
+	"lower":    "LOWER",
+	"upper":    "UPPER",
+	"trim":     "TRIM",
+	"ltrim":    "LTRIM",
+	"rtrim":    "RTRIM",
+	"length":   "LENGTH",
+	"replace":  "REPLACE",
+	"coalesce": "COALESCE",
+	"nullif":   "NULLIF",
+	"abs":      "ABS",
+	"round":    "ROUND",
+	"floor":    "FLOOR",
+	"ceil":     "CEIL",
+	"ceiling":  "CEIL",
+}
+
+// SQL writes the expression as an SQL condition in dialect, for use in a
+// CHECK constraint. column maps each column the expression refers to onto its
+// name in the table. Constructs the dialect has no equivalent for, such as
+// regular expressions in SQLite, are reported as errors wrapping
+// ErrNoSQLEquivalent.
+func (e *Expression) SQL(dialect dialects.Dialect, column func(name string) (string, error)) (string, error) {
+	w := &sqlWriter{dialect: dialect, name: dialect.Name(), column: column}
+	return w.write(e.root)
+}
+
+type sqlWriter struct {
+	dialect dialects.Dialect
+	name    string
+	column  func(name string) (string, error)
+}
+
+func (w *sqlWriter) write(node exprNode) (string, error) {
+	switch n := node.(type) {
+	case *literalNode:
+		return w.dialect.FormatValue(n.value), nil
+
+	case *columnNode:
+		name, err := w.column(n.name)
+		if err != nil {
+			return "", err
+		}
+		return w.dialect.QuoteIdentifier(name), nil
+
+	case *logicalNode:
+		op := " OR "
+		if n.and {
+			op = " AND "
+		}
+		return w.join(op, n.left, n.right)
+
+	case *notNode:
+		operand, err := w.operand(n.operand)
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + operand, nil
+
+	case *negateNode:
+		operand, err := w.operand(n.operand)
+		if err != nil {
+			return "", err
+		}
+		return "-" + operand, nil
+
+	case *compareNode:
+		switch n.op {
+		case "==":
+			return w.join(" = ", n.left, n.right)
+		case "!=":
+			return w.join(" <> ", n.left, n.right)
+		}
+		return w.join(" "+n.op+" ", n.left, n.right)
+
+	case *isNullNode:
+		operand, err := w.operand(n.operand)
+		if err != nil {
+			return "", err
+		}
+		if n.negate {
+			return operand + " IS NOT NULL", nil
+		}
+		return operand + " IS NULL", nil
+
+	case *matchNode:
+		return w.match(n)
+
+	case *inNode:
+		operand, err := w.operand(n.operand)
+		if err != nil {
+			return "", err
+		}
+		values, err := w.list(n.values)
+		if err != nil {
+			return "", err
+		}
+		if n.negate {
+			return operand + " NOT IN (" + values + ")", nil
+		}
+		return operand + " IN (" + values + ")", nil
+
+	case *betweenNode:
+		parts, err := w.operands(n.operand, n.low, n.high)
+		if err != nil {
+			return "", err
+		}
+		op := " BETWEEN "
+		if n.negate {
+			op = " NOT BETWEEN "
+		}
+		return parts[0] + op + parts[1] + " AND " + parts[2], nil
+
+	case *arithmeticNode:
+		return w.arithmetic(n.op, n.left, n.right)
+
+	case *caseNode:
+		return w.caseExpr(n)
+
+	case *callNode:
+		return w.call(n)
+	}
+
+	return "", fmt.Errorf("cannot write %T as SQL", node)
+}
+
+// operand writes node, parenthesised when it is compound
+func (w *sqlWriter) operand(node exprNode) (string, error) {
+	sql, err := w.write(node)
+	if err != nil {
+		return "", err
+	}
+	if w.compound(node) {
+		return "(" + sql + ")", nil
+	}
+	return sql, nil
+}
+
+// compound reports whether node is written with operators that bind less
+// tightly than a function call
+func (w *sqlWriter) compound(node exprNode) bool {
+	switch n := node.(type) {
+	case *literalNode, *columnNode, *caseNode:
+		return false
+	case *negateNode:
+		_, literal := n.operand.(*literalNode)
+		return !literal
+	case *callNode:
+		return n.name == "mod" && w.name != "oracle"
+	case *arithmeticNode:
+		return !(n.op == "||" && w.name == "mysql") && !(n.op == "%" && w.name == "oracle")
+	}
+	return true
+}
+
+func (w *sqlWriter) operands(nodes ...exprNode) ([]string, error) {
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		sql, err := w.operand(node)
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = sql
+	}
+	return parts, nil
+}
+
+func (w *sqlWriter) join(op string, left, right exprNode) (string, error) {
+	parts, err := w.operands(left, right)
+	if err != nil {
+		return "", err
+	}
+	return parts[0] + op + parts[1], nil
+}
+
+func (w *sqlWriter) list(nodes []exprNode) (string, error) {
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		sql, err := w.write(node)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = sql
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+func (w *sqlWriter) arithmetic(op string, left, right exprNode) (string, error) {
+	switch {
+	case op == "||" && w.name == "mysql":
+		return w.function("CONCAT", left, right)
+	case op == "||" && w.name == "sqlserver":
+		op = "+"
+	case op == "%" && w.name == "oracle":
+		return w.function("MOD", left, right)
+	}
+	return w.join(" "+op+" ", left, right)
+}
+
+func (w *sqlWriter) match(n *matchNode) (string, error) {
+	parts, err := w.operands(n.operand, n.pattern)
+	if err != nil {
+		return "", err
+	}
+	operand, pattern := parts[0], parts[1]
+
+	not := ""
+	if n.negate {
+		not = "NOT "
+	}
+
+	switch n.kind {
+	case matchRegexp:
+		switch w.name {
+		case "postgresql":
+			if n.negate {
+				return operand + " !~ " + pattern, nil
+			}
+			return operand + " ~ " + pattern, nil
+		case "mysql":
+			return not + "(" + operand + " REGEXP " + pattern + ")", nil
+		case "oracle":
+			return not + "REGEXP_LIKE(" + operand + ", " + pattern + ")", nil
+		}
+		return "", fmt.Errorf("regular expressions %w for %s", ErrNoSQLEquivalent, w.name)
+
+	case matchILike:
+		if w.name == "postgresql" {
+			return operand + " " + not + "ILIKE " + pattern, nil
+		}
+		operand, pattern = "LOWER("+operand+")", "LOWER("+pattern+")"
+	}
+
+	sql := operand + " " + not + "LIKE " + pattern
+
+	// Patterns escape wildcards with a backslash, which only PostgreSQL and
+	// MySQL take as the escape character without being told
+	if w.name != "postgresql" && w.name != "mysql" {
+		if literal, ok := n.pattern.(*literalNode); !ok || strings.Contains(toString(literal.value), `\`) {
+			sql += ` ESCAPE '\'`
+		}
+	}
+	return sql, nil
+}
+
+func (w *sqlWriter) caseExpr(n *caseNode) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("CASE")
+	if n.operand != nil {
+		operand, err := w.operand(n.operand)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(" " + operand)
+	}
+	for _, when := range n.whens {
+		condition, err := w.write(when.condition)
+		if err != nil {
+			return "", err
+		}
+		result, err := w.write(when.result)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(" WHEN " + condition + " THEN " + result)
+	}
+	if n.elseExpr != nil {
+		elseSQL, err := w.write(n.elseExpr)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(" ELSE " + elseSQL)
+	}
+	sb.WriteString(" END")
+	return sb.String(), nil
+}
+
+func (w *sqlWriter) call(n *callNode) (string, error) {
+	if n.name == "mod" {
+		return w.arithmetic("%", n.args[0], n.args[1])
+	}
+
+	name, ok := sqlFunctions[n.name]
+	if !ok {
+		return "", fmt.Errorf("function %s %w", n.name, ErrNoSQLEquivalent)
+	}
+	switch {
+	case name == "LENGTH" && w.name == "sqlserver":
+		name = "LEN"
+	case name == "LENGTH" && w.name == "mysql":
+		// LENGTH counts bytes in MySQL
+		name = "CHAR_LENGTH"
+	case name == "CEIL" && w.name == "sqlserver":
+		name = "CEILING"
+	case name == "ROUND" && w.name == "sqlserver" && len(n.args) == 1:
+		// SQL Server requires the number of decimals
+		return w.function(name, n.args[0], &literalNode{value: int64(0)})
+	}
+	return w.function(name, n.args...)
+}
+
+func (w *sqlWriter) function(name string, args ...exprNode) (string, error) {
+	list, err := w.list(args)
+	if err != nil {
+		return "", err
+	}
+	return name + "(" + list + ")", nil
+}
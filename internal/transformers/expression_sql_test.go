@@ -0,0 +1,92 @@
+package transformers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"brokolisql-go/pkg/dialects"
+)
+
+func TestExpression_SQL(t *testing.T) {
+	// Columns are renamed to show that the table's names are used
+	column := func(name string) (string, error) {
+		if name == "missing" {
+			return "", fmt.Errorf("unknown column %s", name)
+		}
+		return strings.ToUpper(name), nil
+	}
+
+	tests := []struct {
+		name       string
+		dialect    string
+		expression string
+		want       string
+		wantErr    string
+	}{
+		{name: "Comparison", dialect: "postgres", expression: "amount >= 0", want: `"AMOUNT" >= 0`},
+		{name: "Equality operators", dialect: "postgres", expression: "a == 1 OR b != 'x'", want: `("A" = 1) OR ("B" <> 'x')`},
+		{name: "Nested logic", dialect: "postgres", expression: "NOT (a > 1 AND b < 2)", want: `NOT (("A" > 1) AND ("B" < 2))`},
+		{name: "Negative literal", dialect: "postgres", expression: "a > -1", want: `"A" > -1`},
+		{name: "IS NOT NULL", dialect: "postgres", expression: "a IS NOT NULL", want: `"A" IS NOT NULL`},
+		{name: "IN list", dialect: "mysql", expression: "status NOT IN ('new', 'done')", want: "`STATUS` NOT IN ('new', 'done')"},
+		{name: "BETWEEN", dialect: "postgres", expression: "qty BETWEEN 1 AND 10 * 2", want: `"QTY" BETWEEN 1 AND (10 * 2)`},
+		{name: "CASE", dialect: "postgres", expression: "CASE WHEN a > 0 THEN 1 ELSE 0 END = 1", want: `CASE WHEN "A" > 0 THEN 1 ELSE 0 END = 1`},
+		{name: "if", dialect: "postgres", expression: "if(a > 0, b, c) > 0", want: `CASE WHEN "A" > 0 THEN "B" ELSE "C" END > 0`},
+		{name: "Boolean literal as bit", dialect: "sqlserver", expression: "active = TRUE", want: "[ACTIVE] = 1"},
+
+		// Operators that differ between dialects
+		{name: "Concatenation", dialect: "postgres", expression: "a || b = 'xy'", want: `("A" || "B") = 'xy'`},
+		{name: "Concatenation in MySQL", dialect: "mysql", expression: "a || b = 'xy'", want: "CONCAT(`A`, `B`) = 'xy'"},
+		{name: "Concatenation in SQL Server", dialect: "sqlserver", expression: "a || b = 'xy'", want: "([A] + [B]) = 'xy'"},
+		{name: "Modulo", dialect: "sqlite", expression: "mod(a, 2) = 0", want: `("A" % 2) = 0`},
+		{name: "Modulo in Oracle", dialect: "oracle", expression: "a % 2 = 0", want: `MOD("A", 2) = 0`},
+		{name: "LIKE", dialect: "postgres", expression: "code LIKE 'A%'", want: `"CODE" LIKE 'A%'`},
+		{name: "LIKE with escape", dialect: "sqlite", expression: `code NOT LIKE 'A\%%'`, want: `"CODE" NOT LIKE 'A\%%' ESCAPE '\'`},
+		{name: "ILIKE", dialect: "postgres", expression: "code ILIKE 'a%'", want: `"CODE" ILIKE 'a%'`},
+		{name: "ILIKE elsewhere", dialect: "sqlite", expression: "code ILIKE 'a%'", want: `LOWER("CODE") LIKE LOWER('a%')`},
+		{name: "Regular expression", dialect: "postgres", expression: "code !~ '^[0-9]+$'", want: `"CODE" !~ '^[0-9]+$'`},
+		{name: "Regular expression in MySQL", dialect: "mysql", expression: "code ~ '^[0-9]+$'", want: "(`CODE` REGEXP '^[0-9]+$')"},
+		{name: "Regular expression in Oracle", dialect: "oracle", expression: "code !~ '^[0-9]+$'", want: `NOT REGEXP_LIKE("CODE", '^[0-9]+$')`},
+		{name: "Regular expression in SQLite", dialect: "sqlite", expression: "code ~ '^[0-9]+$'", wantErr: "regular expressions cannot be written as SQL for sqlite"},
+
+		// Functions
+		{name: "Function", dialect: "postgres", expression: "length(trim(name)) > 0", want: `LENGTH(TRIM("NAME")) > 0`},
+		{name: "Function in MySQL", dialect: "mysql", expression: "length(name) <= 10", want: "CHAR_LENGTH(`NAME`) <= 10"},
+		{name: "Renamed functions in SQL Server", dialect: "sqlserver", expression: "length(name) > ceil(round(a))", want: "LEN([NAME]) > CEILING(ROUND([A], 0))"},
+		{name: "Function without SQL equivalent", dialect: "postgres", expression: "to_number(a) > 0", wantErr: "function to_number cannot be written as SQL"},
+		{name: "Unknown column", dialect: "postgres", expression: "missing > 0", wantErr: "unknown column missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialect, err := dialects.GetDialect(tt.dialect)
+			if err != nil {
+				t.Fatalf("GetDialect() error = %v", err)
+			}
+			expr, err := ParseExpression(tt.expression)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+
+			got, err := expr.SQL(dialect, column)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("SQL() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				// Only constructs without an equivalent can be left out
+				if want := strings.Contains(tt.wantErr, "cannot be written"); errors.Is(err, ErrNoSQLEquivalent) != want {
+					t.Errorf("SQL() error = %v, errors.Is(ErrNoSQLEquivalent) = %v, want %v", err, !want, want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SQL() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SQL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
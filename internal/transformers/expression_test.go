@@ -0,0 +1,193 @@
+package transformers
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"brokolisql-go/pkg/common"
+)
+
+func TestExpression_Eval(t *testing.T) {
+	row := common.DataRow{
+		"first_name": "John",
+		"last_name":  "Doe",
+		"age":        "42",
+		"price":      19.5,
+		"quantity":   3,
+		"email":      "John.Doe@Example.com",
+		"country":    "USA",
+		"notes":      nil,
+		"discount":   "",
+		"Order ID":   "A-17",
+		"active":     true,
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		want       interface{}
+	}{
+		// Literals and columns
+		{name: "Integer literal", expression: "42", want: int64(42)},
+		{name: "Decimal literal", expression: "1.5e2", want: float64(150)},
+		{name: "String literal with escaped quote", expression: "'it''s'", want: "it's"},
+		{name: "Column", expression: "first_name", want: "John"},
+		{name: "Quoted column", expression: `"Order ID"`, want: "A-17"},
+		{name: "Backquoted column", expression: "`Order ID`", want: "A-17"},
+		{name: "Missing column is NULL", expression: "missing", want: nil},
+
+		// Arithmetic
+		{name: "Concatenation with +", expression: "first_name + ' ' + last_name", want: "John Doe"},
+		{name: "Concatenation with + and a blank cell", expression: "first_name + discount", want: "John"},
+		{name: "Concatenation with ||", expression: "age || '!'", want: "42!"},
+		{name: "Numeric string arithmetic", expression: "age + 1", want: int64(43)},
+		{name: "Float arithmetic", expression: "price * quantity", want: 58.5},
+		{name: "Precedence", expression: "1 + 2 * 3", want: int64(7)},
+		{name: "Parentheses", expression: "(1 + 2) * 3", want: int64(9)},
+		{name: "Exact integer division", expression: "10 / 2", want: int64(5)},
+		{name: "Inexact integer division", expression: "7 / 2", want: 3.5},
+		{name: "Modulo", expression: "7 % 3", want: int64(1)},
+		{name: "Unary minus", expression: "-quantity", want: int64(-3)},
+		{name: "NULL propagates", expression: "notes + 1", want: nil},
+		{name: "Blank cell is NULL", expression: "price + discount", want: nil},
+		{name: "Blank cell is NULL in products", expression: "discount * quantity", want: nil},
+
+		// Comparisons and logic
+		{name: "Numeric comparison of string column", expression: "age > 9", want: true},
+		{name: "Equality", expression: "country = 'USA'", want: true},
+		{name: "Inequality", expression: "country <> 'USA'", want: false},
+		{name: "AND", expression: "age >= 18 AND country = 'USA'", want: true},
+		{name: "OR", expression: "age < 18 or country = 'USA'", want: true},
+		{name: "NOT", expression: "NOT active", want: false},
+		{name: "Comparison with NULL is NULL", expression: "notes = 'x'", want: nil},
+		{name: "NULL AND false is false", expression: "notes = 'x' AND false", want: false},
+		{name: "NULL OR true is true", expression: "notes = 'x' OR true", want: true},
+		{name: "IS NULL", expression: "notes IS NULL", want: true},
+		{name: "IS NOT NULL", expression: "missing IS NOT NULL", want: false},
+		{name: "IN with brackets", expression: "country in ['USA', 'UK']", want: true},
+		{name: "NOT IN with parentheses", expression: "quantity NOT IN (1, 2)", want: true},
+		{name: "BETWEEN", expression: "age BETWEEN 40 AND 50", want: true},
+		{name: "NOT BETWEEN", expression: "age NOT BETWEEN 40 AND 50", want: false},
+
+		// Pattern matching
+		{name: "LIKE", expression: "email LIKE '%@Example.com'", want: true},
+		{name: "LIKE is case sensitive", expression: "email LIKE '%@example.com'", want: false},
+		{name: "ILIKE", expression: "email ILIKE '%@example.com'", want: true},
+		{name: "LIKE single character", expression: "country LIKE 'U_A'", want: true},
+		{name: "NOT LIKE", expression: "country NOT LIKE 'U%'", want: false},
+		{name: "Regular expression", expression: "\"Order ID\" ~ '^[A-Z]-[0-9]+$'", want: true},
+		{name: "Negated regular expression", expression: "\"Order ID\" !~ '^[0-9]+$'", want: true},
+
+		// Functions
+		{name: "lower", expression: "lower(email)", want: "john.doe@example.com"},
+		{name: "Function names are case-insensitive", expression: "UPPER(country)", want: "USA"},
+		{name: "length", expression: "length(first_name)", want: int64(4)},
+		{name: "substr", expression: "substr(email, 1, 4)", want: "John"},
+		{name: "replace", expression: "replace(email, 'Example', 'test')", want: "John.Doe@test.com"},
+		{name: "regexp_replace", expression: "regexp_replace(\"Order ID\", '[^0-9]', '')", want: "17"},
+		{name: "split_part", expression: "split_part(email, '@', 2)", want: "Example.com"},
+		{name: "lpad", expression: "lpad(quantity, 3, '0')", want: "003"},
+		{name: "concat skips NULL", expression: "concat(first_name, notes, last_name)", want: "JohnDoe"},
+		{name: "coalesce", expression: "coalesce(notes, missing, 'n/a')", want: "n/a"},
+		{name: "nullif", expression: "nullif(country, 'USA')", want: nil},
+		{name: "round", expression: "round(price)", want: int64(20)},
+		{name: "round with digits", expression: "round(2.345, 2)", want: 2.35},
+		{name: "abs", expression: "abs(-5)", want: int64(5)},
+		{name: "power", expression: "power(2, 10)", want: int64(1024)},
+		{name: "greatest", expression: "greatest(quantity, 7, 5)", want: int64(7)},
+		{name: "Function result NULL for NULL argument", expression: "upper(notes)", want: nil},
+
+		// Conditionals
+		{name: "Searched CASE", expression: "CASE WHEN age < 18 THEN 'minor' WHEN age < 65 THEN 'adult' ELSE 'senior' END", want: "adult"},
+		{name: "Simple CASE", expression: "case country when 'UK' then 'GB' when 'USA' then 'US' end", want: "US"},
+		{name: "CASE without match or ELSE", expression: "CASE WHEN false THEN 1 END", want: nil},
+		{name: "if", expression: "if(active, 'yes', 'no')", want: "yes"},
+		{name: "if only evaluates the chosen branch", expression: "if(quantity > 0, price / quantity, 1 / 0)", want: 6.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := ParseExpression(tt.expression)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.expression, err)
+			}
+
+			got, err := expr.Eval(row)
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Eval(%q) = %#v, want %#v", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpression_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		wantPos    int
+		wantMsg    string
+	}{
+		{name: "Missing operand", expression: "price * ", wantPos: 9, wantMsg: "expected a value"},
+		{name: "Unbalanced parenthesis", expression: "(1 + 2", wantPos: 7, wantMsg: "expected ')'"},
+		{name: "Trailing token", expression: "a b", wantPos: 3, wantMsg: "unexpected 'b'"},
+		{name: "Unterminated string", expression: "name = 'abc", wantPos: 8, wantMsg: "unterminated string"},
+		{name: "Unknown function", expression: "1 + foo(2)", wantPos: 5, wantMsg: "unknown function foo"},
+		{name: "Wrong argument count", expression: "lower(a, b)", wantPos: 1, wantMsg: "lower expects 1 argument, got 2"},
+		{name: "IN without list", expression: "country in ", wantPos: 12, wantMsg: "expected '(' or '['"},
+		{name: "CASE without WHEN", expression: "CASE ELSE 1 END", wantPos: 6, wantMsg: "unexpected keyword ELSE"},
+		{name: "CASE without END", expression: "CASE WHEN a THEN 1", wantPos: 19, wantMsg: "expected 'END'"},
+		{name: "Invalid regular expression", expression: "code ~ '[a-'", wantPos: 8, wantMsg: "invalid pattern"},
+		{name: "Unexpected character", expression: "a ? b", wantPos: 3, wantMsg: "unexpected character '?'"},
+		{name: "Reserved keyword", expression: "AND = 1", wantPos: 1, wantMsg: "unexpected keyword AND"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExpression(tt.expression)
+			if err == nil {
+				t.Fatalf("ParseExpression(%q) expected an error", tt.expression)
+			}
+
+			var exprErr *ExpressionError
+			if !errors.As(err, &exprErr) {
+				t.Fatalf("ParseExpression() error = %T, want *ExpressionError", err)
+			}
+			if exprErr.Pos != tt.wantPos {
+				t.Errorf("ParseExpression() error position = %d, want %d (%v)", exprErr.Pos, tt.wantPos, err)
+			}
+			if !strings.Contains(exprErr.Msg, tt.wantMsg) {
+				t.Errorf("ParseExpression() error = %q, want it to contain %q", exprErr.Msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestExpression_EvalErrors(t *testing.T) {
+	row := common.DataRow{"name": "John", "zero": 0}
+
+	tests := []struct {
+		name       string
+		expression string
+	}{
+		{name: "Arithmetic on text", expression: "name * 2"},
+		{name: "Division by zero", expression: "10 / zero"},
+		{name: "Function argument of wrong type", expression: "abs(name)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := ParseExpression(tt.expression)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q) error = %v", tt.expression, err)
+			}
+			if _, err := expr.Eval(row); err == nil {
+				t.Errorf("Eval(%q) expected an error", tt.expression)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package transformers
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+)
+
+// sortingIterator sorts a stream of rows. Up to bufferSize rows are sorted in
+// memory; larger inputs are written to disk as sorted runs which are then
+// merged. Spilled rows are written with gob, so they read back with the
+// same values and types they had in memory.
+type sortingIterator struct {
+	source     common.RowIterator
+	transform  Transformation
+	bufferSize int
+	spillDir   string
+
+	started bool
+	buffer  []common.DataRow
+	pos     int
+	runs    []*sortRun
+}
+
+// sortRun is a sorted run of rows spilled to a temporary file
+type sortRun struct {
+	file    *os.File
+	decoder *gob.Decoder
+	head    common.DataRow
+}
+
+// Types of the values rows may hold besides those gob knows already, which
+// it needs to be told of to write them as interface values
+func init() {
+	gob.Register(time.Time{})
+	gob.Register(dialects.Date{})
+	gob.Register(dialects.Numeric(""))
+	gob.Register(json.Number(""))
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+func newSortingIterator(source common.RowIterator, transform Transformation, bufferSize int, spillDir string) *sortingIterator {
+	if bufferSize <= 0 {
+		bufferSize = 100000
+	}
+
+	return &sortingIterator{
+		source:     source,
+		transform:  transform,
+		bufferSize: bufferSize,
+		spillDir:   spillDir,
+	}
+}
+
+func (it *sortingIterator) Columns() []string {
+	return it.source.Columns()
+}
+
+func (it *sortingIterator) ColumnTypes() map[string]dialects.ColumnDef {
+	return common.ColumnTypesOf(it.source)
+}
+
+func (it *sortingIterator) Next() (common.DataRow, error) {
+	if !it.started {
+		it.started = true
+		if err := it.consume(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(it.runs) == 0 {
+		if it.pos >= len(it.buffer) {
+			return nil, io.EOF
+		}
+		row := it.buffer[it.pos]
+		it.pos++
+		return row, nil
+	}
+
+	return it.nextMerged()
+}
+
+// consume reads the whole source, spilling sorted runs whenever the buffer
+// fills up
+func (it *sortingIterator) consume() error {
+	for {
+		row, err := it.source.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		it.buffer = append(it.buffer, row)
+		if len(it.buffer) >= it.bufferSize {
+			if err := it.spill(); err != nil {
+				return err
+			}
+		}
+	}
+
+	it.sortBuffer()
+	if len(it.runs) > 0 && len(it.buffer) > 0 {
+		if err := it.spill(); err != nil {
+			return err
+		}
+	}
+
+	for _, run := range it.runs {
+		if err := run.advance(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (it *sortingIterator) sortBuffer() {
+	sort.SliceStable(it.buffer, func(i, j int) bool {
+		return lessRows(it.buffer[i], it.buffer[j], it.transform)
+	})
+}
+
+// spill sorts the buffered rows and writes them to a new run file
+func (it *sortingIterator) spill() error {
+	it.sortBuffer()
+
+	file, err := os.CreateTemp(it.spillDir, "brokolisql-sort-*.gob")
+	if err != nil {
+		return fmt.Errorf("failed to create sort spill file: %w", err)
+	}
+	run := &sortRun{file: file}
+	it.runs = append(it.runs, run)
+
+	writer := bufio.NewWriter(file)
+	encoder := gob.NewEncoder(writer)
+	for _, row := range it.buffer {
+		if err := encoder.Encode(row); err != nil {
+			return fmt.Errorf("failed to write sort spill file: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write sort spill file: %w", err)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind sort spill file: %w", err)
+	}
+	run.decoder = gob.NewDecoder(bufio.NewReader(file))
+
+	it.buffer = it.buffer[:0]
+	return nil
+}
+
+// nextMerged returns the smallest head among all runs. Ties go to the earlier
+// run, which keeps the sort stable.
+func (it *sortingIterator) nextMerged() (common.DataRow, error) {
+	var best *sortRun
+	for _, run := range it.runs {
+		if run.head == nil {
+			continue
+		}
+		if best == nil || lessRows(run.head, best.head, it.transform) {
+			best = run
+		}
+	}
+
+	if best == nil {
+		return nil, io.EOF
+	}
+
+	row := best.head
+	if err := best.advance(); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// advance reads the next row of the run into head, leaving it nil at the end
+func (r *sortRun) advance() error {
+	var row common.DataRow
+	if err := r.decoder.Decode(&row); err != nil {
+		if err == io.EOF {
+			r.head = nil
+			return nil
+		}
+		return fmt.Errorf("failed to read sort spill file: %w", err)
+	}
+	r.head = row
+	return nil
+}
+
+func (it *sortingIterator) Close() error {
+	for _, run := range it.runs {
+		run.file.Close()
+		os.Remove(run.file.Name())
+	}
+	it.runs = nil
+	return it.source.Close()
+}
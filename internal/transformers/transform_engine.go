@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
 )
 
 type TransformConfig struct {
@@ -30,6 +31,22 @@ type Transformation struct {
 
 type TransformEngine struct {
 	config TransformConfig
+
+	// expressions caches parsed expressions and conditions by source text
+	expressions map[string]*Expression
+
+	// SortBufferSize is the number of rows a streaming sort keeps in memory
+	// before spilling a sorted run to disk
+	SortBufferSize int
+	// SpillDir is the directory for sort spill files (os.TempDir() if empty)
+	SpillDir string
+}
+
+// rowStep is the row-by-row form of a transformation. columns maps the input
+// column list to the output one and apply reports whether the row is kept.
+type rowStep struct {
+	columns func(columns []string) []string
+	apply   func(row common.DataRow) (bool, error)
 }
 
 func NewTransformEngine(configFile string) (*TransformEngine, error) {
@@ -44,13 +61,87 @@ func NewTransformEngine(configFile string) (*TransformEngine, error) {
 		return nil, fmt.Errorf("failed to parse transform config: %w", err)
 	}
 
-	return &TransformEngine{
-		config: config,
-	}, nil
+	engine := &TransformEngine{
+		config:         config,
+		expressions:    make(map[string]*Expression),
+		SortBufferSize: 100000,
+	}
+
+	// Parse every expression up front so that syntax errors are reported
+	// before any data is read
+	for i, transform := range config.Transformations {
+		for _, field := range expressionFields(transform) {
+			if field.source == "" {
+				continue
+			}
+			if _, err := engine.expression(field.source); err != nil {
+				return nil, fmt.Errorf("transformation %d (%s): invalid %s: %w", i+1, transform.Type, field.name, err)
+			}
+		}
+	}
+
+	return engine, nil
+}
+
+type expressionField struct {
+	name   string
+	source string
+}
+
+// expressionFields returns the expression-valued fields of a transformation
+func expressionFields(transform Transformation) []expressionField {
+	switch transform.Type {
+	case "add_column":
+		return []expressionField{{"expression", transform.Expression}}
+	case "filter_rows":
+		return []expressionField{{"condition", transform.Condition}}
+	case "update_rows":
+		return []expressionField{{"expression", transform.Expression}, {"condition", transform.Condition}}
+	}
+	return nil
+}
+
+// expression returns the parsed form of source, parsing it on first use
+func (e *TransformEngine) expression(source string) (*Expression, error) {
+	if expr, ok := e.expressions[source]; ok {
+		return expr, nil
+	}
+
+	expr, err := ParseExpression(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.expressions == nil {
+		e.expressions = make(map[string]*Expression)
+	}
+	e.expressions[source] = expr
+	return expr, nil
+}
+
+// checkColumns reports expressions of transform that refer to a column
+// missing from columns. index is the 0-based position of the transformation.
+func (e *TransformEngine) checkColumns(index int, transform Transformation, columns []string) error {
+	for _, field := range expressionFields(transform) {
+		if field.source == "" {
+			continue
+		}
+		expr, err := e.expression(field.source)
+		if err != nil {
+			return err
+		}
+		if err := expr.checkColumns(columns); err != nil {
+			return fmt.Errorf("transformation %d (%s): invalid %s: %w", index+1, transform.Type, field.name, err)
+		}
+	}
+	return nil
 }
 
 func (e *TransformEngine) ApplyTransformations(dataset *common.DataSet) error {
-	for _, transform := range e.config.Transformations {
+	for i, transform := range e.config.Transformations {
+		if err := e.checkColumns(i, transform, dataset.Columns); err != nil {
+			return err
+		}
 		if err := e.applyTransformation(transform, dataset); err != nil {
 			return err
 		}
@@ -58,205 +149,365 @@ func (e *TransformEngine) ApplyTransformations(dataset *common.DataSet) error {
 	return nil
 }
 
+// Stream applies the transformations to rows as they are read from it.
+// Row-level transformations are applied one row at a time. Sorting needs the
+// whole input, so it buffers up to SortBufferSize rows and spills sorted runs
+// to temporary files beyond that.
+func (e *TransformEngine) Stream(it common.RowIterator) (common.RowIterator, error) {
+	for i, transform := range e.config.Transformations {
+		if err := e.checkColumns(i, transform, it.Columns()); err != nil {
+			return nil, err
+		}
+		if transform.Type == "sort" {
+			if len(transform.Columns) == 0 {
+				return nil, fmt.Errorf("sort transformation requires columns")
+			}
+			it = newSortingIterator(it, transform, e.SortBufferSize, e.SpillDir)
+			continue
+		}
+
+		step, err := e.rowStep(transform)
+		if err != nil {
+			return nil, err
+		}
+		it = &transformIterator{
+			source:  it,
+			step:    step,
+			columns: step.columns(it.Columns()),
+			types:   declaredTypes(transform, common.ColumnTypesOf(it)),
+		}
+	}
+	return it, nil
+}
+
 func (e *TransformEngine) applyTransformation(transform Transformation, dataset *common.DataSet) error {
+	if transform.Type == "sort" {
+		return e.sortRows(transform, dataset)
+	}
+
+	step, err := e.rowStep(transform)
+	if err != nil {
+		return err
+	}
+
+	dataset.Columns = step.columns(dataset.Columns)
+	dataset.Types = declaredTypes(transform, dataset.Types)
+
+	keptRows := dataset.Rows[:0]
+	for _, row := range dataset.Rows {
+		keep, err := step.apply(row)
+		if err != nil {
+			return err
+		}
+		if keep {
+			keptRows = append(keptRows, row)
+		}
+	}
+	dataset.Rows = keptRows
+
+	return nil
+}
+
+func (e *TransformEngine) rowStep(transform Transformation) (*rowStep, error) {
 	switch transform.Type {
 	case "rename_columns":
-		return e.renameColumns(transform, dataset)
+		return e.renameColumns(transform)
 	case "add_column":
-		return e.addColumn(transform, dataset)
+		return e.addColumn(transform)
 	case "filter_rows":
-		return e.filterRows(transform, dataset)
+		return e.filterRows(transform)
+	case "update_rows":
+		return e.updateRows(transform)
 	case "apply_function":
-		return e.applyFunction(transform, dataset)
+		return e.applyFunction(transform)
 	case "replace_values":
-		return e.replaceValues(transform, dataset)
+		return e.replaceValues(transform)
 	case "drop_columns":
-		return e.dropColumns(transform, dataset)
-	case "sort":
-		return e.sortRows(transform, dataset)
+		return e.dropColumns(transform)
 	default:
-		return fmt.Errorf("unsupported transformation type: %s", transform.Type)
+		return nil, fmt.Errorf("unsupported transformation type: %s", transform.Type)
 	}
 }
 
-func (e *TransformEngine) renameColumns(transform Transformation, dataset *common.DataSet) error {
-	if transform.Mapping == nil {
-		return fmt.Errorf("rename_columns transformation requires a mapping")
-	}
+// sameColumns is the column mapping for transformations that keep the schema
+func sameColumns(columns []string) []string {
+	return columns
+}
 
-	newColumns := make([]string, len(dataset.Columns))
-	copy(newColumns, dataset.Columns)
+// declaredTypes returns the column types declared by the source after
+// transform. Renamed columns keep their type, while columns whose values the
+// transformation sets have their type inferred again. Types of fields nested
+// in a column, keyed by its name and a dot, follow the column.
+func declaredTypes(transform Transformation, types map[string]dialects.ColumnDef) map[string]dialects.ColumnDef {
+	if len(types) == 0 {
+		return types
+	}
 
-	for i, col := range dataset.Columns {
-		if newName, ok := transform.Mapping[col]; ok {
-			newColumns[i] = newName
-		}
+	result := make(map[string]dialects.ColumnDef, len(types))
+	for col, def := range types {
+		result[col] = def
 	}
 
-	for _, row := range dataset.Rows {
+	switch transform.Type {
+	case "rename_columns":
+		for oldName := range transform.Mapping {
+			removeDeclared(result, oldName)
+		}
 		for oldName, newName := range transform.Mapping {
-			if val, ok := row[oldName]; ok {
-				row[newName] = val
-				delete(row, oldName)
+			for col, def := range types {
+				if col == oldName {
+					result[newName] = def
+				} else if nested, ok := strings.CutPrefix(col, oldName+"."); ok {
+					result[newName+"."+nested] = def
+				}
 			}
 		}
+	case "add_column":
+		removeDeclared(result, transform.Name)
+	case "update_rows", "apply_function", "replace_values":
+		removeDeclared(result, transform.Column)
 	}
-
-	dataset.Columns = newColumns
-	return nil
+	return result
 }
 
-func (e *TransformEngine) addColumn(transform Transformation, dataset *common.DataSet) error {
-	if transform.Name == "" {
-		return fmt.Errorf("add_column transformation requires a name")
-	}
-	if transform.Expression == "" {
-		return fmt.Errorf("add_column transformation requires an expression")
+// removeDeclared removes the type of column and of the fields nested in it
+func removeDeclared(types map[string]dialects.ColumnDef, column string) {
+	delete(types, column)
+	for col := range types {
+		if strings.HasPrefix(col, column+".") {
+			delete(types, col)
+		}
 	}
+}
 
-	dataset.Columns = append(dataset.Columns, transform.Name)
+// transformIterator applies a rowStep to each row read from its source
+type transformIterator struct {
+	source  common.RowIterator
+	step    *rowStep
+	columns []string
+	types   map[string]dialects.ColumnDef
+}
 
-	for _, row := range dataset.Rows {
+func (it *transformIterator) Columns() []string {
+	return it.columns
+}
 
-		if strings.Contains(transform.Expression, "+") {
-
-			parts := strings.Split(transform.Expression, "+")
-			result := ""
-			for _, part := range parts {
-				part = strings.TrimSpace(part)
-				if val, ok := row[part]; ok {
-					result += fmt.Sprintf("%v", val)
-				} else {
-					// Check if this is a string literal (enclosed in quotes)
-					if (strings.HasPrefix(part, "'") && strings.HasSuffix(part, "'")) ||
-						(strings.HasPrefix(part, "\"") && strings.HasSuffix(part, "\"")) {
-						// Remove the quotes and add the literal string
-						result += part[1 : len(part)-1]
-					} else {
-						result += part
-					}
-				}
-			}
-			row[transform.Name] = result
-		} else {
+func (it *transformIterator) ColumnTypes() map[string]dialects.ColumnDef {
+	return it.types
+}
 
-			row[transform.Name] = transform.Expression
+func (it *transformIterator) Next() (common.DataRow, error) {
+	for {
+		row, err := it.source.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		keep, err := it.step.apply(row)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			return row, nil
 		}
 	}
+}
 
-	return nil
+func (it *transformIterator) Close() error {
+	return it.source.Close()
 }
 
-func (e *TransformEngine) filterRows(transform Transformation, dataset *common.DataSet) error {
-	if transform.Condition == "" {
-		return fmt.Errorf("filter_rows transformation requires a condition")
+func (e *TransformEngine) renameColumns(transform Transformation) (*rowStep, error) {
+	if transform.Mapping == nil {
+		return nil, fmt.Errorf("rename_columns transformation requires a mapping")
 	}
 
-	// Validate the condition format
-	if strings.Contains(transform.Condition, " in ") {
-		parts := strings.Split(transform.Condition, " in ")
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid 'in' condition: %s", transform.Condition)
-		}
+	return &rowStep{
+		columns: func(columns []string) []string {
+			newColumns := make([]string, len(columns))
+			copy(newColumns, columns)
 
-		// Check if the values part is properly formatted
-		valuesStr := strings.TrimSpace(parts[1])
-		if !strings.HasPrefix(valuesStr, "[") || !strings.HasSuffix(valuesStr, "]") {
-			return fmt.Errorf("invalid 'in' condition format: values must be enclosed in []")
-		}
+			for i, col := range columns {
+				if newName, ok := transform.Mapping[col]; ok {
+					newColumns[i] = newName
+				}
+			}
+			return newColumns
+		},
+		apply: func(row common.DataRow) (bool, error) {
+			for oldName, newName := range transform.Mapping {
+				if val, ok := row[oldName]; ok {
+					row[newName] = val
+					delete(row, oldName)
+				}
+			}
+			return true, nil
+		},
+	}, nil
+}
 
-		// Check if the column name is not empty
-		colName := strings.TrimSpace(parts[0])
-		if colName == "" {
-			return fmt.Errorf("invalid 'in' condition: column name cannot be empty")
-		}
+func (e *TransformEngine) addColumn(transform Transformation) (*rowStep, error) {
+	if transform.Name == "" {
+		return nil, fmt.Errorf("add_column transformation requires a name")
+	}
+	if transform.Expression == "" {
+		return nil, fmt.Errorf("add_column transformation requires an expression")
 	}
 
-	var filteredRows []common.DataRow
-	for _, row := range dataset.Rows {
+	expr, err := e.expression(transform.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid add_column expression: %w", err)
+	}
 
-		if strings.Contains(transform.Condition, " in ") {
-			parts := strings.Split(transform.Condition, " in ")
-			// We already validated the format above, so we can safely proceed
-			colName := strings.TrimSpace(parts[0])
-			valuesStr := strings.TrimSpace(parts[1])
-			valuesStr = strings.Trim(valuesStr, "[]")
-			values := strings.Split(valuesStr, ",")
-
-			if colVal, ok := row[colName]; ok {
-				for _, val := range values {
-					val = strings.Trim(val, " '\"")
-					if fmt.Sprintf("%v", colVal) == val {
-						filteredRows = append(filteredRows, row)
-						break
-					}
-				}
+	return &rowStep{
+		columns: func(columns []string) []string {
+			newColumns := make([]string, len(columns), len(columns)+1)
+			copy(newColumns, columns)
+			return append(newColumns, transform.Name)
+		},
+		apply: func(row common.DataRow) (bool, error) {
+			value, err := expr.Eval(row)
+			if err != nil {
+				return false, fmt.Errorf("failed to evaluate %q for column %s: %w", expr, transform.Name, err)
 			}
-		} else {
-			// For other conditions, keep all rows for now
-			filteredRows = append(filteredRows, row)
-		}
+			row[transform.Name] = value
+			return true, nil
+		},
+	}, nil
+}
+
+func (e *TransformEngine) filterRows(transform Transformation) (*rowStep, error) {
+	if transform.Condition == "" {
+		return nil, fmt.Errorf("filter_rows transformation requires a condition")
 	}
 
-	dataset.Rows = filteredRows
-	return nil
+	condition, err := e.expression(transform.Condition)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter_rows condition: %w", err)
+	}
+
+	return &rowStep{
+		columns: sameColumns,
+		apply: func(row common.DataRow) (bool, error) {
+			keep, err := condition.Test(row)
+			if err != nil {
+				return false, fmt.Errorf("failed to evaluate condition %q: %w", condition, err)
+			}
+			return keep, nil
+		},
+	}, nil
 }
 
-func (e *TransformEngine) applyFunction(transform Transformation, dataset *common.DataSet) error {
+// updateRows sets a column to the value of an expression in every row that
+// matches the optional condition. The column is added if it does not exist.
+func (e *TransformEngine) updateRows(transform Transformation) (*rowStep, error) {
 	if transform.Column == "" {
-		return fmt.Errorf("apply_function transformation requires a column")
+		return nil, fmt.Errorf("update_rows transformation requires a column")
 	}
-	if transform.Function == "" {
-		return fmt.Errorf("apply_function transformation requires a function")
+	if transform.Expression == "" {
+		return nil, fmt.Errorf("update_rows transformation requires an expression")
 	}
 
-	for _, row := range dataset.Rows {
-		if val, ok := row[transform.Column]; ok {
-			switch transform.Function {
-			case "lower":
-				if str, ok := val.(string); ok {
-					row[transform.Column] = strings.ToLower(str)
+	expr, err := e.expression(transform.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid update_rows expression: %w", err)
+	}
+
+	var condition *Expression
+	if transform.Condition != "" {
+		if condition, err = e.expression(transform.Condition); err != nil {
+			return nil, fmt.Errorf("invalid update_rows condition: %w", err)
+		}
+	}
+
+	return &rowStep{
+		columns: func(columns []string) []string {
+			for _, col := range columns {
+				if col == transform.Column {
+					return columns
 				}
-			case "upper":
-				if str, ok := val.(string); ok {
-					row[transform.Column] = strings.ToUpper(str)
+			}
+			newColumns := make([]string, len(columns), len(columns)+1)
+			copy(newColumns, columns)
+			return append(newColumns, transform.Column)
+		},
+		apply: func(row common.DataRow) (bool, error) {
+			if condition != nil {
+				matched, err := condition.Test(row)
+				if err != nil {
+					return false, fmt.Errorf("failed to evaluate condition %q: %w", condition, err)
 				}
-			case "trim":
-				if str, ok := val.(string); ok {
-					row[transform.Column] = strings.TrimSpace(str)
+				if !matched {
+					return true, nil
 				}
-			default:
-				return fmt.Errorf("unsupported function: %s", transform.Function)
 			}
-		}
-	}
 
-	return nil
+			value, err := expr.Eval(row)
+			if err != nil {
+				return false, fmt.Errorf("failed to evaluate %q for column %s: %w", expr, transform.Column, err)
+			}
+			row[transform.Column] = value
+			return true, nil
+		},
+	}, nil
 }
 
-func (e *TransformEngine) replaceValues(transform Transformation, dataset *common.DataSet) error {
+func (e *TransformEngine) applyFunction(transform Transformation) (*rowStep, error) {
 	if transform.Column == "" {
-		return fmt.Errorf("replace_values transformation requires a column")
+		return nil, fmt.Errorf("apply_function transformation requires a column")
 	}
-	if transform.Mapping == nil {
-		return fmt.Errorf("replace_values transformation requires a mapping")
+	if transform.Function == "" {
+		return nil, fmt.Errorf("apply_function transformation requires a function")
 	}
 
-	for _, row := range dataset.Rows {
-		if val, ok := row[transform.Column]; ok {
-			strVal := fmt.Sprintf("%v", val)
-			if newVal, ok := transform.Mapping[strVal]; ok {
-				row[transform.Column] = newVal
+	var fn func(string) string
+	switch transform.Function {
+	case "lower":
+		fn = strings.ToLower
+	case "upper":
+		fn = strings.ToUpper
+	case "trim":
+		fn = strings.TrimSpace
+	default:
+		return nil, fmt.Errorf("unsupported function: %s", transform.Function)
+	}
+
+	return &rowStep{
+		columns: sameColumns,
+		apply: func(row common.DataRow) (bool, error) {
+			if str, ok := row[transform.Column].(string); ok {
+				row[transform.Column] = fn(str)
 			}
-		}
+			return true, nil
+		},
+	}, nil
+}
+
+func (e *TransformEngine) replaceValues(transform Transformation) (*rowStep, error) {
+	if transform.Column == "" {
+		return nil, fmt.Errorf("replace_values transformation requires a column")
+	}
+	if transform.Mapping == nil {
+		return nil, fmt.Errorf("replace_values transformation requires a mapping")
 	}
 
-	return nil
+	return &rowStep{
+		columns: sameColumns,
+		apply: func(row common.DataRow) (bool, error) {
+			if val, ok := row[transform.Column]; ok {
+				strVal := fmt.Sprintf("%v", val)
+				if newVal, ok := transform.Mapping[strVal]; ok {
+					row[transform.Column] = newVal
+				}
+			}
+			return true, nil
+		},
+	}, nil
 }
 
-func (e *TransformEngine) dropColumns(transform Transformation, dataset *common.DataSet) error {
+func (e *TransformEngine) dropColumns(transform Transformation) (*rowStep, error) {
 	if len(transform.Columns) == 0 {
-		return fmt.Errorf("drop_columns transformation requires columns")
+		return nil, fmt.Errorf("drop_columns transformation requires columns")
 	}
 
 	dropSet := make(map[string]bool)
@@ -264,21 +515,23 @@ func (e *TransformEngine) dropColumns(transform Transformation, dataset *common.
 		dropSet[col] = true
 	}
 
-	var newColumns []string
-	for _, col := range dataset.Columns {
-		if !dropSet[col] {
-			newColumns = append(newColumns, col)
-		}
-	}
-
-	for _, row := range dataset.Rows {
-		for col := range dropSet {
-			delete(row, col)
-		}
-	}
-
-	dataset.Columns = newColumns
-	return nil
+	return &rowStep{
+		columns: func(columns []string) []string {
+			var newColumns []string
+			for _, col := range columns {
+				if !dropSet[col] {
+					newColumns = append(newColumns, col)
+				}
+			}
+			return newColumns
+		},
+		apply: func(row common.DataRow) (bool, error) {
+			for col := range dropSet {
+				delete(row, col)
+			}
+			return true, nil
+		},
+	}, nil
 }
 
 func (e *TransformEngine) sortRows(transform Transformation, dataset *common.DataSet) error {
@@ -287,31 +540,36 @@ func (e *TransformEngine) sortRows(transform Transformation, dataset *common.Dat
 	}
 
 	sort.SliceStable(dataset.Rows, func(i, j int) bool {
-		for _, col := range transform.Columns {
-			valI, okI := dataset.Rows[i][col]
-			valJ, okJ := dataset.Rows[j][col]
+		return lessRows(dataset.Rows[i], dataset.Rows[j], transform)
+	})
 
-			if !okI && !okJ {
-				continue
-			}
-			if !okI {
-				return !transform.Ascending
-			}
-			if !okJ {
-				return transform.Ascending
-			}
+	return nil
+}
 
-			strI := fmt.Sprintf("%v", valI)
-			strJ := fmt.Sprintf("%v", valJ)
-			if strI != strJ {
-				if transform.Ascending {
-					return strI < strJ
-				}
-				return strI > strJ
-			}
+// lessRows reports whether row a sorts before row b for a sort transformation
+func lessRows(a, b common.DataRow, transform Transformation) bool {
+	for _, col := range transform.Columns {
+		valI, okI := a[col]
+		valJ, okJ := b[col]
+
+		if !okI && !okJ {
+			continue
+		}
+		if !okI {
+			return !transform.Ascending
+		}
+		if !okJ {
+			return transform.Ascending
 		}
-		return false
-	})
 
-	return nil
+		strI := fmt.Sprintf("%v", valI)
+		strJ := fmt.Sprintf("%v", valJ)
+		if strI != strJ {
+			if transform.Ascending {
+				return strI < strJ
+			}
+			return strI > strJ
+		}
+	}
+	return false
 }
@@ -2,10 +2,14 @@ package transformers
 
 import (
 	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func createTestTransformConfig(t *testing.T, config string) string {
@@ -92,6 +96,25 @@ func TestNewTransformEngine(t *testing.T) {
 
 	invalidConfigPath := createTestTransformConfig(t, invalidConfig)
 
+	// Invalid expressions are rejected before any data is read
+	invalidConditionPath := createTestTransformConfig(t, `{
+		"transformations": [
+			{
+				"type": "filter_rows",
+				"condition": "country in "
+			}
+		]
+	}`)
+	invalidExpressionPath := createTestTransformConfig(t, `{
+		"transformations": [
+			{
+				"type": "add_column",
+				"name": "total",
+				"expression": "price * unknown_fn(quantity)"
+			}
+		]
+	}`)
+
 	// Non-existent file
 	nonExistentPath := filepath.Join(os.TempDir(), "non_existent_file.json")
 
@@ -115,6 +138,16 @@ func TestNewTransformEngine(t *testing.T) {
 			configPath: nonExistentPath,
 			wantErr:    true,
 		},
+		{
+			name:       "Invalid condition in filter_rows",
+			configPath: invalidConditionPath,
+			wantErr:    true,
+		},
+		{
+			name:       "Invalid expression in add_column",
+			configPath: invalidExpressionPath,
+			wantErr:    true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -255,6 +288,101 @@ func TestTransformEngine_FilterRows(t *testing.T) {
 	}
 }
 
+func TestTransformEngine_FilterRows_Expression(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		wantIDs   []int
+	}{
+		{name: "Comparison", condition: "id > 1", wantIDs: []int{2, 3}},
+		{name: "AND with LIKE", condition: "id < 3 AND email LIKE '%example.com'", wantIDs: []int{1, 2}},
+		{name: "OR with NOT IN", condition: "country NOT IN ('USA', 'UK') OR city = 'London'", wantIDs: []int{2, 3}},
+		{name: "NULL never matches", condition: "id = NULL", wantIDs: []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := createTestTransformConfig(t, `{
+				"transformations": [
+					{
+						"type": "filter_rows",
+						"condition": "`+tt.condition+`"
+					}
+				]
+			}`)
+
+			engine, err := NewTransformEngine(configPath)
+			if err != nil {
+				t.Fatalf("Failed to create transform engine: %v", err)
+			}
+
+			dataset := createTestDataset()
+			if err := engine.ApplyTransformations(dataset); err != nil {
+				t.Fatalf("ApplyTransformations() error = %v", err)
+			}
+
+			ids := []int{}
+			for _, row := range dataset.Rows {
+				ids = append(ids, row["id"].(int))
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("FilterRows(%q) kept ids %v, want %v", tt.condition, ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestTransformEngine_UpdateRows(t *testing.T) {
+	config := `{
+		"transformations": [
+			{
+				"type": "update_rows",
+				"column": "region",
+				"expression": "CASE country WHEN 'USA' THEN 'NA' WHEN 'Canada' THEN 'NA' ELSE 'EU' END"
+			},
+			{
+				"type": "update_rows",
+				"column": "email",
+				"expression": "lower(email)",
+				"condition": "country = 'USA'"
+			}
+		]
+	}`
+
+	configPath := createTestTransformConfig(t, config)
+
+	engine, err := NewTransformEngine(configPath)
+	if err != nil {
+		t.Fatalf("Failed to create transform engine: %v", err)
+	}
+
+	dataset := createTestDataset()
+	if err := engine.ApplyTransformations(dataset); err != nil {
+		t.Fatalf("ApplyTransformations() error = %v", err)
+	}
+
+	// A new column is appended; an existing one keeps its position
+	expectedColumns := []string{"id", "first_name", "last_name", "email", "country", "city", "region"}
+	if !reflect.DeepEqual(dataset.Columns, expectedColumns) {
+		t.Errorf("UpdateRows() columns = %v, want %v", dataset.Columns, expectedColumns)
+	}
+
+	wantRegions := []string{"NA", "EU", "NA"}
+	for i, row := range dataset.Rows {
+		if row["region"] != wantRegions[i] {
+			t.Errorf("UpdateRows() row %d region = %v, want %v", i, row["region"], wantRegions[i])
+		}
+	}
+
+	// Only the matching row is updated
+	if got := dataset.Rows[0]["email"]; got != "john.doe@example.com" {
+		t.Errorf("UpdateRows() email = %v, want it lower-cased", got)
+	}
+	if got := dataset.Rows[1]["email"]; got != "jane.smith@example.com" {
+		t.Errorf("UpdateRows() email = %v, want it unchanged", got)
+	}
+}
+
 func TestTransformEngine_ApplyFunction(t *testing.T) {
 	config := `{
 		"transformations": [
@@ -547,18 +675,6 @@ func TestTransformEngine_ErrorHandling(t *testing.T) {
 			}`,
 			wantErr: true,
 		},
-		{
-			name: "Invalid condition in filter_rows",
-			config: `{
-				"transformations": [
-					{
-						"type": "filter_rows",
-						"condition": "country in "
-					}
-				]
-			}`,
-			wantErr: true,
-		},
 		{
 			name: "Missing column in apply_function",
 			config: `{
@@ -675,3 +791,221 @@ func TestTransformEngine_ErrorHandling(t *testing.T) {
 		})
 	}
 }
+
+func TestTransformEngine_UnknownColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			name:    "Filter on a misspelled column",
+			config:  `{"transformations": [{"type": "filter_rows", "condition": "id > 1 AND nosuch = 1"}]}`,
+			wantErr: `transformation 1 (filter_rows): invalid condition: unknown column "nosuch" at position 12`,
+		},
+		{
+			name:    "Update condition on a misspelled column",
+			config:  `{"transformations": [{"type": "update_rows", "column": "city", "expression": "'x'", "condition": "\"Country\" = 'UK'"}]}`,
+			wantErr: `transformation 1 (update_rows): invalid condition: unknown column "Country" at position 1`,
+		},
+		{
+			name:    "Column renamed by an earlier transformation",
+			config:  `{"transformations": [{"type": "rename_columns", "mapping": {"city": "town"}}, {"type": "add_column", "name": "place", "expression": "city || ', ' || country"}]}`,
+			wantErr: `transformation 2 (add_column): invalid expression: unknown column "city" at position 1`,
+		},
+		{
+			name:   "Column added by an earlier transformation",
+			config: `{"transformations": [{"type": "add_column", "name": "place", "expression": "city || ', ' || country"}, {"type": "filter_rows", "condition": "place LIKE 'London%'"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := NewTransformEngine(createTestTransformConfig(t, tt.config))
+			if err != nil {
+				t.Fatalf("Failed to create transform engine: %v", err)
+			}
+
+			err = engine.ApplyTransformations(createTestDataset())
+			if got := fmt.Sprint(err); (tt.wantErr == "" && err != nil) || (tt.wantErr != "" && !strings.HasPrefix(got, tt.wantErr)) {
+				t.Errorf("ApplyTransformations() error = %v, want %q", err, tt.wantErr)
+			}
+
+			_, err = engine.Stream(common.NewDataSetIterator(createTestDataset()))
+			if got := fmt.Sprint(err); (tt.wantErr == "" && err != nil) || (tt.wantErr != "" && !strings.HasPrefix(got, tt.wantErr)) {
+				t.Errorf("Stream() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTransformEngine_Stream(t *testing.T) {
+	config := `{
+		"transformations": [
+			{
+				"type": "filter_rows",
+				"condition": "country in ['USA', 'UK', 'Canada']"
+			},
+			{
+				"type": "drop_columns",
+				"columns": ["city"]
+			},
+			{
+				"type": "sort",
+				"columns": ["country"],
+				"ascending": true
+			}
+		]
+	}`
+
+	configPath := createTestTransformConfig(t, config)
+
+	engine, err := NewTransformEngine(configPath)
+	if err != nil {
+		t.Fatalf("Failed to create transform engine: %v", err)
+	}
+
+	for _, bufferSize := range []int{100, 1} {
+		engine.SortBufferSize = bufferSize
+		engine.SpillDir = t.TempDir()
+
+		it, err := engine.Stream(common.NewDataSetIterator(createTestDataset()))
+		if err != nil {
+			t.Fatalf("Stream() error = %v", err)
+		}
+
+		dataset, err := common.CollectDataSet(it)
+		if err != nil {
+			t.Fatalf("CollectDataSet() error = %v", err)
+		}
+
+		expectedColumns := []string{"id", "first_name", "last_name", "email", "country"}
+		if !reflect.DeepEqual(dataset.Columns, expectedColumns) {
+			t.Errorf("Stream() columns = %v, want %v", dataset.Columns, expectedColumns)
+		}
+
+		expectedOrder := []string{"Canada", "UK", "USA"}
+		if len(dataset.Rows) != len(expectedOrder) {
+			t.Fatalf("Stream() buffer %d returned %d rows, want %d", bufferSize, len(dataset.Rows), len(expectedOrder))
+		}
+		for i, country := range expectedOrder {
+			if dataset.Rows[i]["country"] != country {
+				t.Errorf("Stream() buffer %d got %v at position %d, want %v", bufferSize, dataset.Rows[i]["country"], i, country)
+			}
+			if _, ok := dataset.Rows[i]["city"]; ok {
+				t.Errorf("Stream() column 'city' not dropped")
+			}
+		}
+
+		// Spill files must be removed once the iterator is closed
+		entries, err := os.ReadDir(engine.SpillDir)
+		if err != nil {
+			t.Fatalf("Failed to read spill dir: %v", err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("Stream() left %d spill files behind", len(entries))
+		}
+	}
+}
+
+func TestTransformEngine_StreamSpilledValues(t *testing.T) {
+	configPath := createTestTransformConfig(t, `{"transformations": [{"type": "sort", "columns": ["id"], "ascending": false}]}`)
+	engine, err := NewTransformEngine(configPath)
+	if err != nil {
+		t.Fatalf("Failed to create transform engine: %v", err)
+	}
+
+	created := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	dataset := &common.DataSet{
+		Columns: []string{"id", "created", "day", "amount"},
+		Rows: []common.DataRow{
+			{"id": int64(9007199254740993), "created": created, "day": dialects.Date(created), "amount": dialects.Numeric("10.50")},
+			{"id": int64(9007199254740995), "created": created.Add(time.Hour), "day": nil, "amount": 2.5},
+			{"id": int64(9007199254740994), "created": nil, "day": dialects.Date(created), "amount": map[string]interface{}{"value": int64(3)}},
+		},
+	}
+
+	sorted := make(map[int][]common.DataRow)
+	for _, bufferSize := range []int{100, 1} {
+		engine.SortBufferSize = bufferSize
+		engine.SpillDir = t.TempDir()
+
+		it, err := engine.Stream(common.NewDataSetIterator(dataset))
+		if err != nil {
+			t.Fatalf("Stream() error = %v", err)
+		}
+		got, err := common.CollectDataSet(it)
+		if err != nil {
+			t.Fatalf("CollectDataSet() buffer %d error = %v", bufferSize, err)
+		}
+		sorted[bufferSize] = got.Rows
+	}
+
+	if !reflect.DeepEqual(sorted[1], sorted[100]) {
+		t.Errorf("Spilled rows = %#v\nwant the rows sorted in memory %#v", sorted[1], sorted[100])
+	}
+	if id := sorted[1][0]["id"]; id != int64(9007199254740995) {
+		t.Errorf("First spilled id = %#v, want int64(9007199254740995)", id)
+	}
+}
+
+func TestTransformEngine_DeclaredTypes(t *testing.T) {
+	config := `{
+		"transformations": [
+			{
+				"type": "rename_columns",
+				"mapping": {"email": "mail"}
+			},
+			{
+				"type": "apply_function",
+				"column": "country",
+				"function": "lower"
+			},
+			{
+				"type": "sort",
+				"columns": ["id"],
+				"ascending": false
+			}
+		]
+	}`
+
+	configPath := createTestTransformConfig(t, config)
+
+	engine, err := NewTransformEngine(configPath)
+	if err != nil {
+		t.Fatalf("Failed to create transform engine: %v", err)
+	}
+
+	declared := func() *common.DataSet {
+		dataset := createTestDataset()
+		dataset.Types = map[string]dialects.ColumnDef{
+			"id":      {Type: dialects.SQLTypeBigInt},
+			"email":   {Type: dialects.SQLTypeText},
+			"country": {Type: dialects.SQLTypeText},
+		}
+		return dataset
+	}
+
+	// Renamed columns keep their type and changed columns lose it
+	want := map[string]dialects.ColumnDef{
+		"id":   {Type: dialects.SQLTypeBigInt},
+		"mail": {Type: dialects.SQLTypeText},
+	}
+
+	dataset := declared()
+	if err := engine.ApplyTransformations(dataset); err != nil {
+		t.Fatalf("ApplyTransformations() error = %v", err)
+	}
+	if !reflect.DeepEqual(dataset.Types, want) {
+		t.Errorf("ApplyTransformations() types = %v, want %v", dataset.Types, want)
+	}
+
+	it, err := engine.Stream(common.NewDataSetIterator(declared()))
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	defer it.Close()
+	if got := common.ColumnTypesOf(it); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stream() types = %v, want %v", got, want)
+	}
+}
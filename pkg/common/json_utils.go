@@ -1,9 +1,13 @@
 package common
 
 import (
+	"brokolisql-go/pkg/dialects"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"reflect"
+	"sort"
 )
 
 type DataRow map[string]interface{}
@@ -11,20 +15,33 @@ type DataRow map[string]interface{}
 type DataSet struct {
 	Columns []string
 	Rows    []DataRow
+	// Types holds the column types declared by sources with a schema, such
+	// as Parquet files, by column name. Other columns are inferred.
+	Types map[string]dialects.ColumnDef
 }
 
+// ParseJSONData decodes a JSON array of objects, or a single object, into
+// records. Numbers are kept as json.Number.
 func ParseJSONData(jsonBytes []byte) ([]map[string]interface{}, error) {
-	var data []map[string]interface{}
-	err := json.Unmarshal(jsonBytes, &data)
-
-	if err != nil || len(data) == 0 {
+	var doc interface{}
+	if err := DecodeJSON(jsonBytes, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON data: %w", err)
+	}
 
-		var singleObject map[string]interface{}
-		err = json.Unmarshal(jsonBytes, &singleObject)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse JSON data: %w", err)
+	var data []map[string]interface{}
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		data = []map[string]interface{}{v}
+	case []interface{}:
+		for i, element := range v {
+			record, ok := element.(map[string]interface{})
+			if !ok && element != nil {
+				return nil, fmt.Errorf("failed to parse JSON data: element %d is %s, want an object", i, jsonKind(element))
+			}
+			data = append(data, record)
 		}
-		data = []map[string]interface{}{singleObject}
+	default:
+		return nil, fmt.Errorf("failed to parse JSON data: the document is %s, want an array or object", jsonKind(doc))
 	}
 
 	if len(data) == 0 {
@@ -34,6 +51,45 @@ func ParseJSONData(jsonBytes []byte) ([]map[string]interface{}, error) {
 	return data, nil
 }
 
+// DecodeJSON decodes data into v like json.Unmarshal, except that numbers
+// are kept as json.Number, so that integers beyond 2^53 keep their digits
+func DecodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("invalid data after the top-level value")
+	}
+	return CheckJSONNumbers(reflect.ValueOf(v).Elem().Interface())
+}
+
+// CheckJSONNumbers returns an error for the first json.Number in a decoded
+// value that is out of the range of a float64, such as 1e400, which
+// json.Unmarshal would have rejected
+func CheckJSONNumbers(value interface{}) error {
+	switch v := value.(type) {
+	case json.Number:
+		if _, err := v.Float64(); err != nil {
+			return fmt.Errorf("number %s is out of range", v)
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			if err := CheckJSONNumbers(item); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := CheckJSONNumbers(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func ConvertToDataSet(data []map[string]interface{}) *DataSet {
 
 	columnSet := make(map[string]bool)
@@ -47,24 +103,11 @@ func ConvertToDataSet(data []map[string]interface{}) *DataSet {
 	for col := range columnSet {
 		columns = append(columns, col)
 	}
+	sort.Strings(columns)
 
 	rows := make([]DataRow, 0, len(data))
 	for _, obj := range data {
-		row := make(DataRow)
-		for key, value := range obj {
-
-			if IsComplex(value) {
-				jsonBytes, err := json.Marshal(value)
-				if err == nil {
-					row[key] = string(jsonBytes)
-				} else {
-					row[key] = fmt.Sprintf("%v", value)
-				}
-			} else {
-				row[key] = value
-			}
-		}
-		rows = append(rows, row)
+		rows = append(rows, ConvertToDataRow(obj))
 	}
 
 	return &DataSet{
@@ -81,3 +124,23 @@ func IsComplex(v interface{}) bool {
 	kind := reflect.TypeOf(v).Kind()
 	return kind == reflect.Map || kind == reflect.Slice || kind == reflect.Array
 }
+
+// ConvertToDataRow converts a decoded JSON object into a DataRow, serialising
+// nested objects and arrays back to JSON strings
+func ConvertToDataRow(obj map[string]interface{}) DataRow {
+	row := make(DataRow, len(obj))
+	for key, value := range obj {
+
+		if IsComplex(value) {
+			jsonBytes, err := json.Marshal(value)
+			if err == nil {
+				row[key] = string(jsonBytes)
+			} else {
+				row[key] = fmt.Sprintf("%v", value)
+			}
+		} else {
+			row[key] = value
+		}
+	}
+	return row
+}
@@ -11,6 +11,11 @@ import (
 
 type LogLevel int
 
+// WarningPrefix starts the messages written to a plain io.Writer that warn
+// about input left out of the conversion, so that a logger reading them can
+// report them as warnings rather than progress
+const WarningPrefix = "Warning: "
+
 const (
 	LogLevelDebug LogLevel = iota
 	LogLevelInfo
@@ -28,8 +33,10 @@ type Logger struct {
 	level         LogLevel
 }
 
+// NewLogger returns a logger writing to standard error, which leaves standard
+// output to the generated SQL
 func NewLogger(level LogLevel) *Logger {
-	return NewLoggerWithWriter(os.Stdout, level)
+	return NewLoggerWithWriter(os.Stderr, level)
 }
 
 func NewLoggerWithWriter(writer io.Writer, level LogLevel) *Logger {
@@ -0,0 +1,322 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathSegment is one step of a record path: an object key, an array index or
+// a wildcard over all elements. JSON Pointer tokens set both key and index,
+// which one applies depends on the value they are used on.
+type pathSegment struct {
+	key      string
+	index    int
+	hasIndex bool
+	hasKey   bool
+	wildcard bool
+}
+
+// jsonPath is a parsed JSON Pointer or JSONPath expression
+type jsonPath struct {
+	expr     string
+	segments []pathSegment
+}
+
+// parseJSONPath parses a JSON Pointer such as /data/items, a JSONPath such
+// as $.data.items[*] or $['data']['items'], or a dotted path such as
+// data.items. Only child, index and wildcard steps are supported.
+func parseJSONPath(expr string) (*jsonPath, error) {
+	p := &jsonPath{expr: expr}
+	var err error
+
+	switch {
+	case expr == "" || expr == "$":
+	case strings.HasPrefix(expr, "/"):
+		p.segments = parsePointer(expr)
+	case strings.HasPrefix(expr, "$"):
+		p.segments, err = parseJSONPathSteps(expr[1:])
+	default:
+		p.segments, err = parseJSONPathSteps("." + expr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %q: %w", expr, err)
+	}
+	return p, nil
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its reference tokens
+func parsePointer(expr string) []pathSegment {
+	tokens := strings.Split(expr[1:], "/")
+	segments := make([]pathSegment, len(tokens))
+	for i, token := range tokens {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		segments[i] = pathSegment{key: token, hasKey: true}
+		if n, err := strconv.Atoi(token); err == nil && n >= 0 {
+			segments[i].index = n
+			segments[i].hasIndex = true
+		}
+	}
+	return segments
+}
+
+// parseJSONPathSteps parses the steps of a JSONPath after the leading $
+func parseJSONPathSteps(s string) ([]pathSegment, error) {
+	var segments []pathSegment
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			if strings.HasPrefix(s, "..") {
+				return nil, fmt.Errorf("recursive descent (..) is not supported")
+			}
+			end := strings.IndexAny(s[1:], ".[") + 1
+			if end == 0 {
+				end = len(s)
+			}
+			name := s[1:end]
+			switch name {
+			case "":
+				return nil, fmt.Errorf("empty field name")
+			case "*":
+				segments = append(segments, pathSegment{wildcard: true})
+			default:
+				segments = append(segments, pathSegment{key: name, hasKey: true})
+			}
+			s = s[end:]
+
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ]")
+			}
+			inner := strings.TrimSpace(s[1:end])
+			switch {
+			case inner == "*":
+				segments = append(segments, pathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segments = append(segments, pathSegment{key: inner[1 : len(inner)-1], hasKey: true})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("unsupported selector [%s]", inner)
+				}
+				segments = append(segments, pathSegment{index: n, hasIndex: true})
+			}
+			s = s[end+1:]
+
+		default:
+			return nil, fmt.Errorf("unexpected %q", s[0])
+		}
+	}
+	return segments, nil
+}
+
+// eval returns the values the path selects in doc. A wildcard fans out over
+// the elements of an array or the values of an object.
+func (p *jsonPath) eval(doc interface{}) ([]interface{}, error) {
+	nodes := []interface{}{doc}
+	for _, seg := range p.segments {
+		next := make([]interface{}, 0, len(nodes))
+		for _, node := range nodes {
+			switch v := node.(type) {
+			case map[string]interface{}:
+				if seg.wildcard {
+					for _, value := range v {
+						next = append(next, value)
+					}
+					continue
+				}
+				if !seg.hasKey {
+					return nil, fmt.Errorf("path %q: index [%d] used on an object", p.expr, seg.index)
+				}
+				value, ok := v[seg.key]
+				if !ok {
+					return nil, fmt.Errorf("path %q: field %q not found", p.expr, seg.key)
+				}
+				next = append(next, value)
+
+			case []interface{}:
+				if seg.wildcard {
+					next = append(next, v...)
+					continue
+				}
+				if !seg.hasIndex {
+					return nil, fmt.Errorf("path %q: field %q used on an array", p.expr, seg.key)
+				}
+				i := seg.index
+				if i < 0 {
+					i += len(v)
+				}
+				if i < 0 || i >= len(v) {
+					return nil, fmt.Errorf("path %q: index %d out of range for an array of %d elements", p.expr, seg.index, len(v))
+				}
+				next = append(next, v[i])
+
+			default:
+				return nil, fmt.Errorf("path %q: cannot select into %s", p.expr, jsonKind(node))
+			}
+		}
+		nodes = next
+	}
+	return nodes, nil
+}
+
+// hasWildcard reports whether the path can select more than one value
+func (p *jsonPath) hasWildcard() bool {
+	for _, seg := range p.segments {
+		if seg.wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// columnName derives a column name from the keys and indexes of the path,
+// such as meta_generated_at for meta.generated_at
+func (p *jsonPath) columnName() string {
+	parts := make([]string, 0, len(p.segments))
+	for _, seg := range p.segments {
+		if seg.hasKey {
+			parts = append(parts, seg.key)
+		} else {
+			parts = append(parts, strconv.Itoa(seg.index))
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	default:
+		return "a number"
+	}
+}
+
+// liftedField is an envelope value copied into every record
+type liftedField struct {
+	column string
+	path   *jsonPath
+}
+
+// RecordSelector picks the records out of a wrapped JSON document such as
+// {"data": {"items": [...]}, "meta": {...}}, and copies selected envelope
+// fields into every record as extra columns.
+type RecordSelector struct {
+	path  *jsonPath
+	lifts []liftedField
+}
+
+// NewRecordSelector parses a record path and the fields to lift. Paths are
+// JSON Pointers (/data/items), JSONPath expressions ($.data.items) or dotted
+// paths (data.items); an empty record path selects the whole document. Lifted
+// fields are paths from the document root, optionally prefixed with the
+// column name as in generated=meta.generated_at. Without a name the column
+// is named after the path, as in meta_generated_at.
+func NewRecordSelector(recordPath string, lift []string) (*RecordSelector, error) {
+	path, err := parseJSONPath(recordPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid record path: %w", err)
+	}
+
+	s := &RecordSelector{path: path}
+	seen := make(map[string]bool, len(lift))
+	for _, spec := range lift {
+		column, expr, named := strings.Cut(spec, "=")
+		if !named {
+			expr = spec
+		}
+
+		liftPath, err := parseJSONPath(strings.TrimSpace(expr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid lifted field: %w", err)
+		}
+		if liftPath.hasWildcard() || len(liftPath.segments) == 0 {
+			return nil, fmt.Errorf("invalid lifted field %q: the path must select a single value", spec)
+		}
+
+		column = strings.TrimSpace(column)
+		if !named {
+			column = liftPath.columnName()
+		}
+		if column == "" {
+			return nil, fmt.Errorf("invalid lifted field %q: empty column name", spec)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("lifted column %q is defined more than once", column)
+		}
+		seen[column] = true
+
+		s.lifts = append(s.lifts, liftedField{column: column, path: liftPath})
+	}
+	return s, nil
+}
+
+// Select returns the records of a decoded JSON document. The record path may
+// lead to an array of objects or to a single object.
+func (s *RecordSelector) Select(doc interface{}) ([]map[string]interface{}, error) {
+	nodes, err := s.path.eval(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select records: %w", err)
+	}
+
+	var records []map[string]interface{}
+	for _, node := range nodes {
+		switch v := node.(type) {
+		case map[string]interface{}:
+			records = append(records, v)
+		case []interface{}:
+			for i, element := range v {
+				record, ok := element.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("failed to select records: element %d of %q is %s, want an object", i, s.path.expr, jsonKind(element))
+				}
+				records = append(records, record)
+			}
+		default:
+			return nil, fmt.Errorf("failed to select records: %q is %s, want an array or object", s.path.expr, jsonKind(node))
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no data found in JSON content")
+	}
+
+	for _, lift := range s.lifts {
+		values, err := lift.path.eval(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lift field: %w", err)
+		}
+		for _, record := range records {
+			if _, exists := record[lift.column]; exists {
+				return nil, fmt.Errorf("lifted column %q collides with a record field of the same name", lift.column)
+			}
+			record[lift.column] = values[0]
+		}
+	}
+
+	return records, nil
+}
+
+// ParseJSONRecords decodes a JSON document and selects its records. A nil
+// selector behaves like ParseJSONData.
+func ParseJSONRecords(jsonBytes []byte, selector *RecordSelector) ([]map[string]interface{}, error) {
+	if selector == nil {
+		return ParseJSONData(jsonBytes)
+	}
+
+	var doc interface{}
+	if err := DecodeJSON(jsonBytes, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON data: %w", err)
+	}
+	return selector.Select(doc)
+}
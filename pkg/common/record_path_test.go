@@ -0,0 +1,140 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONRecords(t *testing.T) {
+	envelope := `{
+		"data": {"items": [{"id": 1}, {"id": 2}], "a/b": [{"id": 3}]},
+		"pages": [{"items": [{"id": 4}]}, {"items": [{"id": 5}]}],
+		"meta": {"generated_at": "2024-01-02", "tags": ["x"]},
+		"one": {"id": 6},
+		"count": 2
+	}`
+
+	tests := []struct {
+		name       string
+		recordPath string
+		lift       []string
+		want       []map[string]interface{}
+		wantErr    string
+	}{
+		{
+			name:       "JSONPath",
+			recordPath: "$.data.items",
+			want:       []map[string]interface{}{{"id": json.Number("1")}, {"id": json.Number("2")}},
+		},
+		{
+			name:       "JSONPath with wildcard",
+			recordPath: "$.data.items[*]",
+			want:       []map[string]interface{}{{"id": json.Number("1")}, {"id": json.Number("2")}},
+		},
+		{
+			name:       "Bracket notation",
+			recordPath: "$['data'][\"a/b\"]",
+			want:       []map[string]interface{}{{"id": json.Number("3")}},
+		},
+		{
+			name:       "Dotted path",
+			recordPath: "data.items",
+			want:       []map[string]interface{}{{"id": json.Number("1")}, {"id": json.Number("2")}},
+		},
+		{
+			name:       "JSON Pointer",
+			recordPath: "/data/a~1b",
+			want:       []map[string]interface{}{{"id": json.Number("3")}},
+		},
+		{
+			name:       "JSON Pointer to an element",
+			recordPath: "/data/items/1",
+			want:       []map[string]interface{}{{"id": json.Number("2")}},
+		},
+		{
+			name:       "Negative index",
+			recordPath: "$.data.items[-1]",
+			want:       []map[string]interface{}{{"id": json.Number("2")}},
+		},
+		{
+			name:       "Wildcard over pages",
+			recordPath: "$.pages[*].items",
+			want:       []map[string]interface{}{{"id": json.Number("4")}, {"id": json.Number("5")}},
+		},
+		{
+			name:       "Single object",
+			recordPath: "$.one",
+			want:       []map[string]interface{}{{"id": json.Number("6")}},
+		},
+		{
+			name:       "Lifted fields",
+			recordPath: "/one",
+			lift:       []string{"meta.generated_at", "tags = $.meta.tags", "/count"},
+			want: []map[string]interface{}{
+				{"id": json.Number("6"), "meta_generated_at": "2024-01-02", "tags": []interface{}{"x"}, "count": json.Number("2")},
+			},
+		},
+		{
+			name:       "Missing field",
+			recordPath: "$.data.rows",
+			wantErr:    `path "$.data.rows": field "rows" not found`,
+		},
+		{
+			name:       "Index out of range",
+			recordPath: "$.data.items[5]",
+			wantErr:    "index 5 out of range for an array of 2 elements",
+		},
+		{
+			name:       "Not an array or object",
+			recordPath: "$.count",
+			wantErr:    `"$.count" is a number, want an array or object`,
+		},
+		{
+			name:       "Array of scalars",
+			recordPath: "$.meta.tags",
+			wantErr:    `element 0 of "$.meta.tags" is a string, want an object`,
+		},
+		{
+			name:       "Recursive descent",
+			recordPath: "$..items",
+			wantErr:    "recursive descent (..) is not supported",
+		},
+		{
+			name:       "Lifted field with a wildcard",
+			recordPath: "$.one",
+			lift:       []string{"$.pages[*]"},
+			wantErr:    "the path must select a single value",
+		},
+		{
+			name:       "Lifted field colliding with a record field",
+			recordPath: "$.one",
+			lift:       []string{"id=count"},
+			wantErr:    `lifted column "id" collides with a record field`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := NewRecordSelector(tt.recordPath, tt.lift)
+			var got []map[string]interface{}
+			if err == nil {
+				got, err = ParseJSONRecords([]byte(envelope), selector)
+			}
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseJSONRecords() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseJSONRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"brokolisql-go/pkg/dialects"
+	"io"
+)
+
+// RowIterator is the streaming counterpart to DataSet. Rows are produced one
+// at a time so that callers only hold as much data in memory as they need.
+// Next returns io.EOF once all rows have been consumed.
+type RowIterator interface {
+	Columns() []string
+	Next() (DataRow, error)
+	Close() error
+}
+
+// TypedIterator is a RowIterator over a source that declares the types of
+// its columns. Declared columns are created with their type as is instead of
+// one inferred from the values.
+type TypedIterator interface {
+	RowIterator
+	ColumnTypes() map[string]dialects.ColumnDef
+}
+
+// ColumnTypesOf returns the column types declared by it, or nil if it does
+// not declare any
+func ColumnTypesOf(it RowIterator) map[string]dialects.ColumnDef {
+	if typed, ok := it.(TypedIterator); ok {
+		return typed.ColumnTypes()
+	}
+	return nil
+}
+
+// dataSetIterator adapts an in-memory DataSet to the RowIterator interface
+type dataSetIterator struct {
+	dataset *DataSet
+	pos     int
+}
+
+// NewDataSetIterator returns a RowIterator over the rows of an in-memory DataSet
+func NewDataSetIterator(dataset *DataSet) RowIterator {
+	return &dataSetIterator{dataset: dataset}
+}
+
+func (it *dataSetIterator) Columns() []string {
+	return it.dataset.Columns
+}
+
+func (it *dataSetIterator) Next() (DataRow, error) {
+	if it.pos >= len(it.dataset.Rows) {
+		return nil, io.EOF
+	}
+	row := it.dataset.Rows[it.pos]
+	it.pos++
+	return row, nil
+}
+
+func (it *dataSetIterator) ColumnTypes() map[string]dialects.ColumnDef {
+	return it.dataset.Types
+}
+
+func (it *dataSetIterator) Close() error {
+	return nil
+}
+
+// CollectDataSet drains a RowIterator into an in-memory DataSet and closes it
+func CollectDataSet(it RowIterator) (*DataSet, error) {
+	defer it.Close()
+
+	rows := []DataRow{}
+	for {
+		row, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+
+	return &DataSet{
+		Columns: it.Columns(),
+		Rows:    rows,
+		Types:   ColumnTypesOf(it),
+	}, nil
+}
@@ -0,0 +1,141 @@
+package dialects
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BatchWriter buffers rows and writes them to a StatementWriter as INSERT (or
+// upsert) statements, one statement per full batch. Memory use is bounded by
+// the batch size regardless of how many rows are written.
+type BatchWriter struct {
+	writer     StatementWriter
+	dialect    Dialect
+	tableName  string
+	columns    []string
+	keyColumns []string
+	// fixedColumns are inserted but never updated by an upsert
+	fixedColumns []string
+	copyMode     bool
+	copyOpen     bool
+	batchSize    int
+	batch        [][]interface{}
+	rowCount     int
+}
+
+// NewBatchWriter creates a BatchWriter that renders INSERT statements for
+// tableName using the given dialect
+func NewBatchWriter(writer StatementWriter, dialect Dialect, tableName string, columns []string, batchSize int) *BatchWriter {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	return &BatchWriter{
+		writer:    writer,
+		dialect:   dialect,
+		tableName: tableName,
+		columns:   columns,
+		batchSize: batchSize,
+		batch:     make([][]interface{}, 0, batchSize),
+	}
+}
+
+// WithKeyColumns switches the writer to upsert mode: rows whose key columns
+// match an existing row update it instead of failing with a conflict
+func (w *BatchWriter) WithKeyColumns(keyColumns []string) *BatchWriter {
+	w.keyColumns = keyColumns
+	return w
+}
+
+// WithFixedColumns keeps the given columns of existing rows unchanged in
+// upsert mode, such as a surrogate key that other tables refer to when rows
+// are matched on other key columns
+func (w *BatchWriter) WithFixedColumns(columns ...string) *BatchWriter {
+	w.fixedColumns = columns
+	return w
+}
+
+// WithCopy switches the writer to COPY mode: all rows are written as a single
+// COPY ... FROM stdin block, which is closed by Close. The dialect must
+// implement CopyDialect.
+func (w *BatchWriter) WithCopy() *BatchWriter {
+	w.copyMode = true
+	return w
+}
+
+// WriteRow adds a row to the current batch, flushing it once it is full.
+// The values must be in the same order as the writer's columns.
+func (w *BatchWriter) WriteRow(values []interface{}) error {
+	w.batch = append(w.batch, values)
+	w.rowCount++
+
+	if len(w.batch) >= w.batchSize {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush writes any buffered rows as a single INSERT or upsert statement
+func (w *BatchWriter) Flush() error {
+	if len(w.batch) == 0 {
+		return nil
+	}
+
+	if w.copyMode {
+		return w.flushCopy()
+	}
+
+	rows := len(w.batch)
+	var sql string
+	if len(w.keyColumns) > 0 {
+		unchanged := append(append([]string{}, w.keyColumns...), w.fixedColumns...)
+		updateColumns := nonKeyColumns(w.columns, unchanged)
+		sql = w.dialect.UpsertInto(w.tableName, w.columns, w.keyColumns, updateColumns, w.batch, len(w.batch))
+	} else {
+		sql = w.dialect.InsertInto(w.tableName, w.columns, w.batch, len(w.batch))
+	}
+	w.batch = w.batch[:0]
+
+	return w.writer.WriteStatement(sql, rows)
+}
+
+// flushCopy writes the buffered rows as COPY data lines, starting the COPY
+// block first if needed
+func (w *BatchWriter) flushCopy() error {
+	copyDialect, ok := w.dialect.(CopyDialect)
+	if !ok {
+		return fmt.Errorf("dialect %s does not support COPY", w.dialect.Name())
+	}
+
+	var sb strings.Builder
+	if !w.copyOpen {
+		sb.WriteString(copyDialect.CopyHeader(w.tableName, w.columns))
+		w.copyOpen = true
+	}
+	for _, row := range w.batch {
+		sb.WriteString(copyDialect.CopyRow(row))
+	}
+	rows := len(w.batch)
+	w.batch = w.batch[:0]
+
+	return w.writer.WriteStatement(sb.String(), rows)
+}
+
+// Close flushes buffered rows and terminates an open COPY block. It must be
+// called once all rows have been written.
+func (w *BatchWriter) Close() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if w.copyOpen {
+		w.copyOpen = false
+		return w.writer.WriteStatement(CopyTerminator+"\n", 0)
+	}
+	return nil
+}
+
+// RowCount returns the number of rows written so far, including buffered rows
+func (w *BatchWriter) RowCount() int {
+	return w.rowCount
+}
@@ -0,0 +1,104 @@
+package dialects
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBatchWriter(t *testing.T) {
+	tests := []struct {
+		name        string
+		batchSize   int
+		rows        [][]interface{}
+		wantInserts int
+	}{
+		{
+			name:        "Partial batch flushed on Flush",
+			batchSize:   10,
+			rows:        [][]interface{}{{1, "John"}, {2, "Jane"}},
+			wantInserts: 1,
+		},
+		{
+			name:        "Full batches written as they fill",
+			batchSize:   2,
+			rows:        [][]interface{}{{1, "John"}, {2, "Jane"}, {3, "Bob"}, {4, "Alice"}, {5, "Eve"}},
+			wantInserts: 3,
+		},
+		{
+			name:        "No rows",
+			batchSize:   2,
+			rows:        nil,
+			wantInserts: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			w := NewBatchWriter(NewTextWriter(&sb), &GenericDialect{}, "users", []string{"id", "name"}, tt.batchSize)
+
+			for _, row := range tt.rows {
+				if err := w.WriteRow(row); err != nil {
+					t.Fatalf("WriteRow() error = %v", err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			sql := sb.String()
+			if got := strings.Count(sql, "INSERT INTO"); got != tt.wantInserts {
+				t.Errorf("BatchWriter wrote %d INSERT statements, want %d", got, tt.wantInserts)
+			}
+
+			if w.RowCount() != len(tt.rows) {
+				t.Errorf("RowCount() = %d, want %d", w.RowCount(), len(tt.rows))
+			}
+
+			// The output must match rendering all rows at once
+			want := (&GenericDialect{}).InsertInto("users", []string{"id", "name"}, tt.rows, tt.batchSize)
+			if sql != want {
+				t.Errorf("BatchWriter output = %q, want %q", sql, want)
+			}
+		})
+	}
+}
+
+// recordingWriter collects statements and their row counts
+type recordingWriter struct {
+	statements []string
+	rows       []int
+}
+
+func (r *recordingWriter) WriteStatement(sql string, rows int) error {
+	r.statements = append(r.statements, sql)
+	r.rows = append(r.rows, rows)
+	return nil
+}
+
+func TestBatchWriter_StatementPerBatch(t *testing.T) {
+	rec := &recordingWriter{}
+	w := NewBatchWriter(rec, &GenericDialect{}, "users", []string{"id"}, 2)
+
+	for i := 1; i <= 5; i++ {
+		if err := w.WriteRow([]interface{}{i}); err != nil {
+			t.Fatalf("WriteRow() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	wantRows := []int{2, 2, 1}
+	if len(rec.rows) != len(wantRows) {
+		t.Fatalf("BatchWriter wrote %d statements, want %d", len(rec.rows), len(wantRows))
+	}
+	for i, want := range wantRows {
+		if rec.rows[i] != want {
+			t.Errorf("statement %d carries %d rows, want %d", i+1, rec.rows[i], want)
+		}
+		if strings.Count(rec.statements[i], "INSERT INTO") != 1 {
+			t.Errorf("statement %d = %q, want a single INSERT", i+1, rec.statements[i])
+		}
+	}
+}
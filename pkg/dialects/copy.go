@@ -0,0 +1,65 @@
+package dialects
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CopyDialect is implemented by dialects that can bulk load rows with a
+// COPY ... FROM stdin block, as understood by psql
+type CopyDialect interface {
+	// CopyHeader returns the COPY statement that starts a data block
+	CopyHeader(tableName string, columns []string) string
+
+	// CopyRow returns one tab separated data line, including the newline
+	CopyRow(values []interface{}) string
+}
+
+// CopyTerminator ends the data block of a COPY ... FROM stdin statement
+const CopyTerminator = "\\.\n"
+
+// copyEscaper escapes the characters that have a special meaning in the COPY
+// text format
+var copyEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"\t", "\\t",
+	"\n", "\\n",
+	"\r", "\\r",
+)
+
+// formatCopyValue renders a value for the COPY text format, using \N for NULL
+func formatCopyValue(value interface{}) string {
+	if value == nil {
+		return "\\N"
+	}
+
+	switch v := value.(type) {
+	case string:
+		return copyEscaper.Replace(v)
+	case []byte:
+		// The bytea hex format, with its backslash escaped for COPY
+		return `\\x` + hex.EncodeToString(v)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v)
+	case float32:
+		return formatFloat(float64(v), 32)
+	case float64:
+		return formatFloat(v, 64)
+	case json.Number:
+		return formatJSONNumber(v)
+	case Date:
+		return time.Time(v).Format(dateLayout)
+	case time.Time:
+		return v.Format(timestampLayout)
+	case bool:
+		if v {
+			return "t"
+		}
+		return "f"
+	default:
+		return copyEscaper.Replace(fmt.Sprintf("%v", v))
+	}
+}
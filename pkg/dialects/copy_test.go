@@ -0,0 +1,72 @@
+package dialects
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatCopyValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{name: "Nil value", value: nil, want: `\N`},
+		{name: "Plain string", value: "test", want: "test"},
+		{name: "Empty string", value: "", want: ""},
+		{name: "Backslash", value: `C:\temp`, want: `C:\\temp`},
+		{name: "Tab", value: "a\tb", want: `a\tb`},
+		{name: "Newline", value: "a\nb", want: `a\nb`},
+		{name: "Carriage return", value: "a\r\nb", want: `a\r\nb`},
+		{name: "Bytes", value: []byte{0x00, 0x5c, 0xff}, want: `\\x005cff`},
+		{name: "Integer", value: 42, want: "42"},
+		{name: "Float", value: 3.14, want: "3.14"},
+		{name: "Whole float", value: float32(70000), want: "70000"},
+		{name: "NaN", value: math.NaN(), want: "NaN"},
+		{name: "Infinity", value: math.Inf(1), want: "Infinity"},
+		{name: "Negative infinity", value: float32(math.Inf(-1)), want: "-Infinity"},
+		{name: "Boolean true", value: true, want: "t"},
+		{name: "Boolean false", value: false, want: "f"},
+		{name: "Numeric", value: Numeric("10.50"), want: "10.50"},
+		{name: "Date", value: Date(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)), want: "2024-01-02"},
+		{name: "Timestamp", value: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC), want: "2024-01-02 15:04:05"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatCopyValue(tt.value); got != tt.want {
+				t.Errorf("formatCopyValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchWriter_Copy(t *testing.T) {
+	var sb strings.Builder
+	w := NewBatchWriter(NewTextWriter(&sb), &PostgresDialect{}, "users", []string{"id"}, 2).WithCopy()
+
+	for i := 1; i <= 3; i++ {
+		if err := w.WriteRow([]interface{}{i}); err != nil {
+			t.Fatalf("WriteRow() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Batches share a single COPY block
+	want := "COPY \"users\" (\"id\") FROM stdin;\n1\n2\n3\n\\.\n\n"
+	if sb.String() != want {
+		t.Errorf("BatchWriter COPY output = %q, want %q", sb.String(), want)
+	}
+
+	w = NewBatchWriter(NewTextWriter(&sb), &GenericDialect{}, "users", []string{"id"}, 2).WithCopy()
+	if err := w.WriteRow([]interface{}{1}); err != nil {
+		t.Fatalf("WriteRow() error = %v", err)
+	}
+	if err := w.Close(); err == nil {
+		t.Errorf("Close() expected error for dialect without COPY support")
+	}
+}
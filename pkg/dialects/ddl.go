@@ -0,0 +1,150 @@
+package dialects
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tableStyle holds the parts of a CREATE TABLE statement that differ between
+// dialects
+type tableStyle struct {
+	// mapType maps a column's generic type to the dialect's column type
+	mapType func(ColumnDef) string
+	// identity returns the clause that makes a column generate its own
+	// values. It is nil when the dialect needs no clause or cannot accept
+	// explicit values for identity columns.
+	identity func(start int64) string
+	// suffix is written between the closing parenthesis and the semicolon
+	suffix string
+}
+
+// createTable renders a table definition: columns first, then a composite
+// primary key, unique key and table checks if there are any, then foreign key
+// constraints
+func createTable(d Dialect, style tableStyle, table TableDef) string {
+	var primaryKeys []string
+	for _, col := range table.Columns {
+		if col.IsPrimaryKey {
+			primaryKeys = append(primaryKeys, col.Name)
+		}
+	}
+	composite := len(primaryKeys) > 1
+
+	var sb strings.Builder
+
+	sb.WriteString("CREATE TABLE ")
+	sb.WriteString(d.QuoteIdentifier(table.Name))
+	sb.WriteString(" (\n")
+
+	for i, col := range table.Columns {
+		if i > 0 {
+			sb.WriteString(",\n")
+		}
+
+		sb.WriteString("  ")
+		sb.WriteString(d.QuoteIdentifier(col.Name))
+		sb.WriteString(" ")
+		sb.WriteString(style.mapType(col))
+
+		if col.Identity && style.identity != nil {
+			sb.WriteString(" ")
+			sb.WriteString(style.identity(table.IdentityStart))
+		}
+
+		if col.Default != nil {
+			sb.WriteString(" DEFAULT ")
+			sb.WriteString(d.FormatValue(col.Default))
+		}
+
+		if col.IsPrimaryKey && !composite {
+			sb.WriteString(" PRIMARY KEY")
+		} else if !col.Nullable {
+			sb.WriteString(" NOT NULL")
+		}
+
+		if col.Unique {
+			sb.WriteString(" UNIQUE")
+		}
+
+		if col.Check != "" {
+			sb.WriteString(" CHECK (")
+			sb.WriteString(col.Check)
+			sb.WriteString(")")
+		}
+	}
+
+	if composite {
+		sb.WriteString(",\n  PRIMARY KEY (")
+		writeIdentifierList(&sb, d, "", primaryKeys)
+		sb.WriteString(")")
+	}
+
+	if len(table.UniqueKey) > 0 {
+		sb.WriteString(",\n  UNIQUE (")
+		writeIdentifierList(&sb, d, "", table.UniqueKey)
+		sb.WriteString(")")
+	}
+
+	for _, check := range table.Checks {
+		sb.WriteString(",\n  CHECK (")
+		sb.WriteString(check)
+		sb.WriteString(")")
+	}
+
+	for _, col := range table.Columns {
+		if !col.IsForeignKey || col.References == "" {
+			continue
+		}
+
+		// The reference is table.column
+		dot := strings.LastIndex(col.References, ".")
+		if dot <= 0 || dot == len(col.References)-1 {
+			continue
+		}
+
+		sb.WriteString(",\n  FOREIGN KEY (")
+		sb.WriteString(d.QuoteIdentifier(col.Name))
+		sb.WriteString(") REFERENCES ")
+		sb.WriteString(d.QuoteIdentifier(col.References[:dot]))
+		sb.WriteString(" (")
+		sb.WriteString(d.QuoteIdentifier(col.References[dot+1:]))
+		sb.WriteString(")")
+
+		if col.OnDeleteCascade {
+			sb.WriteString(" ON DELETE CASCADE")
+		}
+	}
+
+	sb.WriteString("\n)")
+	sb.WriteString(style.suffix)
+	sb.WriteString(";\n")
+
+	return sb.String()
+}
+
+// genericType renders a column type using the generic type names
+func genericType(col ColumnDef) string {
+	switch {
+	case col.Type == SQLTypeVarchar && col.Length > 0:
+		return fmt.Sprintf("VARCHAR(%d)", col.Length)
+	case col.Type == SQLTypeVarchar:
+		return string(SQLTypeText)
+	case col.Type == SQLTypeDecimal && col.Precision > 0:
+		return fmt.Sprintf("DECIMAL(%d,%d)", col.Precision, col.Scale)
+	case col.Type == SQLTypeDecimal:
+		return string(SQLTypeFloat)
+	default:
+		return string(col.Type)
+	}
+}
+
+// generatedByDefault returns the standard identity clause, which lets
+// explicitly inserted values through
+func generatedByDefault(clause string) func(start int64) string {
+	return func(start int64) string {
+		if start > 1 {
+			return fmt.Sprintf("%s (START WITH %d)", clause, start)
+		}
+		return clause
+	}
+}
@@ -0,0 +1,496 @@
+package dialects
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SQLType string
+
+const (
+	SQLTypeInteger  SQLType = "INTEGER"
+	SQLTypeSmallInt SQLType = "SMALLINT"
+	SQLTypeBigInt   SQLType = "BIGINT"
+	SQLTypeFloat    SQLType = "FLOAT"
+	SQLTypeText     SQLType = "TEXT"
+	SQLTypeDate     SQLType = "DATE"
+	SQLTypeDateTime SQLType = "DATETIME"
+	SQLTypeBoolean  SQLType = "BOOLEAN"
+	SQLTypeVarchar  SQLType = "VARCHAR" // Text limited to ColumnDef.Length characters
+	SQLTypeDecimal  SQLType = "DECIMAL" // Exact number with ColumnDef.Precision and Scale
+)
+
+// Numeric is a number kept in the exact decimal form it was read in, such as
+// "10.50". FormatValue writes it unquoted and unchanged.
+type Numeric string
+
+// Date is a calendar date. FormatValue writes it as a date literal, and a
+// time.Time as a timestamp literal.
+type Date time.Time
+
+// GobEncode writes the date as the time.Time it is, which gob cannot do for
+// types without exported fields
+func (d Date) GobEncode() ([]byte, error) {
+	return time.Time(d).GobEncode()
+}
+
+func (d *Date) GobDecode(data []byte) error {
+	return (*time.Time)(d).GobDecode(data)
+}
+
+// Layouts of the text inside date and timestamp literals
+const (
+	dateLayout      = "2006-01-02"
+	timestampLayout = "2006-01-02 15:04:05.999999999"
+)
+
+type ColumnDef struct {
+	Name         string
+	Type         SQLType
+	Nullable     bool
+	IsPrimaryKey bool
+	IsForeignKey bool
+	References   string // Referenced table.column
+	Length       int    // Maximum length of VARCHAR columns
+	Precision    int    // Total digits of DECIMAL columns
+	Scale        int    // Digits after the decimal point of DECIMAL columns
+	Unique       bool
+	Check        string      // SQL condition every row must satisfy
+	Default      interface{} // Value used when none is given, nil for no default
+	// OnDeleteCascade deletes the row when the referenced row is deleted
+	OnDeleteCascade bool
+	// Identity marks a surrogate key the database generates when no value
+	// is given. Explicitly inserted values are still accepted.
+	Identity bool
+}
+
+// TableDef is a complete table definition. Primary and foreign keys are
+// taken from the column definitions; several primary key columns form a
+// composite key.
+type TableDef struct {
+	Name    string
+	Columns []ColumnDef
+	// IdentityStart is the first value generated for the identity column, so
+	// that it does not collide with keys inserted explicitly. Zero means 1.
+	IdentityStart int64
+	// UniqueKey lists columns that are unique together, besides the primary
+	// key
+	UniqueKey []string
+	// Checks are SQL conditions that refer to several columns, which some
+	// databases only accept as table constraints
+	Checks []string
+}
+
+type Dialect interface {
+	Name() string
+
+	CreateTable(tableName string, columns []ColumnDef) string
+
+	// CreateTableDef generates a CREATE TABLE statement including primary
+	// keys, foreign keys and identity columns
+	CreateTableDef(table TableDef) string
+
+	InsertInto(tableName string, columns []string, values [][]interface{}, batchSize int) string
+
+	// UpsertInto generates statements that insert new rows and set the
+	// updateColumns of rows whose key columns match an existing row. With
+	// no updateColumns, existing rows are left unchanged.
+	UpsertInto(tableName string, columns []string, keyColumns []string, updateColumns []string, values [][]interface{}, batchSize int) string
+
+	QuoteIdentifier(identifier string) string
+
+	FormatValue(value interface{}) string
+}
+
+// Info describes a registered SQL dialect
+type Info struct {
+	Name        string   // Name the dialect is chosen by, such as "postgres"
+	Aliases     []string // Other names it can be chosen by
+	Description string   // One line shown in help output
+	New         func() Dialect
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Info{}   // Dialects by lower-cased name
+	aliases    = map[string]string{} // Lower-cased aliases and the names they stand for
+)
+
+// Register makes a dialect available to GetDialect under its name and
+// aliases, which are not case sensitive. Like the other registries of
+// BrokoliSQL, it panics if the name is empty or New is nil, and if the name
+// or an alias is already taken, so a dialect cannot be registered twice or
+// replace another.
+func Register(info Info) {
+	name := strings.ToLower(info.Name)
+	if name == "" || info.New == nil {
+		panic(fmt.Sprintf("dialects: Register of %q needs a name and a New function", info.Name))
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("dialects: Register called twice for %q", name))
+	}
+	if owner, ok := aliases[name]; ok {
+		panic(fmt.Sprintf("dialects: Register of %q, which is an alias of %q", name, owner))
+	}
+	for _, alias := range info.Aliases {
+		alias = strings.ToLower(alias)
+		if _, ok := registry[alias]; ok {
+			panic(fmt.Sprintf("dialects: alias %q of %q is the name of another dialect", alias, name))
+		}
+		if owner, ok := aliases[alias]; ok {
+			panic(fmt.Sprintf("dialects: alias %q of %q is an alias of %q", alias, name, owner))
+		}
+	}
+
+	for _, alias := range info.Aliases {
+		if alias = strings.ToLower(alias); alias != name {
+			aliases[alias] = name
+		}
+	}
+	registry[name] = info
+}
+
+// Dialects returns the registered dialects in alphabetical order of their
+// names
+func Dialects() []Info {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	infos := make([]Info, 0, len(registry))
+	for _, info := range registry {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return strings.ToLower(infos[i].Name) < strings.ToLower(infos[j].Name) })
+	return infos
+}
+
+// Names returns the names and aliases dialects can be chosen by in
+// alphabetical order
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry)+len(aliases))
+	for name := range registry {
+		names = append(names, name)
+	}
+	for alias := range aliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookup returns the dialect registered under name or alias
+func lookup(name string) (Info, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	if owner, ok := aliases[name]; ok {
+		name = owner
+	}
+	info, ok := registry[name]
+	return info, ok
+}
+
+func init() {
+	Register(Info{Name: "postgres", Aliases: []string{"postgresql"}, Description: "PostgreSQL", New: func() Dialect { return &PostgresDialect{} }})
+	Register(Info{Name: "mysql", Description: "MySQL and MariaDB", New: func() Dialect { return &MySQLDialect{} }})
+	Register(Info{Name: "sqlite", Description: "SQLite", New: func() Dialect { return &SQLiteDialect{} }})
+	Register(Info{Name: "sqlserver", Aliases: []string{"mssql"}, Description: "Microsoft SQL Server", New: func() Dialect { return &SQLServerDialect{} }})
+	Register(Info{Name: "oracle", Description: "Oracle Database", New: func() Dialect { return &OracleDialect{} }})
+	Register(Info{Name: "generic", Description: "Standard SQL for other databases", New: func() Dialect { return &GenericDialect{} }})
+}
+
+// GetDialect returns a new instance of the dialect registered as name,
+// ignoring case
+func GetDialect(name string) (Dialect, error) {
+	name = strings.ToLower(name)
+	info, ok := lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unsupported SQL dialect: %s (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return info.New(), nil
+}
+
+type BaseDialect struct{}
+
+// FormatValue writes values as standard SQL literals. Bytes are written as
+// hexadecimal binary strings, and NaN and the infinities as quoted strings,
+// since no dialect has numeric literals for them.
+func (d *BaseDialect) FormatValue(value interface{}) string {
+	if value == nil {
+		return "NULL"
+	}
+
+	switch v := value.(type) {
+	case string:
+
+		escaped := strings.ReplaceAll(v, "'", "''")
+		return fmt.Sprintf("'%s'", escaped)
+	case []byte:
+		return fmt.Sprintf("X'%s'", hex.EncodeToString(v))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v)
+	case float32:
+		return quoteNonFinite(float64(v), formatFloat(float64(v), 32))
+	case float64:
+		return quoteNonFinite(v, formatFloat(v, 64))
+	case Numeric:
+		return string(v)
+	case json.Number:
+		return formatJSONNumber(v)
+	case Date:
+		return fmt.Sprintf("DATE '%s'", time.Time(v).Format(dateLayout))
+	case time.Time:
+		return fmt.Sprintf("TIMESTAMP '%s'", v.Format(timestampLayout))
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	default:
+
+		str := fmt.Sprintf("%v", v)
+		escaped := strings.ReplaceAll(str, "'", "''")
+		return fmt.Sprintf("'%s'", escaped)
+	}
+}
+
+// formatFloat writes whole numbers without an exponent, so that 5000000000
+// is not written as 5e+09, and other numbers in the shortest form that reads
+// back as the same value. NaN and the infinities are spelled as PostgreSQL
+// reads them.
+func formatFloat(v float64, bitSize int) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "Infinity"
+	case math.IsInf(v, -1):
+		return "-Infinity"
+	}
+	if v == math.Trunc(v) && math.Abs(v) < 1e21 {
+		return strconv.FormatFloat(v, 'f', -1, bitSize)
+	}
+	return strconv.FormatFloat(v, 'g', -1, bitSize)
+}
+
+// quoteNonFinite quotes the formatted float v if it is NaN or infinite
+func quoteNonFinite(v float64, formatted string) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "'" + formatted + "'"
+	}
+	return formatted
+}
+
+// nonFinite returns value as a float64 if it is a NaN or infinite float
+func nonFinite(value interface{}) (float64, bool) {
+	var f float64
+	switch v := value.(type) {
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	default:
+		return 0, false
+	}
+	return f, math.IsNaN(f) || math.IsInf(f, 0)
+}
+
+// formatJSONNumber writes a decoded JSON number with its own digits, so that
+// integers beyond 2^53 are exact, and numbers with an exponent like floats
+func formatJSONNumber(v json.Number) string {
+	if !strings.ContainsAny(string(v), "eE") {
+		return string(v)
+	}
+	f, err := v.Float64()
+	if err != nil {
+		return string(v)
+	}
+	return formatFloat(f, 64)
+}
+
+// formatBit formats booleans as 1 and 0 for dialects without boolean
+// literals, and any other value like BaseDialect
+func formatBit(d *BaseDialect, value interface{}) string {
+	if b, ok := value.(bool); ok {
+		if b {
+			return "1"
+		}
+		return "0"
+	}
+	return d.FormatValue(value)
+}
+
+// splitBatches splits values into consecutive slices of at most batchSize rows
+func splitBatches(values [][]interface{}, batchSize int) [][][]interface{} {
+	if batchSize <= 0 {
+		batchSize = len(values)
+	}
+
+	var batches [][][]interface{}
+	for batchStart := 0; batchStart < len(values); batchStart += batchSize {
+		batchEnd := batchStart + batchSize
+		if batchEnd > len(values) {
+			batchEnd = len(values)
+		}
+		batches = append(batches, values[batchStart:batchEnd])
+	}
+
+	return batches
+}
+
+// nonKeyColumns returns the columns that are not part of the key, which are
+// the ones an upsert updates
+func nonKeyColumns(columns, keyColumns []string) []string {
+	keys := make(map[string]bool, len(keyColumns))
+	for _, key := range keyColumns {
+		keys[key] = true
+	}
+
+	var result []string
+	for _, col := range columns {
+		if !keys[col] {
+			result = append(result, col)
+		}
+	}
+	return result
+}
+
+// writeIdentifierList writes a comma separated list of quoted identifiers,
+// each optionally qualified with a table alias
+func writeIdentifierList(sb *strings.Builder, d Dialect, alias string, identifiers []string) {
+	for i, identifier := range identifiers {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if alias != "" {
+			sb.WriteString(alias)
+			sb.WriteString(".")
+		}
+		sb.WriteString(d.QuoteIdentifier(identifier))
+	}
+}
+
+// writeValueRows writes each row as a parenthesised tuple, one per line
+func writeValueRows(sb *strings.Builder, d Dialect, batch [][]interface{}) {
+	for i, row := range batch {
+		if i > 0 {
+			sb.WriteString(",\n")
+		}
+
+		sb.WriteString("(")
+		for j, val := range row {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(d.FormatValue(val))
+		}
+		sb.WriteString(")")
+	}
+}
+
+// onConflictUpsert generates multi-row INSERT statements with an
+// ON CONFLICT ... DO UPDATE clause, as understood by PostgreSQL and SQLite
+func onConflictUpsert(d Dialect, tableName string, columns []string, keyColumns []string, updateColumns []string, values [][]interface{}, batchSize int) string {
+	var sb strings.Builder
+
+	for _, batch := range splitBatches(values, batchSize) {
+		sb.WriteString("INSERT INTO ")
+		sb.WriteString(d.QuoteIdentifier(tableName))
+		sb.WriteString(" (")
+		writeIdentifierList(&sb, d, "", columns)
+		sb.WriteString(") VALUES\n")
+		writeValueRows(&sb, d, batch)
+
+		sb.WriteString("\nON CONFLICT (")
+		writeIdentifierList(&sb, d, "", keyColumns)
+
+		if len(updateColumns) == 0 {
+			sb.WriteString(") DO NOTHING")
+		} else {
+			sb.WriteString(") DO UPDATE SET\n")
+			for i, col := range updateColumns {
+				if i > 0 {
+					sb.WriteString(",\n")
+				}
+				sb.WriteString("  ")
+				sb.WriteString(d.QuoteIdentifier(col))
+				sb.WriteString(" = EXCLUDED.")
+				sb.WriteString(d.QuoteIdentifier(col))
+			}
+		}
+
+		sb.WriteString(";\n\n")
+	}
+
+	return sb.String()
+}
+
+// mergeUpsert generates standard SQL MERGE statements that use a VALUES list
+// as the source table
+func mergeUpsert(d Dialect, tableName string, columns []string, keyColumns []string, updateColumns []string, values [][]interface{}, batchSize int) string {
+	var sb strings.Builder
+
+	for _, batch := range splitBatches(values, batchSize) {
+		sb.WriteString("MERGE INTO ")
+		sb.WriteString(d.QuoteIdentifier(tableName))
+		sb.WriteString(" AS target\nUSING (VALUES\n")
+		writeValueRows(&sb, d, batch)
+		sb.WriteString("\n) AS source (")
+		writeIdentifierList(&sb, d, "", columns)
+		sb.WriteString(")\n")
+
+		writeMergeClauses(&sb, d, columns, keyColumns, updateColumns)
+	}
+
+	return sb.String()
+}
+
+// writeMergeClauses writes the ON condition and the WHEN MATCHED / WHEN NOT
+// MATCHED branches of a MERGE statement joining "target" and "source"
+func writeMergeClauses(sb *strings.Builder, d Dialect, columns, keyColumns, updateColumns []string) {
+	sb.WriteString("ON (")
+	for i, key := range keyColumns {
+		if i > 0 {
+			sb.WriteString(" AND ")
+		}
+		sb.WriteString("target.")
+		sb.WriteString(d.QuoteIdentifier(key))
+		sb.WriteString(" = source.")
+		sb.WriteString(d.QuoteIdentifier(key))
+	}
+	sb.WriteString(")\n")
+
+	if len(updateColumns) > 0 {
+		sb.WriteString("WHEN MATCHED THEN UPDATE SET\n")
+		for i, col := range updateColumns {
+			if i > 0 {
+				sb.WriteString(",\n")
+			}
+			sb.WriteString("  target.")
+			sb.WriteString(d.QuoteIdentifier(col))
+			sb.WriteString(" = source.")
+			sb.WriteString(d.QuoteIdentifier(col))
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("WHEN NOT MATCHED THEN INSERT (")
+	writeIdentifierList(sb, d, "", columns)
+	sb.WriteString(")\nVALUES (")
+	writeIdentifierList(sb, d, "source", columns)
+	sb.WriteString(");\n\n")
+}
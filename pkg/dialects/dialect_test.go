@@ -0,0 +1,716 @@
+package dialects
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetDialect(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  string
+		wantType interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "PostgreSQL",
+			dialect:  "postgres",
+			wantType: &PostgresDialect{},
+			wantErr:  false,
+		},
+		{
+			name:     "PostgreSQL (alternative name)",
+			dialect:  "postgresql",
+			wantType: &PostgresDialect{},
+			wantErr:  false,
+		},
+		{
+			name:     "MySQL",
+			dialect:  "mysql",
+			wantType: &MySQLDialect{},
+			wantErr:  false,
+		},
+		{
+			name:     "SQLite",
+			dialect:  "sqlite",
+			wantType: &SQLiteDialect{},
+			wantErr:  false,
+		},
+		{
+			name:     "SQL Server",
+			dialect:  "sqlserver",
+			wantType: &SQLServerDialect{},
+			wantErr:  false,
+		},
+		{
+			name:     "SQL Server (alternative name)",
+			dialect:  "mssql",
+			wantType: &SQLServerDialect{},
+			wantErr:  false,
+		},
+		{
+			name:     "Oracle",
+			dialect:  "oracle",
+			wantType: &OracleDialect{},
+			wantErr:  false,
+		},
+		{
+			name:     "Generic",
+			dialect:  "generic",
+			wantType: &GenericDialect{},
+			wantErr:  false,
+		},
+		{
+			name:     "Case insensitive",
+			dialect:  "POSTGRES",
+			wantType: &PostgresDialect{},
+			wantErr:  false,
+		},
+		{
+			name:     "Unsupported dialect",
+			dialect:  "unsupported",
+			wantType: nil,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialect, err := GetDialect(tt.dialect)
+
+			// Check error
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetDialect() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			// If we expect an error, no need to check the dialect type
+			if tt.wantErr {
+				return
+			}
+
+			// Check dialect type
+			switch tt.wantType.(type) {
+			case *PostgresDialect:
+				if _, ok := dialect.(*PostgresDialect); !ok {
+					t.Errorf("GetDialect() returned wrong type for %s", tt.dialect)
+				}
+			case *MySQLDialect:
+				if _, ok := dialect.(*MySQLDialect); !ok {
+					t.Errorf("GetDialect() returned wrong type for %s", tt.dialect)
+				}
+			case *SQLiteDialect:
+				if _, ok := dialect.(*SQLiteDialect); !ok {
+					t.Errorf("GetDialect() returned wrong type for %s", tt.dialect)
+				}
+			case *SQLServerDialect:
+				if _, ok := dialect.(*SQLServerDialect); !ok {
+					t.Errorf("GetDialect() returned wrong type for %s", tt.dialect)
+				}
+			case *OracleDialect:
+				if _, ok := dialect.(*OracleDialect); !ok {
+					t.Errorf("GetDialect() returned wrong type for %s", tt.dialect)
+				}
+			case *GenericDialect:
+				if _, ok := dialect.(*GenericDialect); !ok {
+					t.Errorf("GetDialect() returned wrong type for %s", tt.dialect)
+				}
+			default:
+				t.Errorf("Unknown expected type for %s", tt.dialect)
+			}
+
+			// Check dialect name
+			if dialect.Name() == "" {
+				t.Errorf("Dialect name should not be empty")
+			}
+		})
+	}
+}
+
+// inHouseDialect is a dialect registered by a test
+type inHouseDialect struct {
+	GenericDialect
+}
+
+func (d *inHouseDialect) Name() string {
+	return "inhouse"
+}
+
+// unregister removes a dialect registered by a test, along with its aliases
+func unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	delete(registry, name)
+	for alias, owner := range aliases {
+		if owner == name {
+			delete(aliases, alias)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register(Info{Name: "inhouse", Aliases: []string{"warehouse"}, Description: "In-house warehouse", New: func() Dialect { return &inHouseDialect{} }})
+	defer unregister("inhouse")
+
+	for _, name := range []string{"inhouse", "WAREHOUSE"} {
+		dialect, err := GetDialect(name)
+		if err != nil {
+			t.Fatalf("GetDialect(%q) error = %v", name, err)
+		}
+		if _, ok := dialect.(*inHouseDialect); !ok {
+			t.Errorf("GetDialect(%q) returned %T", name, dialect)
+		}
+	}
+
+	var names []string
+	for _, info := range Dialects() {
+		names = append(names, info.Name)
+	}
+	if want := "generic inhouse mysql oracle postgres sqlite sqlserver"; strings.Join(names, " ") != want {
+		t.Errorf("Dialects() = %q, want %q", names, want)
+	}
+
+	_, err := GetDialect("hive")
+	if err == nil || !strings.Contains(err.Error(), "warehouse") {
+		t.Errorf("GetDialect() error = %v, want the registered names listed", err)
+	}
+}
+
+func TestRegister_Collisions(t *testing.T) {
+	newDialect := func() Dialect { return &GenericDialect{} }
+
+	tests := []struct {
+		name string
+		info Info
+	}{
+		{name: "Name of another dialect", info: Info{Name: "Postgres", Aliases: []string{"pg"}, New: newDialect}},
+		{name: "Alias of another dialect as name", info: Info{Name: "postgresql", New: newDialect}},
+		{name: "Name of another dialect as alias", info: Info{Name: "redshift", Aliases: []string{"Postgres"}, New: newDialect}},
+		{name: "Alias of another dialect as alias", info: Info{Name: "azure", Aliases: []string{"mssql"}, New: newDialect}},
+		{name: "No name", info: Info{New: newDialect}},
+		{name: "No constructor", info: Info{Name: "empty"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := Names()
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%+v) did not panic", tt.info)
+				}
+				if after := Names(); strings.Join(after, " ") != strings.Join(before, " ") {
+					t.Errorf("Register() changed the names from %q to %q", before, after)
+				}
+			}()
+			Register(tt.info)
+		})
+	}
+
+	// The dialects whose names were claimed are unaffected
+	for name, want := range map[string]string{"postgres": "postgresql", "postgresql": "postgresql", "mssql": "sqlserver"} {
+		if dialect, err := GetDialect(name); err != nil || dialect.Name() != want {
+			t.Errorf("GetDialect(%q) = %v, %v, want %s", name, dialect, err, want)
+		}
+	}
+	if _, err := GetDialect("pg"); err == nil {
+		t.Errorf("GetDialect() found the alias of a rejected dialect")
+	}
+}
+
+func TestRegister_Concurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		name := "concurrent" + string(rune('a'+i))
+		alias := "parallel" + string(rune('a'+i))
+		defer unregister(name)
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			Register(Info{Name: name, Aliases: []string{alias}, New: func() Dialect { return &GenericDialect{} }})
+		}()
+		go func() {
+			defer wg.Done()
+			GetDialect(alias)
+			Dialects()
+			Names()
+		}()
+	}
+	wg.Wait()
+
+	if _, err := GetDialect("parallela"); err != nil {
+		t.Errorf("GetDialect() error = %v", err)
+	}
+}
+
+func TestBaseDialect_FormatValue(t *testing.T) {
+	d := &BaseDialect{}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{
+			name:  "Nil value",
+			value: nil,
+			want:  "NULL",
+		},
+		{
+			name:  "String value",
+			value: "test",
+			want:  "'test'",
+		},
+		{
+			name:  "String with single quote",
+			value: "test's",
+			want:  "'test''s'",
+		},
+		{
+			name:  "Integer value",
+			value: 42,
+			want:  "42",
+		},
+		{
+			name:  "Float value",
+			value: 3.14,
+			want:  "3.14",
+		},
+		{
+			name:  "Whole float value",
+			value: 5000000000.0,
+			want:  "5000000000",
+		},
+		{
+			name:  "Small float value",
+			value: 1e-7,
+			want:  "1e-07",
+		},
+		{
+			name:  "NaN float value",
+			value: math.NaN(),
+			want:  "'NaN'",
+		},
+		{
+			name:  "Infinite float value",
+			value: float32(math.Inf(-1)),
+			want:  "'-Infinity'",
+		},
+		{
+			name:  "Bytes value",
+			value: []byte{0x01, 0xab},
+			want:  "X'01ab'",
+		},
+		{
+			name:  "Numeric value",
+			value: Numeric("10.50"),
+			want:  "10.50",
+		},
+		{
+			name:  "Date value",
+			value: Date(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)),
+			want:  "DATE '2024-01-02'",
+		},
+		{
+			name:  "Timestamp value",
+			value: time.Date(2024, 1, 2, 15, 4, 5, 500000000, time.UTC),
+			want:  "TIMESTAMP '2024-01-02 15:04:05.5'",
+		},
+		{
+			name:  "Boolean true",
+			value: true,
+			want:  "TRUE",
+		},
+		{
+			name:  "Boolean false",
+			value: false,
+			want:  "FALSE",
+		},
+		{
+			name:  "Custom type",
+			value: struct{ Name string }{"test"},
+			want:  "'{test}'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := d.FormatValue(tt.value)
+			if got != tt.want {
+				t.Errorf("BaseDialect.FormatValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialect_FormatValue_TypedLiterals(t *testing.T) {
+	day := Date(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+	moment := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	fraction := time.Date(2024, 1, 2, 15, 4, 5, 250000000, time.UTC)
+
+	tests := []struct {
+		dialect Dialect
+		value   interface{}
+		want    string
+	}{
+		{&SQLServerDialect{}, day, "CONVERT(DATE, '2024-01-02', 23)"},
+		{&SQLServerDialect{}, fraction, "CONVERT(DATETIME2, '2024-01-02T15:04:05.25', 126)"},
+		{&SQLServerDialect{}, true, "1"},
+		{&OracleDialect{}, day, "TO_DATE('2024-01-02', 'YYYY-MM-DD')"},
+		{&OracleDialect{}, moment, "TO_TIMESTAMP('2024-01-02 15:04:05', 'YYYY-MM-DD HH24:MI:SS')"},
+		{&OracleDialect{}, fraction, "TO_TIMESTAMP('2024-01-02 15:04:05.25', 'YYYY-MM-DD HH24:MI:SS.FF')"},
+		{&OracleDialect{}, false, "0"},
+		{&SQLiteDialect{}, day, "'2024-01-02'"},
+		{&SQLiteDialect{}, moment, "'2024-01-02 15:04:05'"},
+		{&MySQLDialect{}, moment, "TIMESTAMP '2024-01-02 15:04:05'"},
+		{&PostgresDialect{}, Numeric("-0.50"), "-0.50"},
+		{&PostgresDialect{}, []byte{0x00, 0xff}, `'\x00ff'::bytea`},
+		{&PostgresDialect{}, math.Inf(1), "'Infinity'"},
+		{&MySQLDialect{}, []byte{0x00, 0xff}, "X'00ff'"},
+		{&SQLiteDialect{}, []byte{0x00, 0xff}, "X'00ff'"},
+		{&SQLiteDialect{}, math.Inf(1), "9e999"},
+		{&SQLiteDialect{}, math.NaN(), "NULL"},
+		{&SQLServerDialect{}, []byte{0x00, 0xff}, "0x00ff"},
+		{&OracleDialect{}, []byte{0x00, 0xff}, "HEXTORAW('00ff')"},
+		{&OracleDialect{}, math.NaN(), "BINARY_DOUBLE_NAN"},
+		{&OracleDialect{}, float32(math.Inf(-1)), "-BINARY_DOUBLE_INFINITY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name()+" "+tt.want, func(t *testing.T) {
+			if got := tt.dialect.FormatValue(tt.value); got != tt.want {
+				t.Errorf("%s FormatValue() = %v, want %v", tt.dialect.Name(), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnDef(t *testing.T) {
+	// Test creating a ColumnDef
+	col := ColumnDef{
+		Name:     "id",
+		Type:     SQLTypeInteger,
+		Nullable: false,
+	}
+
+	if col.Name != "id" {
+		t.Errorf("Expected column name 'id', got %s", col.Name)
+	}
+
+	if col.Type != SQLTypeInteger {
+		t.Errorf("Expected column type INTEGER, got %s", col.Type)
+	}
+
+	if col.Nullable {
+		t.Errorf("Expected column to be NOT NULL")
+	}
+
+	// Test SQLType constants
+
+	if SQLTypeFloat != "FLOAT" {
+		t.Errorf("Expected SQLTypeFloat to be 'FLOAT', got %s", SQLTypeFloat)
+	}
+
+	if SQLTypeText != "TEXT" {
+		t.Errorf("Expected SQLTypeText to be 'TEXT', got %s", SQLTypeText)
+	}
+
+	if SQLTypeDate != "DATE" {
+		t.Errorf("Expected SQLTypeDate to be 'DATE', got %s", SQLTypeDate)
+	}
+
+	if SQLTypeDateTime != "DATETIME" {
+		t.Errorf("Expected SQLTypeDateTime to be 'DATETIME', got %s", SQLTypeDateTime)
+	}
+
+	if SQLTypeBoolean != "BOOLEAN" {
+		t.Errorf("Expected SQLTypeBoolean to be 'BOOLEAN', got %s", SQLTypeBoolean)
+	}
+}
+
+func TestUpsertInto(t *testing.T) {
+	columns := []string{"id", "name"}
+	values := [][]interface{}{
+		{1, "John"},
+		{2, "Jane"},
+	}
+
+	tests := []struct {
+		name     string
+		dialect  Dialect
+		keys     []string
+		contains []string
+	}{
+		{
+			name:    "PostgreSQL",
+			dialect: &PostgresDialect{},
+			keys:    []string{"id"},
+			contains: []string{
+				"INSERT INTO \"users\" (\"id\", \"name\") VALUES",
+				"ON CONFLICT (\"id\") DO UPDATE SET",
+				"\"name\" = EXCLUDED.\"name\"",
+			},
+		},
+		{
+			name:    "SQLite",
+			dialect: &SQLiteDialect{},
+			keys:    []string{"id"},
+			contains: []string{
+				"ON CONFLICT (\"id\") DO UPDATE SET",
+			},
+		},
+		{
+			name:    "PostgreSQL with only key columns",
+			dialect: &PostgresDialect{},
+			keys:    []string{"id", "name"},
+			contains: []string{
+				"ON CONFLICT (\"id\", \"name\") DO NOTHING",
+			},
+		},
+		{
+			name:    "MySQL",
+			dialect: &MySQLDialect{},
+			keys:    []string{"id"},
+			contains: []string{
+				"INSERT INTO `users` (`id`, `name`) VALUES",
+				"ON DUPLICATE KEY UPDATE",
+				"`name` = VALUES(`name`)",
+			},
+		},
+		{
+			name:    "SQL Server",
+			dialect: &SQLServerDialect{},
+			keys:    []string{"id"},
+			contains: []string{
+				"MERGE INTO [users] AS target",
+				") AS source ([id], [name])",
+				"ON (target.[id] = source.[id])",
+				"target.[name] = source.[name]",
+				"WHEN NOT MATCHED THEN INSERT ([id], [name])",
+			},
+		},
+		{
+			name:    "Oracle",
+			dialect: &OracleDialect{},
+			keys:    []string{"id"},
+			contains: []string{
+				"MERGE INTO \"USERS\" target",
+				"SELECT 1 AS \"ID\", 'John' AS \"NAME\" FROM DUAL",
+				"ON (target.\"ID\" = source.\"ID\")",
+				"WHEN NOT MATCHED THEN INSERT (\"ID\", \"NAME\")",
+			},
+		},
+		{
+			name:    "Generic",
+			dialect: &GenericDialect{},
+			keys:    []string{"id"},
+			contains: []string{
+				"MERGE INTO \"users\" AS target",
+				"WHEN MATCHED THEN UPDATE SET",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := tt.dialect.UpsertInto("users", columns, tt.keys, nonKeyColumns(columns, tt.keys), values, 1)
+
+			for _, s := range tt.contains {
+				if !strings.Contains(sql, s) {
+					t.Errorf("UpsertInto() = %v, should contain %v", sql, s)
+				}
+			}
+
+			// One statement per batch
+			if count := strings.Count(sql, ";\n\n"); count != len(values) {
+				t.Errorf("UpsertInto() wrote %d statements, want %d", count, len(values))
+			}
+		})
+	}
+}
+
+func TestCreateTableDef(t *testing.T) {
+	table := TableDef{
+		Name: "orders",
+		Columns: []ColumnDef{
+			{Name: "id", Type: SQLTypeInteger, IsPrimaryKey: true, Identity: true},
+			{Name: "amount", Type: SQLTypeFloat, Nullable: true},
+			{Name: "customer_id", Type: SQLTypeInteger, IsForeignKey: true, References: "customers.id", OnDeleteCascade: true},
+			{Name: "shipped", Type: SQLTypeBoolean, Nullable: true},
+		},
+		IdentityStart: 10,
+	}
+
+	tests := []struct {
+		name     string
+		dialect  Dialect
+		contains []string
+	}{
+		{
+			name:    "PostgreSQL",
+			dialect: &PostgresDialect{},
+			contains: []string{
+				"\"id\" INTEGER GENERATED BY DEFAULT AS IDENTITY (START WITH 10) PRIMARY KEY",
+				"\"amount\" DOUBLE PRECISION",
+				"\"customer_id\" INTEGER NOT NULL",
+				"FOREIGN KEY (\"customer_id\") REFERENCES \"customers\" (\"id\") ON DELETE CASCADE",
+				"\"shipped\" BOOLEAN",
+			},
+		},
+		{
+			name:    "MySQL",
+			dialect: &MySQLDialect{},
+			contains: []string{
+				"`id` INT AUTO_INCREMENT PRIMARY KEY",
+				"`amount` DOUBLE",
+				"FOREIGN KEY (`customer_id`) REFERENCES `customers` (`id`) ON DELETE CASCADE",
+				"`shipped` TINYINT(1)",
+				") ENGINE=InnoDB",
+			},
+		},
+		{
+			name:    "SQLite",
+			dialect: &SQLiteDialect{},
+			contains: []string{
+				"\"id\" INTEGER PRIMARY KEY",
+				"\"amount\" REAL",
+				"FOREIGN KEY (\"customer_id\") REFERENCES \"customers\" (\"id\") ON DELETE CASCADE",
+			},
+		},
+		{
+			name:    "SQL Server",
+			dialect: &SQLServerDialect{},
+			contains: []string{
+				"[id] INT PRIMARY KEY",
+				"[shipped] BIT",
+				"FOREIGN KEY ([customer_id]) REFERENCES [customers] ([id]) ON DELETE CASCADE",
+			},
+		},
+		{
+			name:    "Oracle",
+			dialect: &OracleDialect{},
+			contains: []string{
+				"\"ID\" NUMBER(10) GENERATED BY DEFAULT ON NULL AS IDENTITY (START WITH 10) PRIMARY KEY",
+				"\"SHIPPED\" NUMBER(1)",
+				"FOREIGN KEY (\"CUSTOMER_ID\") REFERENCES \"CUSTOMERS\" (\"ID\") ON DELETE CASCADE",
+			},
+		},
+		{
+			name:    "Generic",
+			dialect: &GenericDialect{},
+			contains: []string{
+				"\"id\" INTEGER PRIMARY KEY",
+				"\"amount\" FLOAT",
+				"FOREIGN KEY (\"customer_id\") REFERENCES \"customers\" (\"id\") ON DELETE CASCADE",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := tt.dialect.CreateTableDef(table)
+
+			for _, s := range tt.contains {
+				if !strings.Contains(sql, s) {
+					t.Errorf("CreateTableDef() = %v, should contain %v", sql, s)
+				}
+			}
+
+			if !strings.HasSuffix(sql, ";\n") {
+				t.Errorf("CreateTableDef() = %v, should end with semicolon and newline", sql)
+			}
+		})
+	}
+}
+
+func TestCreateTableDef_CompositePrimaryKey(t *testing.T) {
+	d := &PostgresDialect{}
+
+	sql := d.CreateTableDef(TableDef{
+		Name: "order_items",
+		Columns: []ColumnDef{
+			{Name: "order_id", Type: SQLTypeInteger, IsPrimaryKey: true},
+			{Name: "line", Type: SQLTypeInteger, IsPrimaryKey: true},
+			{Name: "sku", Type: SQLTypeText, Nullable: true},
+		},
+		UniqueKey: []string{"sku", "line"},
+	})
+
+	for _, s := range []string{
+		"\"order_id\" INTEGER NOT NULL",
+		"\"line\" INTEGER NOT NULL",
+		"PRIMARY KEY (\"order_id\", \"line\")",
+		"UNIQUE (\"sku\", \"line\")",
+	} {
+		if !strings.Contains(sql, s) {
+			t.Errorf("CreateTableDef() = %v, should contain %v", sql, s)
+		}
+	}
+}
+
+func TestCreateTableDef_SizedTypesAndConstraints(t *testing.T) {
+	table := TableDef{
+		Name: "products",
+		Columns: []ColumnDef{
+			{Name: "sku", Type: SQLTypeVarchar, Length: 12, Unique: true},
+			{Name: "price", Type: SQLTypeDecimal, Precision: 10, Scale: 2, Default: 0, Check: "price >= 0", Nullable: true},
+			{Name: "notes", Type: SQLTypeVarchar, Length: 8000, Nullable: true},
+			{Name: "stock", Type: SQLTypeSmallInt, Nullable: true},
+			{Name: "views", Type: SQLTypeBigInt, Nullable: true},
+		},
+		Checks: []string{"stock <= views"},
+	}
+
+	tests := []struct {
+		name     string
+		dialect  Dialect
+		contains []string
+	}{
+		{
+			name:     "PostgreSQL",
+			dialect:  &PostgresDialect{},
+			contains: []string{"\"sku\" VARCHAR(12) NOT NULL UNIQUE", "\"price\" NUMERIC(10,2) DEFAULT 0 CHECK (price >= 0)", "\"notes\" VARCHAR(8000)", "\"stock\" SMALLINT", "\"views\" BIGINT", "\"views\" BIGINT,\n  CHECK (stock <= views)\n)"},
+		},
+		{
+			name:     "MySQL",
+			dialect:  &MySQLDialect{},
+			contains: []string{"`sku` VARCHAR(12) NOT NULL UNIQUE", "`price` DECIMAL(10,2) DEFAULT 0", "`stock` SMALLINT", "`views` BIGINT"},
+		},
+		{
+			name:     "SQLite",
+			dialect:  &SQLiteDialect{},
+			contains: []string{"\"sku\" VARCHAR(12)", "\"price\" DECIMAL(10,2)", "\"stock\" INTEGER", "\"views\" INTEGER"},
+		},
+		{
+			name:     "SQL Server",
+			dialect:  &SQLServerDialect{},
+			contains: []string{"[sku] NVARCHAR(12) NOT NULL UNIQUE", "[price] DECIMAL(10,2)", "[notes] NVARCHAR(MAX)", "[stock] SMALLINT", "[views] BIGINT"},
+		},
+		{
+			name:     "Oracle",
+			dialect:  &OracleDialect{},
+			contains: []string{"\"SKU\" VARCHAR2(12 CHAR) NOT NULL UNIQUE", "\"PRICE\" NUMBER(10,2) DEFAULT 0", "\"NOTES\" CLOB", "\"STOCK\" NUMBER(5)", "\"VIEWS\" NUMBER(19)"},
+		},
+		{
+			name:     "Generic",
+			dialect:  &GenericDialect{},
+			contains: []string{"\"sku\" VARCHAR(12)", "\"price\" DECIMAL(10,2)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := tt.dialect.CreateTableDef(table)
+
+			for _, s := range tt.contains {
+				if !strings.Contains(sql, s) {
+					t.Errorf("CreateTableDef() = %v, should contain %v", sql, s)
+				}
+			}
+		})
+	}
+}
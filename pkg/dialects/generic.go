@@ -18,54 +18,13 @@ func (d *GenericDialect) QuoteIdentifier(identifier string) string {
 }
 
 func (d *GenericDialect) CreateTable(tableName string, columns []ColumnDef) string {
-	var sb strings.Builder
-
-	sb.WriteString("CREATE TABLE ")
-	sb.WriteString(d.QuoteIdentifier(tableName))
-	sb.WriteString(" (\n")
-
-	// First add all columns
-	for i, col := range columns {
-		if i > 0 {
-			sb.WriteString(",\n")
-		}
-
-		sb.WriteString("  ")
-		sb.WriteString(d.QuoteIdentifier(col.Name))
-		sb.WriteString(" ")
-		sb.WriteString(string(col.Type))
-
-		if col.IsPrimaryKey {
-			sb.WriteString(" PRIMARY KEY")
-		} else if !col.Nullable {
-			sb.WriteString(" NOT NULL")
-		}
-	}
-
-	// Then add foreign key constraints
-	for _, col := range columns {
-		if col.IsForeignKey && col.References != "" {
-			// Parse the reference (table.column)
-			parts := strings.Split(col.References, ".")
-			if len(parts) != 2 {
-				continue
-			}
-			refTable := parts[0]
-			refColumn := parts[1]
-
-			sb.WriteString(",\n  FOREIGN KEY (")
-			sb.WriteString(d.QuoteIdentifier(col.Name))
-			sb.WriteString(") REFERENCES ")
-			sb.WriteString(d.QuoteIdentifier(refTable))
-			sb.WriteString(" (")
-			sb.WriteString(d.QuoteIdentifier(refColumn))
-			sb.WriteString(")")
-		}
-	}
-
-	sb.WriteString("\n);\n")
+	return d.CreateTableDef(TableDef{Name: tableName, Columns: columns})
+}
 
-	return sb.String()
+func (d *GenericDialect) CreateTableDef(table TableDef) string {
+	return createTable(d, tableStyle{
+		mapType: genericType,
+	}, table)
 }
 
 func (d *GenericDialect) InsertInto(tableName string, columns []string, values [][]interface{}, batchSize int) string {
@@ -118,3 +77,7 @@ func (d *GenericDialect) InsertInto(tableName string, columns []string, values [
 
 	return sb.String()
 }
+
+func (d *GenericDialect) UpsertInto(tableName string, columns []string, keyColumns []string, updateColumns []string, values [][]interface{}, batchSize int) string {
+	return mergeUpsert(d, tableName, columns, keyColumns, updateColumns, values, batchSize)
+}
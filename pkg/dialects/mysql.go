@@ -0,0 +1,149 @@
+package dialects
+
+import (
+	"fmt"
+	"strings"
+)
+
+type MySQLDialect struct {
+	BaseDialect
+}
+
+func (d *MySQLDialect) Name() string {
+	return "mysql"
+}
+
+func (d *MySQLDialect) QuoteIdentifier(identifier string) string {
+	return fmt.Sprintf("`%s`", identifier)
+}
+
+func (d *MySQLDialect) CreateTable(tableName string, columns []ColumnDef) string {
+	return d.CreateTableDef(TableDef{Name: tableName, Columns: columns})
+}
+
+func (d *MySQLDialect) CreateTableDef(table TableDef) string {
+	return createTable(d, tableStyle{
+		mapType: d.mapSQLType,
+		// AUTO_INCREMENT moves past explicitly inserted values by itself
+		identity: func(int64) string { return "AUTO_INCREMENT" },
+		suffix:   " ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci",
+	}, table)
+}
+
+func (d *MySQLDialect) InsertInto(tableName string, columns []string, values [][]interface{}, batchSize int) string {
+	var sb strings.Builder
+
+	if batchSize <= 0 {
+		batchSize = len(values)
+	}
+
+	for batchStart := 0; batchStart < len(values); batchStart += batchSize {
+		batchEnd := batchStart + batchSize
+		if batchEnd > len(values) {
+			batchEnd = len(values)
+		}
+
+		batch := values[batchStart:batchEnd]
+
+		sb.WriteString("INSERT INTO ")
+		sb.WriteString(d.QuoteIdentifier(tableName))
+		sb.WriteString(" (")
+
+		for i, col := range columns {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(d.QuoteIdentifier(col))
+		}
+
+		sb.WriteString(") VALUES\n")
+
+		for i, row := range batch {
+			if i > 0 {
+				sb.WriteString(",\n")
+			}
+
+			sb.WriteString("(")
+
+			for j, val := range row {
+				if j > 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteString(d.FormatValue(val))
+			}
+
+			sb.WriteString(")")
+		}
+
+		sb.WriteString(";\n\n")
+	}
+
+	return sb.String()
+}
+
+func (d *MySQLDialect) UpsertInto(tableName string, columns []string, keyColumns []string, updateColumns []string, values [][]interface{}, batchSize int) string {
+	var sb strings.Builder
+
+	if len(updateColumns) == 0 {
+		// Assigning a key column to itself turns duplicates into no-ops
+		updateColumns = keyColumns[:1]
+	}
+
+	for _, batch := range splitBatches(values, batchSize) {
+		sb.WriteString("INSERT INTO ")
+		sb.WriteString(d.QuoteIdentifier(tableName))
+		sb.WriteString(" (")
+		writeIdentifierList(&sb, d, "", columns)
+		sb.WriteString(") VALUES\n")
+		writeValueRows(&sb, d, batch)
+
+		sb.WriteString("\nON DUPLICATE KEY UPDATE\n")
+		for i, col := range updateColumns {
+			if i > 0 {
+				sb.WriteString(",\n")
+			}
+			sb.WriteString("  ")
+			sb.WriteString(d.QuoteIdentifier(col))
+			sb.WriteString(" = VALUES(")
+			sb.WriteString(d.QuoteIdentifier(col))
+			sb.WriteString(")")
+		}
+
+		sb.WriteString(";\n\n")
+	}
+
+	return sb.String()
+}
+
+func (d *MySQLDialect) mapSQLType(col ColumnDef) string {
+	switch col.Type {
+	case SQLTypeInteger:
+		return "INT"
+	case SQLTypeSmallInt:
+		return "SMALLINT"
+	case SQLTypeBigInt:
+		return "BIGINT"
+	case SQLTypeFloat:
+		return "DOUBLE"
+	case SQLTypeText:
+		return "TEXT"
+	case SQLTypeVarchar:
+		if col.Length > 0 {
+			return fmt.Sprintf("VARCHAR(%d)", col.Length)
+		}
+		return "TEXT"
+	case SQLTypeDecimal:
+		if col.Precision > 0 {
+			return fmt.Sprintf("DECIMAL(%d,%d)", col.Precision, col.Scale)
+		}
+		return "DOUBLE"
+	case SQLTypeDate:
+		return "DATE"
+	case SQLTypeDateTime:
+		return "DATETIME"
+	case SQLTypeBoolean:
+		return "TINYINT(1)"
+	default:
+		return string(col.Type)
+	}
+}
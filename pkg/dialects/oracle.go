@@ -0,0 +1,205 @@
+package dialects
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+type OracleDialect struct {
+	BaseDialect
+}
+
+func (d *OracleDialect) Name() string {
+	return "oracle"
+}
+
+func (d *OracleDialect) QuoteIdentifier(identifier string) string {
+	return fmt.Sprintf("\"%s\"", strings.ToUpper(identifier))
+}
+
+// FormatValue writes booleans as 1 and 0 for NUMBER(1) columns, and dates
+// with an explicit format mask instead of relying on NLS_DATE_FORMAT. NaN
+// and the infinities use the BINARY_DOUBLE constants.
+func (d *OracleDialect) FormatValue(value interface{}) string {
+	if f, ok := nonFinite(value); ok {
+		switch {
+		case math.IsNaN(f):
+			return "BINARY_DOUBLE_NAN"
+		case f > 0:
+			return "BINARY_DOUBLE_INFINITY"
+		default:
+			return "-BINARY_DOUBLE_INFINITY"
+		}
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return fmt.Sprintf("HEXTORAW('%s')", hex.EncodeToString(v))
+	case Date:
+		return fmt.Sprintf("TO_DATE('%s', 'YYYY-MM-DD')", time.Time(v).Format(dateLayout))
+	case time.Time:
+		if v.Nanosecond() != 0 {
+			return fmt.Sprintf("TO_TIMESTAMP('%s', 'YYYY-MM-DD HH24:MI:SS.FF')", v.Format(timestampLayout))
+		}
+		return fmt.Sprintf("TO_TIMESTAMP('%s', 'YYYY-MM-DD HH24:MI:SS')", v.Format(timestampLayout))
+	}
+	return formatBit(&d.BaseDialect, value)
+}
+
+func (d *OracleDialect) CreateTable(tableName string, columns []ColumnDef) string {
+	return d.CreateTableDef(TableDef{Name: tableName, Columns: columns})
+}
+
+func (d *OracleDialect) CreateTableDef(table TableDef) string {
+	return createTable(d, tableStyle{
+		mapType:  d.mapSQLType,
+		identity: generatedByDefault("GENERATED BY DEFAULT ON NULL AS IDENTITY"),
+	}, table)
+}
+
+func (d *OracleDialect) InsertInto(tableName string, columns []string, values [][]interface{}, batchSize int) string {
+	var sb strings.Builder
+
+	if batchSize <= 0 {
+		batchSize = len(values)
+	}
+
+	for batchStart := 0; batchStart < len(values); batchStart += batchSize {
+		batchEnd := batchStart + batchSize
+		if batchEnd > len(values) {
+			batchEnd = len(values)
+		}
+
+		batch := values[batchStart:batchEnd]
+
+		if len(batch) > 1 {
+
+			sb.WriteString("INSERT ALL\n")
+
+			for _, row := range batch {
+				sb.WriteString("  INTO ")
+				sb.WriteString(d.QuoteIdentifier(tableName))
+				sb.WriteString(" (")
+
+				for i, col := range columns {
+					if i > 0 {
+						sb.WriteString(", ")
+					}
+					sb.WriteString(d.QuoteIdentifier(col))
+				}
+
+				sb.WriteString(") VALUES (")
+
+				for i, val := range row {
+					if i > 0 {
+						sb.WriteString(", ")
+					}
+					sb.WriteString(d.FormatValue(val))
+				}
+
+				sb.WriteString(")\n")
+			}
+
+			sb.WriteString("SELECT 1 FROM DUAL;\n\n")
+		} else if len(batch) == 1 {
+
+			row := batch[0]
+
+			sb.WriteString("INSERT INTO ")
+			sb.WriteString(d.QuoteIdentifier(tableName))
+			sb.WriteString(" (")
+
+			for i, col := range columns {
+				if i > 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteString(d.QuoteIdentifier(col))
+			}
+
+			sb.WriteString(") VALUES (")
+
+			for i, val := range row {
+				if i > 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteString(d.FormatValue(val))
+			}
+
+			sb.WriteString(");\n\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// UpsertInto generates MERGE statements. Oracle has no table value
+// constructor, so the source rows are selected from DUAL.
+func (d *OracleDialect) UpsertInto(tableName string, columns []string, keyColumns []string, updateColumns []string, values [][]interface{}, batchSize int) string {
+	var sb strings.Builder
+
+	for _, batch := range splitBatches(values, batchSize) {
+		sb.WriteString("MERGE INTO ")
+		sb.WriteString(d.QuoteIdentifier(tableName))
+		sb.WriteString(" target\nUSING (\n")
+
+		for i, row := range batch {
+			if i > 0 {
+				sb.WriteString(" UNION ALL\n")
+			}
+
+			sb.WriteString("  SELECT ")
+			for j, val := range row {
+				if j > 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteString(d.FormatValue(val))
+				sb.WriteString(" AS ")
+				sb.WriteString(d.QuoteIdentifier(columns[j]))
+			}
+			sb.WriteString(" FROM DUAL")
+		}
+
+		sb.WriteString("\n) source\n")
+
+		writeMergeClauses(&sb, d, columns, keyColumns, updateColumns)
+	}
+
+	return sb.String()
+}
+
+func (d *OracleDialect) mapSQLType(col ColumnDef) string {
+	switch col.Type {
+	case SQLTypeInteger:
+		return "NUMBER(10)"
+	case SQLTypeSmallInt:
+		return "NUMBER(5)"
+	case SQLTypeBigInt:
+		return "NUMBER(19)"
+	case SQLTypeFloat:
+		return "NUMBER"
+	case SQLTypeText:
+		return "CLOB"
+	case SQLTypeVarchar:
+		// VARCHAR2 is limited to 4000 bytes with the default MAX_STRING_SIZE
+		if col.Length > 0 && col.Length <= 4000 {
+			return fmt.Sprintf("VARCHAR2(%d CHAR)", col.Length)
+		}
+		return "CLOB"
+	case SQLTypeDecimal:
+		if col.Precision > 0 {
+			return fmt.Sprintf("NUMBER(%d,%d)", col.Precision, col.Scale)
+		}
+		return "NUMBER"
+	case SQLTypeDate:
+		return "DATE"
+	case SQLTypeDateTime:
+		return "TIMESTAMP"
+	case SQLTypeBoolean:
+		return "NUMBER(1)" // Oracle uses 0 and 1 for booleans
+	default:
+		return string(col.Type)
+	}
+}
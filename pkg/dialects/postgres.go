@@ -1,6 +1,7 @@
 package dialects
 
 import (
+	"encoding/hex"
 	"fmt"
 	"strings"
 )
@@ -17,33 +18,23 @@ func (d *PostgresDialect) QuoteIdentifier(identifier string) string {
 	return fmt.Sprintf("\"%s\"", identifier)
 }
 
-func (d *PostgresDialect) CreateTable(tableName string, columns []ColumnDef) string {
-	var sb strings.Builder
-
-	sb.WriteString("CREATE TABLE ")
-	sb.WriteString(d.QuoteIdentifier(tableName))
-	sb.WriteString(" (\n")
-
-	for i, col := range columns {
-		if i > 0 {
-			sb.WriteString(",\n")
-		}
-
-		sb.WriteString("  ")
-		sb.WriteString(d.QuoteIdentifier(col.Name))
-		sb.WriteString(" ")
-
-		pgType := d.mapSQLType(col.Type)
-		sb.WriteString(pgType)
-
-		if !col.Nullable {
-			sb.WriteString(" NOT NULL")
-		}
+// FormatValue writes bytes in the bytea hex format
+func (d *PostgresDialect) FormatValue(value interface{}) string {
+	if v, ok := value.([]byte); ok {
+		return fmt.Sprintf("'\\x%s'::bytea", hex.EncodeToString(v))
 	}
+	return d.BaseDialect.FormatValue(value)
+}
 
-	sb.WriteString("\n);\n")
+func (d *PostgresDialect) CreateTable(tableName string, columns []ColumnDef) string {
+	return d.CreateTableDef(TableDef{Name: tableName, Columns: columns})
+}
 
-	return sb.String()
+func (d *PostgresDialect) CreateTableDef(table TableDef) string {
+	return createTable(d, tableStyle{
+		mapType:  d.mapSQLType,
+		identity: generatedByDefault("GENERATED BY DEFAULT AS IDENTITY"),
+	}, table)
 }
 
 func (d *PostgresDialect) InsertInto(tableName string, columns []string, values [][]interface{}, batchSize int) string {
@@ -97,14 +88,32 @@ func (d *PostgresDialect) InsertInto(tableName string, columns []string, values
 	return sb.String()
 }
 
-func (d *PostgresDialect) mapSQLType(sqlType SQLType) string {
-	switch sqlType {
+func (d *PostgresDialect) UpsertInto(tableName string, columns []string, keyColumns []string, updateColumns []string, values [][]interface{}, batchSize int) string {
+	return onConflictUpsert(d, tableName, columns, keyColumns, updateColumns, values, batchSize)
+}
+
+func (d *PostgresDialect) mapSQLType(col ColumnDef) string {
+	switch col.Type {
 	case SQLTypeInteger:
 		return "INTEGER"
+	case SQLTypeSmallInt:
+		return "SMALLINT"
+	case SQLTypeBigInt:
+		return "BIGINT"
 	case SQLTypeFloat:
 		return "DOUBLE PRECISION"
 	case SQLTypeText:
 		return "TEXT"
+	case SQLTypeVarchar:
+		if col.Length > 0 {
+			return fmt.Sprintf("VARCHAR(%d)", col.Length)
+		}
+		return "TEXT"
+	case SQLTypeDecimal:
+		if col.Precision > 0 {
+			return fmt.Sprintf("NUMERIC(%d,%d)", col.Precision, col.Scale)
+		}
+		return "NUMERIC"
 	case SQLTypeDate:
 		return "DATE"
 	case SQLTypeDateTime:
@@ -112,6 +121,32 @@ func (d *PostgresDialect) mapSQLType(sqlType SQLType) string {
 	case SQLTypeBoolean:
 		return "BOOLEAN"
 	default:
-		return string(sqlType)
+		return string(col.Type)
+	}
+}
+
+func (d *PostgresDialect) CopyHeader(tableName string, columns []string) string {
+	var sb strings.Builder
+
+	sb.WriteString("COPY ")
+	sb.WriteString(d.QuoteIdentifier(tableName))
+	sb.WriteString(" (")
+	writeIdentifierList(&sb, d, "", columns)
+	sb.WriteString(") FROM stdin;\n")
+
+	return sb.String()
+}
+
+func (d *PostgresDialect) CopyRow(values []interface{}) string {
+	var sb strings.Builder
+
+	for i, val := range values {
+		if i > 0 {
+			sb.WriteString("\t")
+		}
+		sb.WriteString(formatCopyValue(val))
 	}
+	sb.WriteString("\n")
+
+	return sb.String()
 }
@@ -0,0 +1,138 @@
+package dialects
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+type SQLiteDialect struct {
+	BaseDialect
+}
+
+func (d *SQLiteDialect) Name() string {
+	return "sqlite"
+}
+
+func (d *SQLiteDialect) QuoteIdentifier(identifier string) string {
+	return fmt.Sprintf("\"%s\"", identifier)
+}
+
+// FormatValue writes dates and timestamps as ISO 8601 text, which is how
+// SQLite's date functions expect them and which sorts correctly. The
+// infinities are written as numbers too large for a REAL, which SQLite reads
+// as infinite, and NaN as NULL, which is how SQLite stores it.
+func (d *SQLiteDialect) FormatValue(value interface{}) string {
+	if f, ok := nonFinite(value); ok {
+		switch {
+		case math.IsNaN(f):
+			return "NULL"
+		case f > 0:
+			return "9e999"
+		default:
+			return "-9e999"
+		}
+	}
+
+	switch v := value.(type) {
+	case Date:
+		return fmt.Sprintf("'%s'", time.Time(v).Format(dateLayout))
+	case time.Time:
+		return fmt.Sprintf("'%s'", v.Format(timestampLayout))
+	}
+	return d.BaseDialect.FormatValue(value)
+}
+
+func (d *SQLiteDialect) CreateTable(tableName string, columns []ColumnDef) string {
+	return d.CreateTableDef(TableDef{Name: tableName, Columns: columns})
+}
+
+func (d *SQLiteDialect) CreateTableDef(table TableDef) string {
+	return createTable(d, tableStyle{
+		// An INTEGER PRIMARY KEY already generates values as the rowid
+		mapType: d.mapSQLType,
+	}, table)
+}
+
+func (d *SQLiteDialect) InsertInto(tableName string, columns []string, values [][]interface{}, batchSize int) string {
+	var sb strings.Builder
+
+	if batchSize <= 0 {
+		batchSize = len(values)
+	}
+
+	for batchStart := 0; batchStart < len(values); batchStart += batchSize {
+		batchEnd := batchStart + batchSize
+		if batchEnd > len(values) {
+			batchEnd = len(values)
+		}
+
+		batch := values[batchStart:batchEnd]
+
+		sb.WriteString("INSERT INTO ")
+		sb.WriteString(d.QuoteIdentifier(tableName))
+		sb.WriteString(" (")
+
+		for i, col := range columns {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(d.QuoteIdentifier(col))
+		}
+
+		sb.WriteString(") VALUES\n")
+
+		for i, row := range batch {
+			if i > 0 {
+				sb.WriteString(",\n")
+			}
+
+			sb.WriteString("(")
+
+			for j, val := range row {
+				if j > 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteString(d.FormatValue(val))
+			}
+
+			sb.WriteString(")")
+		}
+
+		sb.WriteString(";\n\n")
+	}
+
+	return sb.String()
+}
+
+func (d *SQLiteDialect) UpsertInto(tableName string, columns []string, keyColumns []string, updateColumns []string, values [][]interface{}, batchSize int) string {
+	return onConflictUpsert(d, tableName, columns, keyColumns, updateColumns, values, batchSize)
+}
+
+func (d *SQLiteDialect) mapSQLType(col ColumnDef) string {
+	switch col.Type {
+	case SQLTypeInteger, SQLTypeSmallInt, SQLTypeBigInt:
+		return "INTEGER"
+	case SQLTypeFloat:
+		return "REAL"
+	case SQLTypeText:
+		return "TEXT"
+	case SQLTypeVarchar:
+		if col.Length > 0 {
+			return fmt.Sprintf("VARCHAR(%d)", col.Length)
+		}
+		return "TEXT"
+	case SQLTypeDecimal:
+		if col.Precision > 0 {
+			return fmt.Sprintf("DECIMAL(%d,%d)", col.Precision, col.Scale)
+		}
+		return "REAL"
+	case SQLTypeDate, SQLTypeDateTime:
+		return "TEXT" // SQLite doesn't have native date types
+	case SQLTypeBoolean:
+		return "INTEGER" // SQLite uses 0 and 1 for booleans
+	default:
+		return string(col.Type)
+	}
+}
@@ -1,8 +1,10 @@
 package dialects
 
 import (
+	"encoding/hex"
 	"fmt"
 	"strings"
+	"time"
 )
 
 type SQLServerDialect struct {
@@ -17,33 +19,31 @@ func (d *SQLServerDialect) QuoteIdentifier(identifier string) string {
 	return fmt.Sprintf("[%s]", identifier)
 }
 
-func (d *SQLServerDialect) CreateTable(tableName string, columns []ColumnDef) string {
-	var sb strings.Builder
-
-	sb.WriteString("CREATE TABLE ")
-	sb.WriteString(d.QuoteIdentifier(tableName))
-	sb.WriteString(" (\n")
-
-	for i, col := range columns {
-		if i > 0 {
-			sb.WriteString(",\n")
-		}
-
-		sb.WriteString("  ")
-		sb.WriteString(d.QuoteIdentifier(col.Name))
-		sb.WriteString(" ")
-
-		sqlServerType := d.mapSQLType(col.Type)
-		sb.WriteString(sqlServerType)
-
-		if !col.Nullable {
-			sb.WriteString(" NOT NULL")
-		}
+// FormatValue writes booleans as 1 and 0 for BIT columns, since TRUE and
+// FALSE are not literals in T-SQL. Dates are converted with an explicit
+// style so that the server's language settings cannot change their meaning.
+func (d *SQLServerDialect) FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case []byte:
+		return "0x" + hex.EncodeToString(v)
+	case Date:
+		return fmt.Sprintf("CONVERT(DATE, '%s', 23)", time.Time(v).Format(dateLayout))
+	case time.Time:
+		return fmt.Sprintf("CONVERT(DATETIME2, '%s', 126)", v.Format("2006-01-02T15:04:05.9999999"))
 	}
+	return formatBit(&d.BaseDialect, value)
+}
 
-	sb.WriteString("\n);\n")
+func (d *SQLServerDialect) CreateTable(tableName string, columns []ColumnDef) string {
+	return d.CreateTableDef(TableDef{Name: tableName, Columns: columns})
+}
 
-	return sb.String()
+func (d *SQLServerDialect) CreateTableDef(table TableDef) string {
+	return createTable(d, tableStyle{
+		// IDENTITY columns reject explicit values unless IDENTITY_INSERT is
+		// switched on, so identity keys stay plain columns
+		mapType: d.mapSQLType,
+	}, table)
 }
 
 func (d *SQLServerDialect) InsertInto(tableName string, columns []string, values [][]interface{}, batchSize int) string {
@@ -115,14 +115,33 @@ func (d *SQLServerDialect) InsertInto(tableName string, columns []string, values
 	return sb.String()
 }
 
-func (d *SQLServerDialect) mapSQLType(sqlType SQLType) string {
-	switch sqlType {
+func (d *SQLServerDialect) UpsertInto(tableName string, columns []string, keyColumns []string, updateColumns []string, values [][]interface{}, batchSize int) string {
+	return mergeUpsert(d, tableName, columns, keyColumns, updateColumns, values, batchSize)
+}
+
+func (d *SQLServerDialect) mapSQLType(col ColumnDef) string {
+	switch col.Type {
 	case SQLTypeInteger:
 		return "INT"
+	case SQLTypeSmallInt:
+		return "SMALLINT"
+	case SQLTypeBigInt:
+		return "BIGINT"
 	case SQLTypeFloat:
 		return "FLOAT"
 	case SQLTypeText:
 		return "NVARCHAR(MAX)"
+	case SQLTypeVarchar:
+		// NVARCHAR(n) is limited to 4000 characters
+		if col.Length > 0 && col.Length <= 4000 {
+			return fmt.Sprintf("NVARCHAR(%d)", col.Length)
+		}
+		return "NVARCHAR(MAX)"
+	case SQLTypeDecimal:
+		if col.Precision > 0 {
+			return fmt.Sprintf("DECIMAL(%d,%d)", col.Precision, col.Scale)
+		}
+		return "FLOAT"
 	case SQLTypeDate:
 		return "DATE"
 	case SQLTypeDateTime:
@@ -130,6 +149,6 @@ func (d *SQLServerDialect) mapSQLType(sqlType SQLType) string {
 	case SQLTypeBoolean:
 		return "BIT"
 	default:
-		return string(sqlType)
+		return string(col.Type)
 	}
 }
@@ -0,0 +1,29 @@
+package dialects
+
+import (
+	"io"
+)
+
+// StatementWriter receives generated SQL one statement at a time. rows is the
+// number of data rows carried by the statement, 0 for DDL and separators.
+// Writing statements individually lets a destination such as a database
+// connection execute and account for each batch on its own.
+type StatementWriter interface {
+	WriteStatement(sql string, rows int) error
+}
+
+// textWriter writes statements verbatim to an io.Writer
+type textWriter struct {
+	writer io.Writer
+}
+
+// NewTextWriter returns a StatementWriter that appends every statement to w,
+// producing a plain SQL script
+func NewTextWriter(w io.Writer) StatementWriter {
+	return &textWriter{writer: w}
+}
+
+func (t *textWriter) WriteStatement(sql string, rows int) error {
+	_, err := io.WriteString(t.writer, sql)
+	return err
+}
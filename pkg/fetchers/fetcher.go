@@ -3,6 +3,10 @@ package fetchers
 import (
 	"brokolisql-go/pkg/common"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 )
 
 var (
@@ -13,14 +17,82 @@ type Fetcher interface {
 	Fetch(source string, options map[string]interface{}) (*common.DataSet, error)
 }
 
+// SourceType describes a registered kind of remote source
+type SourceType struct {
+	Name        string   // Name the source type is chosen by, such as "rest"
+	MIMETypes   []string // Media types of the data it fetches
+	Description string   // One line shown in help output
+	New         func() Fetcher
+}
+
+var (
+	sourceTypesMu sync.RWMutex
+	sourceTypes   = map[string]SourceType{} // Source types by lower-cased name
+)
+
+// Register makes a source type available to GetFetcher under its name,
+// which is not case sensitive. Like the other registries of BrokoliSQL, it
+// panics if the name is empty or New is nil, and if the name is already
+// taken, so a source type cannot be registered twice or replace another.
+func Register(sourceType SourceType) {
+	name := strings.ToLower(sourceType.Name)
+	if name == "" || sourceType.New == nil {
+		panic(fmt.Sprintf("fetchers: Register of %q needs a name and a New function", sourceType.Name))
+	}
+
+	sourceTypesMu.Lock()
+	defer sourceTypesMu.Unlock()
+
+	if _, ok := sourceTypes[name]; ok {
+		panic(fmt.Sprintf("fetchers: Register called twice for %q", name))
+	}
+	sourceTypes[name] = sourceType
+}
+
+// SourceTypes returns the registered source types in alphabetical order
+func SourceTypes() []SourceType {
+	sourceTypesMu.RLock()
+	defer sourceTypesMu.RUnlock()
+
+	types := make([]SourceType, 0, len(sourceTypes))
+	for _, sourceType := range sourceTypes {
+		types = append(types, sourceType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types
+}
+
+// Names returns the names of the registered source types in alphabetical
+// order
+func Names() []string {
+	sourceTypesMu.RLock()
+	defer sourceTypesMu.RUnlock()
+
+	names := make([]string, 0, len(sourceTypes))
+	for name := range sourceTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register(SourceType{
+		Name:        "rest",
+		MIMETypes:   []string{"application/json"},
+		Description: "JSON from a REST API over HTTP",
+		New:         func() Fetcher { return &RESTFetcher{} },
+	})
+}
+
+// GetFetcher returns a new fetcher of the source type registered as
+// sourceType, ignoring case
 func GetFetcher(sourceType string) (Fetcher, error) {
-	switch sourceType {
-	case "rest":
-		return &RESTFetcher{}, nil
-	// Future implementations can be added here
-	// case "database":
-	//     return &DatabaseFetcher{}, nil
-	default:
-		return nil, ErrUnsupportedSourceType
+	sourceTypesMu.RLock()
+	registered, ok := sourceTypes[strings.ToLower(sourceType)]
+	sourceTypesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s (available: %s)", ErrUnsupportedSourceType, sourceType, strings.Join(Names(), ", "))
 	}
+	return registered.New(), nil
 }
@@ -0,0 +1,70 @@
+package fetchers
+
+import (
+	"brokolisql-go/pkg/common"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// staticFetcher is a fetcher registered by a test
+type staticFetcher struct{}
+
+func (f *staticFetcher) Fetch(source string, options map[string]interface{}) (*common.DataSet, error) {
+	return &common.DataSet{Columns: []string{"source"}, Rows: []common.DataRow{{"source": source}}}, nil
+}
+
+func TestRegister(t *testing.T) {
+	Register(SourceType{Name: "Static", Description: "Fixed rows", New: func() Fetcher { return &staticFetcher{} }})
+	defer func() {
+		sourceTypesMu.Lock()
+		delete(sourceTypes, "static")
+		sourceTypesMu.Unlock()
+	}()
+
+	fetcher, err := GetFetcher("static")
+	if err != nil {
+		t.Fatalf("GetFetcher() error = %v", err)
+	}
+	dataset, err := fetcher.Fetch("inventory", nil)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if got := dataset.Rows[0]["source"]; got != "inventory" {
+		t.Errorf("Fetch() source = %v, want inventory", got)
+	}
+
+	if want := []string{"rest", "static"}; !reflect.DeepEqual(Names(), want) {
+		t.Errorf("Names() = %q, want %q", Names(), want)
+	}
+
+	_, err = GetFetcher("ftp")
+	if !errors.Is(err, ErrUnsupportedSourceType) || !strings.Contains(err.Error(), "rest, static") {
+		t.Errorf("GetFetcher() error = %v, want ErrUnsupportedSourceType listing the source types", err)
+	}
+}
+
+func TestRegister_Invalid(t *testing.T) {
+	newFetcher := func() Fetcher { return &staticFetcher{} }
+	for _, sourceType := range []SourceType{{Name: "REST", New: newFetcher}, {New: newFetcher}, {Name: "empty"}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%+v) did not panic", sourceType)
+				}
+			}()
+			Register(sourceType)
+		}()
+	}
+
+	if want := []string{"rest"}; !reflect.DeepEqual(Names(), want) {
+		t.Errorf("Names() = %q, want %q", Names(), want)
+	}
+	// The source type whose name was claimed is unaffected
+	if fetcher, err := GetFetcher("rest"); err != nil {
+		t.Errorf("GetFetcher() error = %v", err)
+	} else if _, ok := fetcher.(*RESTFetcher); !ok {
+		t.Errorf("GetFetcher() returned %T, want the REST fetcher", fetcher)
+	}
+}
@@ -37,12 +37,17 @@ func (f *RESTFetcher) Fetch(source string, options map[string]interface{}) (*com
 
 	requestOptions := f.extractRequestOptions(options)
 
+	selector, err := f.extractRecordSelector(options)
+	if err != nil {
+		return nil, err
+	}
+
 	responseBody, err := f.executeRequest(source, requestOptions)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := common.ParseJSONData(responseBody)
+	data, err := common.ParseJSONRecords(responseBody, selector)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
 	}
@@ -86,6 +91,18 @@ func (f *RESTFetcher) extractRequestOptions(options map[string]interface{}) Requ
 	return requestOptions
 }
 
+// extractRecordSelector reads the "record_path" option, the path to the
+// records in a wrapped response, and the "lift" option, the envelope fields
+// added to every record. It returns nil when neither is set.
+func (f *RESTFetcher) extractRecordSelector(options map[string]interface{}) (*common.RecordSelector, error) {
+	recordPath, _ := options["record_path"].(string)
+	lift, _ := options["lift"].([]string)
+	if recordPath == "" && len(lift) == 0 {
+		return nil, nil
+	}
+	return common.NewRecordSelector(recordPath, lift)
+}
+
 func (f *RESTFetcher) executeRequest(url string, options RequestOptions) ([]byte, error) {
 
 	req, err := http.NewRequest(options.Method, url, nil)
@@ -36,6 +36,13 @@ func TestRESTFetcher_Fetch(t *testing.T) {
 				},
 				"tags": ["developer", "golang"]
 			}`)))
+		case "/wrapped":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			errors.CheckErrorMultiple(w.Write([]byte(`{
+				"data": {"items": [{"id": 1}, {"id": 2}, {"id": 3}]},
+				"meta": {"generated_at": "2024-01-02T15:04:05Z"}
+			}`)))
 		case "/empty":
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusOK)
@@ -91,6 +98,20 @@ func TestRESTFetcher_Fetch(t *testing.T) {
 			wantErr:     false,
 			expectedLen: 2,
 		},
+		{
+			name:        "Fetch wrapped records",
+			source:      server.URL + "/wrapped",
+			options:     map[string]interface{}{"record_path": "$.data.items", "lift": []string{"meta.generated_at"}},
+			wantErr:     false,
+			expectedLen: 3,
+		},
+		{
+			name:        "Fetch with a record path that does not match",
+			source:      server.URL + "/wrapped",
+			options:     map[string]interface{}{"record_path": "/data/rows"},
+			wantErr:     true,
+			expectedLen: 0,
+		},
 		{
 			name:        "Fetch empty response",
 			source:      server.URL + "/empty",
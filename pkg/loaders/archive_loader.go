@@ -0,0 +1,160 @@
+package loaders
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"brokolisql-go/pkg/common"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// zipMagic starts every zip archive that holds files
+var zipMagic = []byte("PK\x03\x04")
+
+// ArchiveLoader reads zip and tar archives, the latter compressed or not.
+// Each file in the archive is read with the loader for its extension,
+// configured from Config, and becomes a table named after the file. Files of
+// other formats are skipped.
+type ArchiveLoader struct {
+	Config *Config // Options for the formats of the archived files; nil uses the defaults
+}
+
+// Load reads an archive that holds a single table
+func (l *ArchiveLoader) Load(filePath string) (*common.DataSet, error) {
+	tables, err := l.LoadTables(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) != 1 {
+		return nil, fmt.Errorf("archive holds %d tables, convert it into one table per file instead", len(tables))
+	}
+	return tables[0].DataSet, nil
+}
+
+// LoadTables reads every file of a supported format in the archive, in
+// archive order
+func (l *ArchiveLoader) LoadTables(filePath string) ([]NamedDataSet, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	content, err := decompress(file)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	var tables []NamedDataSet
+	visit := func(name string, r io.Reader) error {
+		loaded, err := l.loadEntry(name, r)
+		tables = append(tables, loaded...)
+		return err
+	}
+
+	br := bufio.NewReader(content)
+	if head, _ := br.Peek(len(zipMagic)); bytes.Equal(head, zipMagic) {
+		// Zip archives are read from their directory at the end, so
+		// compressed ones are decompressed to a file first
+		zipPath := filePath
+		if sniffCompression(filePath) != "" {
+			var remove func()
+			if zipPath, remove, err = writeTemp(br, "archive.zip"); err != nil {
+				return nil, err
+			}
+			defer remove()
+		}
+		err = walkZip(zipPath, visit)
+	} else {
+		err = walkTar(br, visit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("archive %s holds no files of a supported format", filepath.Base(filePath))
+	}
+	return tables, nil
+}
+
+// walkZip calls visit with the name and content of each file in a zip archive
+func walkZip(filePath string, visit func(name string, r io.Reader) error) error {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read zip archive: %w", err)
+	}
+	defer archive.Close()
+
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read %s from zip archive: %w", f.Name, err)
+		}
+		err = visit(f.Name, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkTar calls visit with the name and content of each regular file in a tar
+// archive
+func walkTar(r io.Reader, visit func(name string, r io.Reader) error) error {
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		if !header.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		if err := visit(header.Name, archive); err != nil {
+			return err
+		}
+	}
+}
+
+// loadEntry reads the tables of a file in the archive, named after the file
+// without its directory and extensions. Hidden files, such as the resource
+// forks macOS adds to zip archives, and files of other formats are skipped
+// without being extracted.
+func (l *ArchiveLoader) loadEntry(name string, r io.Reader) ([]NamedDataSet, error) {
+	config := l.Config
+	if config == nil {
+		config = &Config{}
+	}
+
+	base := path.Base(name)
+	if strings.HasPrefix(base, ".") || strings.HasPrefix(name, "__MACOSX/") || !supportedFile(base) {
+		return nil, nil
+	}
+
+	filePath, remove, err := writeTemp(r, base)
+	if err != nil {
+		return nil, err
+	}
+	defer remove()
+
+	tables, err := loadFileTables(filePath, base, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", name, err)
+	}
+	return tables, nil
+}
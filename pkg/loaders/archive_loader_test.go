@@ -0,0 +1,149 @@
+package loaders
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+type archiveEntry struct {
+	name    string
+	content []byte
+}
+
+func zipBytes(t *testing.T, entries []archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		f, err := w.Create(e.name)
+		if err != nil {
+			t.Fatalf("Failed to add %s to zip archive: %v", e.name, err)
+		}
+		f.Write(e.content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close zip archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzBytes(t *testing.T, entries []archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	for _, e := range entries {
+		if err := w.WriteHeader(&tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("Failed to add %s to tar archive: %v", e.name, err)
+		}
+		w.Write(e.content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close tar archive: %v", err)
+	}
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestArchiveLoader_LoadTables(t *testing.T) {
+	entries := []archiveEntry{
+		{name: "export/users.csv", content: []byte("id,name\n1,Ann\n2,Bob\n")},
+		{name: "export/README.txt", content: []byte("Nightly export")},
+		{name: "export/orders.json", content: []byte(`[{"id": 1, "total": 9.5}]`)},
+		{name: "export/2024/sales.csv.gz", content: gzipBytes(t, "day,amount\n2024-01-01,10\n")},
+		{name: "__MACOSX/export/._users.csv", content: []byte{0, 5, 22, 7}},
+	}
+
+	tests := []struct {
+		name string
+		file string
+		data []byte
+	}{
+		{name: "zip", file: "export.zip", data: zipBytes(t, entries)},
+		{name: "tar.gz", file: "export.tar.gz", data: tarGzBytes(t, entries)},
+		{name: "tgz", file: "export.tgz", data: tarGzBytes(t, entries)},
+		{name: "Compressed zip", file: "export.zip.gz", data: gzipBytes(t, string(zipBytes(t, entries)))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, tt.file, tt.data)
+			loader, err := GetLoader(path)
+			if err != nil {
+				t.Fatalf("GetLoader() error = %v", err)
+			}
+			archive, ok := loader.(*ArchiveLoader)
+			if !ok {
+				t.Fatalf("GetLoader() = %T, want *ArchiveLoader", loader)
+			}
+
+			tables, err := archive.LoadTables(path)
+			if err != nil {
+				t.Fatalf("LoadTables() error = %v", err)
+			}
+
+			want := []struct {
+				name string
+				rows int
+			}{{"users", 2}, {"orders", 1}, {"sales", 1}}
+			if len(tables) != len(want) {
+				t.Fatalf("LoadTables() returned %d tables, want %d", len(tables), len(want))
+			}
+			for i, w := range want {
+				if tables[i].Name != w.name || len(tables[i].DataSet.Rows) != w.rows {
+					t.Errorf("Table %d = %s with %d rows, want %s with %d rows", i, tables[i].Name, len(tables[i].DataSet.Rows), w.name, w.rows)
+				}
+			}
+		})
+	}
+}
+
+func TestArchiveLoader_Load(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []archiveEntry
+		wantErr string
+	}{
+		{
+			name:    "Single file",
+			entries: []archiveEntry{{name: "users.csv", content: []byte("id,name\n1,Ann\n")}},
+		},
+		{
+			name: "Several files",
+			entries: []archiveEntry{
+				{name: "users.csv", content: []byte("id,name\n1,Ann\n")},
+				{name: "teams.csv", content: []byte("id,name\n1,Core\n")},
+			},
+			wantErr: "archive holds 2 tables",
+		},
+		{
+			name:    "No supported files",
+			entries: []archiveEntry{{name: "notes.txt", content: []byte("nothing here")}},
+			wantErr: "archive data.zip holds no files of a supported format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, "data.zip", zipBytes(t, tt.entries))
+			dataset, err := (&ArchiveLoader{}).Load(path)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Load() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if len(dataset.Rows) != 1 || dataset.Rows[0]["name"] != "Ann" {
+				t.Errorf("Rows = %v", dataset.Rows)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package loaders
+
+import (
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+)
+
+// AvroLoader reads Avro object container files one record at a time. Column
+// types come from the writer schema embedded in the file: primitive and
+// logical types map to the matching SQL types, and unions with null make a
+// column nullable. Nested records and arrays of records are kept as objects,
+// so each record type becomes a related table with its declared types.
+type AvroLoader struct{}
+
+func (l *AvroLoader) Load(filePath string) (*common.DataSet, error) {
+	it, err := l.Stream(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return common.CollectDataSet(it)
+}
+
+func (l *AvroLoader) Stream(filePath string) (common.RowIterator, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Avro file: %w", err)
+	}
+
+	it, err := l.open(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return it, nil
+}
+
+func (l *AvroLoader) open(file *os.File) (*avroIterator, error) {
+	dec, err := ocf.NewDecoder(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Avro file: %w", err)
+	}
+
+	record, ok := avroResolve(dec.Schema()).(*avro.RecordSchema)
+	if !ok {
+		return nil, fmt.Errorf("top-level Avro schema must be a record, found %s", dec.Schema().Type())
+	}
+
+	it := &avroIterator{
+		file:   file,
+		dec:    dec,
+		schema: record,
+		types:  make(map[string]dialects.ColumnDef),
+	}
+	for _, field := range record.Fields() {
+		it.columns = append(it.columns, field.Name())
+		avroDeclare(it.types, field.Name(), field.Name(), field.Type())
+	}
+	return it, nil
+}
+
+type avroIterator struct {
+	file    *os.File
+	dec     *ocf.Decoder
+	schema  *avro.RecordSchema
+	columns []string
+	types   map[string]dialects.ColumnDef
+}
+
+func (it *avroIterator) Columns() []string {
+	return it.columns
+}
+
+func (it *avroIterator) ColumnTypes() map[string]dialects.ColumnDef {
+	return it.types
+}
+
+func (it *avroIterator) Next() (common.DataRow, error) {
+	if !it.dec.HasNext() {
+		if err := it.dec.Error(); err != nil {
+			return nil, fmt.Errorf("failed to read Avro file: %w", err)
+		}
+		return nil, io.EOF
+	}
+
+	var value interface{}
+	if err := it.dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("failed to decode Avro record: %w", err)
+	}
+
+	record, _ := avroValue(it.schema, value).(map[string]interface{})
+	return common.ConvertToDataRow(record), nil
+}
+
+func (it *avroIterator) Close() error {
+	return it.file.Close()
+}
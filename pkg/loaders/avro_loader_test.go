@@ -0,0 +1,248 @@
+package loaders
+
+import (
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hamba/avro/v2/ocf"
+)
+
+const avroOrderSchema = `{
+	"type": "record", "name": "Order", "namespace": "shop",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "quantity", "type": "int"},
+		{"name": "note", "type": ["null", "string"]},
+		{"name": "day", "type": {"type": "int", "logicalType": "date"}},
+		{"name": "placed", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "total", "type": {"type": "bytes", "logicalType": "decimal", "precision": 9, "scale": 2}},
+		{"name": "ref", "type": {"type": "string", "logicalType": "uuid"}},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "PAID"]}},
+		{"name": "paid", "type": "boolean"},
+		{"name": "weight", "type": ["null", "double"]},
+		{"name": "code", "type": {"type": "fixed", "name": "Code", "size": 2}},
+		{"name": "attrs", "type": {"type": "map", "values": "string"}},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "customer", "type": ["null", {
+			"type": "record", "name": "Customer",
+			"fields": [
+				{"name": "name", "type": "string"},
+				{"name": "since", "type": {"type": "int", "logicalType": "date"}}
+			]
+		}]},
+		{"name": "lines", "type": {"type": "array", "items": {
+			"type": "record", "name": "Line",
+			"fields": [
+				{"name": "sku", "type": "string"},
+				{"name": "amount", "type": "double"}
+			]
+		}}}
+	]
+}`
+
+// writeAvro saves records as an Avro object container file and returns its
+// path
+func writeAvro(t *testing.T, schema string, records []map[string]interface{}) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "data.avro")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create Avro file: %v", err)
+	}
+	defer file.Close()
+
+	enc, err := ocf.NewEncoder(schema, file)
+	if err != nil {
+		t.Fatalf("Failed to create Avro encoder: %v", err)
+	}
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			t.Fatalf("Failed to write Avro record: %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Failed to close Avro encoder: %v", err)
+	}
+	return path
+}
+
+func TestGetLoader_Avro(t *testing.T) {
+	loader, err := GetLoader("test.avro")
+	if err != nil {
+		t.Fatalf("GetLoader() error = %v", err)
+	}
+	if _, ok := loader.(*AvroLoader); !ok {
+		t.Errorf("GetLoader() returned wrong loader type for .avro file")
+	}
+}
+
+func TestAvroLoader_Load(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	placed := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	ref := "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"
+	path := writeAvro(t, avroOrderSchema, []map[string]interface{}{
+		{
+			"id": int64(1), "quantity": 3, "note": map[string]interface{}{"string": "gift"}, "day": day, "placed": placed,
+			"total": big.NewRat(1250, 100), "ref": ref, "status": "PAID", "paid": true,
+			"weight": map[string]interface{}{"double": 1.5}, "code": [2]byte{'o', 'k'},
+			"attrs": map[string]interface{}{"k": "v"}, "tags": []interface{}{"a", "b"},
+			"customer": map[string]interface{}{"shop.Customer": map[string]interface{}{"name": "Ann", "since": day}},
+			"lines":    []interface{}{map[string]interface{}{"sku": "A1", "amount": 2.5}},
+		},
+		{
+			"id": int64(2), "quantity": 1, "note": nil, "day": day, "placed": placed,
+			"total": big.NewRat(-5, 100), "ref": ref, "status": "NEW", "paid": false,
+			"weight": nil, "code": [2]byte{0xff, 0x00},
+			"attrs": map[string]interface{}{}, "tags": []interface{}{},
+			"customer": nil, "lines": []interface{}{},
+		},
+	})
+
+	got, err := (&AvroLoader{}).Load(path)
+	if err != nil {
+		t.Fatalf("AvroLoader.Load() error = %v", err)
+	}
+
+	wantColumns := []string{"id", "quantity", "note", "day", "placed", "total", "ref", "status", "paid", "weight", "code", "attrs", "tags", "customer", "lines"}
+	if !reflect.DeepEqual(got.Columns, wantColumns) {
+		t.Errorf("Columns = %q, want %q", got.Columns, wantColumns)
+	}
+
+	wantRows := []common.DataRow{
+		{
+			"id": int64(1), "quantity": int64(3), "note": "gift", "day": day, "placed": placed,
+			"total": "12.50", "ref": ref, "status": "PAID", "paid": true, "weight": 1.5, "code": "ok",
+			"attrs": `{"k":"v"}`, "tags": `["a","b"]`,
+			"customer": `{"name":"Ann","since":"2024-03-01T00:00:00Z"}`, "lines": `[{"amount":2.5,"sku":"A1"}]`,
+		},
+		{
+			"id": int64(2), "quantity": int64(1), "note": nil, "day": day, "placed": placed,
+			"total": "-0.05", "ref": ref, "status": "NEW", "paid": false, "weight": nil, "code": "ff00",
+			"attrs": `{}`, "tags": `[]`, "customer": nil, "lines": `[]`,
+		},
+	}
+	if !reflect.DeepEqual(got.Rows, wantRows) {
+		t.Errorf("Rows = %v, want %v", got.Rows, wantRows)
+	}
+
+	// Records are left to become related tables, with their fields declared
+	// under their path
+	wantTypes := map[string]dialects.ColumnDef{
+		"id":             {Name: "id", Type: dialects.SQLTypeBigInt},
+		"quantity":       {Name: "quantity", Type: dialects.SQLTypeInteger},
+		"note":           {Name: "note", Type: dialects.SQLTypeText, Nullable: true},
+		"day":            {Name: "day", Type: dialects.SQLTypeDate},
+		"placed":         {Name: "placed", Type: dialects.SQLTypeDateTime},
+		"total":          {Name: "total", Type: dialects.SQLTypeDecimal, Precision: 9, Scale: 2},
+		"ref":            {Name: "ref", Type: dialects.SQLTypeText},
+		"status":         {Name: "status", Type: dialects.SQLTypeText},
+		"paid":           {Name: "paid", Type: dialects.SQLTypeBoolean},
+		"weight":         {Name: "weight", Type: dialects.SQLTypeFloat, Nullable: true},
+		"code":           {Name: "code", Type: dialects.SQLTypeText},
+		"attrs":          {Name: "attrs", Type: dialects.SQLTypeText},
+		"tags":           {Name: "tags", Type: dialects.SQLTypeText},
+		"customer.name":  {Name: "name", Type: dialects.SQLTypeText},
+		"customer.since": {Name: "since", Type: dialects.SQLTypeDate},
+		"lines.sku":      {Name: "sku", Type: dialects.SQLTypeText},
+		"lines.amount":   {Name: "amount", Type: dialects.SQLTypeFloat},
+	}
+	if !reflect.DeepEqual(got.Types, wantTypes) {
+		t.Errorf("Types = %v, want %v", got.Types, wantTypes)
+	}
+}
+
+func TestAvroLoader_Unions(t *testing.T) {
+	schema := `{
+		"type": "record", "name": "Event",
+		"fields": [
+			{"name": "value", "type": ["null", "int", "string"]},
+			{"name": "at", "type": ["null", {"type": "long", "logicalType": "timestamp-micros"}]}
+		]
+	}`
+	at := time.Date(2024, 3, 1, 9, 30, 0, 1000, time.UTC)
+	path := writeAvro(t, schema, []map[string]interface{}{
+		{"value": map[string]interface{}{"int": 5}, "at": map[string]interface{}{"long.timestamp-micros": at}},
+		{"value": map[string]interface{}{"string": "five"}, "at": nil},
+		{"value": nil, "at": nil},
+	})
+
+	got, err := (&AvroLoader{}).Load(path)
+	if err != nil {
+		t.Fatalf("AvroLoader.Load() error = %v", err)
+	}
+
+	wantRows := []common.DataRow{
+		{"value": int64(5), "at": at},
+		{"value": "five", "at": nil},
+		{"value": nil, "at": nil},
+	}
+	if !reflect.DeepEqual(got.Rows, wantRows) {
+		t.Errorf("Rows = %v, want %v", got.Rows, wantRows)
+	}
+
+	// Unions of several types hold values of any of them
+	wantTypes := map[string]dialects.ColumnDef{
+		"value": {Name: "value", Type: dialects.SQLTypeText, Nullable: true},
+		"at":    {Name: "at", Type: dialects.SQLTypeDateTime, Nullable: true},
+	}
+	if !reflect.DeepEqual(got.Types, wantTypes) {
+		t.Errorf("Types = %v, want %v", got.Types, wantTypes)
+	}
+}
+
+func TestAvroLoader_Stream(t *testing.T) {
+	schema := `{"type": "record", "name": "Reading", "fields": [{"name": "sensor", "type": "int"}]}`
+	records := make([]map[string]interface{}, 25)
+	for i := range records {
+		records[i] = map[string]interface{}{"sensor": i}
+	}
+	path := writeAvro(t, schema, records)
+
+	it, err := (&AvroLoader{}).Stream(path)
+	if err != nil {
+		t.Fatalf("AvroLoader.Stream() error = %v", err)
+	}
+	dataset, err := common.CollectDataSet(it)
+	if err != nil {
+		t.Fatalf("CollectDataSet() error = %v", err)
+	}
+
+	if len(dataset.Rows) != len(records) {
+		t.Fatalf("Stream() returned %d rows, want %d", len(dataset.Rows), len(records))
+	}
+	for i, row := range dataset.Rows {
+		if row["sensor"] != int64(i) {
+			t.Errorf("Row %d = %v", i, row)
+		}
+	}
+}
+
+func TestAvroLoader_NotARecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.avro")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create Avro file: %v", err)
+	}
+	enc, err := ocf.NewEncoder(`"string"`, file)
+	if err != nil {
+		t.Fatalf("Failed to create Avro encoder: %v", err)
+	}
+	if err := enc.Encode("text"); err != nil {
+		t.Fatalf("Failed to write Avro value: %v", err)
+	}
+	enc.Close()
+	file.Close()
+
+	_, err = (&AvroLoader{}).Load(path)
+	if err == nil || !strings.Contains(err.Error(), "top-level Avro schema must be a record, found string") {
+		t.Errorf("AvroLoader.Load() error = %v, want a record schema error", err)
+	}
+}
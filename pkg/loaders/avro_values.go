@@ -0,0 +1,231 @@
+package loaders
+
+import (
+	"brokolisql-go/pkg/dialects"
+	"fmt"
+	"math/big"
+	"reflect"
+	"time"
+
+	"github.com/hamba/avro/v2"
+)
+
+// avroResolve returns the schema a named type reference points to
+func avroResolve(schema avro.Schema) avro.Schema {
+	if ref, ok := schema.(*avro.RefSchema); ok {
+		return ref.Schema()
+	}
+	return schema
+}
+
+// avroBranches returns the schema of a field without its null branch, and
+// whether null is allowed. Unions of more than one other type are returned
+// as they are.
+func avroBranches(schema avro.Schema) (avro.Schema, bool) {
+	schema = avroResolve(schema)
+	union, ok := schema.(*avro.UnionSchema)
+	if !ok {
+		return schema, schema.Type() == avro.Null
+	}
+
+	var others []avro.Schema
+	nullable := false
+	for _, branch := range union.Types() {
+		if branch.Type() == avro.Null {
+			nullable = true
+		} else {
+			others = append(others, avroResolve(branch))
+		}
+	}
+	if len(others) == 1 {
+		return others[0], nullable
+	}
+	return union, nullable
+}
+
+// avroLogical returns the logical type of a schema, or "" if it has none
+func avroLogical(schema avro.Schema) avro.LogicalType {
+	if ls, ok := schema.(avro.LogicalTypeSchema); ok && ls.Logical() != nil {
+		return ls.Logical().Type()
+	}
+	return ""
+}
+
+// avroDeclare records the SQL column of the field at path. Records are not
+// columns themselves: their fields are declared under the record's path, as
+// are the fields of records in arrays, and become the columns of related
+// tables. Maps, other arrays and unions of several types are JSON text.
+func avroDeclare(types map[string]dialects.ColumnDef, path, name string, schema avro.Schema) {
+	schema, nullable := avroBranches(schema)
+
+	switch s := schema.(type) {
+	case *avro.RecordSchema:
+		for _, field := range s.Fields() {
+			avroDeclare(types, path+"."+field.Name(), field.Name(), field.Type())
+		}
+		return
+	case *avro.ArraySchema:
+		if items, _ := avroBranches(s.Items()); items.Type() == avro.Record {
+			avroDeclare(types, path, name, items)
+			return
+		}
+	}
+
+	types[path] = avroColumnDef(name, schema, nullable)
+}
+
+// avroColumnDef returns the SQL column for a field of the given type, taken
+// from its logical type if it has one
+func avroColumnDef(name string, schema avro.Schema, nullable bool) dialects.ColumnDef {
+	def := dialects.ColumnDef{Name: name, Type: dialects.SQLTypeText, Nullable: nullable}
+
+	switch avroLogical(schema) {
+	case avro.Decimal:
+		if decimal, ok := schema.(avro.LogicalTypeSchema).Logical().(*avro.DecimalLogicalSchema); ok {
+			def.Type = dialects.SQLTypeDecimal
+			def.Precision, def.Scale = decimal.Precision(), decimal.Scale()
+		}
+		return def
+	case avro.Date:
+		def.Type = dialects.SQLTypeDate
+		return def
+	case avro.TimestampMillis, avro.TimestampMicros, avro.LocalTimestampMillis, avro.LocalTimestampMicros:
+		def.Type = dialects.SQLTypeDateTime
+		return def
+	case avro.TimeMillis, avro.TimeMicros, avro.UUID, avro.Duration:
+		return def
+	}
+
+	switch schema.Type() {
+	case avro.Int:
+		def.Type = dialects.SQLTypeInteger
+	case avro.Long:
+		def.Type = dialects.SQLTypeBigInt
+	case avro.Float, avro.Double:
+		def.Type = dialects.SQLTypeFloat
+	case avro.Boolean:
+		def.Type = dialects.SQLTypeBoolean
+	}
+	return def
+}
+
+// avroTypeName returns the name a union branch is keyed by when a decoded
+// value keeps its branch: the full name of named types, and the type and
+// any logical type of the others
+func avroTypeName(schema avro.Schema) string {
+	if named, ok := schema.(avro.NamedSchema); ok {
+		return named.FullName()
+	}
+	name := string(schema.Type())
+	if logical := avroLogical(schema); logical != "" {
+		name += "." + string(logical)
+	}
+	return name
+}
+
+// avroValue converts a decoded value of the given type to the Go type of its
+// SQL column: int64 for integers, float64, a decimal string for decimals,
+// time.Time for dates and timestamps, and text for times of day and binary
+// values. Records and maps become maps and arrays slices of converted values.
+func avroValue(schema avro.Schema, v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	switch s := avroResolve(schema).(type) {
+	case *avro.UnionSchema:
+		// Values of some branches are decoded keyed by the branch's name
+		if wrapped, ok := v.(map[string]interface{}); ok && len(wrapped) == 1 {
+			for _, branch := range s.Types() {
+				if inner, ok := wrapped[avroTypeName(avroResolve(branch))]; ok {
+					return avroValue(branch, inner)
+				}
+			}
+		}
+		if branch, _ := avroBranches(s); branch != s {
+			return avroValue(branch, v)
+		}
+		return avroScalar(v, -1)
+
+	case *avro.RecordSchema:
+		values, ok := v.(map[string]interface{})
+		if !ok {
+			return avroScalar(v, -1)
+		}
+		record := make(map[string]interface{}, len(s.Fields()))
+		for _, field := range s.Fields() {
+			record[field.Name()] = avroValue(field.Type(), values[field.Name()])
+		}
+		return record
+
+	case *avro.ArraySchema:
+		items, ok := v.([]interface{})
+		if !ok {
+			return avroScalar(v, -1)
+		}
+		converted := make([]interface{}, len(items))
+		for i, item := range items {
+			converted[i] = avroValue(s.Items(), item)
+		}
+		return converted
+
+	case *avro.MapSchema:
+		entries, ok := v.(map[string]interface{})
+		if !ok {
+			return avroScalar(v, -1)
+		}
+		converted := make(map[string]interface{}, len(entries))
+		for key, value := range entries {
+			converted[key] = avroValue(s.Values(), value)
+		}
+		return converted
+
+	default:
+		scale := -1
+		if avroLogical(s) == avro.Decimal {
+			if decimal, ok := s.(avro.LogicalTypeSchema).Logical().(*avro.DecimalLogicalSchema); ok {
+				scale = decimal.Scale()
+			}
+		}
+		return avroScalar(v, scale)
+	}
+}
+
+// avroScalar converts a decoded primitive value. Decimals are written with
+// scale digits after the point, or as many as they need if scale is negative.
+func avroScalar(v interface{}, scale int) interface{} {
+	switch x := v.(type) {
+	case int:
+		return int64(x)
+	case int32:
+		return int64(x)
+	case float32:
+		return float64(x)
+	case *big.Rat:
+		if scale < 0 {
+			// Decimals have a power of ten denominator
+			scale = 0
+			for unit := big.NewInt(1); unit.Cmp(x.Denom()) < 0; unit.Mul(unit, big.NewInt(10)) {
+				scale++
+			}
+		}
+		return x.FloatString(scale)
+	case time.Time:
+		return x.UTC()
+	case time.Duration:
+		return timeOfDay(x)
+	case []byte:
+		return binaryText(x)
+	case string, bool, int64, float64:
+		return x
+	}
+
+	// Fixed values are decoded as byte arrays
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return binaryText(b)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,198 @@
+package loaders
+
+import (
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressionExtensions are the extensions of compressed files, whose format
+// is given by the extension before them, such as data.csv.gz
+var compressionExtensions = map[string]bool{
+	".gz":   true,
+	".gzip": true,
+	".bz2":  true,
+	".zst":  true,
+	".zstd": true,
+}
+
+// Compression formats, recognised by the bytes their content starts with
+const (
+	compressionGzip  = "gzip"
+	compressionBzip2 = "bzip2"
+	compressionZstd  = "zstd"
+)
+
+var compressionMagic = []struct {
+	format string
+	magic  []byte
+}{
+	{compressionGzip, []byte{0x1f, 0x8b}},
+	{compressionBzip2, []byte("BZh")},
+	{compressionZstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+}
+
+// TrimCompression returns filePath without its compression extension, such
+// as data.csv for data.csv.gz, or filePath itself if it has none
+func TrimCompression(filePath string) string {
+	ext := filepath.Ext(filePath)
+	if compressionExtensions[strings.ToLower(ext)] {
+		return strings.TrimSuffix(filePath, ext)
+	}
+	return filePath
+}
+
+// detectCompression returns the compression format of content starting with
+// head, or "" if it is not compressed
+func detectCompression(head []byte) string {
+	for _, c := range compressionMagic {
+		if bytes.HasPrefix(head, c.magic) {
+			return c.format
+		}
+	}
+	return ""
+}
+
+// sniffCompression returns the compression format of the file at filePath,
+// or "" if it is not compressed or cannot be read
+func sniffCompression(filePath string) string {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	head := make([]byte, 4)
+	n, _ := io.ReadFull(file, head)
+	return detectCompression(head[:n])
+}
+
+// decompress returns a reader of the decompressed content of r, in the
+// compression format its first bytes show. Content that is not compressed is
+// read as it is.
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(4)
+
+	switch detectCompression(head) {
+	case compressionGzip:
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip data: %w", err)
+		}
+		return zr, nil
+	case compressionBzip2:
+		return io.NopCloser(bzip2.NewReader(br)), nil
+	case compressionZstd:
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zstd data: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return io.NopCloser(br), nil
+}
+
+// writeTemp copies r to a file called name in a new temporary directory and
+// returns its path. remove deletes the directory.
+func writeTemp(r io.Reader, name string) (path string, remove func(), err error) {
+	dir, err := os.MkdirTemp("", "brokolisql-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	remove = func() { os.RemoveAll(dir) }
+
+	path = filepath.Join(dir, filepath.Base(name))
+	file, err := os.Create(path)
+	if err != nil {
+		remove()
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	_, err = io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		remove()
+		return "", nil, fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+	return path, remove, nil
+}
+
+// CompressedLoader reads gzip, bzip2 and zstd compressed files with the loader
+// of the format they hold. The format is recognised from the file's first
+// bytes, so files that turn out not to be compressed are read as they are.
+// The content is decompressed to a temporary file first, as some formats are
+// read more than once or out of order.
+type CompressedLoader struct {
+	Loader Loader // Loader of the decompressed content
+	Name   string // File name of the decompressed content, such as data.csv
+}
+
+func (l *CompressedLoader) Load(filePath string) (*common.DataSet, error) {
+	path, remove, err := l.extract(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer remove()
+
+	return l.Loader.Load(path)
+}
+
+func (l *CompressedLoader) Stream(filePath string) (common.RowIterator, error) {
+	path, remove, err := l.extract(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	it, err := OpenStream(l.Loader, path)
+	if err != nil {
+		remove()
+		return nil, err
+	}
+	return &tempFileIterator{RowIterator: it, remove: remove}, nil
+}
+
+// extract decompresses the file at filePath to a temporary file
+func (l *CompressedLoader) extract(filePath string) (string, func(), error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open compressed file: %w", err)
+	}
+	defer file.Close()
+
+	content, err := decompress(file)
+	if err != nil {
+		return "", nil, err
+	}
+	defer content.Close()
+
+	return writeTemp(content, l.Name)
+}
+
+// tempFileIterator reads the rows of a temporary file, which is removed once
+// the iterator is closed
+type tempFileIterator struct {
+	common.RowIterator
+	remove func()
+}
+
+func (it *tempFileIterator) ColumnTypes() map[string]dialects.ColumnDef {
+	return common.ColumnTypesOf(it.RowIterator)
+}
+
+func (it *tempFileIterator) Close() error {
+	err := it.RowIterator.Close()
+	it.remove()
+	return err
+}
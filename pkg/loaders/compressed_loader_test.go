@@ -0,0 +1,180 @@
+package loaders
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// bzip2CSV is a small CSV file compressed with bzip2, which the standard
+// library can only read
+const bzip2CSV = "425a68393141592653591d53e2250000075d0000100004300030003623a0002213479468f2840000b560f4c6e0b4c3053f8bb9229c28480ea9f11280"
+
+const compressedCSV = "id,name\n1,Ann\n2,Bob\n"
+
+func gzipBytes(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatalf("Failed to compress: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to compress: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func zstdBytes(t *testing.T, content string) []byte {
+	t.Helper()
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("Failed to create zstd encoder: %v", err)
+	}
+	defer enc.Close()
+	return enc.EncodeAll([]byte(content), nil)
+}
+
+// writeFile saves content as name in a temporary directory and returns its
+// path
+func writeFile(t *testing.T, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestNewLoader_Compressed(t *testing.T) {
+	tests := []struct {
+		filePath  string
+		wantInner Loader
+		wantName  string
+		wantErr   bool
+	}{
+		{filePath: "data.csv.gz", wantInner: &CSVLoader{}, wantName: "data.csv"},
+		{filePath: "data.json.bz2", wantInner: &JSONLoader{}, wantName: "data.json"},
+		{filePath: "events.jsonl.zst", wantInner: &JSONLinesLoader{}, wantName: "events.jsonl"},
+		{filePath: "exports/orders.xml.GZ", wantInner: &XMLLoader{}, wantName: "orders.xml"},
+		{filePath: "data.gz", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filePath, func(t *testing.T) {
+			loader, err := GetLoader(tt.filePath)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnsupportedFormat) {
+					t.Errorf("GetLoader() error = %v, want ErrUnsupportedFormat", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetLoader() error = %v", err)
+			}
+
+			compressed, ok := loader.(*CompressedLoader)
+			if !ok {
+				t.Fatalf("GetLoader() = %T, want *CompressedLoader", loader)
+			}
+			if reflect.TypeOf(compressed.Loader) != reflect.TypeOf(tt.wantInner) {
+				t.Errorf("Loader = %T, want %T", compressed.Loader, tt.wantInner)
+			}
+			if compressed.Name != tt.wantName {
+				t.Errorf("Name = %q, want %q", compressed.Name, tt.wantName)
+			}
+		})
+	}
+
+	for _, path := range []string{"data.zip", "data.tar", "data.tar.gz", "data.tgz", "data.tar.zst"} {
+		if loader, err := GetLoader(path); err != nil {
+			t.Errorf("GetLoader(%q) error = %v", path, err)
+		} else if _, ok := loader.(*ArchiveLoader); !ok {
+			t.Errorf("GetLoader(%q) = %T, want *ArchiveLoader", path, loader)
+		}
+	}
+}
+
+func TestCompressedLoader_Load(t *testing.T) {
+	bzip2Data, err := hex.DecodeString(bzip2CSV)
+	if err != nil {
+		t.Fatalf("Failed to decode bzip2 data: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+	}{
+		{name: "gzip", file: "data.csv.gz", content: gzipBytes(t, compressedCSV)},
+		{name: "bzip2", file: "data.csv.bz2", content: bzip2Data},
+		{name: "zstd", file: "data.csv.zst", content: zstdBytes(t, compressedCSV)},
+		{name: "Not compressed after all", file: "data.csv.gz", content: []byte(compressedCSV)},
+		{name: "Compressed without the extension", file: "data.csv", content: gzipBytes(t, compressedCSV)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, tt.file, tt.content)
+			loader, err := GetLoader(path)
+			if err != nil {
+				t.Fatalf("GetLoader() error = %v", err)
+			}
+			if _, ok := loader.(*CompressedLoader); !ok {
+				t.Fatalf("GetLoader() = %T, want *CompressedLoader", loader)
+			}
+
+			dataset, err := loader.Load(path)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(dataset.Columns, []string{"id", "name"}) {
+				t.Errorf("Columns = %q", dataset.Columns)
+			}
+			if len(dataset.Rows) != 2 || dataset.Rows[1]["name"] != "Bob" {
+				t.Errorf("Rows = %v", dataset.Rows)
+			}
+		})
+	}
+}
+
+func TestCompressedLoader_Stream(t *testing.T) {
+	// Temporary files go to a directory the test can check is left empty
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	path := writeFile(t, "events.jsonl.zst", zstdBytes(t, "{\"id\": 1}\n{\"id\": 2}\n{\"id\": 3}\n"))
+	loader, err := GetLoader(path)
+	if err != nil {
+		t.Fatalf("GetLoader() error = %v", err)
+	}
+
+	it, err := OpenStream(loader, path)
+	if err != nil {
+		t.Fatalf("OpenStream() error = %v", err)
+	}
+	n := 0
+	for {
+		if _, err := it.Next(); err != nil {
+			break
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("Stream() returned %d rows, want 3", n)
+	}
+
+	if err := it.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("Close() left %d temporary files", len(entries))
+	}
+}
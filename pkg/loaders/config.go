@@ -0,0 +1,95 @@
+package loaders
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds loader options per input format, and the character encoding
+// of text inputs. It is read from a JSON or YAML file, for example:
+//
+//	format: csv
+//	encoding: windows-1252
+//	source_column: source_file
+//	csv:
+//	  delimiter: ";"
+//	  skip_rows: 2
+//	json:
+//	  record_path: $.data.items
+//	  lift: [meta.generated_at]
+//	jsonl:
+//	  skip_invalid: true
+//	xml:
+//	  row_path: /orders/order
+//	excel:
+//	  sheet: Summary
+//	  range: B3:F200
+//	parquet:
+//	  columns: [id, name, total]
+type Config struct {
+	Format       string           `json:"format,omitempty" yaml:"format,omitempty"`               // Format of the input, such as csv, detected from the extension or content if empty
+	Encoding     string           `json:"encoding,omitempty" yaml:"encoding,omitempty"`           // Encoding of text files, detected if empty or "auto"
+	SourceColumn string           `json:"source_column,omitempty" yaml:"source_column,omitempty"` // Column receiving the name of the file each row was read from, if not empty
+	CSV          CSVOptions       `json:"csv" yaml:"csv"`
+	JSON         JSONOptions      `json:"json" yaml:"json"`
+	JSONLines    JSONLinesOptions `json:"jsonl" yaml:"jsonl"`
+	XML          XMLOptions       `json:"xml" yaml:"xml"`
+	Excel        ExcelOptions     `json:"excel" yaml:"excel"`
+	Parquet      ParquetOptions   `json:"parquet" yaml:"parquet"`
+}
+
+// LoadConfig reads a loader config file. Files ending in .yaml or .yml are
+// parsed as YAML and anything else as JSON. Unknown fields are rejected so
+// that typos do not go unnoticed.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read loader config: %w", err)
+	}
+
+	var config Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&config)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse loader config: %w", err)
+	}
+
+	if config.Format != "" {
+		if _, err := lookupFormat(config.Format); err != nil {
+			return nil, fmt.Errorf("invalid loader config: %w", err)
+		}
+	}
+	if err := validateEncoding(config.Encoding); err != nil {
+		return nil, fmt.Errorf("invalid loader config: %w", err)
+	}
+	if err := config.CSV.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid loader config: %w", err)
+	}
+	if err := config.JSON.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid loader config: %w", err)
+	}
+	if err := config.XML.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid loader config: %w", err)
+	}
+	if err := config.Excel.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid loader config: %w", err)
+	}
+	if err := config.Parquet.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid loader config: %w", err)
+	}
+	return &config, nil
+}
@@ -0,0 +1,72 @@
+package loaders
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    CSVOptions
+		wantErr string
+	}{
+		{
+			name:    "YAML",
+			file:    "loaders.yaml",
+			content: "csv:\n  delimiter: \";\"\n  skip_rows: 2\n  no_header: true\n",
+			want:    CSVOptions{Delimiter: ";", SkipRows: 2, NoHeader: true},
+		},
+		{
+			name:    "JSON",
+			file:    "loaders.json",
+			content: `{"csv": {"quote": "'", "ragged": "pad"}}`,
+			want:    CSVOptions{Quote: "'", Ragged: RaggedPad},
+		},
+		{
+			name:    "Unknown field",
+			file:    "loaders.json",
+			content: `{"csv": {"separator": ";"}}`,
+			wantErr: `unknown field "separator"`,
+		},
+		{
+			name:    "Invalid option",
+			file:    "loaders.yml",
+			content: "csv:\n  trim: both\n",
+			wantErr: `invalid loader config: unknown CSV trim policy "both"`,
+		},
+		{
+			name:    "Unknown encoding",
+			file:    "loaders.yaml",
+			content: "encoding: ebcdic-klingon\n",
+			wantErr: `invalid loader config: unknown encoding "ebcdic-klingon"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+
+			config, err := LoadConfig(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadConfig() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if config.CSV != tt.want {
+				t.Errorf("LoadConfig() CSV = %+v, want %+v", config.CSV, tt.want)
+			}
+		})
+	}
+}
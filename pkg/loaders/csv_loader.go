@@ -2,55 +2,365 @@ package loaders
 
 import (
 	"brokolisql-go/pkg/common"
+	"bufio"
 	"encoding/csv"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"unicode/utf8"
 )
 
-type CSVLoader struct{}
+// Trim policies for CSVOptions.Trim
+const (
+	TrimNone    = "none"    // Keep all whitespace
+	TrimHeaders = "headers" // Trim column names only
+	TrimAll     = "all"     // Trim column names and values
+)
+
+// Ragged row policies for CSVOptions.Ragged
+const (
+	RaggedError = "error" // Fail on a record with a different number of fields
+	RaggedPad   = "pad"   // Fill missing fields with NULL; records with extra fields still fail
+	RaggedSkip  = "skip"  // Leave out records with a different number of fields
+)
+
+// QuoteNone turns quoting off, so that quote characters are ordinary data
+const QuoteNone = "none"
+
+// CSVOptions describes the dialect of a delimited text file. The zero value
+// reads comma separated files with a header row and double quotes.
+type CSVOptions struct {
+	Delimiter  string `json:"delimiter,omitempty" yaml:"delimiter,omitempty"`     // Field separator, "," if empty; "tab" or "\t" for tabs
+	Quote      string `json:"quote,omitempty" yaml:"quote,omitempty"`             // Quote character, `"` if empty; "none" disables quoting
+	LazyQuotes bool   `json:"lazy_quotes,omitempty" yaml:"lazy_quotes,omitempty"` // Accept stray quotes inside fields
+	Comment    string `json:"comment,omitempty" yaml:"comment,omitempty"`         // Lines starting with this character are skipped
+	SkipRows   int    `json:"skip_rows,omitempty" yaml:"skip_rows,omitempty"`     // Lines skipped before the header, such as report titles
+	NoHeader   bool   `json:"no_header,omitempty" yaml:"no_header,omitempty"`     // The first record is data; columns are named column_1, column_2, ...
+	Trim       string `json:"trim,omitempty" yaml:"trim,omitempty"`               // TrimNone, TrimHeaders (default) or TrimAll
+	Ragged     string `json:"ragged,omitempty" yaml:"ragged,omitempty"`           // RaggedError (default), RaggedPad or RaggedSkip
+}
+
+// csvDialect is a validated CSVOptions
+type csvDialect struct {
+	delimiter rune
+	quote     rune // 0 when quoting is off
+	comment   rune
+	trim      string
+	ragged    string
+}
+
+// Validate reports options that cannot be used
+func (o CSVOptions) Validate() error {
+	_, err := o.dialect()
+	return err
+}
+
+func (o CSVOptions) dialect() (csvDialect, error) {
+	d := csvDialect{delimiter: ',', quote: '"', trim: TrimHeaders, ragged: RaggedError}
+
+	switch o.Delimiter {
+	case "":
+	case "tab", `\t`:
+		d.delimiter = '\t'
+	default:
+		r, err := singleRune("delimiter", o.Delimiter)
+		if err != nil {
+			return d, err
+		}
+		if r == '\r' || r == '\n' {
+			return d, fmt.Errorf("CSV delimiter cannot be a line break")
+		}
+		d.delimiter = r
+	}
+
+	switch o.Quote {
+	case "":
+	case QuoteNone:
+		d.quote = 0
+	default:
+		r, err := singleRune("quote", o.Quote)
+		if err != nil {
+			return d, err
+		}
+		d.quote = r
+	}
+	if d.quote == d.delimiter {
+		return d, fmt.Errorf("CSV quote and delimiter must differ")
+	}
+
+	if o.Comment != "" {
+		r, err := singleRune("comment", o.Comment)
+		if err != nil {
+			return d, err
+		}
+		if r == d.delimiter || r == d.quote {
+			return d, fmt.Errorf("CSV comment character must differ from the delimiter and quote")
+		}
+		d.comment = r
+	}
+
+	if o.SkipRows < 0 {
+		return d, fmt.Errorf("CSV skip rows must not be negative, got %d", o.SkipRows)
+	}
+
+	switch o.Trim {
+	case "":
+	case TrimNone, TrimHeaders, TrimAll:
+		d.trim = o.Trim
+	default:
+		return d, fmt.Errorf("unknown CSV trim policy %q (want none, headers or all)", o.Trim)
+	}
+
+	switch o.Ragged {
+	case "":
+	case RaggedError, RaggedPad, RaggedSkip:
+		d.ragged = o.Ragged
+	default:
+		return d, fmt.Errorf("unknown CSV ragged row policy %q (want error, pad or skip)", o.Ragged)
+	}
+
+	return d, nil
+}
+
+func singleRune(name, s string) (rune, error) {
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, fmt.Errorf("CSV %s must be a single character, got %q", name, s)
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return r, nil
+}
+
+type CSVLoader struct {
+	Options  CSVOptions
+	Encoding string // Character encoding of the file, detected if empty or EncodingAuto
+}
 
 func (l *CSVLoader) Load(filePath string) (*common.DataSet, error) {
+	it, err := l.Stream(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return common.CollectDataSet(it)
+}
+
+// Stream opens the CSV file and returns an iterator that reads one record at a
+// time, so memory use does not grow with the size of the file.
+func (l *CSVLoader) Stream(filePath string) (common.RowIterator, error) {
+	dialect, err := l.Options.dialect()
+	if err != nil {
+		return nil, err
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open CSV file: %w", err)
 	}
-	defer func(file *os.File) {
-		err := file.Close()
-		if err != nil {
-			fmt.Printf("failed to close CSV file: %v\n", err)
+	text, err := openText(file, l.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	it, err := newCSVIterator(text, dialect, l.Options)
+	if err != nil {
+		text.Close()
+		return nil, err
+	}
+	return it, nil
+}
+
+func newCSVIterator(file io.ReadCloser, dialect csvDialect, options CSVOptions) (*csvIterator, error) {
+	input := bufio.NewReader(file)
+	for i := 0; i < options.SkipRows; i++ {
+		if _, err := input.ReadString('\n'); err != nil {
+			return nil, fmt.Errorf("failed to skip CSV rows: file has fewer than %d lines", options.SkipRows)
 		}
-	}(file)
+	}
 
-	reader := csv.NewReader(file)
+	// encoding/csv only knows double quotes. Other quote characters are
+	// swapped with the double quote on the way in and back in each field;
+	// without quoting, the double quote is swapped with a private use
+	// character that csv does not treat specially.
+	swap := func(r rune) rune { return r }
+	var source io.Reader = input
+	if dialect.quote != '"' {
+		other := dialect.quote
+		if other == 0 {
+			other = '\uE000'
+		}
+		swap = func(r rune) rune {
+			switch r {
+			case '"':
+				return other
+			case other:
+				return '"'
+			}
+			return r
+		}
+		source = &runeMapper{r: input, mapping: swap}
+	}
+
+	reader := csv.NewReader(source)
+	reader.ReuseRecord = true
+	reader.Comma = swap(dialect.delimiter)
+	reader.LazyQuotes = options.LazyQuotes
+	if dialect.comment != 0 {
+		reader.Comment = swap(dialect.comment)
+	}
+	reader.FieldsPerRecord = -1 // Ragged records are handled by csvIterator
+
+	it := &csvIterator{
+		file:    file,
+		reader:  reader,
+		dialect: dialect,
+		skipped: options.SkipRows,
+	}
+	if dialect.quote != '"' {
+		it.unswap = func(s string) string { return strings.Map(swap, s) }
+	}
 
-	headers, err := reader.Read()
+	first, err := reader.Read()
 	if err != nil {
+		if options.NoHeader {
+			return nil, fmt.Errorf("failed to read first CSV record: %w", err)
+		}
 		return nil, fmt.Errorf("failed to read CSV headers: %w", err)
 	}
 
-	for i, header := range headers {
-		headers[i] = strings.TrimSpace(header)
+	it.columns = make([]string, len(first))
+	if options.NoHeader {
+		for i := range first {
+			it.columns[i] = fmt.Sprintf("column_%d", i+1)
+		}
+		it.pending = it.toRow(first)
+	} else {
+		for i, header := range first {
+			header = it.field(header)
+			if dialect.trim != TrimNone {
+				header = strings.TrimSpace(header)
+			}
+			it.columns[i] = header
+		}
 	}
 
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV data: %w", err)
+	return it, nil
+}
+
+// csvIterator yields the records of a CSV file as DataRows
+type csvIterator struct {
+	file    io.Closer
+	reader  *csv.Reader
+	dialect csvDialect
+	columns []string
+	unswap  func(string) string // Undoes the quote swap, nil if there was none
+	pending common.DataRow      // First record of a file without header
+	skipped int                 // Lines skipped before the reader started
+}
+
+func (it *csvIterator) Columns() []string {
+	return it.columns
+}
+
+func (it *csvIterator) Next() (common.DataRow, error) {
+	if it.pending != nil {
+		row := it.pending
+		it.pending = nil
+		return row, nil
 	}
 
-	rows := make([]common.DataRow, 0, len(records))
-	for _, record := range records {
-		row := make(common.DataRow)
-		for i, value := range record {
-			if i < len(headers) {
-				row[headers[i]] = value
+	for {
+		record, err := it.reader.Read()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV data: %w", err)
+		}
+
+		if len(record) != len(it.columns) {
+			switch {
+			case it.dialect.ragged == RaggedSkip:
+				continue
+			case it.dialect.ragged == RaggedPad && len(record) < len(it.columns):
+			case it.dialect.ragged == RaggedPad:
+				// Dropping the extra fields would lose data without notice
+				line, _ := it.reader.FieldPos(0)
+				return nil, fmt.Errorf("failed to read CSV data: record on line %d has %d fields, want at most %d to pad", line+it.skipped, len(record), len(it.columns))
+			default:
+				line, _ := it.reader.FieldPos(0)
+				return nil, fmt.Errorf("failed to read CSV data: record on line %d has %d fields, want %d", line+it.skipped, len(record), len(it.columns))
 			}
 		}
-		rows = append(rows, row)
+
+		return it.toRow(record), nil
 	}
+}
 
-	return &common.DataSet{
-		Columns: headers,
-		Rows:    rows,
-	}, nil
+// toRow maps a record to the columns. Missing fields are NULL.
+func (it *csvIterator) toRow(record []string) common.DataRow {
+	row := make(common.DataRow, len(it.columns))
+	for i, col := range it.columns {
+		if i >= len(record) {
+			row[col] = nil
+			continue
+		}
+		value := it.field(record[i])
+		if it.dialect.trim == TrimAll {
+			value = strings.TrimSpace(value)
+		}
+		row[col] = value
+	}
+	return row
+}
+
+// field returns a parsed field as it appears in the file
+func (it *csvIterator) field(s string) string {
+	if it.unswap != nil {
+		return it.unswap(s)
+	}
+	return s
+}
+
+func (it *csvIterator) Close() error {
+	if err := it.file.Close(); err != nil {
+		return fmt.Errorf("failed to close CSV file: %w", err)
+	}
+	return nil
+}
+
+// runeMapper applies a rune mapping to everything read through it. Bytes that
+// are not valid UTF-8 are passed through unchanged.
+type runeMapper struct {
+	r       *bufio.Reader
+	mapping func(rune) rune
+	buf     []byte
+}
+
+func (m *runeMapper) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(m.buf) > 0 {
+			copied := copy(p[n:], m.buf)
+			m.buf = m.buf[copied:]
+			n += copied
+			continue
+		}
+
+		r, size, err := m.r.ReadRune()
+		if err != nil {
+			if n > 0 && err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+
+		if r == utf8.RuneError && size == 1 {
+			m.r.UnreadRune()
+			b, _ := m.r.ReadByte()
+			m.buf = append(m.buf[:0], b)
+			continue
+		}
+		m.buf = utf8.AppendRune(m.buf[:0], m.mapping(r))
+	}
+	return n, nil
 }
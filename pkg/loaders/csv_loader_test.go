@@ -2,9 +2,11 @@ package loaders
 
 import (
 	"brokolisql-go/pkg/common"
+	"io"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -110,3 +112,191 @@ func TestGetLoader_CSV(t *testing.T) {
 		t.Errorf("GetLoader() returned wrong loader type for CSV file")
 	}
 }
+
+func TestCSVLoader_Stream(t *testing.T) {
+	tempDir := t.TempDir()
+
+	csvPath := filepath.Join(tempDir, "test.csv")
+	if err := os.WriteFile(csvPath, []byte("Name, Age\nJohn,30\nJane,25\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test CSV file: %v", err)
+	}
+
+	l := &CSVLoader{}
+	it, err := l.Stream(csvPath)
+	if err != nil {
+		t.Fatalf("CSVLoader.Stream() error = %v", err)
+	}
+	defer it.Close()
+
+	if want := []string{"Name", "Age"}; !reflect.DeepEqual(it.Columns(), want) {
+		t.Errorf("Columns() = %v, want %v", it.Columns(), want)
+	}
+
+	var rows []common.DataRow
+	for {
+		row, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		rows = append(rows, row)
+	}
+
+	want := []common.DataRow{
+		{"Name": "John", "Age": "30"},
+		{"Name": "Jane", "Age": "25"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Stream() rows = %v, want %v", rows, want)
+	}
+}
+
+func TestCSVLoader_Options(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		options     CSVOptions
+		wantColumns []string
+		wantRows    []common.DataRow
+		wantErr     string
+	}{
+		{
+			name:        "Semicolon delimiter",
+			content:     "name;price\nKäse;3,50\n",
+			options:     CSVOptions{Delimiter: ";"},
+			wantColumns: []string{"name", "price"},
+			wantRows:    []common.DataRow{{"name": "Käse", "price": "3,50"}},
+		},
+		{
+			name:        "Tab delimiter",
+			content:     "a\tb\n1\t2\n",
+			options:     CSVOptions{Delimiter: "tab"},
+			wantColumns: []string{"a", "b"},
+			wantRows:    []common.DataRow{{"a": "1", "b": "2"}},
+		},
+		{
+			name:        "Single quotes",
+			content:     "id,note\n1,'say \"hi\", ''friend'''\n",
+			options:     CSVOptions{Quote: "'"},
+			wantColumns: []string{"id", "note"},
+			wantRows:    []common.DataRow{{"id": "1", "note": `say "hi", 'friend'`}},
+		},
+		{
+			name:        "Quoting off",
+			content:     "id|size\n1|12\" pipe\n",
+			options:     CSVOptions{Delimiter: "|", Quote: QuoteNone},
+			wantColumns: []string{"id", "size"},
+			wantRows:    []common.DataRow{{"id": "1", "size": `12" pipe`}},
+		},
+		{
+			name:        "Lazy quotes",
+			content:     "id,size\n1,12\" pipe\n",
+			options:     CSVOptions{LazyQuotes: true},
+			wantColumns: []string{"id", "size"},
+			wantRows:    []common.DataRow{{"id": "1", "size": `12" pipe`}},
+		},
+		{
+			name:    "Stray quote without lazy quotes",
+			content: "id,size\n1,12\" pipe\n",
+			wantErr: "failed to read CSV data",
+		},
+		{
+			name:        "Comments and skipped rows",
+			content:     "Monthly export\ngenerated 2024-01-02\nid,name\n# draft row\n1,Ann\n",
+			options:     CSVOptions{SkipRows: 2, Comment: "#"},
+			wantColumns: []string{"id", "name"},
+			wantRows:    []common.DataRow{{"id": "1", "name": "Ann"}},
+		},
+		{
+			name:        "No header",
+			content:     "1,Ann\n2,Bob\n",
+			options:     CSVOptions{NoHeader: true},
+			wantColumns: []string{"column_1", "column_2"},
+			wantRows:    []common.DataRow{{"column_1": "1", "column_2": "Ann"}, {"column_1": "2", "column_2": "Bob"}},
+		},
+		{
+			name:        "Trim all",
+			content:     " id , name \n 1 , Ann \n",
+			options:     CSVOptions{Trim: TrimAll},
+			wantColumns: []string{"id", "name"},
+			wantRows:    []common.DataRow{{"id": "1", "name": "Ann"}},
+		},
+		{
+			name:        "Trim none",
+			content:     " id , name \n 1 , Ann \n",
+			options:     CSVOptions{Trim: TrimNone},
+			wantColumns: []string{" id ", " name "},
+			wantRows:    []common.DataRow{{" id ": " 1 ", " name ": " Ann "}},
+		},
+		{
+			name:        "Ragged rows padded",
+			content:     "a,b,c\n1,2\n1,2,3\n",
+			options:     CSVOptions{Ragged: RaggedPad},
+			wantColumns: []string{"a", "b", "c"},
+			wantRows:    []common.DataRow{{"a": "1", "b": "2", "c": nil}, {"a": "1", "b": "2", "c": "3"}},
+		},
+		{
+			name:    "Long rows not padded",
+			content: "a,b,c\n1,2\n1,2,3,4\n",
+			options: CSVOptions{Ragged: RaggedPad},
+			wantErr: "record on line 3 has 4 fields, want at most 3 to pad",
+		},
+		{
+			name:        "Ragged rows skipped",
+			content:     "a,b\n1\n1,2\n",
+			options:     CSVOptions{Ragged: RaggedSkip},
+			wantColumns: []string{"a", "b"},
+			wantRows:    []common.DataRow{{"a": "1", "b": "2"}},
+		},
+		{
+			name:    "Ragged rows rejected",
+			content: "a,b\n1,2\n1\n",
+			wantErr: "record on line 3 has 1 fields, want 2",
+		},
+		{
+			name:    "Multi-character delimiter",
+			options: CSVOptions{Delimiter: "||"},
+			wantErr: `CSV delimiter must be a single character, got "||"`,
+		},
+		{
+			name:    "Quote equal to delimiter",
+			options: CSVOptions{Delimiter: "'", Quote: "'"},
+			wantErr: "CSV quote and delimiter must differ",
+		},
+		{
+			name:    "Unknown ragged policy",
+			options: CSVOptions{Ragged: "ignore"},
+			wantErr: `unknown CSV ragged row policy "ignore"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data.csv")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test CSV file: %v", err)
+			}
+
+			l := &CSVLoader{Options: tt.options}
+			got, err := l.Load(path)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CSVLoader.Load() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CSVLoader.Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Columns, tt.wantColumns) {
+				t.Errorf("Columns = %q, want %q", got.Columns, tt.wantColumns)
+			}
+			if !reflect.DeepEqual(got.Rows, tt.wantRows) {
+				t.Errorf("Rows = %v, want %v", got.Rows, tt.wantRows)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package loaders
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// EncodingAuto detects the encoding of a text file, which is also what an
+// empty encoding name does
+const EncodingAuto = "auto"
+
+// sniffSize is how much of a file is examined to detect its encoding
+const sniffSize = 64 * 1024
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// xmlDeclaration matches the encoding named in an XML declaration
+var xmlDeclaration = regexp.MustCompile(`^<\?xml[^>]*\sencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// LookupEncoding returns the character encoding with the given name or
+// label, such as "windows-1252", "latin1" or "utf-16le"
+func LookupEncoding(name string) (encoding.Encoding, error) {
+	if enc, err := htmlindex.Get(name); err == nil {
+		return enc, nil
+	}
+	if enc, err := ianaindex.IANA.Encoding(name); err == nil && enc != nil {
+		return enc, nil
+	}
+	return nil, fmt.Errorf("unknown encoding %q", name)
+}
+
+// validateEncoding checks an encoding option, which may be empty or "auto"
+func validateEncoding(name string) error {
+	if name == "" || strings.EqualFold(name, EncodingAuto) {
+		return nil
+	}
+	_, err := LookupEncoding(name)
+	return err
+}
+
+// decodeText returns r transcoded to UTF-8, without a byte order mark. name
+// is the encoding of r; when it is empty or "auto" the encoding is detected
+// from a byte order mark, an XML declaration or the bytes themselves.
+func decodeText(r io.Reader, name string) (io.Reader, error) {
+	input := bufio.NewReaderSize(r, sniffSize)
+
+	var enc encoding.Encoding
+	var text io.Reader = input
+	if name == "" || strings.EqualFold(name, EncodingAuto) {
+		// A file shorter than sniffSize is returned whole, with an error
+		head, err := input.Peek(sniffSize)
+		enc = sniffEncoding(head, err != nil)
+		if enc == unicode.UTF8 && err == nil {
+			// Only the head was seen to be UTF-8
+			text = &utf8Checker{r: input}
+		}
+	} else {
+		var err error
+		if enc, err = LookupEncoding(name); err != nil {
+			return nil, err
+		}
+	}
+
+	if enc != unicode.UTF8 {
+		text = transform.NewReader(input, enc.NewDecoder())
+	}
+	return skipBOM(text), nil
+}
+
+// sniffEncoding guesses the encoding of a file from its first bytes, which
+// are the whole file if complete is set. Text that is not valid UTF-8 is
+// taken to be Windows-1252, the superset of Latin-1 that Windows tools write.
+func sniffEncoding(head []byte, complete bool) encoding.Encoding {
+	switch {
+	case bytes.HasPrefix(head, utf8BOM):
+		return unicode.UTF8
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	}
+
+	if match := xmlDeclaration.FindSubmatch(head); match != nil {
+		if enc, err := LookupEncoding(string(match[1])); err == nil {
+			return enc
+		}
+	}
+
+	if order, ok := sniffUTF16(head); ok {
+		return unicode.UTF16(order, unicode.IgnoreBOM)
+	}
+
+	if !complete {
+		head = trimPartialRune(head)
+	}
+	if utf8.Valid(head) {
+		return unicode.UTF8
+	}
+	return charmap.Windows1252
+}
+
+// trimPartialRune drops a character cut off at the end of a sample
+func trimPartialRune(head []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(head); i++ {
+		if start := len(head) - i; utf8.RuneStart(head[start]) {
+			if !utf8.FullRune(head[start:]) {
+				return head[:start]
+			}
+			break
+		}
+	}
+	return head
+}
+
+// sniffUTF16 recognises UTF-16 without a byte order mark by the zero bytes
+// that mostly ASCII text has in every other position
+func sniffUTF16(head []byte) (unicode.Endianness, bool) {
+	n := min(len(head), 512) &^ 1
+	if n < 4 {
+		return unicode.LittleEndian, false
+	}
+
+	evenZeros, oddZeros := 0, 0
+	for i := 0; i < n; i += 2 {
+		if head[i] == 0 {
+			evenZeros++
+		}
+		if head[i+1] == 0 {
+			oddZeros++
+		}
+	}
+
+	pairs := n / 2
+	switch {
+	case oddZeros*10 >= pairs*4 && evenZeros*10 < pairs:
+		return unicode.LittleEndian, true
+	case evenZeros*10 >= pairs*4 && oddZeros*10 < pairs:
+		return unicode.BigEndian, true
+	}
+	return unicode.LittleEndian, false
+}
+
+// utf8Checker reads text detected as UTF-8 from its first sniffSize bytes,
+// failing at an invalid byte further on rather than passing it through to
+// become a replacement character
+type utf8Checker struct {
+	r       io.Reader
+	offset  int64  // Bytes checked so far
+	partial []byte // Start of a character cut off at the end of the last read
+}
+
+func (c *utf8Checker) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+
+	// Finish the character the last read cut off
+	start := 0
+	for len(c.partial) > 0 && start < n && !utf8.FullRune(c.partial) {
+		c.partial = append(c.partial, p[start])
+		start++
+	}
+	if len(c.partial) > 0 && utf8.FullRune(c.partial) {
+		if !utf8.Valid(c.partial) {
+			return 0, c.invalid()
+		}
+		c.offset += int64(len(c.partial))
+		c.partial = c.partial[:0]
+	}
+
+	whole := p[start:n]
+	if len(c.partial) == 0 {
+		whole = trimPartialRune(whole)
+		if !utf8.Valid(whole) {
+			i := invalidUTF8(whole)
+			c.offset += int64(i)
+			return start + i, c.invalid()
+		}
+		c.offset += int64(len(whole))
+		c.partial = append(c.partial, p[start+len(whole):n]...)
+	}
+
+	if err == io.EOF && len(c.partial) > 0 {
+		return n, c.invalid()
+	}
+	return n, err
+}
+
+// invalid returns the error for the invalid UTF-8 at the offset reached
+func (c *utf8Checker) invalid() error {
+	return fmt.Errorf("invalid UTF-8 at byte %d, after the first %d KB the encoding was detected from; name the encoding of the file, such as windows-1252", c.offset, sniffSize/1024)
+}
+
+// invalidUTF8 returns the index of the first byte of b that is not part of a
+// valid UTF-8 character
+func invalidUTF8(b []byte) int {
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return len(b)
+}
+
+// skipBOM drops a leading UTF-8 byte order mark
+func skipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if head, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(head, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+	return br
+}
+
+// decodedFile is an open file read through decodeText
+type decodedFile struct {
+	io.Reader
+	io.Closer
+}
+
+// openText wraps an open file so that it reads as UTF-8. The file is closed
+// if the encoding is unknown.
+func openText(file io.ReadCloser, name string) (io.ReadCloser, error) {
+	text, err := decodeText(file, name)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return decodedFile{Reader: text, Closer: file}, nil
+}
+
+// utf8CharsetReader lets encoding/xml accept documents that declare another
+// encoding, once decodeText has already converted them to UTF-8
+func utf8CharsetReader(label string, input io.Reader) (io.Reader, error) {
+	return input, nil
+}
@@ -0,0 +1,163 @@
+package loaders
+
+import (
+	"brokolisql-go/pkg/common"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+// utf16Text encodes s as UTF-16, with a byte order mark if bom is set
+func utf16Text(t *testing.T, s string, order unicode.Endianness, bom bool) string {
+	t.Helper()
+	policy := unicode.IgnoreBOM
+	if bom {
+		policy = unicode.UseBOM
+	}
+	encoded, err := unicode.UTF16(order, policy).NewEncoder().String(s)
+	if err != nil {
+		t.Fatalf("Failed to encode UTF-16: %v", err)
+	}
+	return encoded
+}
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		encoding string
+		want     string
+		wantErr  string
+	}{
+		{name: "UTF-8", input: "name\nJosé\n", want: "name\nJosé\n"},
+		{name: "UTF-8 BOM", input: "\xEF\xBB\xBFname\n", want: "name\n"},
+		{name: "UTF-16LE BOM", input: utf16Text(t, "name\nJosé\n", unicode.LittleEndian, true), want: "name\nJosé\n"},
+		{name: "UTF-16BE BOM", input: utf16Text(t, "name\nJosé\n", unicode.BigEndian, true), want: "name\nJosé\n"},
+		{name: "UTF-16LE without BOM", input: utf16Text(t, "name\nJosé\n", unicode.LittleEndian, false), want: "name\nJosé\n"},
+		{name: "Windows-1252", input: "name\nJos\xe9 \x80\n", want: "name\nJosé €\n"},
+		{name: "XML declaration", input: `<?xml version="1.0" encoding="ISO-8859-1"?><a>Jos` + "\xe9</a>", want: `<?xml version="1.0" encoding="ISO-8859-1"?><a>José</a>`},
+		{name: "Explicit Latin-1", input: "Jos\xe9", encoding: "latin1", want: "José"},
+		{name: "Explicit UTF-16LE with BOM", input: utf16Text(t, "José", unicode.LittleEndian, true), encoding: "utf-16le", want: "José"},
+		{name: "Explicit auto", input: "Jos\xe9", encoding: "AUTO", want: "José"},
+		{name: "Unknown encoding", input: "name", encoding: "klingon", wantErr: `unknown encoding "klingon"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := decodeText(strings.NewReader(tt.input), tt.encoding)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("decodeText() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeText() error = %v", err)
+			}
+
+			got, err := io.ReadAll(text)
+			if err != nil {
+				t.Fatalf("Failed to read decoded text: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("decodeText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeText_InvalidAfterSniff(t *testing.T) {
+	ascii := strings.Repeat("a", sniffSize)
+	accented := "a" + strings.Repeat("é€", sniffSize/4)
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{name: "Valid", input: accented + "Zürich\n"},
+		{name: "Invalid byte", input: ascii + "Jos\xe9\n", wantErr: "invalid UTF-8 at byte 65539"},
+		{name: "Invalid continuation", input: accented + "\xe2\x82x", wantErr: fmt.Sprintf("invalid UTF-8 at byte %d", len(accented))},
+		{name: "Cut off character", input: ascii + "\xe2\x82", wantErr: "invalid UTF-8 at byte 65536"},
+	}
+
+	for _, tt := range tests {
+		for _, oneByte := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/one byte %t", tt.name, oneByte), func(t *testing.T) {
+				var input io.Reader = strings.NewReader(tt.input)
+				if oneByte {
+					input = iotest.OneByteReader(input)
+				}
+				text, err := decodeText(input, "")
+				if err != nil {
+					t.Fatalf("decodeText() error = %v", err)
+				}
+
+				got, err := io.ReadAll(text)
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("Read error = %v, want it to contain %q", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Failed to read decoded text: %v", err)
+				}
+				if string(got) != tt.input {
+					t.Errorf("decodeText() changed %d bytes of valid UTF-8 into %d", len(tt.input), len(got))
+				}
+			})
+		}
+	}
+}
+
+func TestLoaders_Encoding(t *testing.T) {
+	want := common.DataRow{"name": "José", "city": "Zürich"}
+
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		encoding string
+	}{
+		{name: "CSV with UTF-8 BOM", file: "data.csv", content: "\xEF\xBB\xBFname,city\nJosé,Zürich\n"},
+		{name: "CSV in Windows-1252", file: "data.csv", content: "name,city\nJos\xe9,Z\xfcrich\n"},
+		{name: "CSV with explicit encoding", file: "data.csv", content: "name,city\nJos\xe9,Z\xfcrich\n", encoding: "iso-8859-1"},
+		{name: "JSON in UTF-16LE", file: "data.json", content: utf16Text(t, `[{"name": "José", "city": "Zürich"}]`, unicode.LittleEndian, true)},
+		{name: "XML declared as Latin-1", file: "data.xml", content: `<?xml version="1.0" encoding="ISO-8859-1"?>` + "<people><person><name>Jos\xe9</name><city>Z\xfcrich</city></person><person/></people>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+
+			loader, err := NewLoader(path, &Config{Encoding: tt.encoding})
+			if err != nil {
+				t.Fatalf("NewLoader() error = %v", err)
+			}
+			got, err := loader.Load(path)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if len(got.Rows) == 0 || !reflect.DeepEqual(got.Rows[0], want) {
+				t.Errorf("Rows = %q, want the first to be %q", got.Rows, want)
+			}
+		})
+	}
+
+	t.Run("Unknown encoding", func(t *testing.T) {
+		if _, err := NewLoader("data.csv", &Config{Encoding: "klingon"}); err == nil {
+			t.Error("NewLoader() error = nil, want an unknown encoding error")
+		}
+	})
+}
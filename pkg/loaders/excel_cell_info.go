@@ -0,0 +1,284 @@
+package loaders
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// cellInfo is what a worksheet says about a cell besides its value
+type cellInfo struct {
+	cellType excelize.CellType
+	style    int    // Style index, inherited from the row or column when unset
+	formula  string // Formula text, without the leading =
+	// sharedFormula marks a cell sharing the formula of another cell, whose
+	// text has to be derived from that cell
+	sharedFormula bool
+}
+
+// cellTypes maps the t attribute of a cell to its type
+var cellTypes = map[string]excelize.CellType{
+	"b":         excelize.CellTypeBool,
+	"d":         excelize.CellTypeDate,
+	"e":         excelize.CellTypeError,
+	"n":         excelize.CellTypeNumber,
+	"s":         excelize.CellTypeSharedString,
+	"str":       excelize.CellTypeFormula,
+	"inlineStr": excelize.CellTypeInlineString,
+}
+
+// colStyle is the style of a range of columns
+type colStyle struct {
+	min, max, style int
+}
+
+// sheetCellInfo reads the cell attributes of a worksheet straight from the
+// file, row by row alongside excelize.Rows. Looking them up through
+// excelize instead loads the whole worksheet into memory.
+type sheetCellInfo struct {
+	archive   *zip.ReadCloser
+	part      io.ReadCloser
+	decoder   *xml.Decoder
+	colStyles []colStyle
+	done      bool
+	wanted    int        // Last row asked for
+	row       int        // Last row read, which may be past wanted
+	cells     []cellInfo // Cells of row by column, from column 1
+}
+
+func openSheetCellInfo(filePath, sheet string) (*sheetCellInfo, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Excel file: %w", err)
+	}
+
+	partName, err := worksheetPart(&archive.Reader, sheet)
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+	part, err := openPart(&archive.Reader, partName)
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+
+	return &sheetCellInfo{archive: archive, part: part, decoder: xml.NewDecoder(part)}, nil
+}
+
+// worksheetPart finds the file of a sheet in the archive through the
+// workbook and its relationships
+func worksheetPart(archive *zip.Reader, sheet string) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"id,attr"` // r:id
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodePart(archive, "xl/workbook.xml", &workbook); err != nil {
+		return "", err
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodePart(archive, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+
+	for _, s := range workbook.Sheets {
+		if s.Name != sheet {
+			continue
+		}
+		for _, rel := range rels.Relationships {
+			if rel.ID != s.ID {
+				continue
+			}
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/"), nil
+			}
+			return "xl/" + rel.Target, nil
+		}
+	}
+	return "", fmt.Errorf("excel sheet %q not found in the workbook", sheet)
+}
+
+func openPart(archive *zip.Reader, name string) (io.ReadCloser, error) {
+	for _, f := range archive.File {
+		if strings.EqualFold(f.Name, name) {
+			part, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s from Excel file: %w", name, err)
+			}
+			return part, nil
+		}
+	}
+	return nil, fmt.Errorf("excel file has no %s", name)
+}
+
+func decodePart(archive *zip.Reader, name string, v interface{}) error {
+	part, err := openPart(archive, name)
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+
+	if err := xml.NewDecoder(part).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s of Excel file: %w", name, err)
+	}
+	return nil
+}
+
+// canRead reports whether the cells of row can still be read
+func (s *sheetCellInfo) canRead(row int) bool {
+	return row >= s.wanted
+}
+
+// at returns the cells of row by column, or nil if the worksheet stores
+// nothing for it. Rows must be asked for in increasing order.
+func (s *sheetCellInfo) at(row int) ([]cellInfo, error) {
+	s.wanted = row
+	for s.row < row && !s.done {
+		if err := s.next(); err != nil {
+			return nil, fmt.Errorf("failed to read Excel worksheet: %w", err)
+		}
+	}
+	if s.row != row {
+		return nil, nil
+	}
+	return s.cells, nil
+}
+
+// next reads the next row, along with the column styles that come before the
+// rows
+func (s *sheetCellInfo) next() error {
+	for {
+		tok, err := s.decoder.Token()
+		if err == io.EOF {
+			s.done = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch el := tok.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "col":
+				col := colStyle{
+					min:   intAttr(el, "min"),
+					max:   intAttr(el, "max"),
+					style: intAttr(el, "style"),
+				}
+				if col.style != 0 {
+					s.colStyles = append(s.colStyles, col)
+				}
+			case "row":
+				return s.readRow(el)
+			}
+		case xml.EndElement:
+			if el.Name.Local == "sheetData" {
+				s.done = true
+				return nil
+			}
+		}
+	}
+}
+
+func (s *sheetCellInfo) readRow(start xml.StartElement) error {
+	if r := intAttr(start, "r"); r > 0 {
+		s.row = r
+	} else {
+		s.row++
+	}
+	rowStyle := intAttr(start, "s")
+
+	s.cells = s.cells[:0]
+	col := 0
+	for {
+		tok, err := s.decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch el := tok.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "c":
+				col++
+				if ref := attr(el, "r"); ref != "" {
+					if col, _, err = excelize.CellNameToCoordinates(ref); err != nil {
+						return err
+					}
+				}
+				for len(s.cells) < col {
+					s.cells = append(s.cells, cellInfo{})
+				}
+
+				cell := cellInfo{cellType: cellTypes[attr(el, "t")], style: intAttr(el, "s")}
+				if cell.style == 0 {
+					cell.style = s.inheritedStyle(col, rowStyle)
+				}
+				s.cells[col-1] = cell
+			case "f":
+				var f struct {
+					Type    string `xml:"t,attr"`
+					Content string `xml:",chardata"`
+				}
+				if err := s.decoder.DecodeElement(&f, &el); err != nil {
+					return err
+				}
+				if col > 0 {
+					s.cells[col-1].formula = f.Content
+					s.cells[col-1].sharedFormula = f.Type == "shared" && f.Content == ""
+				}
+			}
+		case xml.EndElement:
+			if el.Name.Local == "row" {
+				return nil
+			}
+		}
+	}
+}
+
+// inheritedStyle returns the style of a cell without one, which is that of
+// its row or else its column
+func (s *sheetCellInfo) inheritedStyle(col, rowStyle int) int {
+	if rowStyle != 0 {
+		return rowStyle
+	}
+	for _, c := range s.colStyles {
+		if c.min <= col && col <= c.max {
+			return c.style
+		}
+	}
+	return 0
+}
+
+func (s *sheetCellInfo) Close() error {
+	s.part.Close()
+	return s.archive.Close()
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// intAttr returns an integer attribute, or 0 if it is missing or invalid
+func intAttr(el xml.StartElement, name string) int {
+	n, _ := strconv.Atoi(attr(el, name))
+	return n
+}
@@ -0,0 +1,294 @@
+package loaders
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// cellPos is the 1-based column and row of a cell
+type cellPos struct {
+	col, row int
+}
+
+// excelCellReader turns the cells of a sheet into values. In typed mode
+// numbers become int64 or float64, cells with a date or time number format
+// become time.Time, and booleans become bool.
+type excelCellReader struct {
+	file     *excelize.File
+	sheet    string
+	typed    bool
+	formulas bool
+	date1904 bool
+
+	info         *sheetCellInfo      // Cell types, styles and formulas, nil in text mode
+	dateStyles   map[int]bool        // Whether a style index has a date format
+	merged       map[cellPos]cellPos // Start of the merge covering a cell, for FillMerged
+	mergedValues map[cellPos]interface{}
+}
+
+func newExcelCellReader(file *excelize.File, sheet string, options ExcelOptions) (*excelCellReader, error) {
+	r := &excelCellReader{
+		file:       file,
+		sheet:      sheet,
+		typed:      options.Values != ExcelValuesText,
+		formulas:   options.Formulas == ExcelFormulaText,
+		dateStyles: make(map[int]bool),
+	}
+
+	if r.typed {
+		props, err := file.GetWorkbookProps()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Excel workbook properties: %w", err)
+		}
+		r.date1904 = props.Date1904 != nil && *props.Date1904
+	}
+
+	if r.typed || r.formulas {
+		info, err := openSheetCellInfo(file.Path, sheet)
+		if err != nil {
+			return nil, err
+		}
+		r.info = info
+	}
+
+	if options.FillMerged {
+		merges, err := file.GetMergeCells(sheet)
+		if err != nil {
+			r.close()
+			return nil, fmt.Errorf("failed to read merged cells of Excel sheet %q: %w", sheet, err)
+		}
+
+		r.merged = make(map[cellPos]cellPos)
+		r.mergedValues = make(map[cellPos]interface{})
+		for _, merge := range merges {
+			var start, end cellPos
+			if start.col, start.row, err = excelize.CellNameToCoordinates(merge.GetStartAxis()); err != nil {
+				r.close()
+				return nil, fmt.Errorf("invalid merged cell in Excel sheet %q: %w", sheet, err)
+			}
+			if end.col, end.row, err = excelize.CellNameToCoordinates(merge.GetEndAxis()); err != nil {
+				r.close()
+				return nil, fmt.Errorf("invalid merged cell in Excel sheet %q: %w", sheet, err)
+			}
+			for row := start.row; row <= end.row; row++ {
+				for col := start.col; col <= end.col; col++ {
+					if pos := (cellPos{col, row}); pos != start {
+						r.merged[pos] = start
+					}
+				}
+			}
+		}
+	}
+
+	return r, nil
+}
+
+func (r *excelCellReader) close() error {
+	if r.info == nil {
+		return nil
+	}
+	return r.info.Close()
+}
+
+// value returns the value of the cell at col and row, given its content as
+// read from the row: raw in typed mode, as displayed otherwise. Cells covered
+// by a merge take the value of the merge's first cell when FillMerged is set.
+func (r *excelCellReader) value(col, row int, content string) (interface{}, error) {
+	if start, ok := r.merged[cellPos{col, row}]; ok {
+		return r.mergedValue(start)
+	}
+	return r.convert(col, row, content)
+}
+
+// mergedValue returns the value of the first cell of a merge, which may lie
+// outside the rows and columns being read
+func (r *excelCellReader) mergedValue(start cellPos) (interface{}, error) {
+	if value, ok := r.mergedValues[start]; ok {
+		return value, nil
+	}
+
+	ref, err := excelize.CoordinatesToCellName(start.col, start.row)
+	if err != nil {
+		return nil, err
+	}
+	content, err := r.file.GetCellValue(r.sheet, ref, excelize.Options{RawCellValue: r.typed})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Excel cell %s!%s: %w", r.sheet, ref, err)
+	}
+
+	value, err := r.convert(start.col, start.row, content)
+	if err != nil {
+		return nil, err
+	}
+	r.mergedValues[start] = value
+	return value, nil
+}
+
+func (r *excelCellReader) convert(col, row int, content string) (interface{}, error) {
+	if !r.formulas && !r.typed {
+		return content, nil
+	}
+
+	ref, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := r.infoOf(col, row, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.formulas {
+		formula := info.formula
+		if info.sharedFormula {
+			if formula, err = r.file.GetCellFormula(r.sheet, ref); err != nil {
+				return nil, fmt.Errorf("failed to read formula of Excel cell %s!%s: %w", r.sheet, ref, err)
+			}
+		}
+		if formula != "" {
+			return "=" + formula, nil
+		}
+	}
+
+	if !r.typed {
+		return content, nil
+	}
+	if content == "" {
+		return nil, nil
+	}
+
+	switch info.cellType {
+	case excelize.CellTypeBool:
+		return content == "1" || strings.EqualFold(content, "TRUE"), nil
+	case excelize.CellTypeDate:
+		return parseISODate(content), nil
+	case excelize.CellTypeError:
+		// Error values such as #DIV/0! or #N/A have no SQL equivalent
+		return nil, nil
+	case excelize.CellTypeSharedString, excelize.CellTypeInlineString, excelize.CellTypeFormula:
+		return content, nil
+	}
+
+	number, err := strconv.ParseFloat(content, 64)
+	if err != nil {
+		return content, nil
+	}
+
+	if r.hasDateFormat(info.style) {
+		t, err := excelize.ExcelDateToTime(number, r.date1904)
+		if err != nil {
+			return content, nil
+		}
+		return t, nil
+	}
+
+	if !strings.ContainsAny(content, ".eE") {
+		if n, err := strconv.ParseInt(content, 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	return number, nil
+}
+
+// infoOf returns the type, style and formula of a cell. Cells of the row
+// being read come from the worksheet stream; cells of earlier rows, such as
+// the first cell of a merge, are looked up one by one.
+func (r *excelCellReader) infoOf(col, row int, ref string) (cellInfo, error) {
+	if r.info.canRead(row) {
+		cells, err := r.info.at(row)
+		if err != nil {
+			return cellInfo{}, err
+		}
+		if col <= len(cells) {
+			return cells[col-1], nil
+		}
+		return cellInfo{}, nil
+	}
+
+	var info cellInfo
+	var err error
+	if info.cellType, err = r.file.GetCellType(r.sheet, ref); err != nil {
+		return info, fmt.Errorf("failed to read type of Excel cell %s!%s: %w", r.sheet, ref, err)
+	}
+	if info.style, err = r.file.GetCellStyle(r.sheet, ref); err != nil {
+		return info, fmt.Errorf("failed to read style of Excel cell %s!%s: %w", r.sheet, ref, err)
+	}
+	if info.formula, err = r.file.GetCellFormula(r.sheet, ref); err != nil {
+		return info, fmt.Errorf("failed to read formula of Excel cell %s!%s: %w", r.sheet, ref, err)
+	}
+	return info, nil
+}
+
+// hasDateFormat reports whether a style's number format shows a date or a
+// time
+func (r *excelCellReader) hasDateFormat(styleID int) bool {
+	if isDate, ok := r.dateStyles[styleID]; ok {
+		return isDate
+	}
+
+	isDate := false
+	if style, err := r.file.GetStyle(styleID); err == nil {
+		if style.CustomNumFmt != nil {
+			isDate = isDateFormatCode(*style.CustomNumFmt)
+		} else {
+			isDate = isBuiltInDateFormat(style.NumFmt)
+		}
+	}
+	r.dateStyles[styleID] = isDate
+	return isDate
+}
+
+// isBuiltInDateFormat reports whether a built-in number format is a date or
+// time format, including the formats of East Asian locales
+func isBuiltInDateFormat(id int) bool {
+	return (id >= 14 && id <= 22) || (id >= 27 && id <= 36) ||
+		(id >= 45 && id <= 47) || (id >= 50 && id <= 58)
+}
+
+// isDateFormatCode reports whether a custom number format such as
+// dd/mm/yyyy hh:mm shows a date or a time. Quoted text, escaped characters
+// and bracketed sections such as colors, locales and elapsed times like [h]
+// are ignored, as is every section after the first.
+func isDateFormatCode(code string) bool {
+	inQuotes, inBrackets := false, false
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case inQuotes:
+			inQuotes = c != '"'
+		case inBrackets:
+			inBrackets = c != ']'
+		case c == '"':
+			inQuotes = true
+		case c == '[':
+			inBrackets = true
+		case c == '\\' || c == '_' || c == '*':
+			// The next character is shown literally or used as padding
+			i++
+		case c == ';':
+			return false
+		default:
+			switch c | 0x20 {
+			case 'd', 'm', 'y', 'h', 's':
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseISODate parses the ISO 8601 value of a date cell, keeping it as text
+// if it is in a layout not known here
+func parseISODate(s string) interface{} {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return s
+}
@@ -2,62 +2,410 @@ package loaders
 
 import (
 	"brokolisql-go/pkg/common"
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
 
 	"github.com/xuri/excelize/v2"
 )
 
-type ExcelLoader struct{}
+// Cell value modes for ExcelOptions.Values
+const (
+	ExcelValuesTyped = "typed" // Numbers, dates and booleans as Go values
+	ExcelValuesText  = "text"  // Cells as Excel displays them
+)
+
+// Formula modes for ExcelOptions.Formulas
+const (
+	ExcelFormulaResult = "value"   // The result Excel cached when the file was saved
+	ExcelFormulaText   = "formula" // The formula itself, such as =SUM(A1:A3)
+)
+
+// ExcelOptions selects what part of a workbook is read and how cells are
+// turned into values. The zero value reads the first sheet with the column
+// names in row 1, and types cells by their Excel type and number format.
+type ExcelOptions struct {
+	Sheet      string `json:"sheet,omitempty" yaml:"sheet,omitempty"`             // Sheet name or 1-based position, the first sheet if empty
+	AllSheets  bool   `json:"all_sheets,omitempty" yaml:"all_sheets,omitempty"`   // Read every sheet as a table of its own
+	HeaderRow  int    `json:"header_row,omitempty" yaml:"header_row,omitempty"`   // Sheet row holding the column names, the first row of Range if zero
+	Range      string `json:"range,omitempty" yaml:"range,omitempty"`             // Cells to read, such as B3:F200, B3:F (to the last row) or B:F
+	Values     string `json:"values,omitempty" yaml:"values,omitempty"`           // ExcelValuesTyped (default) or ExcelValuesText
+	Formulas   string `json:"formulas,omitempty" yaml:"formulas,omitempty"`       // ExcelFormulaResult (default) or ExcelFormulaText
+	FillMerged bool   `json:"fill_merged,omitempty" yaml:"fill_merged,omitempty"` // Repeat the value of a merged cell in every cell it covers
+}
+
+// cellRange is a parsed ExcelOptions.Range. Rows and columns are 1-based; a
+// zero last row or column means the range is open-ended.
+type cellRange struct {
+	firstCol, lastCol int
+	firstRow, lastRow int
+}
+
+// Validate reports options that cannot be used
+func (o ExcelOptions) Validate() error {
+	if o.Sheet != "" && o.AllSheets {
+		return fmt.Errorf("an Excel sheet cannot be selected when reading all sheets")
+	}
+	if o.HeaderRow < 0 {
+		return fmt.Errorf("excel header row must be positive, got %d", o.HeaderRow)
+	}
+
+	switch o.Values {
+	case "", ExcelValuesTyped, ExcelValuesText:
+	default:
+		return fmt.Errorf("unknown Excel value mode %q (want typed or text)", o.Values)
+	}
+	switch o.Formulas {
+	case "", ExcelFormulaResult, ExcelFormulaText:
+	default:
+		return fmt.Errorf("unknown Excel formula mode %q (want value or formula)", o.Formulas)
+	}
+
+	cells, err := parseCellRange(o.Range)
+	if err != nil {
+		return err
+	}
+	if o.HeaderRow > 0 && (o.HeaderRow < cells.firstRow || (cells.lastRow > 0 && o.HeaderRow > cells.lastRow)) {
+		return fmt.Errorf("excel header row %d is outside the range %s", o.HeaderRow, o.Range)
+	}
+	return nil
+}
+
+// parseCellRange parses a range such as B3:F200. Either end may leave out its
+// row number. An empty range covers the whole sheet.
+func parseCellRange(s string) (cellRange, error) {
+	r := cellRange{firstCol: 1, firstRow: 1}
+	if s == "" {
+		return r, nil
+	}
+
+	first, last, ok := strings.Cut(strings.ToUpper(strings.TrimSpace(s)), ":")
+	if !ok {
+		return r, fmt.Errorf("invalid Excel range %q, want a range such as B3:F200", s)
+	}
+
+	var err error
+	if r.firstCol, r.firstRow, err = parseCellRef(first); err != nil {
+		return r, fmt.Errorf("invalid Excel range %q: %w", s, err)
+	}
+	if r.firstRow == 0 {
+		r.firstRow = 1
+	}
+	if r.lastCol, r.lastRow, err = parseCellRef(last); err != nil {
+		return r, fmt.Errorf("invalid Excel range %q: %w", s, err)
+	}
+
+	if r.lastCol < r.firstCol || (r.lastRow > 0 && r.lastRow < r.firstRow) {
+		return r, fmt.Errorf("invalid Excel range %q: the end comes before the start", s)
+	}
+	return r, nil
+}
+
+// parseCellRef parses a cell reference such as B3, or a column such as B. The
+// row is 0 when it is left out.
+func parseCellRef(ref string) (col, row int, err error) {
+	letters := strings.TrimRight(ref, "0123456789")
+	if col, err = excelize.ColumnNameToNumber(letters); err != nil {
+		return 0, 0, err
+	}
+	if digits := ref[len(letters):]; digits != "" {
+		if row, err = strconv.Atoi(digits); err != nil || row < 1 {
+			return 0, 0, fmt.Errorf("invalid row in cell %q", ref)
+		}
+	}
+	return col, row, nil
+}
+
+type ExcelLoader struct {
+	Options ExcelOptions
+}
 
 func (l *ExcelLoader) Load(filePath string) (*common.DataSet, error) {
+	it, err := l.Stream(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	dataset, err := common.CollectDataSet(it)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dataset.Rows) == 0 {
+		return nil, fmt.Errorf("excel file must contain at least a header row and one data row")
+	}
+
+	return dataset, nil
+}
+
+// LoadTables reads every sheet when Options.AllSheets is set, and the
+// selected sheet otherwise. Sheets without data rows are left out.
+func (l *ExcelLoader) LoadTables(filePath string) ([]NamedDataSet, error) {
+	cells, err := parseCellRange(l.Options.Range)
+	if err != nil {
+		return nil, err
+	}
 
 	file, err := excelize.OpenFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open Excel file: %w", err)
 	}
-	defer func(file *excelize.File) {
-		err := file.Close()
+	defer file.Close()
+
+	sheets := file.GetSheetList()
+	if !l.Options.AllSheets {
+		sheet, err := selectSheet(sheets, l.Options.Sheet)
+		if err != nil {
+			return nil, err
+		}
+		sheets = []string{sheet}
+	}
+
+	var tables []NamedDataSet
+	for _, sheet := range sheets {
+		it, err := newExcelIterator(file, sheet, cells, l.Options)
+		if err == errNoHeaderRow {
+			continue
+		}
 		if err != nil {
-			fmt.Printf("failed to close Excel file: %v\n", err)
+			return nil, err
 		}
-	}(file)
 
-	sheets := file.GetSheetList()
+		// The file is closed once all sheets are read
+		it.file = nil
+		dataset, err := common.CollectDataSet(it)
+		if err != nil {
+			return nil, err
+		}
+		if len(dataset.Rows) > 0 {
+			tables = append(tables, NamedDataSet{Name: sheet, DataSet: dataset})
+		}
+	}
+
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("excel file must contain at least a header row and one data row")
+	}
+	return tables, nil
+}
+
+// Stream reads the selected sheet row by row using the excelize streaming
+// reader
+func (l *ExcelLoader) Stream(filePath string) (common.RowIterator, error) {
+	cells, err := parseCellRange(l.Options.Range)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := excelize.OpenFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Excel file: %w", err)
+	}
+
+	sheet, err := selectSheet(file.GetSheetList(), l.Options.Sheet)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	it, err := newExcelIterator(file, sheet, cells, l.Options)
+	if err == errNoHeaderRow {
+		err = fmt.Errorf("excel file must contain at least a header row and one data row")
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return it, nil
+}
+
+// selectSheet finds a sheet by name, or by 1-based position when no sheet
+// has that name. An empty name selects the first sheet.
+func selectSheet(sheets []string, name string) (string, error) {
 	if len(sheets) == 0 {
-		return nil, fmt.Errorf("no sheets found in Excel file")
+		return "", fmt.Errorf("no sheets found in Excel file")
+	}
+	if name == "" {
+		return sheets[0], nil
+	}
+
+	for _, sheet := range sheets {
+		if sheet == name {
+			return sheet, nil
+		}
 	}
+	for _, sheet := range sheets {
+		if strings.EqualFold(sheet, name) {
+			return sheet, nil
+		}
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < 1 || n > len(sheets) {
+			return "", fmt.Errorf("excel sheet %d does not exist, the workbook has %d sheets", n, len(sheets))
+		}
+		return sheets[n-1], nil
+	}
+	return "", fmt.Errorf("excel sheet %q not found (sheets: %s)", name, strings.Join(sheets, ", "))
+}
 
-	sheetName := sheets[0]
+var errNoHeaderRow = errors.New("excel sheet has no header row")
 
-	rows, err := file.GetRows(sheetName)
+// newExcelIterator reads sheet from the header row on, within cells. A zero
+// options.HeaderRow is the first row of cells.
+func newExcelIterator(file *excelize.File, sheet string, cells cellRange, options ExcelOptions) (*excelIterator, error) {
+	reader, err := newExcelCellReader(file, sheet, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read rows from Excel sheet: %w", err)
+		return nil, err
 	}
 
-	if len(rows) < 2 {
-		return nil, fmt.Errorf("excel file must contain at least a header row and one data row")
+	rows, err := file.Rows(sheet)
+	if err != nil {
+		reader.close()
+		return nil, fmt.Errorf("failed to read rows from Excel sheet %q: %w", sheet, err)
 	}
 
-	headers := rows[0]
+	headerRow := options.HeaderRow
+	if headerRow == 0 {
+		headerRow = cells.firstRow
+	}
+	it := &excelIterator{file: file, rows: rows, sheet: sheet, cells: cells, reader: reader}
 
+	// Rows above the header, such as report titles, are skipped
+	for it.row < headerRow {
+		if !rows.Next() {
+			it.closeRows()
+			if err := rows.Error(); err != nil {
+				return nil, fmt.Errorf("failed to read rows from Excel sheet %q: %w", sheet, err)
+			}
+			return nil, errNoHeaderRow
+		}
+		it.row++
+	}
+
+	// Column names are read as displayed, whatever the value mode
+	headers, err := it.cellsOf(false)
+	if err != nil {
+		it.closeRows()
+		return nil, err
+	}
+
+	// Cells under an empty header are not read
 	for i, header := range headers {
 		headers[i] = strings.TrimSpace(header)
+		if headers[i] != "" {
+			it.names = append(it.names, headers[i])
+		}
 	}
+	it.columns = headers
+
+	return it, nil
+}
+
+// excelIterator yields the rows of an Excel sheet as DataRows
+type excelIterator struct {
+	file    *excelize.File // Closed with the iterator unless nil
+	rows    *excelize.Rows
+	sheet   string
+	cells   cellRange
+	reader  *excelCellReader
+	row     int      // Sheet row the reader is on
+	columns []string // Header of each cell in the row, "" if it has none
+	names   []string // The non-empty headers
+}
+
+func (it *excelIterator) Columns() []string {
+	return it.names
+}
+
+func (it *excelIterator) Next() (common.DataRow, error) {
+	for {
+		if (it.cells.lastRow > 0 && it.row >= it.cells.lastRow) || !it.rows.Next() {
+			if err := it.rows.Error(); err != nil {
+				return nil, fmt.Errorf("failed to read rows from Excel sheet %q: %w", it.sheet, err)
+			}
+			return nil, io.EOF
+		}
+		it.row++
+
+		cells, err := it.cellsOf(it.reader.typed)
+		if err != nil {
+			return nil, err
+		}
 
-	dataRows := make([]common.DataRow, 0, len(rows)-1)
-	for _, row := range rows[1:] {
 		dataRow := make(common.DataRow)
-		for i, value := range row {
-			if i < len(headers) && headers[i] != "" {
-				dataRow[headers[i]] = value
+		empty := true
+		for i, column := range it.columns {
+			if column == "" {
+				continue
+			}
+
+			// Cells past the last one stored may still be covered by a merge
+			raw := ""
+			if i < len(cells) {
+				raw = cells[i]
+			}
+			value, err := it.reader.value(it.cells.firstCol+i, it.row, raw)
+			if err != nil {
+				return nil, err
+			}
+
+			// Missing cells are left out, as with CSV rows padded with NULL
+			if value == nil || (value == "" && i >= len(cells)) {
+				continue
+			}
+			dataRow[column] = value
+			if value != "" {
+				empty = false
 			}
 		}
-		dataRows = append(dataRows, dataRow)
+
+		// Blank rows, often left behind by formatting, carry no data
+		if empty {
+			continue
+		}
+		return dataRow, nil
 	}
+}
 
-	return &common.DataSet{
-		Columns: headers,
-		Rows:    dataRows,
-	}, nil
+// cellsOf returns the cells of the current row within the range's columns,
+// as stored in the file when raw is set and as displayed otherwise
+func (it *excelIterator) cellsOf(raw bool) ([]string, error) {
+	values, err := it.rows.Columns(excelize.Options{RawCellValue: raw})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rows from Excel sheet %q: %w", it.sheet, err)
+	}
+
+	if it.cells.lastCol > 0 && len(values) > it.cells.lastCol {
+		values = values[:it.cells.lastCol]
+	}
+	if it.cells.firstCol > len(values) {
+		return nil, nil
+	}
+	return values[it.cells.firstCol-1:], nil
+}
+
+// closeRows closes the readers of the sheet, but not the file
+func (it *excelIterator) closeRows() error {
+	err := it.rows.Close()
+	if closeErr := it.reader.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (it *excelIterator) Close() error {
+	if err := it.closeRows(); err != nil {
+		if it.file != nil {
+			it.file.Close()
+		}
+		return err
+	}
+	if it.file == nil {
+		return nil
+	}
+	if err := it.file.Close(); err != nil {
+		return fmt.Errorf("failed to close Excel file: %w", err)
+	}
+	return nil
 }
@@ -1,7 +1,14 @@
 package loaders
 
 import (
+	"brokolisql-go/pkg/common"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
 )
 
 func TestGetLoader_Excel(t *testing.T) {
@@ -28,48 +35,360 @@ func TestGetLoader_Excel(t *testing.T) {
 	}
 }
 
-// Note: Full testing of the Excel loader would require creating actual Excel files,
-// which is complex to do programmatically in this environment.
-// In a real-world scenario, you would:
-// 1. Create test Excel files
-// 2. Test various scenarios (valid files, empty files, files with missing values)
-// 3. Test error cases (non-existent files, invalid Excel files)
-//
-// Example test structure would be similar to the other loader tests:
-/*
+// writeWorkbook saves a workbook with the given sheets, each a list of rows
+// starting at A1, and returns its path
+func writeWorkbook(t *testing.T, sheets map[string][][]interface{}, order ...string) string {
+	t.Helper()
+
+	file := excelize.NewFile()
+	defer file.Close()
+
+	for i, name := range order {
+		if i == 0 {
+			if err := file.SetSheetName("Sheet1", name); err != nil {
+				t.Fatalf("Failed to rename sheet: %v", err)
+			}
+		} else if _, err := file.NewSheet(name); err != nil {
+			t.Fatalf("Failed to add sheet: %v", err)
+		}
+
+		for r, row := range sheets[name] {
+			cell, _ := excelize.CoordinatesToCellName(1, r+1)
+			if err := file.SetSheetRow(name, cell, &row); err != nil {
+				t.Fatalf("Failed to write row: %v", err)
+			}
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "workbook.xlsx")
+	if err := file.SaveAs(path); err != nil {
+		t.Fatalf("Failed to save workbook: %v", err)
+	}
+	return path
+}
+
 func TestExcelLoader_Load(t *testing.T) {
-	// Create a temporary directory for test files
-	tempDir, err := os.MkdirTemp("", "excel_loader_test")
+	path := writeWorkbook(t, map[string][][]interface{}{
+		"Summary": {
+			{"id", "name"},
+			{"1", "John"},
+			{},
+			{"2", "Jane"},
+		},
+		"Q1 Sales": {
+			{"Quarterly report"},
+			{},
+			{"", "region", "amount", "note"},
+			{"", "North", "100", "x"},
+			{"", "South", "200", "y"},
+			{"", "Total", "300"},
+		},
+	}, "Summary", "Q1 Sales")
+
+	tests := []struct {
+		name        string
+		options     ExcelOptions
+		wantColumns []string
+		wantRows    []common.DataRow
+		wantErr     string
+	}{
+		{
+			name:        "First sheet, blank rows left out",
+			wantColumns: []string{"id", "name"},
+			wantRows:    []common.DataRow{{"id": "1", "name": "John"}, {"id": "2", "name": "Jane"}},
+		},
+		{
+			name:        "Sheet by name",
+			options:     ExcelOptions{Sheet: "q1 sales", HeaderRow: 3},
+			wantColumns: []string{"region", "amount", "note"},
+			wantRows: []common.DataRow{
+				{"region": "North", "amount": "100", "note": "x"},
+				{"region": "South", "amount": "200", "note": "y"},
+				{"region": "Total", "amount": "300"},
+			},
+		},
+		{
+			name:        "Sheet by position with a range",
+			options:     ExcelOptions{Sheet: "2", Range: "B3:C5"},
+			wantColumns: []string{"region", "amount"},
+			wantRows: []common.DataRow{
+				{"region": "North", "amount": "100"},
+				{"region": "South", "amount": "200"},
+			},
+		},
+		{
+			name:        "Open-ended range",
+			options:     ExcelOptions{Sheet: "Q1 Sales", Range: "C3:C"},
+			wantColumns: []string{"amount"},
+			wantRows:    []common.DataRow{{"amount": "100"}, {"amount": "200"}, {"amount": "300"}},
+		},
+		{
+			name:    "Unknown sheet",
+			options: ExcelOptions{Sheet: "Budget"},
+			wantErr: `excel sheet "Budget" not found (sheets: Summary, Q1 Sales)`,
+		},
+		{
+			name:    "Sheet position out of range",
+			options: ExcelOptions{Sheet: "3"},
+			wantErr: "excel sheet 3 does not exist, the workbook has 2 sheets",
+		},
+		{
+			name:    "Header row below the data",
+			options: ExcelOptions{HeaderRow: 10},
+			wantErr: "at least a header row and one data row",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &ExcelLoader{Options: tt.options}
+			got, err := l.Load(path)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ExcelLoader.Load() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExcelLoader.Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Columns, tt.wantColumns) {
+				t.Errorf("Columns = %q, want %q", got.Columns, tt.wantColumns)
+			}
+			if !reflect.DeepEqual(got.Rows, tt.wantRows) {
+				t.Errorf("Rows = %v, want %v", got.Rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func TestExcelLoader_LoadTables(t *testing.T) {
+	path := writeWorkbook(t, map[string][][]interface{}{
+		"Customers": {{"id", "name"}, {"1", "John"}},
+		"Notes":     {},
+		"Orders":    {{"id", "total"}, {"10", "5.5"}, {"11", "7"}},
+	}, "Customers", "Notes", "Orders")
+
+	l := &ExcelLoader{Options: ExcelOptions{AllSheets: true}}
+	tables, err := l.LoadTables(path)
 	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
+		t.Fatalf("ExcelLoader.LoadTables() error = %v", err)
 	}
-	defer os.RemoveAll(tempDir)
 
-	// Create test Excel files using excelize
-	// ...
+	// The empty sheet is left out
+	var names []string
+	for _, table := range tables {
+		names = append(names, table.Name)
+	}
+	if want := []string{"Customers", "Orders"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("LoadTables() tables = %v, want %v", names, want)
+	}
+	if len(tables[1].DataSet.Rows) != 2 || tables[1].DataSet.Rows[1]["total"] != "7" {
+		t.Errorf("LoadTables() Orders rows = %v", tables[1].DataSet.Rows)
+	}
+}
+
+// writeTypedWorkbook saves a sheet with numbers, dates, booleans, a
+// formula without a cached result and a merged cell, laid out as
+//
+//	region | day        | units | share | paid  | label
+//	North  | 2024-03-01 | 12    | 25%   | TRUE  | =UPPER(A2)
+//	(A2)   | 2024-03-02 | 7     | 12.5% | FALSE |
+func writeTypedWorkbook(t *testing.T) string {
+	t.Helper()
+
+	file := excelize.NewFile()
+	defer file.Close()
+
+	set := func(cell string, value interface{}) {
+		if err := file.SetCellValue("Sheet1", cell, value); err != nil {
+			t.Fatalf("Failed to set cell %s: %v", cell, err)
+		}
+	}
+	style := func(cells string, numFmt int, customNumFmt string) {
+		s := &excelize.Style{NumFmt: numFmt}
+		if customNumFmt != "" {
+			s.CustomNumFmt = &customNumFmt
+		}
+		id, err := file.NewStyle(s)
+		if err == nil {
+			first, last, _ := strings.Cut(cells, ":")
+			err = file.SetCellStyle("Sheet1", first, last, id)
+		}
+		if err != nil {
+			t.Fatalf("Failed to style cells %s: %v", cells, err)
+		}
+	}
+
+	for i, header := range []string{"region", "day", "units", "share", "paid", "label"} {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		set(cell, header)
+	}
+	set("A2", "North")
+	set("B2", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	set("B3", time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC))
+	set("C2", 12)
+	set("C3", 7)
+	set("D2", 0.25)
+	set("D3", 0.125)
+	set("E2", true)
+	set("E3", false)
+	shared, ref := excelize.STCellFormulaTypeShared, "F2:F3"
+	if err := file.SetCellFormula("Sheet1", "F2", "UPPER(A2)", excelize.FormulaOpts{Type: &shared, Ref: &ref}); err != nil {
+		t.Fatalf("Failed to set formula: %v", err)
+	}
+	if err := file.MergeCell("Sheet1", "A2", "A3"); err != nil {
+		t.Fatalf("Failed to merge cells: %v", err)
+	}
+	style("B2:B3", 0, "dd/mm/yyyy")
+	style("D2:D3", 10, "")
+
+	path := filepath.Join(t.TempDir(), "typed.xlsx")
+	if err := file.SaveAs(path); err != nil {
+		t.Fatalf("Failed to save workbook: %v", err)
+	}
+	return path
+}
+
+func TestExcelLoader_Values(t *testing.T) {
+	path := writeTypedWorkbook(t)
+	march := func(day int) time.Time { return time.Date(2024, 3, day, 0, 0, 0, 0, time.UTC) }
 
 	tests := []struct {
 		name     string
-		filePath string
-		wantErr  bool
-		checkFn  func(*testing.T, *DataSet)
+		options  ExcelOptions
+		wantRows []common.DataRow
+	}{
+		{
+			name: "Typed values",
+			wantRows: []common.DataRow{
+				{"region": "North", "day": march(1), "units": int64(12), "share": 0.25, "paid": true},
+				{"day": march(2), "units": int64(7), "share": 0.125, "paid": false},
+			},
+		},
+		{
+			name:    "Merged cells filled down",
+			options: ExcelOptions{FillMerged: true},
+			wantRows: []common.DataRow{
+				{"region": "North", "day": march(1), "units": int64(12), "share": 0.25, "paid": true},
+				{"region": "North", "day": march(2), "units": int64(7), "share": 0.125, "paid": false},
+			},
+		},
+		{
+			name:    "Formula text",
+			options: ExcelOptions{Formulas: ExcelFormulaText, Range: "F1:F3"},
+			wantRows: []common.DataRow{
+				{"label": "=UPPER(A2)"},
+				{"label": "=UPPER(A3)"},
+			},
+		},
+		{
+			name:    "Displayed text",
+			options: ExcelOptions{Values: ExcelValuesText, FillMerged: true},
+			wantRows: []common.DataRow{
+				{"region": "North", "day": "01/03/2024", "units": "12", "share": "25.00%", "paid": "TRUE", "label": ""},
+				{"region": "North", "day": "02/03/2024", "units": "7", "share": "12.50%", "paid": "FALSE", "label": ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &ExcelLoader{Options: tt.options}
+			got, err := l.Load(path)
+			if err != nil {
+				t.Fatalf("ExcelLoader.Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Rows, tt.wantRows) {
+				t.Errorf("Rows = %v, want %v", got.Rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func TestExcelLoader_ValuesStreamed(t *testing.T) {
+	file, err := excelize.OpenFile(writeTypedWorkbook(t))
+	if err != nil {
+		t.Fatalf("Failed to open workbook: %v", err)
+	}
+	defer file.Close()
+
+	it, err := newExcelIterator(file, "Sheet1", cellRange{firstCol: 1, firstRow: 1}, ExcelOptions{})
+	if err != nil {
+		t.Fatalf("newExcelIterator() error = %v", err)
+	}
+	it.file = nil
+	dataset, err := common.CollectDataSet(it)
+	if err != nil {
+		t.Fatalf("CollectDataSet() error = %v", err)
+	}
+	if len(dataset.Rows) != 2 || dataset.Rows[0]["units"] != int64(12) {
+		t.Fatalf("Rows = %v, want the typed rows", dataset.Rows)
+	}
+
+	// Types and styles come with the rows, so excelize never loads
+	// the worksheet into memory
+	file.Sheet.Range(func(name, _ interface{}) bool {
+		t.Errorf("worksheet %v was loaded into memory", name)
+		return true
+	})
+}
+
+func TestIsDateFormatCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"dd/mm/yyyy", true},
+		{"h:mm AM/PM", true},
+		{"[$-409]mmmm d, yyyy", true},
+		{"0.00%", false},
+		{"#,##0.00 \"kr\"", false},
+		{"[Red]#,##0;[Blue]-#,##0", false},
+		{"0.00_);(0.00)", false},
+		{"General", false},
+		{"0 \\d", false},
+		{"[h]:mm", true},
+	}
+
+	for _, tt := range tests {
+		if got := isDateFormatCode(tt.code); got != tt.want {
+			t.Errorf("isDateFormatCode(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestExcelOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options ExcelOptions
+		wantErr string
 	}{
-		// Test cases
-		// ...
+		{name: "Defaults"},
+		{name: "Range", options: ExcelOptions{Range: "b3:f200", HeaderRow: 4}},
+		{name: "Column range", options: ExcelOptions{Range: "B:F"}},
+		{name: "Sheet with all sheets", options: ExcelOptions{Sheet: "Data", AllSheets: true}, wantErr: "cannot be selected when reading all sheets"},
+		{name: "Negative header row", options: ExcelOptions{HeaderRow: -1}, wantErr: "header row must be positive"},
+		{name: "Unknown value mode", options: ExcelOptions{Values: "raw"}, wantErr: `unknown Excel value mode "raw"`},
+		{name: "Unknown formula mode", options: ExcelOptions{Formulas: "text"}, wantErr: `unknown Excel formula mode "text"`},
+		{name: "Single cell", options: ExcelOptions{Range: "B3"}, wantErr: `invalid Excel range "B3"`},
+		{name: "Reversed range", options: ExcelOptions{Range: "F3:B1"}, wantErr: "the end comes before the start"},
+		{name: "Header outside the range", options: ExcelOptions{Range: "A5:C10", HeaderRow: 2}, wantErr: "header row 2 is outside the range A5:C10"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			l := &ExcelLoader{}
-			got, err := l.Load(tt.filePath)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("ExcelLoader.Load() error = %v, wantErr %v", err, tt.wantErr)
+			err := tt.options.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
 				return
 			}
-			if !tt.wantErr && tt.checkFn != nil {
-				tt.checkFn(t, got)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
 			}
 		})
 	}
 }
-*/
@@ -0,0 +1,483 @@
+package loaders
+
+import (
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FilesLoader reads the files named by a glob pattern, such as
+// sales_2024-*.csv, or held by a directory. Each file is read with the loader
+// for its format, configured from Config. Load and Stream append the files
+// into one table, while LoadTables makes a table of each file, named after it.
+type FilesLoader struct {
+	Config *Config // Options for the formats of the files; nil uses the defaults
+}
+
+// IsMultiFile reports whether input names several files: a directory, or a
+// glob pattern that is not the name of a file
+func IsMultiFile(input string) bool {
+	if info, err := os.Stat(input); err == nil {
+		return info.IsDir()
+	}
+	return strings.ContainsAny(input, "*?[")
+}
+
+// ExpandInput returns the files input names in name order: the file itself,
+// the files matching a glob pattern, or the files in a directory, without
+// its subdirectories. Hidden files are skipped, and so are files without the
+// extension of a supported format unless the format of the files is given.
+func ExpandInput(input, format string) ([]string, error) {
+	var paths []string
+	info, err := os.Stat(input)
+	switch {
+	case err == nil && info.IsDir():
+		entries, err := os.ReadDir(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input directory: %w", err)
+		}
+		for _, entry := range entries {
+			paths = append(paths, filepath.Join(input, entry.Name()))
+		}
+	case err == nil:
+		return []string{input}, nil
+	case strings.ContainsAny(input, "*?["):
+		if paths, err = filepath.Glob(input); err != nil {
+			return nil, fmt.Errorf("invalid input pattern %s: %w", input, err)
+		}
+	default:
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	var files []string
+	for _, path := range paths {
+		if strings.HasPrefix(filepath.Base(path), ".") || (format == "" && !supportedFile(path)) {
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, path)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files of a supported format found in %s", input)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// supportedFile reports whether name has the extension of a supported format
+// or archive, possibly followed by a compression extension
+func supportedFile(name string) bool {
+	return formatOfPath(name) != nil
+}
+
+// fileTableName returns the table name of a file: its base name without
+// directory and extensions
+func fileTableName(name string) string {
+	table := TrimCompression(filepath.Base(name))
+	return strings.TrimSuffix(table, filepath.Ext(table))
+}
+
+// loadFileTables reads the file at filePath as a table named after name.
+// Sheets of workbooks read with AllSheets, and tables of archives, are
+// prefixed with that name.
+func loadFileTables(filePath, name string, config *Config) ([]NamedDataSet, error) {
+	loader, err := NewLoader(filePath, config)
+	if err != nil {
+		return nil, err
+	}
+
+	table := fileTableName(name)
+	if multiTable, ok := loader.(MultiTableLoader); ok && (config.Excel.AllSheets || IsArchive(filePath)) {
+		tables, err := multiTable.LoadTables(filePath)
+		if err != nil {
+			return nil, err
+		}
+		for i := range tables {
+			tables[i].Name = table + "_" + tables[i].Name
+		}
+		return tables, nil
+	}
+
+	dataset, err := loader.Load(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return []NamedDataSet{{Name: table, DataSet: dataset}}, nil
+}
+
+// fileConfig returns the config the files are read with. The source column
+// is added by the FilesLoader, so it is cleared for the loader of each file.
+func (l *FilesLoader) fileConfig() *Config {
+	config := Config{}
+	if l.Config != nil {
+		config = *l.Config
+	}
+	config.SourceColumn = ""
+	return &config
+}
+
+// Load appends the files into one table. Its columns are those of all the
+// files, in the order they first appear, and rows of files without a column
+// leave it empty.
+func (l *FilesLoader) Load(input string) (*common.DataSet, error) {
+	config := l.fileConfig()
+	files, err := ExpandInput(input, config.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	schemas := make([]fileSchema, len(files))
+	var rows []common.DataRow
+	for i, file := range files {
+		loader, err := NewLoader(file, config)
+		if err != nil {
+			return nil, err
+		}
+		dataset, err := loader.Load(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", file, err)
+		}
+		if err := l.addSource(dataset, file); err != nil {
+			return nil, err
+		}
+		schemas[i] = fileSchema{columns: dataset.Columns, types: dataset.Types}
+		rows = append(rows, dataset.Rows...)
+	}
+
+	columns, types := appendSchema(schemas)
+	if rows == nil {
+		rows = []common.DataRow{}
+	}
+	return &common.DataSet{Columns: l.withSource(columns), Rows: rows, Types: types}, nil
+}
+
+// Stream appends the files into one table like Load, reading them one after
+// the other. The columns of every file are needed before the first row, so
+// each file is opened once up front to read them.
+func (l *FilesLoader) Stream(input string) (common.RowIterator, error) {
+	config := l.fileConfig()
+	files, err := ExpandInput(input, config.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	schemas := make([]fileSchema, len(files))
+	for i, file := range files {
+		it, err := openFile(file, config)
+		if err != nil {
+			return nil, err
+		}
+		schemas[i] = fileSchema{columns: it.Columns(), types: common.ColumnTypesOf(it)}
+		it.Close()
+		if err := l.checkSource(schemas[i].columns, file); err != nil {
+			return nil, err
+		}
+	}
+
+	columns, types := appendSchema(schemas)
+	return &filesIterator{
+		files:   files,
+		config:  config,
+		source:  l.sourceColumn(),
+		columns: l.withSource(columns),
+		types:   types,
+	}, nil
+}
+
+// LoadTables reads each file as a table named after it, without its
+// directory and extensions
+func (l *FilesLoader) LoadTables(input string) ([]NamedDataSet, error) {
+	config := l.fileConfig()
+	files, err := ExpandInput(input, config.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []NamedDataSet
+	for _, file := range files {
+		loaded, err := loadFileTables(file, file, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", file, err)
+		}
+		for _, table := range loaded {
+			if err := l.addSource(table.DataSet, file); err != nil {
+				return nil, err
+			}
+			table.DataSet.Columns = l.withSource(table.DataSet.Columns)
+		}
+		tables = append(tables, loaded...)
+	}
+	return tables, nil
+}
+
+// openFile opens a row iterator over one of the files
+func openFile(file string, config *Config) (common.RowIterator, error) {
+	loader, err := NewLoader(file, config)
+	if err != nil {
+		return nil, err
+	}
+	it, err := OpenStream(loader, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", file, err)
+	}
+	return it, nil
+}
+
+// sourceColumn returns the column receiving the name of each row's file, or
+// "" if there is none
+func (l *FilesLoader) sourceColumn() string {
+	if l.Config == nil {
+		return ""
+	}
+	return l.Config.SourceColumn
+}
+
+// checkSource fails if a file already has a column named like the source
+// column
+func (l *FilesLoader) checkSource(columns []string, file string) error {
+	source := l.sourceColumn()
+	if source == "" {
+		return nil
+	}
+	for _, column := range columns {
+		if column == source {
+			return fmt.Errorf("%s already has a column named %s, choose another source column", file, source)
+		}
+	}
+	return nil
+}
+
+// addSource sets the source column of the rows of a file to its name. The
+// column is added to the columns by withSource.
+func (l *FilesLoader) addSource(dataset *common.DataSet, file string) error {
+	source := l.sourceColumn()
+	if source == "" {
+		return nil
+	}
+	if err := l.checkSource(dataset.Columns, file); err != nil {
+		return err
+	}
+	name := filepath.Base(file)
+	for _, row := range dataset.Rows {
+		row[source] = name
+	}
+	return nil
+}
+
+// withSource returns columns followed by the source column, if there is one
+func (l *FilesLoader) withSource(columns []string) []string {
+	if source := l.sourceColumn(); source != "" {
+		return append(columns[:len(columns):len(columns)], source)
+	}
+	return columns
+}
+
+// filesIterator reads the rows of several files, one file after the other
+type filesIterator struct {
+	files   []string
+	config  *Config
+	source  string // Column receiving the name of each row's file, if not empty
+	columns []string
+	types   map[string]dialects.ColumnDef
+	current common.RowIterator // Iterator over files[next-1], nil between files
+	next    int
+}
+
+func (it *filesIterator) Columns() []string {
+	return it.columns
+}
+
+func (it *filesIterator) ColumnTypes() map[string]dialects.ColumnDef {
+	return it.types
+}
+
+func (it *filesIterator) Next() (common.DataRow, error) {
+	for {
+		if it.current == nil {
+			if it.next == len(it.files) {
+				return nil, io.EOF
+			}
+			current, err := openFile(it.files[it.next], it.config)
+			if err != nil {
+				return nil, err
+			}
+			it.current = current
+			it.next++
+		}
+
+		row, err := it.current.Next()
+		if err == io.EOF {
+			it.current.Close()
+			it.current = nil
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", it.files[it.next-1], err)
+		}
+		if it.source != "" {
+			row[it.source] = filepath.Base(it.files[it.next-1])
+		}
+		return row, nil
+	}
+}
+
+func (it *filesIterator) Close() error {
+	if it.current == nil {
+		return nil
+	}
+	err := it.current.Close()
+	it.current = nil
+	return err
+}
+
+// fileSchema is the columns and declared types of one of the appended files
+type fileSchema struct {
+	columns []string
+	types   map[string]dialects.ColumnDef
+}
+
+// holds reports whether the file has the column key names, or the column
+// holding the nested field it names
+func (s fileSchema) holds(key string) bool {
+	for _, column := range s.columns {
+		if column == key || strings.HasPrefix(key, column+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// appendSchema returns the columns of files appended into one table, in the
+// order they first appear, and the declared types that hold the values of
+// every file. Types are kept only where every file holding the column
+// declares one, and are widened to fit all of them. Columns some files lack
+// are nullable.
+func appendSchema(schemas []fileSchema) ([]string, map[string]dialects.ColumnDef) {
+	var columns []string
+	seen := make(map[string]bool)
+	for _, schema := range schemas {
+		for _, column := range schema.columns {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+
+	var types map[string]dialects.ColumnDef
+	for _, schema := range schemas {
+		for key := range schema.types {
+			if _, done := types[key]; done {
+				continue
+			}
+			def, ok := appendType(schemas, key)
+			if !ok {
+				continue
+			}
+			if types == nil {
+				types = make(map[string]dialects.ColumnDef)
+			}
+			types[key] = def
+		}
+	}
+	return columns, types
+}
+
+// appendType returns the declared type of key across the files, and false if
+// a file holding it does not declare it or the types do not widen into one
+func appendType(schemas []fileSchema, key string) (dialects.ColumnDef, bool) {
+	var merged dialects.ColumnDef
+	declared, missing := false, false
+	for _, schema := range schemas {
+		if !schema.holds(key) {
+			missing = true
+			continue
+		}
+		def, ok := schema.types[key]
+		if !ok {
+			return dialects.ColumnDef{}, false
+		}
+		if !declared {
+			merged, declared = def, true
+			continue
+		}
+		if merged, ok = widenColumnDef(merged, def); !ok {
+			return dialects.ColumnDef{}, false
+		}
+	}
+	merged.Nullable = merged.Nullable || missing
+	return merged, declared
+}
+
+// integerDigits are the decimal digits the integer types hold
+var integerDigits = map[dialects.SQLType]int{
+	dialects.SQLTypeSmallInt: 5,
+	dialects.SQLTypeInteger:  10,
+	dialects.SQLTypeBigInt:   19,
+}
+
+// widenColumnDef returns a type holding the values of both a and b: the wider
+// of two integer or text types, a DECIMAL with the integer and fraction
+// digits of both, FLOAT for floats mixed with exact numbers, or DATETIME for
+// dates mixed with timestamps. It returns false for types that do not mix.
+func widenColumnDef(a, b dialects.ColumnDef) (dialects.ColumnDef, bool) {
+	widened := a
+	widened.Nullable = a.Nullable || b.Nullable
+	if a.Type == b.Type && a.Type != dialects.SQLTypeVarchar && a.Type != dialects.SQLTypeDecimal {
+		return widened, true
+	}
+
+	_, aInteger := integerDigits[a.Type]
+	_, bInteger := integerDigits[b.Type]
+	aExact := aInteger || a.Type == dialects.SQLTypeDecimal
+	bExact := bInteger || b.Type == dialects.SQLTypeDecimal
+
+	switch {
+	case aInteger && bInteger:
+		if integerDigits[b.Type] > integerDigits[a.Type] {
+			widened.Type = b.Type
+		}
+	case aExact && bExact:
+		digits := max(exactDigits(a), exactDigits(b))
+		widened.Type = dialects.SQLTypeDecimal
+		widened.Scale = max(a.Scale, b.Scale)
+		widened.Precision = digits + widened.Scale
+	case (aExact || a.Type == dialects.SQLTypeFloat) && (bExact || b.Type == dialects.SQLTypeFloat):
+		widened.Type = dialects.SQLTypeFloat
+		widened.Precision, widened.Scale = 0, 0
+	case a.Type == dialects.SQLTypeVarchar && b.Type == dialects.SQLTypeVarchar:
+		widened.Length = max(a.Length, b.Length)
+	case isText(a.Type) && isText(b.Type):
+		widened.Type = dialects.SQLTypeText
+		widened.Length = 0
+	case isTemporal(a.Type) && isTemporal(b.Type):
+		widened.Type = dialects.SQLTypeDateTime
+	default:
+		return dialects.ColumnDef{}, false
+	}
+	return widened, true
+}
+
+// exactDigits returns the digits before the decimal point an integer or
+// DECIMAL type holds
+func exactDigits(def dialects.ColumnDef) int {
+	if digits, ok := integerDigits[def.Type]; ok {
+		return digits
+	}
+	return def.Precision - def.Scale
+}
+
+func isText(t dialects.SQLType) bool {
+	return t == dialects.SQLTypeText || t == dialects.SQLTypeVarchar
+}
+
+func isTemporal(t dialects.SQLType) bool {
+	return t == dialects.SQLTypeDate || t == dialects.SQLTypeDateTime
+}
@@ -0,0 +1,317 @@
+package loaders
+
+import (
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files with the given contents in a new directory and
+// returns its path
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestExpandInput(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"sales_2024-02.csv":  "id\n2\n",
+		"sales_2024-01.csv":  "id\n1\n",
+		"sales_2023-12.csv":  "id\n0\n",
+		"stock.jsonl":        `{"id": 1}`,
+		"notes.txt":          "Nightly export",
+		".sales_2024-03.csv": "id\n3\n",
+	})
+	if err := os.Mkdir(filepath.Join(dir, "archive.csv"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		format  string
+		want    []string
+		wantErr string
+	}{
+		{name: "Directory", input: dir, want: []string{"sales_2023-12.csv", "sales_2024-01.csv", "sales_2024-02.csv", "stock.jsonl"}},
+		{name: "Glob pattern", input: filepath.Join(dir, "sales_2024-*.csv"), want: []string{"sales_2024-01.csv", "sales_2024-02.csv"}},
+		{name: "Single file", input: filepath.Join(dir, "stock.jsonl"), want: []string{"stock.jsonl"}},
+		{name: "Given format", input: filepath.Join(dir, "*s*"), format: "csv", want: []string{"notes.txt", "sales_2023-12.csv", "sales_2024-01.csv", "sales_2024-02.csv", "stock.jsonl"}},
+		{name: "No match", input: filepath.Join(dir, "*.xml"), wantErr: "no files of a supported format found in"},
+		{name: "Missing file", input: filepath.Join(dir, "missing.csv"), wantErr: "failed to read input"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := ExpandInput(tt.input, tt.format)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ExpandInput() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandInput() error = %v", err)
+			}
+
+			var got []string
+			for _, file := range files {
+				got = append(got, filepath.Base(file))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandInput() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLoader_Files(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"data.csv": "id\n1\n"})
+
+	tests := []struct {
+		name   string
+		input  string
+		config *Config
+		want   bool
+	}{
+		{name: "Directory", input: dir, want: true},
+		{name: "Glob pattern", input: filepath.Join(dir, "*.csv"), want: true},
+		{name: "Single file", input: filepath.Join(dir, "data.csv")},
+		{name: "Single file with a source column", input: filepath.Join(dir, "data.csv"), config: &Config{SourceColumn: "source"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader, err := NewLoader(tt.input, tt.config)
+			if err != nil {
+				t.Fatalf("NewLoader() error = %v", err)
+			}
+			if _, ok := loader.(*FilesLoader); ok != tt.want {
+				t.Errorf("NewLoader() = %T, want FilesLoader %v", loader, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilesLoader_Load(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"sales_2024-01.csv":   "id,amount\n1,10\n2,20\n",
+		"sales_2024-02.csv":   "id,region,amount\n3,EU,2.5\n",
+		"sales_2024-03.jsonl": `{"id": 4, "channel": "web"}` + "\n",
+	})
+
+	loader := &FilesLoader{Config: &Config{SourceColumn: "source_file"}}
+	got, err := loader.Load(dir)
+	if err != nil {
+		t.Fatalf("FilesLoader.Load() error = %v", err)
+	}
+
+	wantColumns := []string{"id", "amount", "region", "channel", "source_file"}
+	if !reflect.DeepEqual(got.Columns, wantColumns) {
+		t.Errorf("Columns = %q, want %q", got.Columns, wantColumns)
+	}
+	wantRows := []common.DataRow{
+		{"id": "1", "amount": "10", "source_file": "sales_2024-01.csv"},
+		{"id": "2", "amount": "20", "source_file": "sales_2024-01.csv"},
+		{"id": "3", "region": "EU", "amount": "2.5", "source_file": "sales_2024-02.csv"},
+		{"id": json.Number("4"), "channel": "web", "source_file": "sales_2024-03.jsonl"},
+	}
+	if !reflect.DeepEqual(got.Rows, wantRows) {
+		t.Errorf("Rows = %v, want %v", got.Rows, wantRows)
+	}
+
+	// Streaming reads the same table one file after the other
+	it, err := loader.Stream(dir)
+	if err != nil {
+		t.Fatalf("FilesLoader.Stream() error = %v", err)
+	}
+	streamed, err := common.CollectDataSet(it)
+	if err != nil {
+		t.Fatalf("CollectDataSet() error = %v", err)
+	}
+	if !reflect.DeepEqual(streamed.Columns, wantColumns) {
+		t.Errorf("Stream() columns = %q, want %q", streamed.Columns, wantColumns)
+	}
+	if !reflect.DeepEqual(streamed.Rows, wantRows) {
+		t.Errorf("Stream() rows = %v, want %v", streamed.Rows, wantRows)
+	}
+}
+
+func TestFilesLoader_SourceColumnClash(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"data.csv": "id,file\n1,a\n"})
+
+	loader := &FilesLoader{Config: &Config{SourceColumn: "file"}}
+	if _, err := loader.Load(dir); err == nil || !strings.Contains(err.Error(), "already has a column named file") {
+		t.Errorf("FilesLoader.Load() error = %v, want a clash with the source column", err)
+	}
+	if _, err := loader.Stream(dir); err == nil || !strings.Contains(err.Error(), "already has a column named file") {
+		t.Errorf("FilesLoader.Stream() error = %v, want a clash with the source column", err)
+	}
+}
+
+func TestFilesLoader_LoadTables(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"customers.csv": "id,name\n1,Ann\n",
+		"regions.tsv":   "code\tname\nEU\tEurope\n",
+		"readme.md":     "Exports",
+	})
+	if err := os.WriteFile(filepath.Join(dir, "orders.json.gz"), gzipBytes(t, `[{"id": 1}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loader := &FilesLoader{Config: &Config{SourceColumn: "source"}}
+	tables, err := loader.LoadTables(dir)
+	if err != nil {
+		t.Fatalf("FilesLoader.LoadTables() error = %v", err)
+	}
+
+	var names []string
+	for _, table := range tables {
+		names = append(names, table.Name)
+	}
+	if want := []string{"customers", "orders", "regions"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("LoadTables() names = %q, want %q", names, want)
+	}
+
+	regions := tables[2].DataSet
+	if want := []string{"code", "name", "source"}; !reflect.DeepEqual(regions.Columns, want) {
+		t.Errorf("Columns = %q, want %q", regions.Columns, want)
+	}
+	if got := regions.Rows[0]["source"]; got != "regions.tsv" {
+		t.Errorf("source = %v, want %q", got, "regions.tsv")
+	}
+}
+
+func TestAppendSchema(t *testing.T) {
+	schemas := []fileSchema{
+		{
+			columns: []string{"id", "total", "placed", "note", "customer"},
+			types: map[string]dialects.ColumnDef{
+				"id":            {Name: "id", Type: dialects.SQLTypeInteger},
+				"total":         {Name: "total", Type: dialects.SQLTypeDecimal, Precision: 10, Scale: 2},
+				"placed":        {Name: "placed", Type: dialects.SQLTypeDate},
+				"note":          {Name: "note", Type: dialects.SQLTypeText},
+				"customer.name": {Name: "customer.name", Type: dialects.SQLTypeVarchar, Length: 20},
+			},
+		},
+		{
+			columns: []string{"id", "total", "placed", "note", "customer", "channel"},
+			types: map[string]dialects.ColumnDef{
+				"id":            {Name: "id", Type: dialects.SQLTypeBigInt},
+				"total":         {Name: "total", Type: dialects.SQLTypeDecimal, Precision: 6, Scale: 4},
+				"placed":        {Name: "placed", Type: dialects.SQLTypeDateTime},
+				"note":          {Name: "note", Type: dialects.SQLTypeBoolean},
+				"customer.name": {Name: "customer.name", Type: dialects.SQLTypeVarchar, Length: 40},
+				"channel":       {Name: "channel", Type: dialects.SQLTypeText},
+			},
+		},
+		// Files without a schema leave the columns they hold to inference
+		{columns: []string{"id", "total", "placed", "note", "customer", "region"}},
+	}
+
+	columns, types := appendSchema(schemas[:2])
+	if want := []string{"id", "total", "placed", "note", "customer", "channel"}; !reflect.DeepEqual(columns, want) {
+		t.Errorf("Columns = %q, want %q", columns, want)
+	}
+	wantTypes := map[string]dialects.ColumnDef{
+		"id":            {Name: "id", Type: dialects.SQLTypeBigInt},
+		"total":         {Name: "total", Type: dialects.SQLTypeDecimal, Precision: 12, Scale: 4},
+		"placed":        {Name: "placed", Type: dialects.SQLTypeDateTime},
+		"customer.name": {Name: "customer.name", Type: dialects.SQLTypeVarchar, Length: 40},
+		"channel":       {Name: "channel", Type: dialects.SQLTypeText, Nullable: true},
+	}
+	if !reflect.DeepEqual(types, wantTypes) {
+		t.Errorf("Types = %v, want %v", types, wantTypes)
+	}
+
+	columns, types = appendSchema(schemas)
+	if want := []string{"id", "total", "placed", "note", "customer", "channel", "region"}; !reflect.DeepEqual(columns, want) {
+		t.Errorf("Columns = %q, want %q", columns, want)
+	}
+	wantTypes = map[string]dialects.ColumnDef{
+		"channel": {Name: "channel", Type: dialects.SQLTypeText, Nullable: true},
+	}
+	if !reflect.DeepEqual(types, wantTypes) {
+		t.Errorf("Types = %v, want %v", types, wantTypes)
+	}
+}
+
+func TestWidenColumnDef(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   dialects.ColumnDef
+		want   dialects.ColumnDef
+		wantOK bool
+	}{
+		{
+			name:   "Same type",
+			a:      dialects.ColumnDef{Type: dialects.SQLTypeBoolean},
+			b:      dialects.ColumnDef{Type: dialects.SQLTypeBoolean, Nullable: true},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeBoolean, Nullable: true},
+			wantOK: true,
+		},
+		{
+			name:   "Integers",
+			a:      dialects.ColumnDef{Type: dialects.SQLTypeInteger},
+			b:      dialects.ColumnDef{Type: dialects.SQLTypeSmallInt},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeInteger},
+			wantOK: true,
+		},
+		{
+			name:   "Integer and decimal",
+			a:      dialects.ColumnDef{Type: dialects.SQLTypeInteger},
+			b:      dialects.ColumnDef{Type: dialects.SQLTypeDecimal, Precision: 5, Scale: 2},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeDecimal, Precision: 12, Scale: 2},
+			wantOK: true,
+		},
+		{
+			name:   "Float and decimal",
+			a:      dialects.ColumnDef{Type: dialects.SQLTypeDecimal, Precision: 5, Scale: 2},
+			b:      dialects.ColumnDef{Type: dialects.SQLTypeFloat},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeFloat},
+			wantOK: true,
+		},
+		{
+			name:   "Varchar and text",
+			a:      dialects.ColumnDef{Type: dialects.SQLTypeVarchar, Length: 10},
+			b:      dialects.ColumnDef{Type: dialects.SQLTypeText},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeText},
+			wantOK: true,
+		},
+		{
+			name:   "Date and timestamp",
+			a:      dialects.ColumnDef{Type: dialects.SQLTypeDate},
+			b:      dialects.ColumnDef{Type: dialects.SQLTypeDateTime},
+			want:   dialects.ColumnDef{Type: dialects.SQLTypeDateTime},
+			wantOK: true,
+		},
+		{
+			name: "Number and text",
+			a:    dialects.ColumnDef{Type: dialects.SQLTypeInteger},
+			b:    dialects.ColumnDef{Type: dialects.SQLTypeText},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := widenColumnDef(tt.a, tt.b)
+			if ok != tt.wantOK {
+				t.Fatalf("widenColumnDef() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("widenColumnDef() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
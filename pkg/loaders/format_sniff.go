@@ -0,0 +1,242 @@
+package loaders
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// Signatures binary formats start with
+var (
+	parquetMagic = []byte("PAR1")
+	avroMagic    = []byte("Obj\x01")
+	oleMagic     = []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1} // Legacy Office documents, such as .xls workbooks
+	tarMagic     = []byte("ustar")                                        // At offset 257 of tar archives
+)
+
+// excelPart is a file every xlsx workbook holds, telling workbooks from other
+// zip archives
+const excelPart = "xl/workbook.xml"
+
+// sniffDelimiters are the field delimiters tried on delimited text, with
+// their spelling in CSVOptions and the format they make
+var sniffDelimiters = []struct {
+	delimiter rune
+	option    string
+	format    string
+}{
+	{',', ",", "csv"},
+	{'\t', "tab", "tsv"},
+	{';', ";", "csv"},
+	{'|', "|", "psv"},
+}
+
+// sniffFormat recognises the format of the file at filePath from as many of
+// its first bytes as are examined for its encoding, decompressed if need be.
+// Registered formats with a Sniff function are chosen over the built-in ones
+// when they are more confident. It returns false if the file cannot be read
+// or looks like no supported format.
+func sniffFormat(filePath string) (Detection, bool) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return Detection{}, false
+	}
+	defer file.Close()
+
+	content, err := decompress(file)
+	if err != nil {
+		return Detection{}, false
+	}
+	defer content.Close()
+
+	head := make([]byte, sniffSize)
+	n, _ := io.ReadFull(content, head)
+	detection, ok := sniffContent(head[:n], n < sniffSize)
+
+	// The parts of large workbooks may not all fit in the first bytes, but
+	// the zip directory at the end lists them
+	if ok && detection.Format == "zip" && sniffCompression(filePath) == "" && zipHolds(filePath, excelPart) {
+		detection.Format, detection.Confidence = "excel", 1
+	}
+	if sniffed, found := sniffRegistered(head[:n]); found && (!ok || sniffed.Confidence > detection.Confidence) {
+		return sniffed, true
+	}
+	return detection, ok
+}
+
+// sniffRegistered asks the registered formats with a Sniff function how
+// likely head is in their format, and returns the most likely one
+func sniffRegistered(head []byte) (Detection, bool) {
+	best, found := Detection{}, false
+	formats := registered()
+	for i := len(formats) - 1; i >= 0; i-- {
+		if formats[i].Sniff == nil {
+			continue
+		}
+		if confidence := formats[i].Sniff(head); confidence > best.Confidence {
+			best, found = sniffed(formats[i].Name, min(confidence, 1)), true
+		}
+	}
+	return best, found
+}
+
+// zipHolds reports whether the zip archive at filePath holds a file called
+// name
+func zipHolds(filePath, name string) bool {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return false
+	}
+	defer archive.Close()
+
+	for _, f := range archive.File {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffContent recognises a format from the first bytes of a file, which
+// are all of it if complete is set. Binary formats are told by their
+// signature, JSON and XML by how their text starts, and anything else that is
+// text is taken for delimited text, with the delimiter that splits its lines
+// most consistently.
+func sniffContent(head []byte, complete bool) (Detection, bool) {
+	switch {
+	case bytes.HasPrefix(head, parquetMagic):
+		return sniffed("parquet", 1), true
+	case bytes.HasPrefix(head, avroMagic):
+		return sniffed("avro", 1), true
+	case bytes.HasPrefix(head, zipMagic):
+		if bytes.Contains(head, []byte(excelPart)) {
+			return sniffed("excel", 1), true
+		}
+		return sniffed("zip", 0.9), true
+	case bytes.HasPrefix(head, oleMagic):
+		return sniffed("excel", 0.6), true
+	case len(head) >= 262 && bytes.Equal(head[257:262], tarMagic):
+		return sniffed("tar", 1), true
+	}
+
+	// Binary content, and text in encodings wider than a byte, is not
+	// recognised
+	if bytes.IndexByte(head, 0) >= 0 {
+		return Detection{}, false
+	}
+
+	text := bytes.TrimLeft(bytes.TrimPrefix(head, utf8BOM), " \t\r\n")
+	if len(text) == 0 {
+		return Detection{}, false
+	}
+	switch text[0] {
+	case '<':
+		if bytes.HasPrefix(text, []byte("<?xml")) {
+			return sniffed("xml", 1), true
+		}
+		return sniffed("xml", 0.7), true
+	case '[':
+		return sniffed("json", 0.9), true
+	case '{':
+		return sniffJSON(completeLines(text, complete)), true
+	}
+	return sniffDelimited(completeLines(text, complete))
+}
+
+// sniffed returns a detection from the content of a file
+func sniffed(format string, confidence float64) Detection {
+	return Detection{Format: format, Method: DetectedByContent, Confidence: confidence}
+}
+
+// completeLines returns the non-empty lines of text, without the last one if
+// text is cut short and it may be incomplete
+func completeLines(text []byte, complete bool) [][]byte {
+	lines := bytes.Split(text, []byte("\n"))
+	if !complete && len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+
+	var nonEmpty [][]byte
+	for _, line := range lines {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			nonEmpty = append(nonEmpty, line)
+		}
+	}
+	return nonEmpty
+}
+
+// sniffJSON tells JSON Lines, where each line holds an object, from a JSON
+// document that starts with an object spread over several lines
+func sniffJSON(lines [][]byte) Detection {
+	objects := 0
+	for _, line := range lines {
+		if line[0] == '{' && json.Valid(line) {
+			objects++
+		}
+	}
+
+	switch {
+	case objects == 0:
+		return sniffed("json", 0.9)
+	case len(lines) == 1:
+		// A single object on one line reads the same either way
+		return sniffed("json", 0.6)
+	case objects == len(lines):
+		return sniffed("jsonl", 0.95)
+	default:
+		return sniffed("jsonl", 0.6)
+	}
+}
+
+// sniffDelimited finds the delimiter of delimited text: the one that splits
+// its lines into the same number of fields, more than one, most often.
+// Text no delimiter splits is read as a single column.
+func sniffDelimited(lines [][]byte) (Detection, bool) {
+	if len(lines) > 50 {
+		lines = lines[:50]
+	}
+	sample := bytes.Join(lines, []byte("\n"))
+
+	best, bestScore := -1, 0.0
+	for i, d := range sniffDelimiters {
+		if score := delimiterScore(sample, d.delimiter); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return sniffed("csv", 0.3), true
+	}
+
+	detection := sniffed(sniffDelimiters[best].format, 0.4)
+	if len(lines) > 1 {
+		detection.Confidence += 0.5 * bestScore
+	}
+	detection.Delimiter = sniffDelimiters[best].option
+	return detection, true
+}
+
+// delimiterScore returns the share of the records of sample that delimiter
+// splits into as many fields as the first, or 0 if it does not split the
+// first record
+func delimiterScore(sample []byte, delimiter rune) float64 {
+	reader := csv.NewReader(bytes.NewReader(sample))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	records, err := reader.ReadAll()
+	if err != nil || len(records) == 0 || len(records[0]) < 2 {
+		return 0
+	}
+
+	same := 0
+	for _, record := range records {
+		if len(record) == len(records[0]) {
+			same++
+		}
+	}
+	return float64(same) / float64(len(records))
+}
@@ -0,0 +1,313 @@
+package loaders
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Format describes an input format of the registry: the names it is chosen
+// by, the extensions and media types of its files and the loader that reads
+// it. Formats are registered with Register.
+type Format struct {
+	Name        string   // Name the format is chosen by, such as "csv"
+	Aliases     []string // Other names it can be chosen by
+	Extensions  []string // Extensions of its files, such as ".csv"; the first one is given to spooled standard input
+	MIMETypes   []string // Media types it can also be chosen by, such as "text/csv"
+	Description string   // One line shown in help output
+	Archive     bool     // Holds files of other formats, each read as a table
+	New         func(config *Config) (Loader, error)
+	// Sniff returns how likely it is, from 0 to 1, that content starting
+	// with head is in the format. It is optional, and used when a file's
+	// format is found from its content.
+	Sniff func(head []byte) float64
+}
+
+// formatsMu guards formats. The slice is never changed in place: Register
+// replaces it, so the one returned by registered stays valid.
+var formatsMu sync.RWMutex
+
+// formats is the format registry. Inputs are matched to a format by the name
+// given for them, by their extension or, failing both, by their content.
+// Later formats take precedence over earlier ones sharing an extension or
+// media type.
+var formats = []Format{
+	{Name: "csv", Extensions: []string{".csv"}, MIMETypes: []string{"text/csv"}, Description: "Comma-separated values", New: delimitedLoader(",")},
+	{Name: "tsv", Extensions: []string{".tsv"}, MIMETypes: []string{"text/tab-separated-values"}, Description: "Tab-separated values", New: delimitedLoader("tab")},
+	{Name: "psv", Extensions: []string{".psv"}, Description: "Pipe-separated values", New: delimitedLoader("|")},
+	{
+		Name:        "json",
+		Extensions:  []string{".json"},
+		MIMETypes:   []string{"application/json"},
+		Description: "JSON array of objects, or an object holding one",
+		New: func(config *Config) (Loader, error) {
+			if err := config.JSON.Validate(); err != nil {
+				return nil, err
+			}
+			return &JSONLoader{Encoding: config.Encoding, Options: config.JSON}, nil
+		},
+	},
+	{
+		Name:        "jsonl",
+		Aliases:     []string{"ndjson"},
+		Extensions:  []string{".jsonl", ".ndjson"},
+		MIMETypes:   []string{"application/x-ndjson", "application/jsonl"},
+		Description: "JSON Lines, an object on each line",
+		New: func(config *Config) (Loader, error) {
+			return &JSONLinesLoader{Encoding: config.Encoding, Options: config.JSONLines}, nil
+		},
+	},
+	{
+		Name:        "xml",
+		Extensions:  []string{".xml"},
+		MIMETypes:   []string{"application/xml", "text/xml"},
+		Description: "XML with a repeated record element",
+		New: func(config *Config) (Loader, error) {
+			if err := config.XML.Validate(); err != nil {
+				return nil, err
+			}
+			return &XMLLoader{Encoding: config.Encoding, Options: config.XML}, nil
+		},
+	},
+	{
+		Name:        "excel",
+		Aliases:     []string{"xlsx", "xls"},
+		Extensions:  []string{".xlsx", ".xls"},
+		MIMETypes:   []string{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "application/vnd.ms-excel"},
+		Description: "Excel workbook",
+		New: func(config *Config) (Loader, error) {
+			if err := config.Excel.Validate(); err != nil {
+				return nil, err
+			}
+			return &ExcelLoader{Options: config.Excel}, nil
+		},
+	},
+	{
+		Name:        "parquet",
+		Extensions:  []string{".parquet"},
+		MIMETypes:   []string{"application/vnd.apache.parquet"},
+		Description: "Apache Parquet",
+		New: func(config *Config) (Loader, error) {
+			if err := config.Parquet.Validate(); err != nil {
+				return nil, err
+			}
+			return &ParquetLoader{Options: config.Parquet}, nil
+		},
+	},
+	{
+		Name:        "avro",
+		Extensions:  []string{".avro"},
+		MIMETypes:   []string{"application/avro", "avro/binary"},
+		Description: "Apache Avro container file",
+		New: func(config *Config) (Loader, error) {
+			return &AvroLoader{}, nil
+		},
+	},
+	{
+		Name:        "zip",
+		Extensions:  []string{".zip"},
+		MIMETypes:   []string{"application/zip"},
+		Description: "Zip archive, a table of each file",
+		Archive:     true,
+		New:         archiveLoader,
+	},
+	{
+		Name:        "tar",
+		Extensions:  []string{".tar", ".tgz", ".tbz", ".tbz2", ".tzst"},
+		MIMETypes:   []string{"application/x-tar"},
+		Description: "Tar archive, a table of each file",
+		Archive:     true,
+		New:         archiveLoader,
+	},
+}
+
+// Register adds a format to the registry, so that inputs can be read in it
+// by name, extension, media type or, if it has a Sniff function, content.
+// Names and aliases are not case sensitive. Like the other registries of
+// BrokoliSQL, Register panics if the name is empty or New is nil, and if the
+// name or an alias is already taken, so a format cannot be registered twice
+// or replace another. A format may share extensions and media types with
+// earlier formats, and takes precedence over them for those.
+func Register(format Format) {
+	format.Name = strings.ToLower(format.Name)
+	if format.Name == "" || format.New == nil {
+		panic(fmt.Sprintf("loaders: Register of %q needs a name and a New function", format.Name))
+	}
+
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	for _, f := range formats {
+		if f.Name == format.Name {
+			panic(fmt.Sprintf("loaders: Register called twice for %q", format.Name))
+		}
+		for _, name := range append([]string{format.Name}, format.Aliases...) {
+			if strings.EqualFold(f.Name, name) || contains(f.Aliases, name) {
+				panic(fmt.Sprintf("loaders: name or alias %q of %q is taken by %q", name, format.Name, f.Name))
+			}
+		}
+	}
+	formats = append(formats[:len(formats):len(formats)], format)
+}
+
+// registered returns the format registry as it is now
+func registered() []Format {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	return formats
+}
+
+// Formats returns the registered formats in the order they were registered,
+// starting with the built-in ones
+func Formats() []Format {
+	return append([]Format(nil), registered()...)
+}
+
+// delimitedLoader returns the loader constructor of delimited text whose
+// fields are separated by delimiter unless the options say otherwise
+func delimitedLoader(delimiter string) func(config *Config) (Loader, error) {
+	return func(config *Config) (Loader, error) {
+		options := config.CSV
+		if options.Delimiter == "" {
+			options.Delimiter = delimiter
+		}
+		if err := options.Validate(); err != nil {
+			return nil, err
+		}
+		return &CSVLoader{Options: options, Encoding: config.Encoding}, nil
+	}
+}
+
+// archiveLoader returns the loader of zip and tar archives. The format given
+// for the archive does not apply to the files it holds, which are matched to
+// their own.
+func archiveLoader(config *Config) (Loader, error) {
+	entries := *config
+	entries.Format = ""
+	return &ArchiveLoader{Config: &entries}, nil
+}
+
+// FormatNames returns the names input formats can be given by, in registry
+// order
+func FormatNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, f := range registered() {
+		for _, name := range append([]string{f.Name}, f.Aliases...) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// lookupFormat returns the format called name, or with name as a media type,
+// ignoring case and media type parameters such as "; charset=utf-8"
+func lookupFormat(name string) (*Format, error) {
+	name = strings.ToLower(name)
+	mimeType := strings.TrimSpace(strings.SplitN(name, ";", 2)[0])
+	formats := registered()
+	for i := len(formats) - 1; i >= 0; i-- {
+		f := &formats[i]
+		if f.Name == name || contains(f.Aliases, name) || contains(f.MIMETypes, mimeType) {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s (supported: %s)", ErrUnsupportedFormat, name, strings.Join(FormatNames(), ", "))
+}
+
+// formatOfPath returns the format of the file at filePath by its extension,
+// after any compression extension, or nil if no format has that extension
+func formatOfPath(filePath string) *Format {
+	ext := strings.ToLower(filepath.Ext(TrimCompression(filePath)))
+	if ext == "" {
+		return nil
+	}
+	formats := registered()
+	for i := len(formats) - 1; i >= 0; i-- {
+		if contains(formats[i].Extensions, ext) {
+			return &formats[i]
+		}
+	}
+	return nil
+}
+
+// contains reports whether values holds value, ignoring case
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsArchive reports whether filePath names a zip or tar archive, compressed
+// or not
+func IsArchive(filePath string) bool {
+	f := formatOfPath(filePath)
+	return f != nil && f.Archive
+}
+
+// Ways a format is detected, from the most to the least reliable
+const (
+	DetectedByName      = "name"      // Given explicitly, such as with --format
+	DetectedByExtension = "extension" // From the file extension
+	DetectedByContent   = "content"   // From the first bytes of the file
+)
+
+// Detection is the format DetectFormat found for an input
+type Detection struct {
+	Format     string  // Name of the format, such as "csv"
+	Method     string  // How the format was found: DetectedByName, DetectedByExtension or DetectedByContent
+	Confidence float64 // How likely the format is right, from 0 to 1
+	Delimiter  string  // Field delimiter found in delimited text read by content, empty otherwise
+}
+
+// IsArchive reports whether the input is a zip or tar archive
+func (d Detection) IsArchive() bool {
+	f, err := lookupFormat(d.Format)
+	return err == nil && f.Archive
+}
+
+// DetectFormat returns the format of the file at filePath. A format given by
+// name is used as is, otherwise the format is found from the file extension,
+// after any compression extension, and failing that from the content of the
+// file, decompressed if need be. Formats found from the content come with
+// the confidence of the guess.
+func DetectFormat(filePath, name string) (Detection, error) {
+	if name != "" {
+		f, err := lookupFormat(name)
+		if err != nil {
+			return Detection{}, err
+		}
+		return Detection{Format: f.Name, Method: DetectedByName, Confidence: 1}, nil
+	}
+
+	if f := formatOfPath(filePath); f != nil {
+		return Detection{Format: f.Name, Method: DetectedByExtension, Confidence: 0.9}, nil
+	}
+
+	if detection, ok := sniffFormat(filePath); ok {
+		return detection, nil
+	}
+	return Detection{}, fmt.Errorf("%w: %s", ErrUnsupportedFormat, filepath.Ext(TrimCompression(filePath)))
+}
+
+// newDetectedLoader returns the loader of a detected format. A delimiter
+// found in the content is used unless config sets one.
+func newDetectedLoader(detection Detection, config *Config) (Loader, error) {
+	f, err := lookupFormat(detection.Format)
+	if err != nil {
+		return nil, err
+	}
+	if detection.Delimiter != "" && config.CSV.Delimiter == "" {
+		sniffed := *config
+		sniffed.CSV.Delimiter = detection.Delimiter
+		config = &sniffed
+	}
+	return f.New(config)
+}
@@ -0,0 +1,336 @@
+package loaders
+
+import (
+	"brokolisql-go/pkg/common"
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// renamed copies the file at path to one called name in a new directory
+// and returns its path
+func renamed(t *testing.T, path, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return writeFile(t, name, data)
+}
+
+func TestDetectFormat(t *testing.T) {
+	workbook := writeWorkbook(t, map[string][][]interface{}{"Data": {{"id"}, {1}}}, "Data")
+	orders := writeParquet(t, []parquetOrder{{ID: 1}})
+
+	tests := []struct {
+		name     string
+		filePath string
+		format   string
+		want     Detection
+		wantErr  bool
+	}{
+		{
+			name:     "Format given by name",
+			filePath: writeFile(t, "export.txt", []byte("id;name\n1;Ann\n")),
+			format:   "CSV",
+			want:     Detection{Format: "csv", Method: DetectedByName, Confidence: 1},
+		},
+		{
+			name:     "Alias",
+			filePath: "data.json",
+			format:   "ndjson",
+			want:     Detection{Format: "jsonl", Method: DetectedByName, Confidence: 1},
+		},
+		{
+			name:     "Unknown name",
+			filePath: "data.csv",
+			format:   "yaml",
+			wantErr:  true,
+		},
+		{
+			name:     "Extension",
+			filePath: "data.tsv",
+			want:     Detection{Format: "tsv", Method: DetectedByExtension, Confidence: 0.9},
+		},
+		{
+			name:     "Extension before compression",
+			filePath: "backup.tar.zst",
+			want:     Detection{Format: "tar", Method: DetectedByExtension, Confidence: 0.9},
+		},
+		{
+			name:     "Semicolon delimited text",
+			filePath: writeFile(t, "export.txt", []byte("id;name;city\n1;Ann;Oslo\n2;Bob;Rome\n")),
+			want:     Detection{Format: "csv", Method: DetectedByContent, Confidence: 0.9, Delimiter: ";"},
+		},
+		{
+			name:     "Compressed JSON Lines",
+			filePath: writeFile(t, "events.gz", gzipBytes(t, "{\"id\": 1}\n{\"id\": 2}\n")),
+			want:     Detection{Format: "jsonl", Method: DetectedByContent, Confidence: 0.95},
+		},
+		{
+			name:     "Workbook",
+			filePath: renamed(t, workbook, "report"),
+			want:     Detection{Format: "excel", Method: DetectedByContent, Confidence: 1},
+		},
+		{
+			name:     "Parquet",
+			filePath: renamed(t, orders, "orders.bin"),
+			want:     Detection{Format: "parquet", Method: DetectedByContent, Confidence: 1},
+		},
+		{
+			name:     "Binary content",
+			filePath: writeFile(t, "image.dat", []byte{0x89, 'P', 'N', 'G', 0, 0, 0, 13}),
+			wantErr:  true,
+		},
+		{
+			name:     "Missing file",
+			filePath: "missing.dat",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectFormat(tt.filePath, tt.format)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnsupportedFormat) {
+					t.Errorf("DetectFormat() error = %v, want ErrUnsupportedFormat", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DetectFormat() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DetectFormat() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniffContent(t *testing.T) {
+	tests := []struct {
+		name           string
+		content        string
+		wantFormat     string
+		wantDelimiter  string
+		wantConfidence float64
+	}{
+		{name: "XML declaration", content: "\xef\xbb\xbf<?xml version=\"1.0\"?>\n<orders/>", wantFormat: "xml", wantConfidence: 1},
+		{name: "XML element", content: "  <orders><order/></orders>", wantFormat: "xml", wantConfidence: 0.7},
+		{name: "JSON array", content: "[\n  {\"id\": 1}\n]", wantFormat: "json", wantConfidence: 0.9},
+		{name: "JSON object over several lines", content: "{\n  \"data\": []\n}", wantFormat: "json", wantConfidence: 0.9},
+		{name: "JSON object on one line", content: `{"data": []}`, wantFormat: "json", wantConfidence: 0.6},
+		{name: "JSON Lines", content: "{\"id\": 1}\n{\"id\": 2}\n", wantFormat: "jsonl", wantConfidence: 0.95},
+		{name: "JSON Lines with a broken line", content: "{\"id\": 1}\n{\"id\": \n", wantFormat: "jsonl", wantConfidence: 0.6},
+		{name: "Comma delimited", content: "id,name\n1,\"Smith, Ann\"\n", wantFormat: "csv", wantDelimiter: ",", wantConfidence: 0.9},
+		{name: "Tab delimited", content: "id\tname\n1\tAnn, Bob\n", wantFormat: "tsv", wantDelimiter: "tab", wantConfidence: 0.9},
+		{name: "Pipe delimited", content: "id|name\n1|Ann\n2|Bob|extra\n", wantFormat: "psv", wantDelimiter: "|", wantConfidence: 0.4 + 0.5*2/3.0},
+		{name: "Header only", content: "id;name", wantFormat: "csv", wantDelimiter: ";", wantConfidence: 0.4},
+		{name: "Single column", content: "id\n1\n2\n", wantFormat: "csv", wantConfidence: 0.3},
+		{name: "Tar archive", content: strings.Repeat("x", 257) + "ustar\x0000", wantFormat: "tar", wantConfidence: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := sniffContent([]byte(tt.content), true)
+			if !ok {
+				t.Fatalf("sniffContent() did not recognise the content")
+			}
+			if got.Format != tt.wantFormat || got.Delimiter != tt.wantDelimiter || got.Method != DetectedByContent {
+				t.Errorf("sniffContent() = %+v, want format %s and delimiter %q", got, tt.wantFormat, tt.wantDelimiter)
+			}
+			if diff := got.Confidence - tt.wantConfidence; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("Confidence = %v, want %v", got.Confidence, tt.wantConfidence)
+			}
+		})
+	}
+}
+
+func TestNewLoader_Format(t *testing.T) {
+	semicolons := writeFile(t, "export.txt", []byte("id;name\n1;Ann\n"))
+	commas := writeFile(t, "export.txt", []byte("id,name\n1,Ann\n"))
+
+	tests := []struct {
+		name     string
+		filePath string
+		config   *Config
+	}{
+		{name: "Delimiter found from the content", filePath: semicolons},
+		{name: "Format given by name", filePath: commas, config: &Config{Format: "csv"}},
+		{name: "Format and delimiter given", filePath: semicolons, config: &Config{Format: "csv", CSV: CSVOptions{Delimiter: ";"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader, err := NewLoader(tt.filePath, tt.config)
+			if err != nil {
+				t.Fatalf("NewLoader() error = %v", err)
+			}
+			dataset, err := loader.Load(tt.filePath)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if want := []string{"id", "name"}; !reflect.DeepEqual(dataset.Columns, want) {
+				t.Errorf("Columns = %q, want %q", dataset.Columns, want)
+			}
+		})
+	}
+}
+
+// keyValueLoader reads key=value lines as a table with one row, a format
+// registered by tests
+type keyValueLoader struct{}
+
+func (l *keyValueLoader) Load(filePath string) (*common.DataSet, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	row := common.DataRow{}
+	var columns []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		columns = append(columns, key)
+		row[key] = value
+	}
+	return &common.DataSet{Columns: columns, Rows: []common.DataRow{row}}, nil
+}
+
+func TestRegister(t *testing.T) {
+	builtins := len(Formats())
+	builtinFormats := registered()
+	t.Cleanup(func() {
+		formatsMu.Lock()
+		defer formatsMu.Unlock()
+		formats = builtinFormats
+	})
+
+	Register(Format{
+		Name:       "KV",
+		Aliases:    []string{"properties"},
+		Extensions: []string{".kv"},
+		MIMETypes:  []string{"text/x-key-value"},
+		New:        func(config *Config) (Loader, error) { return &keyValueLoader{}, nil },
+		Sniff: func(head []byte) float64 {
+			if bytes.HasPrefix(head, []byte("#kv\n")) {
+				return 1
+			}
+			return 0
+		},
+	})
+	sniffable := writeFile(t, "settings", []byte("#kv\nhost=db\nport=5432\n"))
+
+	tests := []struct {
+		name     string
+		filePath string
+		format   string
+		want     Detection
+	}{
+		{name: "Name", filePath: "settings.txt", format: "kv", want: Detection{Format: "kv", Method: DetectedByName, Confidence: 1}},
+		{name: "Alias", filePath: "settings.txt", format: "Properties", want: Detection{Format: "kv", Method: DetectedByName, Confidence: 1}},
+		{name: "Media type", filePath: "settings.txt", format: "text/x-key-value; charset=utf-8", want: Detection{Format: "kv", Method: DetectedByName, Confidence: 1}},
+		{name: "Built-in media type", filePath: "data", format: "application/x-ndjson", want: Detection{Format: "jsonl", Method: DetectedByName, Confidence: 1}},
+		{name: "Extension", filePath: "settings.kv.gz", want: Detection{Format: "kv", Method: DetectedByExtension, Confidence: 0.9}},
+		{name: "Content", filePath: sniffable, want: Detection{Format: "kv", Method: DetectedByContent, Confidence: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectFormat(tt.filePath, tt.format)
+			if err != nil {
+				t.Fatalf("DetectFormat() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DetectFormat() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Loaded", func(t *testing.T) {
+		loader, err := NewLoader(sniffable, nil)
+		if err != nil {
+			t.Fatalf("NewLoader() error = %v", err)
+		}
+		dataset, err := loader.Load(sniffable)
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if want := []string{"#kv", "host", "port"}; !reflect.DeepEqual(dataset.Columns, want) {
+			t.Errorf("Columns = %q, want %q", dataset.Columns, want)
+		}
+	})
+
+	t.Run("Listed", func(t *testing.T) {
+		names := FormatNames()
+		if got := strings.Join(names[len(names)-2:], " "); got != "kv properties" {
+			t.Errorf("FormatNames() ends with %q, want kv properties", got)
+		}
+	})
+
+	t.Run("Taken names", func(t *testing.T) {
+		newLoader := func(config *Config) (Loader, error) { return &keyValueLoader{}, nil }
+		for _, format := range []Format{
+			{Name: "KV", New: newLoader},
+			{Name: "properties", New: newLoader},
+			{Name: "ini", Aliases: []string{"NDJSON"}, New: newLoader},
+			{Name: "ini", Aliases: []string{"csv"}, New: newLoader},
+			{Extensions: []string{".ini"}, New: newLoader},
+			{Name: "ini"},
+		} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("Register(%+v) did not panic", format)
+					}
+				}()
+				Register(format)
+			}()
+		}
+		if got := len(Formats()); got != builtins+1 {
+			t.Errorf("Formats() has %d formats, want %d", got, builtins+1)
+		}
+	})
+
+	t.Run("Shared extension", func(t *testing.T) {
+		Register(Format{Name: "keyvalue", Extensions: []string{".kv"}, New: func(config *Config) (Loader, error) { return &keyValueLoader{}, nil }})
+		if got, err := DetectFormat("settings.kv", ""); err != nil || got.Format != "keyvalue" {
+			t.Errorf("DetectFormat() = %+v, %v, want the later format", got, err)
+		}
+	})
+}
+
+func TestRegister_Concurrent(t *testing.T) {
+	builtinFormats := registered()
+	t.Cleanup(func() {
+		formatsMu.Lock()
+		defer formatsMu.Unlock()
+		formats = builtinFormats
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		name := "kv" + string(rune('a'+i))
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			Register(Format{Name: name, Extensions: []string{".kv"}, New: func(config *Config) (Loader, error) { return &keyValueLoader{}, nil }})
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := DetectFormat("data.csv", ""); err != nil {
+				t.Errorf("DetectFormat() error = %v", err)
+			}
+			FormatNames()
+		}()
+	}
+	wg.Wait()
+
+	if _, err := lookupFormat("kva"); err != nil {
+		t.Errorf("lookupFormat() error = %v", err)
+	}
+}
@@ -2,11 +2,41 @@ package loaders
 
 import (
 	"brokolisql-go/pkg/common"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 )
 
-type JSONLoader struct{}
+// JSONOptions selects the records of a JSON document that wraps them in an
+// envelope, such as {"data": {"items": [...]}, "meta": {...}}
+type JSONOptions struct {
+	RecordPath string   `json:"record_path,omitempty" yaml:"record_path,omitempty"` // Path to the records: $.data.items, /data/items or data.items
+	Lift       []string `json:"lift,omitempty" yaml:"lift,omitempty"`               // Envelope fields added to every record, such as meta.generated_at or generated=meta.generated_at
+}
+
+// Validate reports paths that cannot be parsed
+func (o JSONOptions) Validate() error {
+	_, err := o.selector()
+	return err
+}
+
+// selector returns the record selector for the options, or nil if the
+// records are the whole document
+func (o JSONOptions) selector() (*common.RecordSelector, error) {
+	if o.RecordPath == "" && len(o.Lift) == 0 {
+		return nil, nil
+	}
+	return common.NewRecordSelector(o.RecordPath, o.Lift)
+}
+
+type JSONLoader struct {
+	Encoding string // Character encoding of the file, detected if empty or EncodingAuto
+	Options  JSONOptions
+}
 
 func (l *JSONLoader) Load(filePath string) (*common.DataSet, error) {
 	fileContent, err := os.ReadFile(filePath)
@@ -14,10 +44,160 @@ func (l *JSONLoader) Load(filePath string) (*common.DataSet, error) {
 		return nil, fmt.Errorf("failed to read JSON file: %w", err)
 	}
 
-	data, err := common.ParseJSONData(fileContent)
+	text, err := decodeText(bytes.NewReader(fileContent), l.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	if fileContent, err = io.ReadAll(text); err != nil {
+		return nil, fmt.Errorf("failed to read JSON file: %w", err)
+	}
+
+	selector, err := l.Options.selector()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := common.ParseJSONRecords(fileContent, selector)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse JSON file: %w", err)
 	}
 
 	return common.ConvertToDataSet(data), nil
 }
+
+var errNotJSONArray = errors.New("JSON document is not an array")
+
+// Stream decodes a top-level JSON array one element at a time. The file is
+// read twice: once to collect the union of keys, which becomes the column
+// list, and once to yield the rows. Documents that are not arrays, and those
+// read with a record path or lifted fields, are loaded into memory as with
+// Load.
+func (l *JSONLoader) Stream(filePath string) (common.RowIterator, error) {
+	if l.Options.RecordPath != "" || len(l.Options.Lift) > 0 {
+		dataset, err := l.Load(filePath)
+		if err != nil {
+			return nil, err
+		}
+		return common.NewDataSetIterator(dataset), nil
+	}
+
+	columns, err := scanJSONArrayColumns(filePath, l.Encoding)
+	if errors.Is(err, errNotJSONArray) {
+		dataset, err := l.Load(filePath)
+		if err != nil {
+			return nil, err
+		}
+		return common.NewDataSetIterator(dataset), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file, decoder, err := openJSONArray(filePath, l.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonArrayIterator{
+		file:    file,
+		decoder: decoder,
+		columns: columns,
+	}, nil
+}
+
+// openJSONArray opens filePath and positions the decoder after the opening
+// bracket of the top-level array
+func openJSONArray(filePath, encoding string) (io.ReadCloser, *json.Decoder, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read JSON file: %w", err)
+	}
+	text, err := openText(file, encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decoder := json.NewDecoder(text)
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		text.Close()
+		return nil, nil, fmt.Errorf("failed to parse JSON file: %w", err)
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		text.Close()
+		return nil, nil, errNotJSONArray
+	}
+
+	return text, decoder, nil
+}
+
+// scanJSONArrayColumns walks the array once and returns the sorted union of
+// all object keys
+func scanJSONArrayColumns(filePath, encoding string) ([]string, error) {
+	file, decoder, err := openJSONArray(filePath, encoding)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	columnSet := make(map[string]bool)
+	count := 0
+	for decoder.More() {
+		var obj map[string]interface{}
+		if err := decoder.Decode(&obj); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON file: %w", err)
+		}
+		if err := common.CheckJSONNumbers(obj); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON file: %w", err)
+		}
+		for key := range obj {
+			columnSet[key] = true
+		}
+		count++
+	}
+
+	if count == 0 {
+		return nil, fmt.Errorf("no data found in JSON content")
+	}
+
+	columns := make([]string, 0, len(columnSet))
+	for col := range columnSet {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	return columns, nil
+}
+
+// jsonArrayIterator yields the elements of a top-level JSON array as DataRows
+type jsonArrayIterator struct {
+	file    io.Closer
+	decoder *json.Decoder
+	columns []string
+}
+
+func (it *jsonArrayIterator) Columns() []string {
+	return it.columns
+}
+
+func (it *jsonArrayIterator) Next() (common.DataRow, error) {
+	if !it.decoder.More() {
+		return nil, io.EOF
+	}
+
+	var obj map[string]interface{}
+	if err := it.decoder.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON file: %w", err)
+	}
+	if err := common.CheckJSONNumbers(obj); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON file: %w", err)
+	}
+
+	return common.ConvertToDataRow(obj), nil
+}
+
+func (it *jsonArrayIterator) Close() error {
+	return it.file.Close()
+}
@@ -2,8 +2,11 @@ package loaders
 
 import (
 	"brokolisql-go/pkg/common"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -89,7 +92,7 @@ func TestJSONLoader_Load(t *testing.T) {
 				if ds.Rows[0]["name"] != "John Doe" {
 					t.Errorf("Expected name 'John Doe', got %v", ds.Rows[0]["name"])
 				}
-				if ds.Rows[0]["age"] != float64(30) {
+				if ds.Rows[0]["age"] != json.Number("30") {
 					t.Errorf("Expected age 30, got %v", ds.Rows[0]["age"])
 				}
 			},
@@ -172,3 +175,149 @@ func TestGetLoader_JSON(t *testing.T) {
 		t.Errorf("GetLoader() returned wrong loader type for JSON file")
 	}
 }
+
+func TestJSONLoader_Stream(t *testing.T) {
+	tempDir := t.TempDir()
+
+	arrayPath := filepath.Join(tempDir, "array.json")
+	arrayJSON := `[
+		{"name": "John", "age": 30},
+		{"name": "Jane", "city": "London", "tags": ["a", "b"]}
+	]`
+	if err := os.WriteFile(arrayPath, []byte(arrayJSON), 0644); err != nil {
+		t.Fatalf("Failed to write array JSON file: %v", err)
+	}
+
+	singlePath := filepath.Join(tempDir, "single.json")
+	if err := os.WriteFile(singlePath, []byte(`{"name": "John"}`), 0644); err != nil {
+		t.Fatalf("Failed to write single JSON file: %v", err)
+	}
+
+	emptyPath := filepath.Join(tempDir, "empty.json")
+	if err := os.WriteFile(emptyPath, []byte(`[]`), 0644); err != nil {
+		t.Fatalf("Failed to write empty JSON file: %v", err)
+	}
+
+	l := &JSONLoader{}
+
+	it, err := l.Stream(arrayPath)
+	if err != nil {
+		t.Fatalf("JSONLoader.Stream() error = %v", err)
+	}
+	dataset, err := common.CollectDataSet(it)
+	if err != nil {
+		t.Fatalf("CollectDataSet() error = %v", err)
+	}
+
+	if want := []string{"age", "city", "name", "tags"}; !reflect.DeepEqual(dataset.Columns, want) {
+		t.Errorf("Stream() columns = %v, want %v", dataset.Columns, want)
+	}
+	if len(dataset.Rows) != 2 {
+		t.Fatalf("Stream() returned %d rows, want 2", len(dataset.Rows))
+	}
+	if dataset.Rows[1]["tags"] != `["a","b"]` {
+		t.Errorf("Stream() nested array = %v, want JSON string", dataset.Rows[1]["tags"])
+	}
+
+	it, err = l.Stream(singlePath)
+	if err != nil {
+		t.Fatalf("JSONLoader.Stream() single object error = %v", err)
+	}
+	dataset, err = common.CollectDataSet(it)
+	if err != nil || len(dataset.Rows) != 1 {
+		t.Errorf("Stream() single object = %v, %v; want one row", dataset, err)
+	}
+
+	if _, err := l.Stream(emptyPath); err == nil {
+		t.Errorf("JSONLoader.Stream() expected error for empty array")
+	}
+}
+
+func TestJSONLoader_RecordPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wrapped.json")
+	content := `{"data": {"items": [{"id": 1}, {"id": 2}]}, "meta": {"generated_at": "2024-01-02"}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write wrapped JSON file: %v", err)
+	}
+
+	want := []common.DataRow{
+		{"id": json.Number("1"), "generated_at": "2024-01-02"},
+		{"id": json.Number("2"), "generated_at": "2024-01-02"},
+	}
+
+	loader, err := NewLoader(path, &Config{JSON: JSONOptions{RecordPath: "$.data.items", Lift: []string{"generated_at=meta.generated_at"}}})
+	if err != nil {
+		t.Fatalf("NewLoader() error = %v", err)
+	}
+
+	dataset, err := loader.Load(path)
+	if err != nil {
+		t.Fatalf("JSONLoader.Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(dataset.Rows, want) {
+		t.Errorf("Load() rows = %v, want %v", dataset.Rows, want)
+	}
+
+	it, err := OpenStream(loader, path)
+	if err != nil {
+		t.Fatalf("JSONLoader.Stream() error = %v", err)
+	}
+	dataset, err = common.CollectDataSet(it)
+	if err != nil {
+		t.Fatalf("CollectDataSet() error = %v", err)
+	}
+	if !reflect.DeepEqual(dataset.Rows, want) {
+		t.Errorf("Stream() rows = %v, want %v", dataset.Rows, want)
+	}
+
+	if _, err := NewLoader(path, &Config{JSON: JSONOptions{RecordPath: "$..items"}}); err == nil {
+		t.Error("NewLoader() error = nil, want an invalid record path error")
+	}
+}
+
+func TestJSONLoader_Numbers(t *testing.T) {
+	tempDir := t.TempDir()
+
+	bigPath := filepath.Join(tempDir, "big.json")
+	if err := os.WriteFile(bigPath, []byte(`[{"id": 12345678901234567, "price": 2.50}]`), 0644); err != nil {
+		t.Fatalf("Failed to write JSON file: %v", err)
+	}
+	hugePath := filepath.Join(tempDir, "huge.json")
+	if err := os.WriteFile(hugePath, []byte(`[{"id": 1}, {"id": 1e400}]`), 0644); err != nil {
+		t.Fatalf("Failed to write JSON file: %v", err)
+	}
+
+	l := &JSONLoader{}
+	want := []common.DataRow{{"id": json.Number("12345678901234567"), "price": json.Number("2.50")}}
+
+	dataset, err := l.Load(bigPath)
+	if err != nil {
+		t.Fatalf("JSONLoader.Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(dataset.Rows, want) {
+		t.Errorf("Load() rows = %v, want %v", dataset.Rows, want)
+	}
+
+	it, err := l.Stream(bigPath)
+	if err != nil {
+		t.Fatalf("JSONLoader.Stream() error = %v", err)
+	}
+	dataset, err = common.CollectDataSet(it)
+	if err != nil {
+		t.Fatalf("CollectDataSet() error = %v", err)
+	}
+	if !reflect.DeepEqual(dataset.Rows, want) {
+		t.Errorf("Stream() rows = %v, want %v", dataset.Rows, want)
+	}
+
+	if _, err := l.Load(hugePath); err == nil || !strings.Contains(err.Error(), "number 1e400 is out of range") {
+		t.Errorf("JSONLoader.Load() error = %v, want number out of range", err)
+	}
+	it, err = l.Stream(hugePath)
+	if err == nil {
+		_, err = common.CollectDataSet(it)
+	}
+	if err == nil || !strings.Contains(err.Error(), "number 1e400 is out of range") {
+		t.Errorf("JSONLoader.Stream() error = %v, want number out of range", err)
+	}
+}
@@ -0,0 +1,196 @@
+package loaders
+
+import (
+	"brokolisql-go/pkg/common"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// JSONLinesOptions controls how newline-delimited JSON is read
+type JSONLinesOptions struct {
+	SkipInvalid bool `json:"skip_invalid,omitempty" yaml:"skip_invalid,omitempty"` // Leave out lines that are not JSON objects instead of failing
+}
+
+// JSONLinesLoader reads JSON Lines (NDJSON) files, which hold one JSON object
+// per line. Blank lines are ignored. Nested objects and arrays are kept as
+// JSON strings, as with JSONLoader, so they take the same nested data path.
+type JSONLinesLoader struct {
+	Encoding string // Character encoding of the file, detected if empty or EncodingAuto
+	Options  JSONLinesOptions
+	OnSkip   func(line int, err error) // Called for each line left out by SkipInvalid
+}
+
+func (l *JSONLinesLoader) Load(filePath string) (*common.DataSet, error) {
+	reader, err := l.open(filePath, l.OnSkip)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var data []map[string]interface{}
+	for {
+		obj, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, obj)
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no data found in JSON Lines file")
+	}
+	return common.ConvertToDataSet(data), nil
+}
+
+// Stream reads the file one line at a time. As with JSONLoader.Stream, the
+// file is read twice: once to collect the union of keys, which becomes the
+// column list, and once to yield the rows.
+func (l *JSONLinesLoader) Stream(filePath string) (common.RowIterator, error) {
+	columns, err := l.scanColumns(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := l.open(filePath, l.OnSkip)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonLinesIterator{reader: reader, columns: columns}, nil
+}
+
+// scanColumns returns the sorted union of the keys of all records. Skipped
+// lines are reported when the rows are read, not here.
+func (l *JSONLinesLoader) scanColumns(filePath string) ([]string, error) {
+	reader, err := l.open(filePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	columnSet := make(map[string]bool)
+	count := 0
+	for {
+		obj, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for key := range obj {
+			columnSet[key] = true
+		}
+		count++
+	}
+
+	if count == 0 {
+		return nil, fmt.Errorf("no data found in JSON Lines file")
+	}
+
+	columns := make([]string, 0, len(columnSet))
+	for col := range columnSet {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	return columns, nil
+}
+
+func (l *JSONLinesLoader) open(filePath string, onSkip func(int, error)) (*jsonLinesReader, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open JSON Lines file: %w", err)
+	}
+	text, err := openText(file, l.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonLinesReader{
+		file:        text,
+		input:       bufio.NewReader(text),
+		skipInvalid: l.Options.SkipInvalid,
+		onSkip:      onSkip,
+	}, nil
+}
+
+var errNullRecord = errors.New("record is null, want a JSON object")
+
+// jsonLinesReader decodes the records of a JSON Lines file in order
+type jsonLinesReader struct {
+	file        io.Closer
+	input       *bufio.Reader
+	line        int
+	skipInvalid bool
+	onSkip      func(int, error)
+}
+
+// next returns the next record, or io.EOF after the last one
+func (r *jsonLinesReader) next() (map[string]interface{}, error) {
+	for {
+		// ReadBytes has no limit on line length, unlike bufio.Scanner
+		data, err := r.input.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read JSON Lines file: %w", err)
+		}
+		if err == io.EOF && len(data) == 0 {
+			return nil, io.EOF
+		}
+		r.line++
+
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+
+		var obj map[string]interface{}
+		err = common.DecodeJSON(data, &obj)
+		if err == nil && obj == nil {
+			err = errNullRecord
+		}
+		if err != nil {
+			if r.skipInvalid {
+				if r.onSkip != nil {
+					r.onSkip(r.line, err)
+				}
+				continue
+			}
+			return nil, fmt.Errorf("failed to parse JSON Lines file: line %d: %w", r.line, err)
+		}
+		return obj, nil
+	}
+}
+
+func (r *jsonLinesReader) Close() error {
+	return r.file.Close()
+}
+
+// jsonLinesIterator yields the records of a JSON Lines file as DataRows
+type jsonLinesIterator struct {
+	reader  *jsonLinesReader
+	columns []string
+}
+
+func (it *jsonLinesIterator) Columns() []string {
+	return it.columns
+}
+
+func (it *jsonLinesIterator) Next() (common.DataRow, error) {
+	obj, err := it.reader.next()
+	if err != nil {
+		return nil, err
+	}
+	return common.ConvertToDataRow(obj), nil
+}
+
+func (it *jsonLinesIterator) Close() error {
+	return it.reader.Close()
+}
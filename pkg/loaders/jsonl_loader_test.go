@@ -0,0 +1,144 @@
+package loaders
+
+import (
+	"brokolisql-go/pkg/common"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONLinesLoader_Load(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		options     JSONLinesOptions
+		wantRows    []common.DataRow
+		wantSkipped []int
+		wantErr     string
+	}{
+		{
+			name:    "One record per line",
+			content: "{\"id\": 1, \"name\": \"John\"}\n\n{\"id\": 2, \"name\": \"Jane\"}",
+			wantRows: []common.DataRow{
+				{"id": json.Number("1"), "name": "John"},
+				{"id": json.Number("2"), "name": "Jane"},
+			},
+		},
+		{
+			name:    "CRLF line endings",
+			content: "{\"id\": 1}\r\n{\"id\": 2}\r\n",
+			wantRows: []common.DataRow{
+				{"id": json.Number("1")},
+				{"id": json.Number("2")},
+			},
+		},
+		{
+			name:    "Nested values are kept as JSON",
+			content: `{"id": 1, "address": {"city": "London"}, "tags": ["a", "b"]}` + "\n",
+			wantRows: []common.DataRow{
+				{"id": json.Number("1"), "address": `{"city":"London"}`, "tags": `["a","b"]`},
+			},
+		},
+		{
+			name:    "Malformed line",
+			content: "{\"id\": 1}\n\n{\"id\": 2,\n{\"id\": 3}\n",
+			wantErr: "failed to parse JSON Lines file: line 3:",
+		},
+		{
+			name:    "Array instead of an object",
+			content: "{\"id\": 1}\n[1, 2]\n",
+			wantErr: "line 2:",
+		},
+		{
+			name:    "Null record",
+			content: "null\n",
+			wantErr: "line 1: record is null",
+		},
+		{
+			name:        "Skip invalid lines",
+			content:     "{\"id\": 1}\nnot json\nnull\n{\"id\": 4}\n",
+			options:     JSONLinesOptions{SkipInvalid: true},
+			wantRows:    []common.DataRow{{"id": json.Number("1")}, {"id": json.Number("4")}},
+			wantSkipped: []int{2, 3},
+		},
+		{
+			name:    "No records",
+			content: "\n\n",
+			wantErr: "no data found in JSON Lines file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data.jsonl")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test JSON Lines file: %v", err)
+			}
+
+			var skipped []int
+			l := &JSONLinesLoader{
+				Options: tt.options,
+				OnSkip:  func(line int, err error) { skipped = append(skipped, line) },
+			}
+			got, err := l.Load(path)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("JSONLinesLoader.Load() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("JSONLinesLoader.Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Rows, tt.wantRows) {
+				t.Errorf("Rows = %v, want %v", got.Rows, tt.wantRows)
+			}
+			if !reflect.DeepEqual(skipped, tt.wantSkipped) {
+				t.Errorf("skipped lines = %v, want %v", skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestJSONLinesLoader_Stream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.ndjson")
+	content := "{\"name\": \"John\", \"age\": 30}\n{oops}\n{\"name\": \"Jane\", \"city\": \"London\"}\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test JSON Lines file: %v", err)
+	}
+
+	var skipped []int
+	l := &JSONLinesLoader{
+		Options: JSONLinesOptions{SkipInvalid: true},
+		OnSkip:  func(line int, err error) { skipped = append(skipped, line) },
+	}
+
+	it, err := l.Stream(path)
+	if err != nil {
+		t.Fatalf("JSONLinesLoader.Stream() error = %v", err)
+	}
+	dataset, err := common.CollectDataSet(it)
+	if err != nil {
+		t.Fatalf("CollectDataSet() error = %v", err)
+	}
+
+	if want := []string{"age", "city", "name"}; !reflect.DeepEqual(dataset.Columns, want) {
+		t.Errorf("Stream() columns = %v, want %v", dataset.Columns, want)
+	}
+	if len(dataset.Rows) != 2 || dataset.Rows[1]["city"] != "London" {
+		t.Errorf("Stream() rows = %v, want John and Jane", dataset.Rows)
+	}
+	// The column scan reads the file too, but each line is reported once
+	if want := []int{2}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped lines = %v, want %v", skipped, want)
+	}
+
+	l.Options.SkipInvalid = false
+	if _, err := l.Stream(path); err == nil || !strings.Contains(err.Error(), "line 2:") {
+		t.Errorf("JSONLinesLoader.Stream() error = %v, want a line 2 error", err)
+	}
+}
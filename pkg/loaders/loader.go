@@ -3,6 +3,7 @@ package loaders
 import (
 	"brokolisql-go/pkg/common"
 	"errors"
+	"io"
 	"path/filepath"
 )
 
@@ -10,19 +11,102 @@ type Loader interface {
 	Load(filePath string) (*common.DataSet, error)
 }
 
+// StreamLoader is implemented by loaders that can emit rows one at a time
+// instead of materialising the whole file as a DataSet.
+type StreamLoader interface {
+	Stream(filePath string) (common.RowIterator, error)
+}
+
+// NamedDataSet is one of several tables read from a single input
+type NamedDataSet struct {
+	Name    string // Where the table came from, such as the sheet name
+	DataSet *common.DataSet
+}
+
+// MultiTableLoader is implemented by loaders whose input can hold several
+// tables, such as workbooks with several sheets
+type MultiTableLoader interface {
+	LoadTables(filePath string) ([]NamedDataSet, error)
+}
+
 func GetLoader(filePath string) (Loader, error) {
-	ext := filepath.Ext(filePath)
-
-	switch ext {
-	case ".csv":
-		return &CSVLoader{}, nil
-	case ".json":
-		return &JSONLoader{}, nil
-	case ".xml":
-		return &XMLLoader{}, nil
-	case ".xlsx", ".xls":
-		return &ExcelLoader{}, nil
-	default:
-		return nil, errors.New("unsupported file format: " + ext)
+	return NewLoader(filePath, nil)
+}
+
+// ErrUnsupportedFormat is returned for files whose extension names no
+// supported format
+var ErrUnsupportedFormat = errors.New("unsupported file format")
+
+// NewLoader returns the loader for the format of filePath, configured with
+// the options for its format from config. A nil config uses the defaults.
+// The format is the one config names, or else the one DetectFormat finds
+// from the extension or content of the file. Compressed files, recognised by
+// a second extension such as data.csv.gz or by their first bytes, are read
+// with the loader of the format they hold, and zip and tar archives with an
+// ArchiveLoader. Directories and glob patterns, and any input when config
+// sets a source column, are read with a FilesLoader.
+func NewLoader(filePath string, config *Config) (Loader, error) {
+	if config == nil {
+		config = &Config{}
+	}
+	if err := validateEncoding(config.Encoding); err != nil {
+		return nil, err
+	}
+
+	if IsMultiFile(filePath) || config.SourceColumn != "" {
+		return &FilesLoader{Config: config}, nil
+	}
+
+	detection, err := DetectFormat(filePath, config.Format)
+	if err != nil {
+		return nil, err
+	}
+	loader, err := newDetectedLoader(detection, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Archives decompress themselves
+	name := TrimCompression(filePath)
+	if detection.IsArchive() || (name == filePath && sniffCompression(filePath) == "") {
+		return loader, nil
+	}
+	return &CompressedLoader{Loader: loader, Name: filepath.Base(name)}, nil
+}
+
+// StdinPath is the input path that stands for standard input
+const StdinPath = "-"
+
+// SpoolInput copies r, such as standard input, to a temporary file with the
+// extension of format, so that it can be read like any input file, including
+// by formats that are read more than once or out of order. Without a format,
+// the file has no extension and its format is found from its content.
+// Compressed content is recognised as for files. remove deletes the
+// temporary file.
+func SpoolInput(r io.Reader, format string) (path string, remove func(), err error) {
+	name := "stdin"
+	if format != "" {
+		f, err := lookupFormat(format)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(f.Extensions) > 0 {
+			name += f.Extensions[0]
+		}
+	}
+	return writeTemp(r, name)
+}
+
+// OpenStream returns a row iterator for filePath. Loaders that do not support
+// streaming fall back to loading the whole file into memory.
+func OpenStream(loader Loader, filePath string) (common.RowIterator, error) {
+	if streamLoader, ok := loader.(StreamLoader); ok {
+		return streamLoader.Stream(filePath)
+	}
+
+	dataset, err := loader.Load(filePath)
+	if err != nil {
+		return nil, err
 	}
+	return common.NewDataSetIterator(dataset), nil
 }
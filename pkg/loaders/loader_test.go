@@ -2,6 +2,10 @@ package loaders
 
 import (
 	"brokolisql-go/pkg/common"
+	"bytes"
+	"errors"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -18,12 +22,36 @@ func TestGetLoader(t *testing.T) {
 			wantType: &CSVLoader{},
 			wantErr:  false,
 		},
+		{
+			name:     "TSV file",
+			filePath: "test.tsv",
+			wantType: &CSVLoader{Options: CSVOptions{Delimiter: "tab"}},
+			wantErr:  false,
+		},
+		{
+			name:     "PSV file",
+			filePath: "test.psv",
+			wantType: &CSVLoader{Options: CSVOptions{Delimiter: "|"}},
+			wantErr:  false,
+		},
 		{
 			name:     "JSON file",
 			filePath: "test.json",
 			wantType: &JSONLoader{},
 			wantErr:  false,
 		},
+		{
+			name:     "JSON Lines file",
+			filePath: "test.jsonl",
+			wantType: &JSONLinesLoader{},
+			wantErr:  false,
+		},
+		{
+			name:     "NDJSON file",
+			filePath: "test.ndjson",
+			wantType: &JSONLinesLoader{},
+			wantErr:  false,
+		},
 		{
 			name:     "XML file",
 			filePath: "test.xml",
@@ -66,15 +94,21 @@ func TestGetLoader(t *testing.T) {
 			}
 
 			// Check loader type
-			switch tt.wantType.(type) {
+			switch want := tt.wantType.(type) {
 			case *CSVLoader:
 				if _, ok := loader.(*CSVLoader); !ok {
 					t.Errorf("GetLoader() returned wrong type for %s", tt.filePath)
+				} else if want.Options.Delimiter != "" && loader.(*CSVLoader).Options != want.Options {
+					t.Errorf("GetLoader() options = %+v, want %+v", loader.(*CSVLoader).Options, want.Options)
 				}
 			case *JSONLoader:
 				if _, ok := loader.(*JSONLoader); !ok {
 					t.Errorf("GetLoader() returned wrong type for %s", tt.filePath)
 				}
+			case *JSONLinesLoader:
+				if _, ok := loader.(*JSONLinesLoader); !ok {
+					t.Errorf("GetLoader() returned wrong type for %s", tt.filePath)
+				}
 			case *XMLLoader:
 				if _, ok := loader.(*XMLLoader); !ok {
 					t.Errorf("GetLoader() returned wrong type for %s", tt.filePath)
@@ -144,3 +178,63 @@ func TestDataSet(t *testing.T) {
 		t.Errorf("Expected country 'UK', got %v", ds.Rows[1]["country"])
 	}
 }
+
+func TestSpoolInput(t *testing.T) {
+	t.Run("Format given by name", func(t *testing.T) {
+		path, remove, err := SpoolInput(strings.NewReader("id,name\n1,Ann\n"), "csv")
+		if err != nil {
+			t.Fatalf("SpoolInput() error = %v", err)
+		}
+
+		dataset, err := (&CSVLoader{}).Load(path)
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if len(dataset.Rows) != 1 || dataset.Rows[0]["name"] != "Ann" {
+			t.Errorf("Rows = %v", dataset.Rows)
+		}
+
+		remove()
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("remove() left %s behind", path)
+		}
+	})
+
+	t.Run("Compressed content", func(t *testing.T) {
+		path, remove, err := SpoolInput(bytes.NewReader(gzipBytes(t, "{\"id\": 1}\n")), "JSONL")
+		if err != nil {
+			t.Fatalf("SpoolInput() error = %v", err)
+		}
+		defer remove()
+
+		loader, err := GetLoader(path)
+		if err != nil {
+			t.Fatalf("GetLoader() error = %v", err)
+		}
+		if _, ok := loader.(*CompressedLoader); !ok {
+			t.Fatalf("GetLoader() = %T, want *CompressedLoader", loader)
+		}
+	})
+
+	t.Run("Format found from the content", func(t *testing.T) {
+		path, remove, err := SpoolInput(strings.NewReader("<rows><row><id>1</id></row></rows>"), "")
+		if err != nil {
+			t.Fatalf("SpoolInput() error = %v", err)
+		}
+		defer remove()
+
+		loader, err := GetLoader(path)
+		if err != nil {
+			t.Fatalf("GetLoader() error = %v", err)
+		}
+		if _, ok := loader.(*XMLLoader); !ok {
+			t.Errorf("GetLoader() = %T, want *XMLLoader", loader)
+		}
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		if _, _, err := SpoolInput(strings.NewReader(""), "yaml"); !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("SpoolInput() error = %v, want ErrUnsupportedFormat", err)
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package loaders
+
+import (
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetBatchSize is the number of rows read from a row group at a time
+const parquetBatchSize = 128
+
+// ParquetOptions controls which columns of a Parquet file are read
+type ParquetOptions struct {
+	Columns []string `json:"columns,omitempty" yaml:"columns,omitempty"` // Top-level columns to read, in this order; all columns if empty
+}
+
+// Validate reports options that cannot be used
+func (o ParquetOptions) Validate() error {
+	seen := make(map[string]bool, len(o.Columns))
+	for _, col := range o.Columns {
+		if col == "" {
+			return fmt.Errorf("empty Parquet column name")
+		}
+		if seen[col] {
+			return fmt.Errorf("parquet column %q is selected more than once", col)
+		}
+		seen[col] = true
+	}
+	return nil
+}
+
+// ParquetLoader reads Parquet files one row group at a time. Column types
+// come from the file's schema rather than being inferred: integers, decimals,
+// dates, timestamps, booleans, floats and strings map to the matching SQL
+// types, and nested lists, maps and structs are stored as JSON text. Only
+// the pages of the selected columns are read.
+type ParquetLoader struct {
+	Options ParquetOptions
+}
+
+func (l *ParquetLoader) Load(filePath string) (*common.DataSet, error) {
+	it, err := l.Stream(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return common.CollectDataSet(it)
+}
+
+func (l *ParquetLoader) Stream(filePath string) (common.RowIterator, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Parquet file: %w", err)
+	}
+
+	it, err := l.open(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return it, nil
+}
+
+func (l *ParquetLoader) open(file *os.File) (*parquetIterator, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Parquet file: %w", err)
+	}
+	pf, err := parquet.OpenFile(file, info.Size(), parquet.SkipPageIndex(true), parquet.SkipBloomFilters(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Parquet file: %w", err)
+	}
+
+	schema := pf.Schema()
+	it := &parquetIterator{
+		file:      file,
+		rowGroups: pf.RowGroups(),
+		types:     make(map[string]dialects.ColumnDef),
+	}
+
+	if len(l.Options.Columns) > 0 {
+		// Rows are converted to a schema with only the selected fields, which
+		// leaves the pages of the other columns unread
+		fields := make(map[string]parquet.Field)
+		for _, field := range schema.Fields() {
+			fields[field.Name()] = field
+		}
+
+		group := make(parquet.Group, len(l.Options.Columns))
+		for _, col := range l.Options.Columns {
+			field, ok := fields[col]
+			if !ok {
+				return nil, fmt.Errorf("column %q not found in the Parquet file (columns: %s)", col, strings.Join(parquetFieldNames(schema), ", "))
+			}
+			group[col] = field
+		}
+
+		projected := parquet.NewSchema(schema.Name(), group)
+		if it.conversion, err = parquet.Convert(projected, schema); err != nil {
+			return nil, fmt.Errorf("failed to select Parquet columns: %w", err)
+		}
+		schema = projected
+		it.columns = l.Options.Columns
+	} else {
+		it.columns = parquetFieldNames(schema)
+	}
+
+	first := 0
+	for _, field := range schema.Fields() {
+		leaves := parquetLeafCount(field)
+		it.fields = append(it.fields, parquetField{Field: field, first: first, leaves: leaves})
+		it.types[field.Name()] = parquetColumnDef(field)
+		first += leaves
+	}
+	it.leaves = make([][]parquet.Value, first)
+
+	return it, nil
+}
+
+func parquetFieldNames(schema *parquet.Schema) []string {
+	var names []string
+	for _, field := range schema.Fields() {
+		names = append(names, field.Name())
+	}
+	return names
+}
+
+// parquetField is a top-level field and the range of leaf columns it spans
+// in the schema the rows are read with
+type parquetField struct {
+	parquet.Field
+	first  int
+	leaves int
+}
+
+type parquetIterator struct {
+	file       *os.File
+	rowGroups  []parquet.RowGroup // Row groups not yet read
+	conversion parquet.Conversion // Selects the columns to read, nil for all columns
+	fields     []parquetField
+	columns    []string
+	types      map[string]dialects.ColumnDef
+
+	rows   parquet.Rows // Rows of the current row group
+	buffer []parquet.Row
+	pos    int
+	leaves [][]parquet.Value // Values of each leaf column of the current row
+}
+
+func (it *parquetIterator) Columns() []string {
+	return it.columns
+}
+
+func (it *parquetIterator) ColumnTypes() map[string]dialects.ColumnDef {
+	return it.types
+}
+
+func (it *parquetIterator) Next() (common.DataRow, error) {
+	for it.pos >= len(it.buffer) {
+		if err := it.fill(); err != nil {
+			return nil, err
+		}
+	}
+
+	row := it.buffer[it.pos]
+	it.pos++
+
+	for i := range it.leaves {
+		it.leaves[i] = nil
+	}
+	row.Range(func(column int, values []parquet.Value) bool {
+		it.leaves[column] = values
+		return true
+	})
+
+	record := make(map[string]interface{}, len(it.fields))
+	for _, field := range it.fields {
+		record[field.Name()] = parquetAssemble(field, it.leaves[field.first:field.first+field.leaves], 0, 0)
+	}
+	return common.ConvertToDataRow(record), nil
+}
+
+// fill reads the next batch of rows into the buffer, moving on to the next
+// row group once the current one is exhausted
+func (it *parquetIterator) fill() error {
+	if it.rows == nil {
+		if len(it.rowGroups) == 0 {
+			return io.EOF
+		}
+		rowGroup := it.rowGroups[0]
+		it.rowGroups = it.rowGroups[1:]
+		if it.conversion != nil {
+			rowGroup = parquet.ConvertRowGroup(rowGroup, it.conversion)
+		}
+		it.rows = rowGroup.Rows()
+	}
+
+	if it.buffer == nil {
+		it.buffer = make([]parquet.Row, parquetBatchSize)
+	}
+	it.buffer = it.buffer[:cap(it.buffer)]
+	n, err := it.rows.ReadRows(it.buffer)
+	it.buffer, it.pos = it.buffer[:n], 0
+
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read Parquet rows: %w", err)
+	}
+	if n == 0 {
+		it.rows.Close()
+		it.rows = nil
+	}
+	return nil
+}
+
+func (it *parquetIterator) Close() error {
+	if it.rows != nil {
+		it.rows.Close()
+		it.rows = nil
+	}
+	return it.file.Close()
+}
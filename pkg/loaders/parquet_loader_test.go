@@ -0,0 +1,284 @@
+package loaders
+
+import (
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+type parquetAddress struct {
+	City  string `parquet:"city"`
+	Since int32  `parquet:"since,date"`
+}
+
+type parquetOrder struct {
+	ID       int64            `parquet:"id"`
+	Quantity int32            `parquet:"quantity"`
+	Customer *string          `parquet:"customer,optional"`
+	Total    int64            `parquet:"total,decimal(2:10)"`
+	Day      int32            `parquet:"day,date"`
+	Placed   time.Time        `parquet:"placed,timestamp(millisecond)"`
+	Paid     bool             `parquet:"paid"`
+	Weight   float64          `parquet:"weight"`
+	Tags     []string         `parquet:"tags,list"`
+	Address  *parquetAddress  `parquet:"address,optional"`
+	Counts   map[string]int32 `parquet:"counts"`
+}
+
+// writeParquet saves rows as a Parquet file with at most two rows per row
+// group and returns its path
+func writeParquet[T any](t *testing.T, rows []T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "data.parquet")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create Parquet file: %v", err)
+	}
+	defer file.Close()
+
+	writer := parquet.NewGenericWriter[T](file, parquet.MaxRowsPerRowGroup(2))
+	if _, err := writer.Write(rows); err != nil {
+		t.Fatalf("Failed to write Parquet rows: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close Parquet writer: %v", err)
+	}
+	return path
+}
+
+func TestGetLoader_Parquet(t *testing.T) {
+	loader, err := GetLoader("test.parquet")
+	if err != nil {
+		t.Fatalf("GetLoader() error = %v", err)
+	}
+	if _, ok := loader.(*ParquetLoader); !ok {
+		t.Errorf("GetLoader() returned wrong loader type for .parquet file")
+	}
+}
+
+func TestParquetLoader_Load(t *testing.T) {
+	ann := "Ann"
+	placed := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	path := writeParquet(t, []parquetOrder{
+		{
+			ID: 1, Quantity: 3, Customer: &ann, Total: 1250, Day: 19783, Placed: placed, Paid: true, Weight: 1.5,
+			Tags: []string{"gift", "express"}, Address: &parquetAddress{City: "Oslo", Since: 1}, Counts: map[string]int32{"a": 2},
+		},
+		{ID: 2, Quantity: 1, Total: -5, Day: 19784, Placed: placed.Add(time.Hour)},
+		{ID: 3, Quantity: 7, Total: 100000, Day: 19785, Placed: placed, Paid: true, Tags: []string{}},
+	})
+
+	loader := &ParquetLoader{}
+	got, err := loader.Load(path)
+	if err != nil {
+		t.Fatalf("ParquetLoader.Load() error = %v", err)
+	}
+
+	wantColumns := []string{"id", "quantity", "customer", "total", "day", "placed", "paid", "weight", "tags", "address", "counts"}
+	if !reflect.DeepEqual(got.Columns, wantColumns) {
+		t.Errorf("Columns = %q, want %q", got.Columns, wantColumns)
+	}
+
+	wantRows := []common.DataRow{
+		{
+			"id": int64(1), "quantity": int64(3), "customer": "Ann", "total": "12.50",
+			"day": time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), "placed": placed, "paid": true, "weight": 1.5,
+			"tags": `["gift","express"]`, "address": `{"city":"Oslo","since":"1970-01-02T00:00:00Z"}`, "counts": `{"a":2}`,
+		},
+		{
+			"id": int64(2), "quantity": int64(1), "customer": nil, "total": "-0.05",
+			"day": time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), "placed": placed.Add(time.Hour), "paid": false, "weight": 0.0,
+			"tags": `[]`, "address": nil, "counts": `{}`,
+		},
+		{
+			"id": int64(3), "quantity": int64(7), "customer": nil, "total": "1000.00",
+			"day": time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC), "placed": placed, "paid": true, "weight": 0.0,
+			"tags": `[]`, "address": nil, "counts": `{}`,
+		},
+	}
+	if !reflect.DeepEqual(got.Rows, wantRows) {
+		t.Errorf("Rows = %v, want %v", got.Rows, wantRows)
+	}
+
+	wantTypes := map[string]dialects.ColumnDef{
+		"id":       {Name: "id", Type: dialects.SQLTypeBigInt},
+		"quantity": {Name: "quantity", Type: dialects.SQLTypeInteger},
+		"customer": {Name: "customer", Type: dialects.SQLTypeText, Nullable: true},
+		"total":    {Name: "total", Type: dialects.SQLTypeDecimal, Precision: 10, Scale: 2},
+		"day":      {Name: "day", Type: dialects.SQLTypeDate},
+		"placed":   {Name: "placed", Type: dialects.SQLTypeDateTime},
+		"paid":     {Name: "paid", Type: dialects.SQLTypeBoolean},
+		"weight":   {Name: "weight", Type: dialects.SQLTypeFloat},
+		"tags":     {Name: "tags", Type: dialects.SQLTypeText},
+		"address":  {Name: "address", Type: dialects.SQLTypeText, Nullable: true},
+		"counts":   {Name: "counts", Type: dialects.SQLTypeText},
+	}
+	if !reflect.DeepEqual(got.Types, wantTypes) {
+		t.Errorf("Types = %v, want %v", got.Types, wantTypes)
+	}
+}
+
+func TestParquetLoader_Columns(t *testing.T) {
+	ann := "Ann"
+	path := writeParquet(t, []parquetOrder{
+		{ID: 1, Customer: &ann, Total: 1250, Address: &parquetAddress{City: "Oslo"}},
+		{ID: 2, Total: 990},
+		{ID: 3, Total: 5},
+	})
+
+	tests := []struct {
+		name        string
+		columns     []string
+		wantColumns []string
+		wantRows    []common.DataRow
+		wantErr     string
+	}{
+		{
+			name:        "Selected columns in the given order",
+			columns:     []string{"total", "customer", "id"},
+			wantColumns: []string{"total", "customer", "id"},
+			wantRows: []common.DataRow{
+				{"total": "12.50", "customer": "Ann", "id": int64(1)},
+				{"total": "9.90", "customer": nil, "id": int64(2)},
+				{"total": "0.05", "customer": nil, "id": int64(3)},
+			},
+		},
+		{
+			name:        "Nested column",
+			columns:     []string{"address"},
+			wantColumns: []string{"address"},
+			wantRows: []common.DataRow{
+				{"address": `{"city":"Oslo","since":"1970-01-01T00:00:00Z"}`},
+				{"address": nil},
+				{"address": nil},
+			},
+		},
+		{
+			name:    "Unknown column",
+			columns: []string{"id", "price"},
+			wantErr: `column "price" not found in the Parquet file (columns: id, quantity, customer,`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &ParquetLoader{Options: ParquetOptions{Columns: tt.columns}}
+			got, err := l.Load(path)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParquetLoader.Load() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParquetLoader.Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Columns, tt.wantColumns) {
+				t.Errorf("Columns = %q, want %q", got.Columns, tt.wantColumns)
+			}
+			if !reflect.DeepEqual(got.Rows, tt.wantRows) {
+				t.Errorf("Rows = %v, want %v", got.Rows, tt.wantRows)
+			}
+			if len(got.Types) != len(tt.wantColumns) {
+				t.Errorf("Types = %v, want one per selected column", got.Types)
+			}
+		})
+	}
+}
+
+func TestParquetLoader_Stream(t *testing.T) {
+	type reading struct {
+		Sensor int32   `parquet:"sensor"`
+		Value  float32 `parquet:"value"`
+	}
+
+	rows := make([]reading, 25)
+	for i := range rows {
+		rows[i] = reading{Sensor: int32(i), Value: float32(i) / 2}
+	}
+	path := writeParquet(t, rows)
+
+	it, err := (&ParquetLoader{}).Stream(path)
+	if err != nil {
+		t.Fatalf("ParquetLoader.Stream() error = %v", err)
+	}
+	dataset, err := common.CollectDataSet(it)
+	if err != nil {
+		t.Fatalf("CollectDataSet() error = %v", err)
+	}
+
+	// Rows come from 13 row groups, in file order
+	if len(dataset.Rows) != len(rows) {
+		t.Fatalf("Stream() returned %d rows, want %d", len(dataset.Rows), len(rows))
+	}
+	for i, row := range dataset.Rows {
+		if row["sensor"] != int64(i) || row["value"] != float64(i)/2 {
+			t.Errorf("Row %d = %v", i, row)
+		}
+	}
+}
+
+func TestFormatDecimal(t *testing.T) {
+	tests := []struct {
+		unscaled int64
+		scale    int
+		want     string
+	}{
+		{12345, 2, "123.45"},
+		{5, 3, "0.005"},
+		{-5, 2, "-0.05"},
+		{-12345, 0, "-12345"},
+		{0, 2, "0.00"},
+		{12, -2, "1200"},
+	}
+
+	for _, tt := range tests {
+		v := parquet.Int64Value(tt.unscaled)
+		if got := formatDecimal(parquetUnscaled(v), tt.scale); got != tt.want {
+			t.Errorf("formatDecimal(%d, %d) = %q, want %q", tt.unscaled, tt.scale, got, tt.want)
+		}
+	}
+
+	// Fixed length decimals are big-endian two's complement
+	v := parquet.FixedLenByteArrayValue([]byte{0xff, 0xff, 0xfe})
+	if got := formatDecimal(parquetUnscaled(v), 1); got != "-0.2" {
+		t.Errorf("formatDecimal(0xfffffe, 1) = %q, want %q", got, "-0.2")
+	}
+}
+
+func TestParquetOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options ParquetOptions
+		wantErr string
+	}{
+		{name: "Defaults"},
+		{name: "Columns", options: ParquetOptions{Columns: []string{"id", "name"}}},
+		{name: "Empty column", options: ParquetOptions{Columns: []string{"id", ""}}, wantErr: "empty Parquet column name"},
+		{name: "Repeated column", options: ParquetOptions{Columns: []string{"id", "id"}}, wantErr: `parquet column "id" is selected more than once`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package loaders
+
+import (
+	"brokolisql-go/pkg/dialects"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/deprecated"
+)
+
+// julianUnixEpoch is the Julian day number of 1970-01-01, the day count
+// INT96 timestamps are based on
+const julianUnixEpoch = 2440588
+
+// parquetColumnDef returns the SQL column for a top-level field, taken from
+// its logical type, or its physical type if it has none. Groups are stored
+// as JSON text.
+func parquetColumnDef(field parquet.Field) dialects.ColumnDef {
+	def := dialects.ColumnDef{Name: field.Name(), Type: dialects.SQLTypeText, Nullable: !field.Required()}
+	if !field.Leaf() {
+		return def
+	}
+
+	typ := field.Type()
+	if lt := typ.LogicalType(); lt != nil {
+		switch {
+		case lt.Decimal != nil:
+			def.Type = dialects.SQLTypeDecimal
+			def.Precision, def.Scale = int(lt.Decimal.Precision), int(lt.Decimal.Scale)
+			return def
+		case lt.Date != nil:
+			def.Type = dialects.SQLTypeDate
+			return def
+		case lt.Timestamp != nil:
+			def.Type = dialects.SQLTypeDateTime
+			return def
+		case lt.Integer != nil:
+			bits, signed := lt.Integer.BitWidth, lt.Integer.IsSigned
+			switch {
+			case bits <= 8 || (bits == 16 && signed):
+				def.Type = dialects.SQLTypeSmallInt
+			case bits == 16 || (bits == 32 && signed):
+				def.Type = dialects.SQLTypeInteger
+			case bits == 32 || signed:
+				def.Type = dialects.SQLTypeBigInt
+			default:
+				// Unsigned 64-bit integers may not fit in a BIGINT
+				def.Type = dialects.SQLTypeDecimal
+				def.Precision = 20
+			}
+			return def
+		case lt.UTF8 != nil, lt.Enum != nil, lt.Json != nil, lt.UUID != nil, lt.Time != nil:
+			return def
+		}
+	}
+
+	switch typ.Kind() {
+	case parquet.Boolean:
+		def.Type = dialects.SQLTypeBoolean
+	case parquet.Int32:
+		def.Type = dialects.SQLTypeInteger
+	case parquet.Int64:
+		def.Type = dialects.SQLTypeBigInt
+	case parquet.Int96:
+		def.Type = dialects.SQLTypeDateTime
+	case parquet.Float, parquet.Double:
+		def.Type = dialects.SQLTypeFloat
+	}
+	return def
+}
+
+// parquetLeafCount returns the number of leaf columns under node
+func parquetLeafCount(node parquet.Node) int {
+	if node.Leaf() {
+		return 1
+	}
+	n := 0
+	for _, field := range node.Fields() {
+		n += parquetLeafCount(field)
+	}
+	return n
+}
+
+// parquetAssemble rebuilds the value of field from the values of its leaf
+// columns, given the definition and repetition levels of its parent. Structs
+// become maps, lists and repeated fields slices, and maps maps keyed by the
+// text of their keys.
+func parquetAssemble(field parquet.Field, columns [][]parquet.Value, def, rep int) interface{} {
+	if field.Repeated() {
+		return parquetRepeated(columns, def, rep, func(instance [][]parquet.Value, def, rep int) interface{} {
+			return parquetNodeValue(field, instance, def, rep)
+		})
+	}
+	if field.Optional() {
+		def++
+		if columns[0][0].DefinitionLevel() < def {
+			return nil
+		}
+	}
+	return parquetNodeValue(field, columns, def, rep)
+}
+
+// parquetRepeated returns the instances of a repeated field, each built by
+// item from its share of the leaf values
+func parquetRepeated(columns [][]parquet.Value, def, rep int, item func(instance [][]parquet.Value, def, rep int) interface{}) []interface{} {
+	def++
+	rep++
+	items := []interface{}{}
+	if columns[0][0].DefinitionLevel() < def {
+		return items
+	}
+
+	// Every leaf column holds the same number of instances, each starting
+	// with a value repeated at this field's level
+	starts := make([]int, len(columns))
+	for {
+		instance := make([][]parquet.Value, len(columns))
+		done := true
+		for i, values := range columns {
+			end := starts[i] + 1
+			for end < len(values) && values[end].RepetitionLevel() > rep {
+				end++
+			}
+			instance[i] = values[starts[i]:end]
+			starts[i] = end
+			done = done && end >= len(values)
+		}
+		items = append(items, item(instance, def, rep))
+		if done {
+			return items
+		}
+	}
+}
+
+// parquetNodeValue returns the value of a field that is present
+func parquetNodeValue(field parquet.Field, columns [][]parquet.Value, def, rep int) interface{} {
+	if field.Leaf() {
+		return parquetValue(field.Type(), columns[0][0])
+	}
+
+	fields := field.Fields()
+	if lt := field.Type().LogicalType(); lt != nil && len(fields) == 1 && fields[0].Repeated() {
+		repeated := fields[0]
+		switch {
+		case lt.List != nil:
+			// Lists wrap each element in a repeated group, except in the
+			// two-level layout of older writers where the repeated field is
+			// the element itself
+			if repeated.Leaf() || len(repeated.Fields()) != 1 || repeated.Name() == "array" || repeated.Name() == field.Name()+"_tuple" {
+				return parquetAssemble(repeated, columns, def, rep)
+			}
+			element := repeated.Fields()[0]
+			return parquetRepeated(columns, def, rep, func(instance [][]parquet.Value, def, rep int) interface{} {
+				return parquetAssemble(element, instance, def, rep)
+			})
+
+		case lt.Map != nil && !repeated.Leaf() && len(repeated.Fields()) == 2:
+			key, value := repeated.Fields()[0], repeated.Fields()[1]
+			keyLeaves := parquetLeafCount(key)
+			entries := make(map[string]interface{})
+			parquetRepeated(columns, def, rep, func(instance [][]parquet.Value, def, rep int) interface{} {
+				k := parquetAssemble(key, instance[:keyLeaves], def, rep)
+				entries[fmt.Sprint(k)] = parquetAssemble(value, instance[keyLeaves:], def, rep)
+				return nil
+			})
+			return entries
+		}
+	}
+
+	record := make(map[string]interface{}, len(fields))
+	first := 0
+	for _, f := range fields {
+		leaves := parquetLeafCount(f)
+		record[f.Name()] = parquetAssemble(f, columns[first:first+leaves], def, rep)
+		first += leaves
+	}
+	return record
+}
+
+// parquetValue converts a leaf value to the Go type of its SQL column:
+// int64 for integers, a decimal string for decimals, time.Time for dates and
+// timestamps, bool, float64 and string. Binary values that are not valid
+// UTF-8 are hex encoded.
+func parquetValue(typ parquet.Type, v parquet.Value) interface{} {
+	if v.IsNull() {
+		return nil
+	}
+
+	if lt := typ.LogicalType(); lt != nil {
+		switch {
+		case lt.Decimal != nil:
+			return formatDecimal(parquetUnscaled(v), int(lt.Decimal.Scale))
+		case lt.Date != nil:
+			return time.Unix(int64(v.Int32())*86400, 0).UTC()
+		case lt.Timestamp != nil:
+			switch unit := lt.Timestamp.Unit; {
+			case unit.Millis != nil:
+				return time.UnixMilli(v.Int64()).UTC()
+			case unit.Micros != nil:
+				return time.UnixMicro(v.Int64()).UTC()
+			default:
+				return time.Unix(0, v.Int64()).UTC()
+			}
+		case lt.Time != nil:
+			var d time.Duration
+			switch unit := lt.Time.Unit; {
+			case unit.Millis != nil:
+				d = time.Duration(v.Int32()) * time.Millisecond
+			case unit.Micros != nil:
+				d = time.Duration(v.Int64()) * time.Microsecond
+			default:
+				d = time.Duration(v.Int64())
+			}
+			return timeOfDay(d)
+		case lt.Integer != nil && !lt.Integer.IsSigned:
+			if lt.Integer.BitWidth == 64 {
+				return strconv.FormatUint(uint64(v.Int64()), 10)
+			}
+			return int64(uint32(v.Int32()))
+		case lt.UUID != nil:
+			b := v.ByteArray()
+			if len(b) == 16 {
+				return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+			}
+		}
+	}
+
+	switch v.Kind() {
+	case parquet.Boolean:
+		return v.Boolean()
+	case parquet.Int32:
+		return int64(v.Int32())
+	case parquet.Int64:
+		return v.Int64()
+	case parquet.Int96:
+		return int96Time(v.Int96())
+	case parquet.Float:
+		return float64(v.Float())
+	case parquet.Double:
+		return v.Double()
+	}
+
+	return binaryText(v.ByteArray())
+}
+
+// binaryText returns bytes as a string if they are valid UTF-8 and hex
+// encoded otherwise
+func binaryText(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	return hex.EncodeToString(b)
+}
+
+// timeOfDay formats a time since midnight as 15:04:05 with any fraction of a
+// second
+func timeOfDay(d time.Duration) string {
+	return time.Time{}.Add(d).Format("15:04:05.999999999")
+}
+
+// parquetUnscaled returns the unscaled integer of a decimal, stored as an
+// INT32, an INT64 or a big-endian two's complement byte array
+func parquetUnscaled(v parquet.Value) *big.Int {
+	switch v.Kind() {
+	case parquet.Int32:
+		return big.NewInt(int64(v.Int32()))
+	case parquet.Int64:
+		return big.NewInt(v.Int64())
+	}
+
+	b := v.ByteArray()
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	return n
+}
+
+// formatDecimal writes unscaled / 10^scale as an exact decimal string
+func formatDecimal(unscaled *big.Int, scale int) string {
+	digits := new(big.Int).Abs(unscaled).String()
+	sign := ""
+	if unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if scale <= 0 {
+		return sign + digits + strings.Repeat("0", -scale)
+	}
+
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// int96Time converts a legacy INT96 timestamp, nanoseconds within the day
+// followed by the Julian day number
+func int96Time(v deprecated.Int96) time.Time {
+	nanos := int64(v[1])<<32 | int64(v[0])
+	days := int64(v[2]) - julianUnixEpoch
+	return time.Unix(days*86400, nanos).UTC()
+}
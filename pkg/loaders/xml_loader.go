@@ -4,12 +4,39 @@ import (
 	"brokolisql-go/pkg/common"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 )
 
-type XMLLoader struct{}
+// XMLOptions controls which elements of an XML document become rows
+type XMLOptions struct {
+	RowPath    string            `json:"row_path,omitempty" yaml:"row_path,omitempty"`     // Elements read as rows, such as /orders/order or //order; the most repeated element if empty
+	Namespaces map[string]string `json:"namespaces,omitempty" yaml:"namespaces,omitempty"` // Namespace URIs of the prefixes used in RowPath, besides those declared in the document
+}
+
+// Validate reports options that cannot be used
+func (o XMLOptions) Validate() error {
+	if o.RowPath == "" {
+		return nil
+	}
+	_, err := parseXMLPath(o.RowPath)
+	return err
+}
+
+// XMLLoader reads the repeating elements of an XML document as rows.
+// Attributes and child elements with only text become columns, in the order
+// they first appear in the document. Child elements with attributes or
+// children of their own become nested objects, and elements that repeat
+// within their parent become arrays, so that they are stored in related
+// tables just like nested JSON. A wrapper element holding nothing but one
+// repeating element, such as <items> around <item>, is that array itself.
+type XMLLoader struct {
+	Encoding string // Character encoding of the file; detected from the BOM, declaration or content if empty or EncodingAuto
+	Options  XMLOptions
+}
 
+// XMLNode is an element and its content, decoded one row element at a time
 type XMLNode struct {
 	XMLName  xml.Name
 	Attrs    []xml.Attr `xml:",any,attr"`
@@ -18,101 +45,491 @@ type XMLNode struct {
 }
 
 func (l *XMLLoader) Load(filePath string) (*common.DataSet, error) {
+	it, err := l.Stream(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return common.CollectDataSet(it)
+}
 
-	file, err := os.Open(filePath)
+// Stream reads the document one row element at a time, so only the current
+// row is held in memory. As with JSONLoader.Stream, the file is read more
+// than once: first to find the row elements when no row path is given, then
+// to collect the columns, and finally to yield the rows.
+func (l *XMLLoader) Stream(filePath string) (common.RowIterator, error) {
+	path, err := l.rowPath(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open XML file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
 
-	var root XMLNode
-	decoder := xml.NewDecoder(file)
-	if err := decoder.Decode(&root); err != nil {
-		return nil, fmt.Errorf("failed to parse XML: %w", err)
+	namespaces := newXMLNamespaces(l.Options.Namespaces)
+	converter := &xmlConverter{namespaces: namespaces, arrays: make(map[string]bool)}
+	columns, err := l.scanColumns(filePath, path, converter)
+	if err != nil {
+		return nil, err
 	}
 
-	rowElements, err := findRepeatingElements(&root)
+	reader, err := l.open(filePath, path, namespaces)
 	if err != nil {
 		return nil, err
 	}
+	return &xmlIterator{reader: reader, converter: converter, columns: columns}, nil
+}
 
-	columnSet := make(map[string]bool)
-	for _, row := range rowElements {
+// rowPath returns the configured row path, or detects one
+func (l *XMLLoader) rowPath(filePath string) (*xmlPath, error) {
+	if l.Options.RowPath != "" {
+		return parseXMLPath(l.Options.RowPath)
+	}
 
-		for _, attr := range row.Attrs {
-			columnSet[attr.Name.Local] = true
+	reader, err := l.open(filePath, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	shape, err := reader.shape()
+	if err != nil {
+		return nil, err
+	}
+	return shape.rowPath(), nil
+}
+
+// scanColumns reads every row to collect the columns in document order and
+// the elements that repeat, which are read as arrays in every row
+func (l *XMLLoader) scanColumns(filePath string, path *xmlPath, converter *xmlConverter) ([]string, error) {
+	reader, err := l.open(filePath, path, converter.namespaces)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var columns []string
+	seen := make(map[string]bool)
+	count := 0
+	for {
+		node, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
 		}
 
-		for _, child := range row.Children {
-			if len(child.Children) == 0 && len(child.Attrs) == 0 {
-				columnSet[child.XMLName.Local] = true
+		converter.findArrays(node, "")
+		keys, _ := converter.record(node, "")
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
 			}
 		}
+		count++
 	}
 
-	columns := make([]string, 0, len(columnSet))
-	for col := range columnSet {
-		columns = append(columns, col)
+	for _, prefix := range path.prefixes() {
+		if _, ok := converter.namespaces.resolve(prefix); !ok {
+			return nil, fmt.Errorf("XML row path %q uses the undeclared namespace prefix %q", path.expr, prefix)
+		}
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("no elements in the XML file match the row path %q", path.expr)
+	}
+	return columns, nil
+}
+
+// open starts reading the file. Namespace declarations are recorded in
+// namespaces when it is not nil.
+func (l *XMLLoader) open(filePath string, path *xmlPath, namespaces *xmlNamespaces) (*xmlReader, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open XML file: %w", err)
+	}
+	text, err := openText(file, l.Encoding)
+	if err != nil {
+		return nil, err
 	}
 
-	rows := make([]common.DataRow, 0, len(rowElements))
-	for _, elem := range rowElements {
-		row := make(common.DataRow)
+	decoder := xml.NewDecoder(text)
+	decoder.CharsetReader = utf8CharsetReader
+	return &xmlReader{file: text, decoder: decoder, path: path, namespaces: namespaces}, nil
+}
 
-		for _, attr := range elem.Attrs {
-			row[attr.Name.Local] = attr.Value
+// xmlReader walks the tokens of a document and decodes the row elements
+type xmlReader struct {
+	file       io.Closer
+	decoder    *xml.Decoder
+	path       *xmlPath
+	namespaces *xmlNamespaces
+	names      []xml.Name // The elements the reader is in, from the root down
+	started    bool       // Whether the root element was seen
+}
+
+// token returns the next token, or io.EOF at the end of the document
+func (r *xmlReader) token() (xml.Token, error) {
+	token, err := r.decoder.Token()
+	if err == io.EOF {
+		if !r.started {
+			return nil, fmt.Errorf("failed to parse XML: the document has no root element")
 		}
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse XML: %w", err)
+	}
+	if _, ok := token.(xml.StartElement); ok {
+		r.started = true
+	}
+	return token, nil
+}
 
-		for _, child := range elem.Children {
-			if len(child.Children) == 0 && len(child.Attrs) == 0 {
-				row[child.XMLName.Local] = strings.TrimSpace(child.Content)
-			}
+// next returns the next row element, or io.EOF after the last one. Matching
+// elements inside a row are part of that row, not rows of their own.
+func (r *xmlReader) next() (*XMLNode, error) {
+	for {
+		token, err := r.token()
+		if err != nil {
+			return nil, err
 		}
 
-		rows = append(rows, row)
+		switch t := token.(type) {
+		case xml.StartElement:
+			if r.namespaces != nil {
+				r.namespaces.declare(t.Attr)
+			}
+			r.names = append(r.names, t.Name)
+			if !r.path.match(r.names, r.resolve) {
+				continue
+			}
+
+			node := &XMLNode{}
+			if err := r.decoder.DecodeElement(node, &t); err != nil {
+				return nil, fmt.Errorf("failed to parse XML: %w", err)
+			}
+			r.names = r.names[:len(r.names)-1]
+			if r.namespaces != nil {
+				r.namespaces.collect(node)
+			}
+			return node, nil
+
+		case xml.EndElement:
+			r.names = r.names[:len(r.names)-1]
+		}
 	}
+}
 
-	return &common.DataSet{
-		Columns: columns,
-		Rows:    rows,
-	}, nil
+func (r *xmlReader) resolve(prefix string) (string, bool) {
+	if r.namespaces == nil {
+		return "", false
+	}
+	return r.namespaces.resolve(prefix)
 }
 
-func findRepeatingElements(root *XMLNode) ([]XMLNode, error) {
+// shape reads the whole document and returns the tree of its element paths
+func (r *xmlReader) shape() (*xmlShape, error) {
+	root := &xmlShape{}
+	stack := []*xmlShape{root}
+	for {
+		token, err := r.token()
+		if err == io.EOF {
+			return root, nil
+		}
+		if err != nil {
+			return nil, err
+		}
 
-	elementCounts := make(map[string]int)
-	for _, child := range root.Children {
-		elementCounts[child.XMLName.Local]++
+		switch t := token.(type) {
+		case xml.StartElement:
+			parent := stack[len(stack)-1]
+			parent.open++
+			stack = append(stack, parent.child(t.Name.Local))
+		case xml.EndElement:
+			shape := stack[len(stack)-1]
+			shape.maxChildren = max(shape.maxChildren, shape.open)
+			shape.open = 0
+			stack = stack[:len(stack)-1]
+		}
 	}
+}
+
+func (r *xmlReader) Close() error {
+	return r.file.Close()
+}
+
+// xmlShape summarizes the elements found at one path of a document
+type xmlShape struct {
+	name        string
+	count       int         // Elements at this path
+	children    []*xmlShape // Child element names in document order
+	maxChildren int         // Most child elements any of the elements has
+	open        int         // Child elements of the element being read
+}
 
-	var mostRepeatedElement string
-	maxCount := 0
-	for elem, count := range elementCounts {
-		if count > maxCount {
-			maxCount = count
-			mostRepeatedElement = elem
+func (s *xmlShape) child(name string) *xmlShape {
+	for _, child := range s.children {
+		if child.name == name {
+			child.count++
+			return child
 		}
 	}
+	child := &xmlShape{name: name, count: 1}
+	s.children = append(s.children, child)
+	return child
+}
 
-	if maxCount > 1 {
-		var elements []XMLNode
-		for _, child := range root.Children {
-			if child.XMLName.Local == mostRepeatedElement {
-				elements = append(elements, child)
+// rowPath picks the row elements: the most repeated child of the root, or
+// of the first element below it with several children. Without repeating
+// elements the children of that element, or the element itself, are the
+// rows.
+func (s *xmlShape) rowPath() *xmlPath {
+	node := s.children[0]
+	steps := []xmlStep{{local: node.name}}
+	for {
+		var most, next *xmlShape
+		for _, child := range node.children {
+			if most == nil || child.count > most.count {
+				most = child
+			}
+			if next == nil && child.maxChildren > 1 {
+				next = child
 			}
 		}
-		return elements, nil
+
+		switch {
+		case most != nil && most.count > 1:
+			steps = append(steps, xmlStep{local: most.name})
+		case next != nil:
+			steps = append(steps, xmlStep{local: next.name})
+			node = next
+			continue
+		case len(node.children) > 0:
+			steps = append(steps, xmlStep{local: "*"})
+		}
+		return &xmlPath{expr: pathExpr(steps), steps: steps}
 	}
+}
+
+func pathExpr(steps []xmlStep) string {
+	var sb strings.Builder
+	for _, step := range steps {
+		sb.WriteString("/" + step.local)
+	}
+	return sb.String()
+}
+
+// xmlIterator yields the row elements of an XML document as DataRows
+type xmlIterator struct {
+	reader    *xmlReader
+	converter *xmlConverter
+	columns   []string
+}
+
+func (it *xmlIterator) Columns() []string {
+	return it.columns
+}
+
+func (it *xmlIterator) Next() (common.DataRow, error) {
+	node, err := it.reader.next()
+	if err != nil {
+		return nil, err
+	}
+	_, record := it.converter.record(node, "")
+	return common.ConvertToDataRow(record), nil
+}
+
+func (it *xmlIterator) Close() error {
+	return it.reader.Close()
+}
+
+// xmlNamespaces maps namespace URIs to the prefixes declared for them, so
+// that elements from other namespaces get distinct column names
+type xmlNamespaces struct {
+	prefixes map[string]string // URI to prefix, the first declaration wins
+	uris     map[string]string // Prefix to URI, configured ones first
+}
+
+func newXMLNamespaces(configured map[string]string) *xmlNamespaces {
+	n := &xmlNamespaces{
+		prefixes: map[string]string{xmlNamespaceURI: "xml"},
+		uris:     make(map[string]string),
+	}
+	for prefix, uri := range configured {
+		n.uris[prefix] = uri
+		n.prefixes[uri] = prefix
+	}
+	return n
+}
+
+// xmlNamespaceURI is the namespace of the predefined xml prefix, as in xml:lang
+const xmlNamespaceURI = "http://www.w3.org/XML/1998/namespace"
+
+// declare records the prefixes declared by the attributes of an element
+func (n *xmlNamespaces) declare(attrs []xml.Attr) {
+	for _, attr := range attrs {
+		if attr.Name.Space != "xmlns" {
+			continue
+		}
+		if _, ok := n.uris[attr.Name.Local]; !ok {
+			n.uris[attr.Name.Local] = attr.Value
+		}
+		if _, ok := n.prefixes[attr.Value]; !ok {
+			n.prefixes[attr.Value] = attr.Name.Local
+		}
+	}
+}
+
+// collect records the prefixes declared in an element and its children
+func (n *xmlNamespaces) collect(node *XMLNode) {
+	n.declare(node.Attrs)
+	for i := range node.Children {
+		n.collect(&node.Children[i])
+	}
+}
+
+func (n *xmlNamespaces) resolve(prefix string) (string, bool) {
+	uri, ok := n.uris[prefix]
+	return uri, ok
+}
+
+// columnName returns the local name, prefixed as in g_price when the name is
+// in a namespace other than parentSpace that has a declared prefix
+func (n *xmlNamespaces) columnName(name xml.Name, parentSpace string) string {
+	if name.Space == "" || name.Space == parentSpace {
+		return name.Local
+	}
+	if prefix := n.prefixes[name.Space]; prefix != "" {
+		return prefix + "_" + name.Local
+	}
+	return name.Local
+}
 
-	for _, child := range root.Children {
-		if len(child.Children) > 1 {
-			return findRepeatingElements(&child)
+// isNamespaceDeclaration reports whether attr is an xmlns or xmlns:prefix
+// attribute, which carries no data
+func isNamespaceDeclaration(attr xml.Attr) bool {
+	return attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns")
+}
+
+// xmlConverter turns row elements into records
+type xmlConverter struct {
+	namespaces *xmlNamespaces
+	arrays     map[string]bool // Paths below the row element, such as items/item, of elements that repeat within their parent
+}
+
+// findArrays records the child elements of node that occur more than once.
+// An element that repeats in any row is read as an array in every row, so
+// that all rows have the same shape.
+func (c *xmlConverter) findArrays(node *XMLNode, path string) {
+	counts := make(map[string]int)
+	for i := range node.Children {
+		child := &node.Children[i]
+		childPath := path + "/" + c.namespaces.columnName(child.XMLName, node.XMLName.Space)
+		if counts[childPath]++; counts[childPath] > 1 {
+			c.arrays[childPath] = true
+		}
+		c.findArrays(child, childPath)
+	}
+}
+
+// record returns the fields of an element and their names in document
+// order: attributes first, then child elements, then any text the element
+// holds besides its children as "value"
+func (c *xmlConverter) record(node *XMLNode, path string) ([]string, map[string]interface{}) {
+	record := make(map[string]interface{})
+	var keys []string
+	add := func(key string, value interface{}) {
+		if _, exists := record[key]; !exists {
+			keys = append(keys, key)
 		}
+		record[key] = value
+	}
+
+	children := make(map[string]bool, len(node.Children))
+	for i := range node.Children {
+		children[c.namespaces.columnName(node.Children[i].XMLName, node.XMLName.Space)] = true
 	}
 
-	if len(root.Children) > 0 {
-		return root.Children, nil
+	for _, attr := range node.Attrs {
+		if isNamespaceDeclaration(attr) {
+			continue
+		}
+		// An attribute named like a child element is kept under another name
+		key := c.namespaces.columnName(attr.Name, "")
+		if children[key] {
+			key += "_attr"
+		}
+		add(key, attr.Value)
+	}
+
+	for i := range node.Children {
+		child := &node.Children[i]
+		key := c.namespaces.columnName(child.XMLName, node.XMLName.Space)
+		childPath := path + "/" + key
+		value := c.value(child, childPath)
+
+		if !c.arrays[childPath] {
+			add(key, value)
+			continue
+		}
+		values, _ := record[key].([]interface{})
+		add(key, append(values, value))
+	}
+
+	if text := strings.TrimSpace(node.Content); text != "" {
+		if _, exists := record["value"]; !exists {
+			add("value", text)
+		}
 	}
 
-	return []XMLNode{*root}, nil
+	return keys, record
+}
+
+// value returns the text of an element with neither data attributes nor
+// children, the array of a wrapper element and a nested record otherwise
+func (c *xmlConverter) value(node *XMLNode, path string) interface{} {
+	if len(node.Children) == 0 && !hasDataAttrs(node) {
+		return strings.TrimSpace(node.Content)
+	}
+	if items, ok := c.wrapped(node, path); ok {
+		return items
+	}
+
+	_, record := c.record(node, path)
+	return record
+}
+
+// wrapped returns the values of the children of a wrapper element: one
+// without data attributes or text whose children all have the same name,
+// which repeats in some row
+func (c *xmlConverter) wrapped(node *XMLNode, path string) ([]interface{}, bool) {
+	if hasDataAttrs(node) || strings.TrimSpace(node.Content) != "" {
+		return nil, false
+	}
+
+	var key string
+	for i := range node.Children {
+		name := c.namespaces.columnName(node.Children[i].XMLName, node.XMLName.Space)
+		if i > 0 && name != key {
+			return nil, false
+		}
+		key = name
+	}
+	childPath := path + "/" + key
+	if !c.arrays[childPath] {
+		return nil, false
+	}
+
+	items := make([]interface{}, len(node.Children))
+	for i := range node.Children {
+		items[i] = c.value(&node.Children[i], childPath)
+	}
+	return items, true
+}
+
+func hasDataAttrs(node *XMLNode) bool {
+	for _, attr := range node.Attrs {
+		if !isNamespaceDeclaration(attr) {
+			return true
+		}
+	}
+	return false
 }
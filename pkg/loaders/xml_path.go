@@ -0,0 +1,114 @@
+package loaders
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// xmlStep is one element step of a row path
+type xmlStep struct {
+	descendant bool   // Preceded by //, so other elements may come in between
+	prefix     string // Namespace prefix, any namespace if empty
+	local      string // Element name, or * for any element
+}
+
+// xmlPath is a parsed row path, a subset of XPath with child (/) and
+// descendant (//) steps, element names, namespace prefixes and wildcards
+type xmlPath struct {
+	expr  string
+	steps []xmlStep
+}
+
+// parseXMLPath parses a row path such as /orders/order, //order or
+// ns:feed/ns:entry. A path without a leading / matches at any depth.
+func parseXMLPath(expr string) (*xmlPath, error) {
+	s := strings.TrimSpace(expr)
+	if s == "" {
+		return nil, fmt.Errorf("empty XML row path")
+	}
+	if !strings.HasPrefix(s, "/") {
+		s = "//" + s
+	}
+
+	p := &xmlPath{expr: expr}
+	for s != "" {
+		step := xmlStep{}
+		switch {
+		case strings.HasPrefix(s, "//"):
+			step.descendant = true
+			s = s[2:]
+		case strings.HasPrefix(s, "/"):
+			s = s[1:]
+		}
+
+		end := strings.IndexByte(s, '/')
+		if end < 0 {
+			end = len(s)
+		}
+		name := s[:end]
+		s = s[end:]
+
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("invalid XML row path %q: empty step", expr)
+		case name == "." || name == ".." || strings.ContainsAny(name, "@[]()="):
+			return nil, fmt.Errorf("invalid XML row path %q: only element names and * are supported, got %q", expr, name)
+		}
+
+		if prefix, local, ok := strings.Cut(name, ":"); ok {
+			if prefix == "" || local == "" {
+				return nil, fmt.Errorf("invalid XML row path %q: invalid name %q", expr, name)
+			}
+			step.prefix, step.local = prefix, local
+		} else {
+			step.local = name
+		}
+		p.steps = append(p.steps, step)
+	}
+	return p, nil
+}
+
+// match reports whether the path selects the element whose ancestors and
+// itself, from the document root down, are names. Prefixes are looked up
+// with resolve, which returns the namespace URI of a prefix.
+func (p *xmlPath) match(names []xml.Name, resolve func(prefix string) (string, bool)) bool {
+	return matchXMLSteps(p.steps, names, resolve)
+}
+
+func matchXMLSteps(steps []xmlStep, names []xml.Name, resolve func(string) (string, bool)) bool {
+	if len(steps) == 0 {
+		return len(names) == 0
+	}
+	if len(names) == 0 {
+		return false
+	}
+
+	step := steps[0]
+	if step.matches(names[0], resolve) && matchXMLSteps(steps[1:], names[1:], resolve) {
+		return true
+	}
+	return step.descendant && matchXMLSteps(steps, names[1:], resolve)
+}
+
+func (s xmlStep) matches(name xml.Name, resolve func(string) (string, bool)) bool {
+	if s.local != "*" && s.local != name.Local {
+		return false
+	}
+	if s.prefix == "" {
+		return true
+	}
+	space, ok := resolve(s.prefix)
+	return ok && space == name.Space
+}
+
+// prefixes returns the namespace prefixes the path uses
+func (p *xmlPath) prefixes() []string {
+	var prefixes []string
+	for _, step := range p.steps {
+		if step.prefix != "" {
+			prefixes = append(prefixes, step.prefix)
+		}
+	}
+	return prefixes
+}
@@ -0,0 +1,523 @@
+// Package pipeline converts input files, or data fetched from a remote source,
+// into SQL written to a sink. It is the conversion behind the brokolisql
+// command, for embedding in other programs. Formats, dialects and source
+// types registered with loaders.Register, dialects.Register and
+// fetchers.Register are available to it like the built-in ones.
+package pipeline
+
+import (
+	"brokolisql-go/internal/processing"
+	"brokolisql-go/internal/transformers"
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"brokolisql-go/pkg/fetchers"
+	"brokolisql-go/pkg/loaders"
+	"brokolisql-go/pkg/sinks"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Ways of converting the files of a directory or glob pattern
+const (
+	MultiFileAppend = "append" // One table with the rows of every file
+	MultiFileTables = "tables" // A table per file, named after it
+)
+
+// Statement modes
+const (
+	ModeInsert = processing.ModeInsert // INSERT statements
+	ModeUpsert = processing.ModeUpsert // Statements updating rows whose KeyColumns match
+	ModeCopy   = processing.ModeCopy   // COPY ... FROM stdin blocks, for PostgreSQL
+)
+
+// Options configure a conversion. Start from DefaultOptions, as the zero
+// value of some options is not their default.
+type Options struct {
+	// Input is the file, directory or glob pattern read, or
+	// loaders.StdinPath to read InputReader
+	Input string
+	// InputReader is read when Input is loaders.StdinPath; nil reads
+	// standard input
+	InputReader io.Reader
+	// Loader holds the input parsing options, including the format; nil
+	// uses the defaults
+	Loader *loaders.Config
+	// MultiFile is how the files of a directory or glob pattern are
+	// converted: MultiFileAppend or MultiFileTables
+	MultiFile string
+
+	// Fetch reads from Source with the fetcher of SourceType instead of
+	// reading Input
+	Fetch      bool
+	Source     string
+	SourceType string
+	// FetchOptions are passed to the fetcher; nil uses the defaults of the
+	// source type
+	FetchOptions map[string]interface{}
+
+	// Table names the output table. Tables read from the sheets of a
+	// workbook, the files of an archive or a table per file are named after
+	// them, prefixed with Table if it is set.
+	Table         string
+	Dialect       string
+	BatchSize     int
+	CreateTable   bool
+	Normalize     bool     // Normalize column names for SQL compatibility
+	Mode          string   // Statement mode: ModeInsert, ModeUpsert or ModeCopy
+	KeyColumns    []string // Columns matching existing rows in upsert mode
+	SchemaFile    string   // JSON or YAML file pinning column types
+	SizedTypes    bool
+	TypeHeadroom  int
+	BooleanValues string // Comma separated true/false pairs inferred as booleans
+	CodeWidth     int    // Digits from which equal-width numbers are kept as text, 0 disables
+	TransformFile string // JSON file with transformation rules
+
+	// Stream converts the input row by row with bounded memory, inferring
+	// types from the first SampleSize rows
+	Stream     bool
+	SampleSize int
+
+	// Rejects receives a CSV report of rows whose values do not fit their
+	// column type, which are then skipped instead of failing; nil fails.
+	// Nothing is written to it when no row is rejected.
+	Rejects io.Writer
+	// Messages receives progress messages; nil discards them. Warnings
+	// about input left out, such as skipped lines, start with
+	// common.WarningPrefix.
+	Messages io.Writer
+}
+
+// DefaultOptions returns the options the brokolisql command defaults to
+func DefaultOptions() Options {
+	return Options{
+		MultiFile:     MultiFileAppend,
+		SourceType:    "rest",
+		Dialect:       "generic",
+		BatchSize:     100,
+		Normalize:     true,
+		Mode:          ModeInsert,
+		TypeHeadroom:  25,
+		BooleanValues: "true/false,yes/no",
+		CodeWidth:     8,
+		SampleSize:    1000,
+	}
+}
+
+// InputName describes the input in messages
+func (o *Options) InputName() string {
+	switch {
+	case o.Fetch:
+		return o.Source
+	case o.Input == loaders.StdinPath:
+		return "standard input"
+	}
+	return o.Input
+}
+
+// Result describes a finished conversion
+type Result struct {
+	Tables   []string          // Names of the tables written, in order
+	Format   loaders.Detection // Format of a single input file, zero for other inputs
+	Rejected int               // Rows written to the reject report
+}
+
+// conversion is the state of one Run
+type conversion struct {
+	options  Options
+	config   *loaders.Config
+	path     string            // File the input is read from: Input, or a copy of InputReader
+	format   loaders.Detection // Format of a single input file
+	rejects  *processing.RejectReport
+	messages io.Writer
+}
+
+// Run converts the input described by options and writes the SQL to sink,
+// committing it once everything is written. The sink is left open for the
+// caller to close.
+func Run(options Options, sink sinks.Sink) (*Result, error) {
+	c := &conversion{options: options, config: options.Loader, messages: options.Messages}
+	if c.config == nil {
+		c.config = &loaders.Config{}
+	}
+	if c.messages == nil {
+		c.messages = io.Discard
+	}
+
+	if options.MultiFile != MultiFileAppend && options.MultiFile != MultiFileTables {
+		return nil, fmt.Errorf("invalid multi-file mode %q, expected %s or %s", options.MultiFile, MultiFileAppend, MultiFileTables)
+	}
+	if options.Fetch && options.Source == "" {
+		return nil, fmt.Errorf("source URL or connection string is required when using fetch mode")
+	}
+	if !options.Fetch && options.Input == "" {
+		return nil, fmt.Errorf("input file is required when not using fetch mode")
+	}
+	if _, err := dialects.GetDialect(options.Dialect); err != nil {
+		return nil, err
+	}
+
+	remove, err := c.resolveInput()
+	if err != nil {
+		return nil, err
+	}
+	defer remove()
+	if err := c.detectInput(); err != nil {
+		return nil, err
+	}
+	if options.Table == "" && !c.config.Excel.AllSheets && !c.format.IsArchive() && !c.tablePerFile() {
+		return nil, fmt.Errorf("a table name is required unless converting all sheets, an archive or a table per file")
+	}
+
+	result := &Result{Format: c.format}
+	if options.Stream {
+		err = c.runStream(sink, result)
+	} else {
+		err = c.run(sink, result)
+	}
+
+	// Rows rejected before a failure are reported too
+	if c.rejects != nil {
+		if flushErr := c.rejects.Flush(); flushErr != nil && err == nil {
+			err = flushErr
+		}
+		result.Rejected = c.rejects.Count()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// run loads the whole input, or fetches it, and converts each of its tables
+func (c *conversion) run(sink sinks.Sink, result *Result) error {
+	var tables []loaders.NamedDataSet // Named after their output tables
+
+	if c.options.Fetch {
+		dataset, err := c.fetch()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.messages, "Successfully fetched %d rows of data\n", len(dataset.Rows))
+		tables = []loaders.NamedDataSet{{Name: c.options.Table, DataSet: dataset}}
+	} else {
+		loader, err := c.newLoader()
+		if err != nil {
+			return err
+		}
+
+		// Archives hold a table per file, named after it, as do directories
+		// and glob patterns converted into a table per file
+		if multiTable, ok := loader.(loaders.MultiTableLoader); ok && (c.config.Excel.AllSheets || c.format.IsArchive() || c.tablePerFile()) {
+			tables, err = multiTable.LoadTables(c.path)
+			if err != nil {
+				return fmt.Errorf("failed to load data: %w", err)
+			}
+			c.nameTables(tables)
+		} else {
+			if c.options.Table == "" {
+				return fmt.Errorf("all sheets only applies to Excel input, name the table instead")
+			}
+			dataset, err := loader.Load(c.path)
+			if err != nil {
+				return fmt.Errorf("failed to load data: %w", err)
+			}
+			tables = []loaders.NamedDataSet{{Name: c.options.Table, DataSet: dataset}}
+		}
+	}
+
+	if c.options.TransformFile != "" {
+		transformEngine, err := transformers.NewTransformEngine(c.options.TransformFile)
+		if err != nil {
+			return fmt.Errorf("failed to initialize transform engine: %w", err)
+		}
+
+		for _, table := range tables {
+			if err := transformEngine.ApplyTransformations(table.DataSet); err != nil {
+				return fmt.Errorf("failed to apply transformations: %w", err)
+			}
+		}
+	}
+
+	schema, err := c.loadSchema()
+	if err != nil {
+		return err
+	}
+	if schema != nil && (c.config.Excel.AllSheets || c.format.IsArchive() || c.tablePerFile()) {
+		return fmt.Errorf("a schema cannot be used with input holding several tables, such as all sheets, an archive or a table per file")
+	}
+
+	booleans, err := processing.ParseBooleanTokens(c.options.BooleanValues)
+	if err != nil {
+		return fmt.Errorf("invalid boolean values: %w", err)
+	}
+	c.startRejects()
+
+	// Each table gets its own CREATE and INSERT section of the script
+	for _, table := range tables {
+		sqlGenerator, err := processing.NewSQLGenerator(c.generatorOptions(table.Name, schema, &booleans))
+		if err != nil {
+			return fmt.Errorf("failed to initialize SQL generator: %w", err)
+		}
+
+		if err := sqlGenerator.GenerateTo(table.DataSet, sink); err != nil {
+			return generateError(sink, err)
+		}
+		result.Tables = append(result.Tables, table.Name)
+	}
+	if err := sink.Commit(); err != nil {
+		return generateError(sink, err)
+	}
+	return nil
+}
+
+// runStream converts the input row by row, writing INSERT batches to the
+// sink as they fill up instead of building the SQL in memory
+func (c *conversion) runStream(sink sinks.Sink, result *Result) error {
+	var rows common.RowIterator
+
+	if c.options.Fetch {
+		dataset, err := c.fetch()
+		if err != nil {
+			return err
+		}
+		rows = common.NewDataSetIterator(dataset)
+	} else {
+		if c.config.Excel.AllSheets {
+			return fmt.Errorf("all sheets cannot be converted in stream mode")
+		}
+		if c.format.IsArchive() {
+			return fmt.Errorf("archives hold a table per file and cannot be converted in stream mode")
+		}
+		if c.tablePerFile() {
+			return fmt.Errorf("a table per file cannot be converted in stream mode")
+		}
+
+		loader, err := c.newLoader()
+		if err != nil {
+			return err
+		}
+
+		rows, err = loaders.OpenStream(loader, c.path)
+		if err != nil {
+			return fmt.Errorf("failed to load data: %w", err)
+		}
+	}
+	defer rows.Close()
+
+	if c.options.TransformFile != "" {
+		transformEngine, err := transformers.NewTransformEngine(c.options.TransformFile)
+		if err != nil {
+			return fmt.Errorf("failed to initialize transform engine: %w", err)
+		}
+
+		rows, err = transformEngine.Stream(rows)
+		if err != nil {
+			return fmt.Errorf("failed to apply transformations: %w", err)
+		}
+	}
+
+	schema, err := c.loadSchema()
+	if err != nil {
+		return err
+	}
+
+	booleans, err := processing.ParseBooleanTokens(c.options.BooleanValues)
+	if err != nil {
+		return fmt.Errorf("invalid boolean values: %w", err)
+	}
+	c.startRejects()
+
+	sqlGenerator, err := processing.NewSQLGenerator(c.generatorOptions(c.options.Table, schema, &booleans))
+	if err != nil {
+		return fmt.Errorf("failed to initialize SQL generator: %w", err)
+	}
+
+	if err := sqlGenerator.GenerateStreamTo(rows, sink); err != nil {
+		return generateError(sink, err)
+	}
+	result.Tables = append(result.Tables, c.options.Table)
+	if err := sink.Commit(); err != nil {
+		return generateError(sink, err)
+	}
+	return nil
+}
+
+// resolveInput sets the file the input is read from. InputReader, read for
+// loaders.StdinPath, is copied to a temporary file that remove deletes. It
+// has no extension, so its format is found from its content unless given.
+func (c *conversion) resolveInput() (remove func(), err error) {
+	c.path = c.options.Input
+	if c.options.Fetch || c.options.Input != loaders.StdinPath {
+		return func() {}, nil
+	}
+
+	r := c.options.InputReader
+	if r == nil {
+		r = os.Stdin
+	}
+	path, remove, err := loaders.SpoolInput(r, c.config.Format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read standard input: %w", err)
+	}
+	c.path = path
+	return remove, nil
+}
+
+// detectInput finds the format of a single input file, reporting formats
+// found from its content, as they are guesses. Directories and glob patterns
+// are detected file by file as they are read.
+func (c *conversion) detectInput() error {
+	if c.options.Fetch || loaders.IsMultiFile(c.path) {
+		return nil
+	}
+
+	detection, err := loaders.DetectFormat(c.path, c.config.Format)
+	if err != nil {
+		if c.config.Format != "" {
+			return fmt.Errorf("invalid format: %w", err)
+		}
+		return fmt.Errorf("could not determine the format of %s from its extension or content, please specify it: %w", c.options.InputName(), err)
+	}
+
+	if detection.Method == loaders.DetectedByContent {
+		details := fmt.Sprintf("%.0f%% confidence", detection.Confidence*100)
+		if detection.Delimiter != "" {
+			details += fmt.Sprintf(", delimiter %q", detection.Delimiter)
+		}
+		fmt.Fprintf(c.messages, "Detected %s input from its content (%s)\n", detection.Format, details)
+	}
+	c.format = detection
+	return nil
+}
+
+// fetch retrieves the data of Source with the fetcher of SourceType
+func (c *conversion) fetch() (*common.DataSet, error) {
+	fetcher, err := fetchers.GetFetcher(c.options.SourceType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fetcher: %w", err)
+	}
+
+	fmt.Fprintf(c.messages, "Fetching data from %s using %s fetcher...\n", c.options.Source, c.options.SourceType)
+	dataset, err := fetcher.Fetch(c.options.Source, c.fetchOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch data: %w", err)
+	}
+	return dataset, nil
+}
+
+// fetchOptions returns the options for the fetcher: FetchOptions if set,
+// otherwise the defaults of the source type
+func (c *conversion) fetchOptions() map[string]interface{} {
+	if c.options.FetchOptions != nil {
+		return c.options.FetchOptions
+	}
+	options := make(map[string]interface{})
+	// Add default options for REST fetcher
+	if c.options.SourceType == "rest" {
+		options["method"] = "GET"
+		options["headers"] = map[string]string{
+			"Accept": "application/json",
+		}
+		options["record_path"] = c.config.JSON.RecordPath
+		options["lift"] = c.config.JSON.Lift
+	}
+	return options
+}
+
+// newLoader returns the loader of the input, reporting the lines a JSON
+// Lines loader skips
+func (c *conversion) newLoader() (loaders.Loader, error) {
+	loader, err := loaders.NewLoader(c.path, c.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loader: %w", err)
+	}
+
+	formatLoader := loader
+	if compressed, ok := loader.(*loaders.CompressedLoader); ok {
+		formatLoader = compressed.Loader
+	}
+	if jsonLines, ok := formatLoader.(*loaders.JSONLinesLoader); ok {
+		jsonLines.OnSkip = func(line int, err error) {
+			fmt.Fprintf(c.messages, "%sSkipped line %d of %s: %v\n", common.WarningPrefix, line, c.options.InputName(), err)
+		}
+	}
+	return loader, nil
+}
+
+// tablePerFile reports whether the input is several files converted into a
+// table each
+func (c *conversion) tablePerFile() bool {
+	return c.options.MultiFile == MultiFileTables && loaders.IsMultiFile(c.path)
+}
+
+// nameTables names tables read from one input after where they came from,
+// such as their sheet, keeping the names unique. With Table set, it is used
+// as a prefix.
+func (c *conversion) nameTables(tables []loaders.NamedDataSet) {
+	used := make(map[string]bool, len(tables))
+	for i := range tables {
+		name := processing.TableNameFrom(tables[i].Name)
+		if c.options.Table != "" {
+			name = c.options.Table + "_" + name
+		}
+
+		unique := name
+		for n := 2; used[unique]; n++ {
+			unique = fmt.Sprintf("%s_%d", name, n)
+		}
+		used[unique] = true
+		tables[i].Name = unique
+	}
+}
+
+// loadSchema reads the schema file, if one was given
+func (c *conversion) loadSchema() (*processing.SchemaOverride, error) {
+	if c.options.SchemaFile == "" {
+		return nil, nil
+	}
+	return processing.LoadSchemaOverride(c.options.SchemaFile)
+}
+
+// startRejects starts the reject report, if Rejects is set
+func (c *conversion) startRejects() {
+	if c.options.Rejects != nil {
+		c.rejects = processing.NewRejectReport(c.options.Rejects)
+	}
+}
+
+// generatorOptions returns the SQL generator options for one output table
+func (c *conversion) generatorOptions(table string, schema *processing.SchemaOverride, booleans *processing.BooleanTokens) processing.SQLGeneratorOptions {
+	// 0 disables the code width rule, where the generator takes it for the
+	// default
+	codeWidth := c.options.CodeWidth
+	if codeWidth == 0 {
+		codeWidth = -1
+	}
+
+	return processing.SQLGeneratorOptions{
+		Dialect:          c.options.Dialect,
+		TableName:        table,
+		CreateTable:      c.options.CreateTable,
+		BatchSize:        c.options.BatchSize,
+		NormalizeColumns: c.options.Normalize,
+		SampleSize:       c.options.SampleSize,
+		Mode:             c.options.Mode,
+		KeyColumns:       c.options.KeyColumns,
+		Schema:           schema,
+		SizedTypes:       c.options.SizedTypes,
+		TypeHeadroom:     c.options.TypeHeadroom,
+		Booleans:         booleans,
+		CodeWidth:        codeWidth,
+		Rejects:          c.rejects,
+		Messages:         c.messages,
+	}
+}
+
+// generateError wraps a failure while generating or loading SQL
+func generateError(sink sinks.Sink, err error) error {
+	if _, ok := sink.(*sinks.DBSink); ok {
+		return fmt.Errorf("failed to load data into target: %w", err)
+	}
+	return fmt.Errorf("failed to generate SQL: %w", err)
+}
@@ -0,0 +1,245 @@
+package pipeline
+
+import (
+	"brokolisql-go/pkg/common"
+	"brokolisql-go/pkg/dialects"
+	"brokolisql-go/pkg/loaders"
+	"brokolisql-go/pkg/sinks"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writeFile saves content as a file called name in a new directory and
+// returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// run converts with options and returns the result and the SQL written
+func run(t *testing.T, options Options) (*Result, string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	sink := sinks.NewWriterSink(&out)
+	defer sink.Close()
+
+	result, err := Run(options, sink)
+	return result, out.String(), err
+}
+
+func TestRun(t *testing.T) {
+	csvFile := writeFile(t, "users.csv", "id,name\n1,Ann\n2,Bob\n")
+	bigFile := writeFile(t, "orders.json", `[{"id": 12345678901234567}, {"id": 2}]`)
+	hugeFile := writeFile(t, "huge.json", `[{"id": 1e400}]`)
+	dir := filepath.Dir(writeFile(t, "a.csv", "id\n1\n"))
+	if err := os.WriteFile(filepath.Join(dir, "b.csv"), []byte("id\n2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		options    func(*Options)
+		wantTables []string
+		wantFormat string
+		wantSQL    []string
+		wantErr    string
+	}{
+		{
+			name:       "File",
+			options:    func(o *Options) { o.Input, o.Table, o.CreateTable = csvFile, "users", true },
+			wantTables: []string{"users"},
+			wantFormat: "csv",
+			wantSQL:    []string{`CREATE TABLE "users"`, `(1, 'Ann')`},
+		},
+		{
+			name: "Reader",
+			options: func(o *Options) {
+				o.Input, o.Table, o.Stream = loaders.StdinPath, "events", true
+				o.InputReader = strings.NewReader("{\"id\": 1}\n{\"id\": 2}\n")
+			},
+			wantTables: []string{"events"},
+			wantFormat: "jsonl",
+			wantSQL:    []string{`INSERT INTO "events"`, "(2)"},
+		},
+		{
+			name:       "Large JSON integers",
+			options:    func(o *Options) { o.Input, o.Table, o.CreateTable = bigFile, "orders", true },
+			wantTables: []string{"orders"},
+			wantFormat: "json",
+			wantSQL:    []string{`"ID" BIGINT`, "(12345678901234567)"},
+		},
+		{
+			name:    "JSON number out of range",
+			options: func(o *Options) { o.Input, o.Table = hugeFile, "orders" },
+			wantErr: "number 1e400 is out of range",
+		},
+		{
+			name:       "Table per file",
+			options:    func(o *Options) { o.Input, o.MultiFile = dir, MultiFileTables },
+			wantTables: []string{"a", "b"},
+			wantSQL:    []string{`INSERT INTO "a"`, `INSERT INTO "b"`},
+		},
+		{
+			name:    "No table",
+			options: func(o *Options) { o.Input = csvFile },
+			wantErr: "a table name is required",
+		},
+		{
+			name:    "Key without upsert mode",
+			options: func(o *Options) { o.Input, o.Table, o.KeyColumns = csvFile, "users", []string{"id"} },
+			wantErr: "key columns id are only used in upsert mode, not insert mode",
+		},
+		{
+			name:    "Unknown dialect",
+			options: func(o *Options) { o.Input, o.Table, o.Dialect = csvFile, "users", "hive" },
+			wantErr: "unsupported SQL dialect: hive",
+		},
+		{
+			name:    "No source",
+			options: func(o *Options) { o.Fetch, o.Table = true, "users" },
+			wantErr: "source URL or connection string is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultOptions()
+			tt.options(&options)
+			result, sql, err := run(t, options)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(result.Tables, tt.wantTables) {
+				t.Errorf("Tables = %q, want %q", result.Tables, tt.wantTables)
+			}
+			if result.Format.Format != tt.wantFormat {
+				t.Errorf("Format = %q, want %q", result.Format.Format, tt.wantFormat)
+			}
+			for _, want := range tt.wantSQL {
+				if !strings.Contains(sql, want) {
+					t.Errorf("SQL does not contain %q:\n%s", want, sql)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_Rejects(t *testing.T) {
+	schema := writeFile(t, "schema.json", `{"columns": [{"name": "price", "type": "float"}]}`)
+	options := DefaultOptions()
+	options.Input = writeFile(t, "prices.csv", "id,price\n1,2.5\n2,x\n")
+	options.Table = "prices"
+	options.SchemaFile = schema
+	var rejects bytes.Buffer
+	options.Rejects = &rejects
+
+	result, sql, err := run(t, options)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Rejected != 1 {
+		t.Errorf("Rejected = %d, want 1", result.Rejected)
+	}
+	if !strings.Contains(rejects.String(), `prices,2,PRICE,x`) {
+		t.Errorf("Reject report = %q, want row 2", rejects.String())
+	}
+	if strings.Contains(sql, "'x'") {
+		t.Errorf("SQL holds the rejected row:\n%s", sql)
+	}
+}
+
+func TestRun_SkippedLineWarnings(t *testing.T) {
+	options := DefaultOptions()
+	options.Input = writeFile(t, "events.jsonl", "{\"id\": 1}\nnot json\n{\"id\": 3}\n")
+	options.Table = "events"
+	options.Loader = &loaders.Config{JSONLines: loaders.JSONLinesOptions{SkipInvalid: true}}
+	var messages bytes.Buffer
+	options.Messages = &messages
+
+	if _, _, err := run(t, options); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// Skipped lines are input left out, so they are reported as warnings
+	want := common.WarningPrefix + "Skipped line 2 of "
+	if !strings.Contains(messages.String(), "\n"+want) && !strings.HasPrefix(messages.String(), want) {
+		t.Errorf("Messages = %q, want a line starting with %q", messages.String(), want)
+	}
+}
+
+// lowerDialect is a dialect registered by a test, writing INSERT statements
+// in lower case to tell it from the generic one
+type lowerDialect struct {
+	dialects.GenericDialect
+}
+
+func (d *lowerDialect) Name() string {
+	return "lowercase"
+}
+
+func (d *lowerDialect) InsertInto(tableName string, columns []string, values [][]interface{}, batchSize int) string {
+	return strings.ToLower(d.GenericDialect.InsertInto(tableName, columns, values, batchSize))
+}
+
+// pairsLoader reads lines of name:value pairs, a format registered by a test
+type pairsLoader struct{}
+
+func (l *pairsLoader) Load(filePath string) (*common.DataSet, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	dataset := &common.DataSet{Columns: []string{"name", "value"}}
+	for _, line := range strings.Fields(string(data)) {
+		name, value, _ := strings.Cut(line, ":")
+		dataset.Rows = append(dataset.Rows, common.DataRow{"name": name, "value": value})
+	}
+	return dataset, nil
+}
+
+// registerPlugins registers the test plugins once, as registries reject a
+// second registration of a name
+var registerPlugins sync.Once
+
+func TestRun_Plugins(t *testing.T) {
+	registerPlugins.Do(func() {
+		loaders.Register(loaders.Format{
+			Name:       "pairs",
+			Extensions: []string{".pairs"},
+			New:        func(config *loaders.Config) (loaders.Loader, error) { return &pairsLoader{}, nil },
+		})
+		dialects.Register(dialects.Info{Name: "lowercase", New: func() dialects.Dialect { return &lowerDialect{} }})
+	})
+
+	options := DefaultOptions()
+	options.Input = writeFile(t, "settings.pairs", "host:db port:5432\n")
+	options.Table = "settings"
+	options.Dialect = "lowercase"
+
+	result, sql, err := run(t, options)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Format.Format != "pairs" {
+		t.Errorf("Format = %q, want pairs", result.Format.Format)
+	}
+	if !strings.Contains(sql, `insert into "settings"`) || !strings.Contains(sql, `('port', '5432')`) {
+		t.Errorf("SQL was not written by the registered format and dialect:\n%s", sql)
+	}
+}
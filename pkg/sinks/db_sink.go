@@ -0,0 +1,190 @@
+package sinks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// DBOptions configures a database sink
+type DBOptions struct {
+	// CommitEvery commits the transaction once at least this many data rows
+	// have been executed in it. 0 loads everything in a single transaction.
+	CommitEvery int
+}
+
+// LoadError reports the statement that failed while loading into a database.
+// The transaction it ran in has been rolled back.
+type LoadError struct {
+	Statement int // 1-based index of the failed statement
+	Batch     int // 1-based index of the failed data batch, 0 for DDL
+	FirstRow  int // 1-based index of the first row in the failed batch
+	LastRow   int // 1-based index of the last row in the failed batch
+	Committed int // Rows committed by earlier transactions
+	Err       error
+}
+
+func (e *LoadError) Error() string {
+	var msg string
+	switch {
+	case e.Batch > 0 && e.FirstRow == e.LastRow:
+		msg = fmt.Sprintf("batch %d (row %d) failed: %v", e.Batch, e.FirstRow, e.Err)
+	case e.Batch > 0:
+		msg = fmt.Sprintf("batch %d (rows %d-%d) failed: %v", e.Batch, e.FirstRow, e.LastRow, e.Err)
+	default:
+		msg = fmt.Sprintf("statement %d failed: %v", e.Statement, e.Err)
+	}
+
+	if e.Committed > 0 {
+		msg += fmt.Sprintf(" (%d rows committed before the failure)", e.Committed)
+	}
+	return msg
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// DBSink executes statements against a database through database/sql.
+// Statements run inside a transaction that is committed every
+// DBOptions.CommitEvery rows and by Commit; a failed statement rolls the
+// current transaction back.
+type DBSink struct {
+	db      *sql.DB
+	tx      *sql.Tx
+	options DBOptions
+
+	statements int
+	batches    int
+	rows       int
+	committed  int
+	failed     error
+}
+
+// Open connects to the database identified by dsn using the driver registered
+// for its scheme
+func Open(dsn string, options DBOptions) (*DBSink, error) {
+	driver, err := LookupDriver(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	dataSourceName := dsn
+	if driver.DataSourceName != nil {
+		dataSourceName = driver.DataSourceName(dsn)
+	}
+
+	db, err := sql.Open(driver.DriverName, dataSourceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open target database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to target database: %w", err)
+	}
+
+	return NewDBSink(db, options), nil
+}
+
+// NewDBSink returns a sink that loads statements into an open database
+func NewDBSink(db *sql.DB, options DBOptions) *DBSink {
+	return &DBSink{
+		db:      db,
+		options: options,
+	}
+}
+
+func (s *DBSink) WriteStatement(statement string, rows int) error {
+	if s.failed != nil {
+		return s.failed
+	}
+
+	// Separators between generated statements carry nothing to execute
+	if strings.TrimSpace(statement) == "" {
+		return nil
+	}
+
+	s.statements++
+	if rows > 0 {
+		s.batches++
+	}
+
+	if s.tx == nil {
+		tx, err := s.db.BeginTx(context.Background(), nil)
+		if err != nil {
+			return s.fail(rows, fmt.Errorf("failed to begin transaction: %w", err))
+		}
+		s.tx = tx
+	}
+
+	if _, err := s.tx.Exec(statement); err != nil {
+		return s.fail(rows, err)
+	}
+	s.rows += rows
+
+	if s.options.CommitEvery > 0 && s.rows-s.committed >= s.options.CommitEvery {
+		return s.Commit()
+	}
+	return nil
+}
+
+// fail rolls back the open transaction and records the failed statement so
+// that later writes and commits are refused
+func (s *DBSink) fail(rows int, err error) error {
+	if s.tx != nil {
+		s.tx.Rollback()
+		s.tx = nil
+	}
+
+	loadErr := &LoadError{
+		Statement: s.statements,
+		Committed: s.committed,
+		Err:       err,
+	}
+	if rows > 0 {
+		loadErr.Batch = s.batches
+		loadErr.FirstRow = s.rows + 1
+		loadErr.LastRow = s.rows + rows
+	}
+
+	s.failed = loadErr
+	return loadErr
+}
+
+// Commit commits the open transaction, if any
+func (s *DBSink) Commit() error {
+	if s.failed != nil {
+		return s.failed
+	}
+	if s.tx == nil {
+		return nil
+	}
+
+	err := s.tx.Commit()
+	s.tx = nil
+	if err != nil {
+		return s.fail(0, fmt.Errorf("failed to commit transaction: %w", err))
+	}
+
+	s.committed = s.rows
+	return nil
+}
+
+// Close rolls back any uncommitted work and closes the database connection
+func (s *DBSink) Close() error {
+	if s.tx != nil {
+		s.tx.Rollback()
+		s.tx = nil
+	}
+
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("failed to close target database: %w", err)
+	}
+	return nil
+}
+
+// RowCount returns the number of rows committed so far
+func (s *DBSink) RowCount() int {
+	return s.committed
+}
@@ -0,0 +1,252 @@
+package sinks
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func openTestTarget(t *testing.T, options DBOptions) (*DBSink, string) {
+	t.Helper()
+
+	dsn := "sqlite://" + filepath.Join(t.TempDir(), "target.db")
+	sink, err := Open(dsn, options)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	return sink, dsn
+}
+
+func countRows(t *testing.T, dsn string) int {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", trimScheme(dsn))
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM "users"`).Scan(&count); err != nil {
+		return -1
+	}
+	return count
+}
+
+func TestDBSink(t *testing.T) {
+	createTable := `CREATE TABLE "users" ("id" INTEGER PRIMARY KEY, "name" TEXT);`
+
+	tests := []struct {
+		name          string
+		commitEvery   int
+		batches       []string
+		wantErr       bool
+		wantBatch     int
+		wantFirstRow  int
+		wantLastRow   int
+		wantCommitted int
+		wantRows      int // -1 when the table must not exist
+	}{
+		{
+			name:        "All batches loaded",
+			commitEvery: 0,
+			batches: []string{
+				`INSERT INTO "users" ("id", "name") VALUES (1, 'John'), (2, 'Jane');`,
+				`INSERT INTO "users" ("id", "name") VALUES (3, 'Bob'), (4, 'Alice');`,
+			},
+			wantRows: 4,
+		},
+		{
+			name:        "Failure rolls back the single transaction",
+			commitEvery: 0,
+			batches: []string{
+				`INSERT INTO "users" ("id", "name") VALUES (1, 'John'), (2, 'Jane');`,
+				`INSERT INTO "users" ("id", "name") VALUES (3, 'Bob'), (1, 'Alice');`,
+			},
+			wantErr:      true,
+			wantBatch:    2,
+			wantFirstRow: 3,
+			wantLastRow:  4,
+			wantRows:     -1,
+		},
+		{
+			name:        "Failure keeps earlier commits",
+			commitEvery: 2,
+			batches: []string{
+				`INSERT INTO "users" ("id", "name") VALUES (1, 'John'), (2, 'Jane');`,
+				`INSERT INTO "users" ("id", "name") VALUES (3, 'Bob'), (1, 'Alice');`,
+			},
+			wantErr:       true,
+			wantBatch:     2,
+			wantFirstRow:  3,
+			wantLastRow:   4,
+			wantCommitted: 2,
+			wantRows:      2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink, dsn := openTestTarget(t, DBOptions{CommitEvery: tt.commitEvery})
+
+			err := sink.WriteStatement(createTable+"\n", 0)
+			for _, batch := range tt.batches {
+				if err != nil {
+					break
+				}
+				err = sink.WriteStatement(batch+"\n\n", 2)
+			}
+			if err == nil {
+				err = sink.Commit()
+			}
+			if closeErr := sink.Close(); closeErr != nil {
+				t.Fatalf("Close() error = %v", closeErr)
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("load error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				var loadErr *LoadError
+				if !errors.As(err, &loadErr) {
+					t.Fatalf("load error = %T, want *LoadError", err)
+				}
+				if loadErr.Batch != tt.wantBatch || loadErr.FirstRow != tt.wantFirstRow || loadErr.LastRow != tt.wantLastRow {
+					t.Errorf("LoadError batch %d rows %d-%d, want batch %d rows %d-%d",
+						loadErr.Batch, loadErr.FirstRow, loadErr.LastRow, tt.wantBatch, tt.wantFirstRow, tt.wantLastRow)
+				}
+				if loadErr.Committed != tt.wantCommitted {
+					t.Errorf("LoadError.Committed = %d, want %d", loadErr.Committed, tt.wantCommitted)
+				}
+			}
+
+			if got := countRows(t, dsn); got != tt.wantRows {
+				t.Errorf("target has %d rows, want %d", got, tt.wantRows)
+			}
+			if want := max(tt.wantRows, 0); sink.RowCount() != want {
+				t.Errorf("RowCount() = %d, want %d", sink.RowCount(), want)
+			}
+		})
+	}
+}
+
+func TestDBSink_CloseWithoutCommit(t *testing.T) {
+	sink, dsn := openTestTarget(t, DBOptions{})
+
+	if err := sink.WriteStatement(`CREATE TABLE "users" ("id" INTEGER);`, 0); err != nil {
+		t.Fatalf("WriteStatement() error = %v", err)
+	}
+	if err := sink.WriteStatement(`INSERT INTO "users" ("id") VALUES (1);`, 1); err != nil {
+		t.Fatalf("WriteStatement() error = %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := countRows(t, dsn); got != -1 {
+		t.Errorf("uncommitted table has %d rows, want it rolled back", got)
+	}
+}
+
+func TestLookupDriver(t *testing.T) {
+	tests := []struct {
+		dsn         string
+		wantDialect string
+		wantErr     bool
+	}{
+		{dsn: "sqlite://data.db", wantDialect: "sqlite"},
+		{dsn: "SQLite:data.db", wantDialect: "sqlite"},
+		{dsn: "unknown://host/db", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			driver, err := LookupDriver(tt.dsn)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LookupDriver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnsupportedTarget) {
+					t.Errorf("LookupDriver() error = %v, want ErrUnsupportedTarget", err)
+				}
+				return
+			}
+			if driver.Dialect != tt.wantDialect {
+				t.Errorf("LookupDriver() dialect = %s, want %s", driver.Dialect, tt.wantDialect)
+			}
+		})
+	}
+}
+
+func unregisterDriver(scheme string) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	delete(drivers, scheme)
+}
+
+func TestRegisterDriver(t *testing.T) {
+	RegisterDriver("Warehouse", Driver{DriverName: "sqlite", Dialect: "generic"})
+	defer unregisterDriver("warehouse")
+
+	driver, err := LookupDriver("warehouse://host/db")
+	if err != nil || driver.Dialect != "generic" {
+		t.Errorf("LookupDriver() = %+v, %v, want the registered driver", driver, err)
+	}
+	if got := strings.Join(Drivers(), " "); got != "sqlite warehouse" {
+		t.Errorf("Drivers() = %q, want %q", got, "sqlite warehouse")
+	}
+
+	tests := []struct {
+		name   string
+		scheme string
+		driver Driver
+	}{
+		{name: "Duplicate scheme", scheme: "SQLITE", driver: Driver{DriverName: "sqlite3", Dialect: "sqlite"}},
+		{name: "No scheme", driver: Driver{DriverName: "sqlite"}},
+		{name: "No driver name", scheme: "lake"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterDriver(%q) did not panic", tt.scheme)
+				}
+			}()
+			RegisterDriver(tt.scheme, tt.driver)
+		})
+	}
+
+	// The driver whose scheme was claimed is unaffected
+	if driver, err := LookupDriver("sqlite://data.db"); err != nil || driver.DriverName != "sqlite" {
+		t.Errorf("LookupDriver() = %+v, %v, want the built-in SQLite driver", driver, err)
+	}
+}
+
+func TestRegisterDriver_Concurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		scheme := "concurrent" + string(rune('a'+i))
+		defer unregisterDriver(scheme)
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			RegisterDriver(scheme, Driver{DriverName: "sqlite"})
+		}()
+		go func() {
+			defer wg.Done()
+			Drivers()
+			LookupDriver(scheme + "://db")
+		}()
+	}
+	wg.Wait()
+
+	if got := len(Drivers()); got != 11 {
+		t.Errorf("len(Drivers()) = %d, want 11", got)
+	}
+}
@@ -0,0 +1,106 @@
+package sinks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// StdoutPath is the output path that stands for standard output
+const StdoutPath = "-"
+
+// FileSink writes statements to a SQL script
+type FileSink struct {
+	path   string   // File the script replaces once committed
+	file   *os.File // Temporary file, nil when writing to a stream the sink does not own
+	writer *bufio.Writer
+	done   bool // Set once the temporary file has replaced path or been removed
+}
+
+// NewFileSink returns a sink that writes statements to a temporary file next
+// to path, which replaces the file at path when the sink is committed. A
+// conversion that fails before that leaves an existing file as it was. The
+// script keeps the permissions of the file it replaces.
+func NewFileSink(path string) (*FileSink, error) {
+	// A temporary file in the same directory is renamed without moving data
+	// across file systems
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := file.Chmod(mode); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	return &FileSink{
+		path:   path,
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}, nil
+}
+
+// NewWriterSink returns a sink that writes statements to w, such as standard
+// output. Closing the sink leaves w open.
+func NewWriterSink(w io.Writer) *FileSink {
+	return &FileSink{writer: bufio.NewWriter(w)}
+}
+
+func (s *FileSink) WriteStatement(sql string, rows int) error {
+	if s.done {
+		return fmt.Errorf("failed to write output file: the sink is committed or closed")
+	}
+	if _, err := io.WriteString(s.writer, sql); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
+}
+
+// Commit flushes buffered statements. A sink created by NewFileSink then
+// renames its temporary file to the output path, after which nothing more
+// can be written to it.
+func (s *FileSink) Commit() error {
+	if s.done {
+		return nil
+	}
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if s.file == nil {
+		return nil
+	}
+
+	s.done = true
+	if err := s.file.Close(); err != nil {
+		os.Remove(s.file.Name())
+		return fmt.Errorf("failed to close output file: %w", err)
+	}
+	if err := os.Rename(s.file.Name(), s.path); err != nil {
+		os.Remove(s.file.Name())
+		return fmt.Errorf("failed to save output file: %w", err)
+	}
+	return nil
+}
+
+// Close discards the temporary file of a sink that was not committed,
+// leaving the output path untouched
+func (s *FileSink) Close() error {
+	if s.file == nil || s.done {
+		return nil
+	}
+	s.done = true
+
+	s.file.Close()
+	if err := os.Remove(s.file.Name()); err != nil {
+		return fmt.Errorf("failed to remove temporary output file: %w", err)
+	}
+	return nil
+}
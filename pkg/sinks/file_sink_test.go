@@ -0,0 +1,82 @@
+package sinks
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readOutput returns the files of dir and the content of path
+func readOutput(t *testing.T, dir, path string) ([]os.DirEntry, string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	return entries, string(content)
+}
+
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "seed.sql")
+	if err := os.WriteFile(path, []byte("-- old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	defer sink.Close()
+
+	if err := sink.WriteStatement("INSERT INTO \"users\" VALUES (1);\n", 1); err != nil {
+		t.Fatalf("WriteStatement() error = %v", err)
+	}
+	if _, content := readOutput(t, dir, path); content != "-- old\n" {
+		t.Errorf("output before Commit() = %q, want the old file", content)
+	}
+
+	if err := sink.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	entries, content := readOutput(t, dir, path)
+	if content != "INSERT INTO \"users\" VALUES (1);\n" {
+		t.Errorf("output after Commit() = %q", content)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory has %d files after Commit(), want the output only", len(entries))
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Errorf("Stat() error = %v", err)
+	} else if info.Mode().Perm() != 0o600 {
+		t.Errorf("output mode = %v, want the replaced file's 0600", info.Mode().Perm())
+	}
+	if err := sink.WriteStatement("SELECT 1;\n", 0); err == nil {
+		t.Errorf("WriteStatement() after Commit() error = nil")
+	}
+}
+
+func TestFileSink_CloseWithoutCommit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "seed.sql")
+
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	if err := sink.WriteStatement("INSERT INTO \"users\" VALUES (1);\n", 1); err != nil {
+		t.Fatalf("WriteStatement() error = %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if entries, _ := readOutput(t, dir, path); len(entries) != 0 {
+		t.Errorf("directory has %d files after Close(), want none", len(entries))
+	}
+}
@@ -0,0 +1,110 @@
+package sinks
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnsupportedTarget = errors.New("unsupported target")
+)
+
+// Sink is a destination for generated SQL. Statements arrive one at a time
+// together with the number of data rows they carry (0 for DDL).
+type Sink interface {
+	WriteStatement(sql string, rows int) error
+	// Commit makes everything written so far durable
+	Commit() error
+	// Close releases the sink. Work that has not been committed is discarded
+	// where the destination supports it.
+	Close() error
+}
+
+// Driver describes how to open a database/sql connection for a target scheme
+type Driver struct {
+	// DriverName is the name the database/sql driver was registered under
+	DriverName string
+	// Dialect is the SQL dialect used to render statements for this database
+	Dialect string
+	// DataSourceName converts a target DSN into the driver's data source
+	// name. When nil the DSN is passed through unchanged.
+	DataSourceName func(dsn string) string
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]Driver{} // Drivers by lower-cased scheme
+)
+
+// RegisterDriver makes a database driver available for targets whose DSN
+// starts with scheme, e.g. "postgres" for "postgres://user@host/db", which is
+// not case sensitive. The database/sql driver itself must be imported by the
+// caller. Like the other registries of BrokoliSQL, RegisterDriver panics if
+// the scheme or DriverName is empty, and if the scheme is already taken, so a
+// driver cannot be registered twice or replace another.
+func RegisterDriver(scheme string, driver Driver) {
+	scheme = strings.ToLower(scheme)
+	if scheme == "" || driver.DriverName == "" {
+		panic(fmt.Sprintf("sinks: RegisterDriver of %q needs a scheme and a driver name", scheme))
+	}
+
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if _, ok := drivers[scheme]; ok {
+		panic(fmt.Sprintf("sinks: RegisterDriver called twice for scheme %q", scheme))
+	}
+	drivers[scheme] = driver
+}
+
+// Drivers returns the registered target schemes in alphabetical order
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	schemes := make([]string, 0, len(drivers))
+	for scheme := range drivers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// LookupDriver returns the driver registered for the scheme of dsn
+func LookupDriver(dsn string) (Driver, error) {
+	scheme := targetScheme(dsn)
+
+	driversMu.RLock()
+	driver, ok := drivers[scheme]
+	driversMu.RUnlock()
+
+	if !ok {
+		return Driver{}, fmt.Errorf("%w: %q (available: %s)", ErrUnsupportedTarget, scheme, strings.Join(Drivers(), ", "))
+	}
+	return driver, nil
+}
+
+// targetScheme returns the lower-cased part of dsn before "://" or ":"
+func targetScheme(dsn string) string {
+	if i := strings.Index(dsn, "://"); i >= 0 {
+		return strings.ToLower(dsn[:i])
+	}
+	if i := strings.Index(dsn, ":"); i >= 0 {
+		return strings.ToLower(dsn[:i])
+	}
+	return strings.ToLower(dsn)
+}
+
+// trimScheme strips the scheme and separator from dsn
+func trimScheme(dsn string) string {
+	if i := strings.Index(dsn, "://"); i >= 0 {
+		return dsn[i+3:]
+	}
+	if i := strings.Index(dsn, ":"); i >= 0 {
+		return dsn[i+1:]
+	}
+	return dsn
+}
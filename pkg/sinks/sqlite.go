@@ -0,0 +1,16 @@
+package sinks
+
+import (
+	// Pure-Go SQLite driver, registered with database/sql as "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// SQLite targets need no external database server, e.g.
+// sqlite://data.db or sqlite:///var/lib/data.db
+func init() {
+	RegisterDriver("sqlite", Driver{
+		DriverName:     "sqlite",
+		Dialect:        "sqlite",
+		DataSourceName: trimScheme,
+	})
+}